@@ -0,0 +1,16 @@
+//go:build !helmenu_go && !helsubmenu_go
+
+package main
+
+import "github.com/micro-editor/tcell/v2"
+
+// PT-BR: Sem as tags helmenu_go ou helsubmenu_go, estes stubs evitam erros de linkagem.
+// EN: Without the helmenu_go or helsubmenu_go tags, these stubs avoid link errors.
+
+var helMenuActive bool
+
+func helMenuDraw() {}
+
+func helMenuOpen() {}
+
+func helMenuHandleKey(_ *tcell.EventKey) bool { return false }
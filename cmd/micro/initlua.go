@@ -9,6 +9,7 @@ import (
 
 	"github.com/helmutkemper/micro/v2/internal/action"
 	"github.com/helmutkemper/micro/v2/internal/buffer"
+	"github.com/helmutkemper/micro/v2/internal/clipboard"
 	"github.com/helmutkemper/micro/v2/internal/config"
 	"github.com/helmutkemper/micro/v2/internal/display"
 	ulua "github.com/helmutkemper/micro/v2/internal/lua"
@@ -35,6 +36,8 @@ func LuaImport(pkg string) *lua.LTable {
 		return luaImportMicroConfig()
 	case "micro/util":
 		return luaImportMicroUtil()
+	case "micro/clipboard":
+		return luaImportMicroClipboard()
 	default:
 		return ulua.Import(pkg)
 	}
@@ -48,6 +51,7 @@ func luaImportMicro() *lua.LTable {
 	ulua.L.SetField(pkg, "InfoBar", luar.New(ulua.L, action.GetInfoBar))
 	ulua.L.SetField(pkg, "Log", luar.New(ulua.L, log.Println))
 	ulua.L.SetField(pkg, "SetStatusInfoFn", luar.New(ulua.L, display.SetStatusInfoFnLua))
+	ulua.L.SetField(pkg, "SetStatusSegment", luar.New(ulua.L, display.SetStatusSegmentLua))
 	ulua.L.SetField(pkg, "CurPane", luar.New(ulua.L, func() *action.BufPane {
 		return action.MainTab().CurPane()
 	}))
@@ -85,6 +89,9 @@ func luaImportMicroConfig() *lua.LTable {
 	ulua.L.SetField(pkg, "RTSyntax", luar.New(ulua.L, config.RTSyntax))
 	ulua.L.SetField(pkg, "RTHelp", luar.New(ulua.L, config.RTHelp))
 	ulua.L.SetField(pkg, "RTPlugin", luar.New(ulua.L, config.RTPlugin))
+	ulua.L.SetField(pkg, "RTSnippet", luar.New(ulua.L, config.RTSnippet))
+	ulua.L.SetField(pkg, "RTDictionary", luar.New(ulua.L, config.RTDictionary))
+	ulua.L.SetField(pkg, "RTAffix", luar.New(ulua.L, config.RTAffix))
 	ulua.L.SetField(pkg, "RegisterCommonOption", luar.New(ulua.L, config.RegisterCommonOptionPlug))
 	ulua.L.SetField(pkg, "RegisterGlobalOption", luar.New(ulua.L, config.RegisterGlobalOptionPlug))
 	ulua.L.SetField(pkg, "GetGlobalOption", luar.New(ulua.L, config.GetGlobalOption))
@@ -145,6 +152,16 @@ func luaImportMicroBuffer() *lua.LTable {
 	return pkg
 }
 
+func luaImportMicroClipboard() *lua.LTable {
+	pkg := ulua.L.NewTable()
+
+	ulua.L.SetField(pkg, "Read", luar.New(ulua.L, clipboard.ReadNamed))
+	ulua.L.SetField(pkg, "Write", luar.New(ulua.L, clipboard.WriteNamed))
+	ulua.L.SetField(pkg, "Registers", luar.New(ulua.L, clipboard.RegisterNames))
+
+	return pkg
+}
+
 func luaImportMicroUtil() *lua.LTable {
 	pkg := ulua.L.NewTable()
 
@@ -22,6 +22,7 @@ import (
 	"github.com/helmutkemper/micro/v2/internal/buffer"
 	"github.com/helmutkemper/micro/v2/internal/clipboard"
 	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/remote"
 	"github.com/helmutkemper/micro/v2/internal/screen"
 	"github.com/helmutkemper/micro/v2/internal/shell"
 	"github.com/helmutkemper/micro/v2/internal/util"
@@ -45,6 +46,13 @@ var (
 	flagClean     = flag.Bool("clean", false, "Clean configuration directory")
 	optionFlags   map[string]*string
 
+	flagRemote        = flag.Bool("remote", false, "Open the files in the running micro")
+	flagRemoteWait    = flag.Bool("remote-wait", false, "Open the files in the running micro and wait until they are closed")
+	flagRemoteSplit   = flag.String("remote-split", "tab", "Open the remote files in a new tab, hsplit or vsplit")
+	flagRemoteCommand = flag.String("remote-command", "", "Run a command in the running micro")
+	flagRecord        = flag.String("record", "", "Record the events of the session in a file")
+	flagReplay        = flag.String("replay", "", "Replay a recorded session and check its final state")
+
 	sighup    chan os.Signal
 	timerChan chan func()
 )
@@ -66,12 +74,28 @@ func InitFlags() {
 		fmt.Println("    \tShow all options help and exit")
 		fmt.Println("-debug")
 		fmt.Println("    \tEnable debug mode (enables logging to ./log.txt)")
+		fmt.Println("-record file")
+		fmt.Println("    \tRecord the events of the session, the terminal size and the opened files in a file")
+		fmt.Println("    \tThe encrypted files, the keys typed in them and the passphrases are not recorded")
+		fmt.Println("-replay file")
+		fmt.Println("    \tReplay a recorded session in a simulated screen, and check that it ends with")
+		fmt.Println("    \tthe recorded buffers and screen")
 		fmt.Println("-profile")
 		fmt.Println("    \tEnable CPU profiling (writes profile info to ./micro.prof")
 		fmt.Println("    \tso it can be analyzed later with \"go tool pprof micro.prof\")")
 		fmt.Println("-version")
 		fmt.Println("    \tShow the version number and information and exit")
 
+		fmt.Print("\nMicro can open files in the micro already running for the user, when its `remotecontrol` option is on.\n")
+		fmt.Println("-remote [FILE[:LINE[:COL]]]...")
+		fmt.Println("    \tOpen the files in the running micro, or in a new micro if none is running")
+		fmt.Println("-remote-wait [FILE[:LINE[:COL]]]...")
+		fmt.Println("    \tLike -remote, and wait until the files are closed, for use as $EDITOR")
+		fmt.Println("-remote-split tab|hsplit|vsplit")
+		fmt.Println("    \tOpen the remote files in new tabs (the default) or in splits")
+		fmt.Println("-remote-command command")
+		fmt.Println("    \tRun a command in the running micro, after opening the files")
+
 		fmt.Print("\nMicro's plugins can be managed at the command line with the following commands.\n")
 		fmt.Println("-plugin install [PLUGIN]...")
 		fmt.Println("    \tInstall plugin(s)")
@@ -248,13 +272,15 @@ func checkBackup(name string) error {
 }
 
 func exit(rc int) {
+	remote.Close()
+	stopRecording()
 	for _, b := range buffer.OpenBuffers {
 		if !b.Modified() {
 			b.Fini()
 		}
 	}
 	if screen.Screen != nil {
-		screen.Screen.Fini()
+		screen.Fini()
 	}
 	os.Exit(rc)
 }
@@ -282,6 +308,10 @@ func main() {
 
 	InitLog()
 
+	if *flagReplay != "" {
+		DoReplay(*flagReplay)
+	}
+
 	if err := config.InitConfigDir(*flagConfigDir); err != nil {
 		screen.TermMessage(err)
 	}
@@ -319,6 +349,7 @@ func main() {
 	}
 
 	DoPluginFlags()
+	DoRemoteFlags()
 
 	if err := screen.Init(); err != nil {
 		fmt.Println(err)
@@ -331,7 +362,7 @@ func main() {
 	defer func() {
 		if err := recover(); err != nil {
 			if screen.Screen != nil {
-				screen.Screen.Fini()
+				screen.Fini()
 			}
 			if e, ok := err.(*lua.ApiError); ok {
 				fmt.Println("Lua API error:", e)
@@ -365,14 +396,24 @@ func main() {
 
 	action.InitGlobals()
 	buffer.SetMessager(action.InfoBar)
+	action.InitClipboardHistory()
 	args := flag.Args()
 	b := LoadInput(args)
 	if len(b) == 0 {
-		screen.Screen.Fini()
+		screen.Fini()
 		runtime.Goexit()
 	}
+	if *flagRecord != "" {
+		if err := startRecording(*flagRecord, b); err != nil {
+			screen.TermMessage(err)
+		}
+	}
 	action.InitTabs(b)
-
+	if config.GetGlobalOption("remotecontrol").(bool) {
+		if err := remote.Listen(remote.SocketPath()); err != nil {
+			log.Println(err)
+		}
+	}
 	if err := config.RunPluginFn("init"); err != nil {
 		screen.TermMessage(err)
 	}
@@ -382,6 +423,10 @@ func main() {
 	if err := config.InitColorscheme(); err != nil {
 		screen.TermMessage(err)
 	}
+	if *flagRemoteCommand != "" {
+		// no micro was running the command
+		action.MainTab().CurPane().HandleCommand(*flagRemoteCommand)
+	}
 	if clipErr != nil {
 		log.Println(clipErr, " or change 'clipboard' option")
 	}
@@ -400,12 +445,23 @@ func main() {
 
 	timerChan = make(chan func())
 
+	buffer.ScanOrphanedBackups(func(backups []buffer.Backup) {
+		if n := len(backups); n > 0 {
+			timerChan <- func() {
+				action.InfoBar.Message(fmt.Sprintf("Found %d orphaned backups, run 'recover' to browse them", n))
+			}
+		}
+	})
+
 	// loop de polling de eventos da tcell
 	go func() {
 		for {
 			screen.Lock()
 			e := screen.Screen.PollEvent()
 			screen.Unlock()
+			if e != nil {
+				e = screen.FilterEvent(e)
+			}
 			if e != nil {
 				screen.Events <- e
 			}
@@ -420,7 +476,12 @@ func main() {
 	// espera primeiro resize
 	select {
 	case event := <-screen.Events:
-		action.Tabs.HandleEvent(event)
+		recordEvent(event)
+		if e, ok := event.(*screen.EventBackground); ok {
+			action.SetTermBackground(e.Dark)
+		} else {
+			action.Tabs.HandleEvent(event)
+		}
 	case <-time.After(10 * time.Millisecond):
 	}
 
@@ -448,6 +509,7 @@ func DoEvent() {
 	helFormDraw()
 
 	screen.Screen.Show()
+	recordFrame()
 
 	// --- espera por algo acontecer ---
 	select {
@@ -466,11 +528,14 @@ func DoEvent() {
 		}
 	case f := <-timerChan:
 		f()
+	case c := <-remote.Calls:
+		action.HandleRemote(c)
 	case <-sighup:
 		exit(0)
 	case <-util.Sigterm:
 		exit(0)
 	}
+	recordEvent(event)
 
 	// erros da tcell
 	if e, ok := event.(*tcell.EventError); ok {
@@ -485,6 +550,11 @@ func DoEvent() {
 		return
 	}
 
+	if e, ok := event.(*screen.EventBackground); ok {
+		action.SetTermBackground(e.Dark)
+		return
+	}
+
 	// ============================
 	// 1) FORM (consome teclas)
 	// ============================
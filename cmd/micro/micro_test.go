@@ -3,14 +3,22 @@ package main
 import (
 	"fmt"
 	"log"
+	"maps"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-errors/errors"
 	"github.com/helmutkemper/micro/v2/internal/action"
 	"github.com/helmutkemper/micro/v2/internal/buffer"
 	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/record"
+	"github.com/helmutkemper/micro/v2/internal/remote"
 	"github.com/helmutkemper/micro/v2/internal/screen"
+	"github.com/helmutkemper/micro/v2/internal/vfs"
 	"github.com/micro-editor/tcell/v2"
 	"github.com/stretchr/testify/assert"
 )
@@ -62,7 +70,7 @@ func startup(args []string) (tcell.SimulationScreen, error) {
 				}
 			}
 			// Print the stack trace too
-			log.Fatalf(errors.Wrap(err, 2).ErrorStack())
+			log.Fatal(errors.Wrap(err, 2).ErrorStack())
 		}
 	}()
 
@@ -273,6 +281,340 @@ func TestMouse(t *testing.T) {
 	assert.Equal(t, "firstline\nsecondline\nbase content\n", string(data))
 }
 
+func TestArchiveEdit(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "archive.zip")
+	if err := vfs.WriteFile(archive+"//dir/a.txt", []byte("base content")); err != nil {
+		t.Fatal(err)
+	}
+	file := archive + "//dir/a.txt"
+
+	openFile(file)
+
+	if findBuffer(file) == nil {
+		t.Fatalf("Could not find buffer %s", file)
+	}
+
+	injectString("new ")
+	injectKey(tcell.KeyCtrlS, rune(tcell.KeyCtrlS), tcell.ModCtrl)
+
+	data, err := vfs.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "new base content\n", string(data))
+}
+
+func TestTransform(t *testing.T) {
+	file := createTestFile(t, "b_c\na_b\nb_c\n")
+
+	openFile(file)
+
+	buf := findBuffer(file)
+	if buf == nil {
+		t.Fatalf("Could not find buffer %s", file)
+	}
+
+	runCommand := func(cmd string) {
+		injectKey(tcell.KeyCtrlE, rune(tcell.KeyCtrlE), tcell.ModCtrl)
+		injectString(cmd)
+		injectKey(tcell.KeyEnter, rune(tcell.KeyEnter), tcell.ModNone)
+	}
+
+	runCommand("transform sort")
+	assert.Equal(t, "a_b\nb_c\nb_c\n", string(buf.Bytes()))
+
+	// the transform is undone at once
+	injectKey(tcell.KeyCtrlZ, rune(tcell.KeyCtrlZ), tcell.ModCtrl)
+	assert.Equal(t, "b_c\na_b\nb_c\n", string(buf.Bytes()))
+
+	// only the selection is transformed
+	injectKey(tcell.KeyHome, 0, tcell.ModCtrl)
+	injectKey(tcell.KeyDown, 0, tcell.ModShift)
+	runCommand("transform camel")
+	injectKey(tcell.KeyCtrlS, rune(tcell.KeyCtrlS), tcell.ModCtrl)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "bC\na_b\nb_c\n", string(data))
+}
+
+func TestGitStatus(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatal(err, string(out))
+		}
+		return string(out)
+	}
+	git("init", "-q")
+	file := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(file, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	openFile(file)
+
+	injectKey(tcell.KeyCtrlE, rune(tcell.KeyCtrlE), tcell.ModCtrl)
+	injectString("gitstatus")
+	injectKey(tcell.KeyEnter, rune(tcell.KeyEnter), tcell.ModNone)
+
+	open := false
+	for _, p := range action.MainTab().Panes {
+		if sp, ok := p.(*action.GitStatusPane); ok && sp.IsActive() {
+			open = true
+		}
+	}
+	if !open {
+		t.Fatal("The git status pane is not open")
+	}
+
+	injectKey(tcell.KeyRune, 's', tcell.ModNone)
+	assert.Equal(t, "A  a.txt\n", git("status", "--short"))
+	injectKey(tcell.KeyRune, ' ', tcell.ModNone)
+	assert.Equal(t, "?? a.txt\n", git("status", "--short"))
+
+	injectKey(tcell.KeyRune, 'q', tcell.ModNone)
+	assert.Len(t, action.MainTab().Panes, 1)
+}
+
+func TestDiagnostics(t *testing.T) {
+	file := createTestFile(t, "one\ntwo\nthree\n")
+
+	openFile(file)
+
+	buf := findBuffer(file)
+	if buf == nil {
+		t.Fatalf("Could not find buffer %s", file)
+	}
+	buf.AddMessage(buffer.NewMessageAtLine("test", "bad line", 3, buffer.MTError))
+	defer buf.ClearMessages("test")
+
+	injectKey(tcell.KeyCtrlE, rune(tcell.KeyCtrlE), tcell.ModCtrl)
+	injectString("diagnostics severity")
+	injectKey(tcell.KeyEnter, rune(tcell.KeyEnter), tcell.ModNone)
+
+	var pane *action.DiagnosticsPane
+	for _, p := range action.MainTab().Panes {
+		if dp, ok := p.(*action.DiagnosticsPane); ok {
+			pane = dp
+		}
+	}
+	if pane == nil {
+		t.Fatal("The diagnostics pane is not open")
+	}
+	assert.Contains(t, string(pane.Buf.Bytes()), ":3:1: error: bad line (test)")
+
+	// enter jumps to the message in the pane of the buffer
+	injectKey(tcell.KeyEnter, rune(tcell.KeyEnter), tcell.ModNone)
+	bp := action.MainTab().CurPane()
+	if assert.NotNil(t, bp) {
+		assert.Equal(t, buf, bp.Buf)
+		assert.Equal(t, buffer.Loc{X: 0, Y: 2}, bp.Cursor.Loc)
+	}
+
+	pane.Quit()
+	assert.Len(t, action.MainTab().Panes, 1)
+}
+
+func TestRemote(t *testing.T) {
+	file := createTestFile(t, "one\ntwo\nthree\n")
+	path := filepath.Join(t.TempDir(), "micro.sock")
+	if err := remote.Listen(path); err != nil {
+		t.Fatal(err)
+	}
+	defer remote.Close()
+
+	done := make(chan error)
+	go func() {
+		done <- remote.Send(path, remote.Request{
+			Files:    []remote.File{{Path: file, Line: 2, Col: 3}},
+			Commands: []string{"setlocal tabsize 3"},
+			Wait:     true,
+		})
+	}()
+	tabs := len(action.Tabs.List)
+	action.HandleRemote(<-remote.Calls)
+
+	// the file is opened in a new tab, where the command runs
+	assert.Len(t, action.Tabs.List, tabs+1)
+	bp := action.MainTab().CurPane()
+	if !assert.NotNil(t, bp) {
+		return
+	}
+	assert.Equal(t, file, bp.Buf.AbsPath)
+	assert.Equal(t, buffer.Loc{X: 2, Y: 1}, bp.Cursor.Loc)
+	assert.Equal(t, float64(3), bp.Buf.Settings["tabsize"])
+
+	select {
+	case <-done:
+		t.Fatal("The client did not wait for the file to be closed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	bp.Quit()
+	assert.Len(t, action.Tabs.List, tabs)
+	assert.NoError(t, <-done)
+
+	// no tab is opened if one of the files cannot be opened
+	go func() {
+		done <- remote.Send(path, remote.Request{Files: []remote.File{{Path: file}, {Path: t.TempDir()}}})
+	}()
+	action.HandleRemote(<-remote.Calls)
+	assert.Error(t, <-done)
+	assert.Len(t, action.Tabs.List, tabs)
+}
+
+// replayRecording replays a recording in the editor, and checks that it
+// ends in the recorded state. The editor is left with an empty buffer
+func replayRecording(t *testing.T, rec *record.Recording) {
+	settings := maps.Clone(config.GlobalSettings)
+	defer func() {
+		config.GlobalSettings = settings
+		for _, b := range editorBuffers() {
+			b.Close()
+		}
+		action.InitTabs([]*buffer.Buffer{buffer.NewBufferFromString("", "", buffer.BTDefault)})
+		action.Tabs.Resize()
+	}()
+
+	t.Chdir(t.TempDir())
+	if err := openRecording(rec, sim); err != nil {
+		t.Fatal(err)
+	}
+	state, err := replayEvents(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, rec.State) {
+		assert.Empty(t, record.Compare(rec.State, state))
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	for _, b := range editorBuffers() {
+		b.Close()
+	}
+	file := createTestFile(t, "one\ntwo\n")
+	b, err := buffer.NewBufferFromFile(file, buffer.BTDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	action.InitTabs([]*buffer.Buffer{b})
+	action.Tabs.Resize()
+
+	path := filepath.Join(t.TempDir(), "session.rec")
+	if err := startRecording(path, []*buffer.Buffer{b}); err != nil {
+		t.Fatal(err)
+	}
+	injectKey(tcell.KeyDown, 0, tcell.ModNone)
+	injectString("new ")
+	injectKey(tcell.KeyCtrlE, rune(tcell.KeyCtrlE), tcell.ModCtrl)
+	injectString("replaceall one three")
+	injectKey(tcell.KeyEnter, rune(tcell.KeyEnter), tcell.ModNone)
+	// the recording stops with the last frame drawn
+	screen.Redraw()
+	DoEvent()
+	stopRecording()
+
+	rec, err := record.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.NotNil(t, rec.State) && assert.Len(t, rec.State.Buffers, 1) {
+		assert.Equal(t, "three\nnew two\n", rec.State.Buffers[0].Contents)
+	}
+	replayRecording(t, rec)
+}
+
+func TestRecordEncrypted(t *testing.T) {
+	for _, b := range editorBuffers() {
+		b.Close()
+	}
+	file := createTestFile(t, "secret text\n")
+	b, err := buffer.NewBufferFromFile(file, buffer.BTDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	action.InitTabs([]*buffer.Buffer{b})
+	action.Tabs.Resize()
+
+	path := filepath.Join(t.TempDir(), "session.rec")
+	if err := startRecording(path, []*buffer.Buffer{b}); err != nil {
+		t.Fatal(err)
+	}
+	injectKey(tcell.KeyCtrlE, rune(tcell.KeyCtrlE), tcell.ModCtrl)
+	injectString("encrypt")
+	injectKey(tcell.KeyEnter, rune(tcell.KeyEnter), tcell.ModNone)
+	for range 2 {
+		injectString("passphrase")
+		injectKey(tcell.KeyEnter, rune(tcell.KeyEnter), tcell.ModNone)
+	}
+	assert.True(t, b.Encrypted())
+	// the text typed in the encrypted buffer is not recorded either
+	injectString("hidden")
+	screen.Events <- tcell.NewEventPaste("pasted", "")
+	for len(screen.Events) > 0 {
+		DoEvent()
+	}
+	assert.Contains(t, string(b.Bytes()), "hiddenpasted")
+	screen.Redraw()
+	DoEvent()
+	stopRecording()
+
+	rec, err := record.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys strings.Builder
+	for _, ev := range rec.Events {
+		keys.WriteRune(ev.Rune)
+	}
+	assert.Contains(t, keys.String(), "encrypt")
+	assert.NotContains(t, keys.String(), "pass")
+	assert.NotContains(t, keys.String(), "hidden")
+	for _, ev := range rec.Events {
+		assert.NotEqual(t, "paste", ev.Type)
+	}
+	if assert.NotNil(t, rec.State) && assert.Len(t, rec.State.Buffers, 1) {
+		assert.Equal(t, record.Buffer{Name: b.GetName(), Encrypted: true}, rec.State.Buffers[0])
+		assert.Nil(t, rec.State.Screen)
+	}
+
+	// the contents of an encrypted buffer are not recorded when it is open
+	// at start
+	if err := startRecording(path, []*buffer.Buffer{b}); err != nil {
+		t.Fatal(err)
+	}
+	stopRecording()
+	if rec, err = record.Read(path); err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, rec.Files, 1) {
+		assert.True(t, rec.Files[0].Encrypted)
+		assert.Empty(t, rec.Files[0].Contents)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	files, _ := filepath.Glob("testdata/*.rec")
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			rec, err := record.Read(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replayRecording(t, rec)
+		})
+	}
+}
+
 var srTestStart = `foo
 foo
 foofoofoo
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/action"
+	"github.com/helmutkemper/micro/v2/internal/buffer"
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/record"
+	"github.com/helmutkemper/micro/v2/internal/screen"
+	"github.com/helmutkemper/micro/v2/internal/util"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// maxReplayDelay is the longest time waited between two replayed events.
+// It is longer than the time between the Esc and the key of the sequences
+// which are handled as Alt shortcuts
+const maxReplayDelay = 250 * time.Millisecond
+
+var (
+	recorder *record.Recorder
+
+	// the state of the editor when the last frame was drawn while
+	// recording, whose buffer contents are read when the recording stops
+	lastFrame struct {
+		events int
+		bufs   []*buffer.Buffer
+		screen []string
+	}
+)
+
+// startRecording starts recording the events of the editor in the file at
+// path, with the buffers opened at start. The contents of the encrypted
+// buffers are not recorded
+func startRecording(path string, bufs []*buffer.Buffer) error {
+	w, h := screen.Screen.Size()
+	header := record.Header{Width: w, Height: h, Settings: config.GlobalSettings}
+	if bindings, err := os.ReadFile(filepath.Join(config.ConfigDir, "bindings.json")); err == nil {
+		header.Bindings = string(bindings)
+	}
+	for _, b := range bufs {
+		f := record.File{
+			Name:     b.GetName(),
+			Path:     b.Path,
+			Contents: string(b.Bytes()),
+			X:        b.GetActiveCursor().X,
+			Y:        b.GetActiveCursor().Y,
+			Settings: b.Settings,
+		}
+		if b.Encrypted() {
+			f = record.File{Name: f.Name, Path: f.Path, Encrypted: true, Settings: f.Settings}
+		} else if _, err := os.Stat(b.AbsPath); err == nil && b.Path != "" {
+			f.Exists = true
+		}
+		header.Files = append(header.Files, f)
+	}
+
+	var err error
+	recorder, err = record.Create(path, header)
+	return err
+}
+
+// recordEvent records an event received by the main loop. The keys and the
+// pastes are not recorded while a password is typed, or while the current
+// pane shows an encrypted buffer, since they would record its text
+func recordEvent(e tcell.Event) {
+	if recorder == nil {
+		return
+	}
+	switch e.(type) {
+	case *tcell.EventKey, *tcell.EventPaste:
+		if action.InfoBar.HasPrompt && action.InfoBar.Masked {
+			return
+		}
+		if h := action.MainTab().CurPane(); h != nil && h.Buf.Encrypted() {
+			return
+		}
+	}
+	recorder.Event(e)
+}
+
+// recordFrame keeps the state of the editor after a frame is drawn. The
+// screen is not kept while an encrypted buffer is open, since it can show
+// its text
+func recordFrame() {
+	if recorder != nil {
+		lastFrame.events = recorder.Events()
+		lastFrame.bufs = editorBuffers()
+		lastFrame.screen = screenLines()
+		for _, b := range lastFrame.bufs {
+			if b.Encrypted() {
+				lastFrame.screen = nil
+			}
+		}
+	}
+}
+
+// stopRecording stops the recording with the state of the editor when the
+// last frame was drawn, so that the event which made micro exit is not
+// replayed
+func stopRecording() {
+	if recorder == nil {
+		return
+	}
+	state := record.State{Events: lastFrame.events, Buffers: stateBuffers(lastFrame.bufs), Screen: lastFrame.screen}
+	if err := recorder.Close(state); err != nil {
+		log.Println("Error stopping the recording:", err)
+	}
+	recorder = nil
+}
+
+// stateBuffers returns the buffers of the state of a recording, without the
+// contents of the encrypted buffers
+func stateBuffers(bufs []*buffer.Buffer) []record.Buffer {
+	var state []record.Buffer
+	for _, b := range bufs {
+		if b.Encrypted() {
+			state = append(state, record.Buffer{Name: b.GetName(), Encrypted: true})
+		} else {
+			state = append(state, record.Buffer{Name: b.GetName(), Contents: string(b.Bytes())})
+		}
+	}
+	return state
+}
+
+// editorBuffers returns the open buffers, other than the buffers of the
+// infobar and of the log
+func editorBuffers() []*buffer.Buffer {
+	var bufs []*buffer.Buffer
+	for _, b := range buffer.OpenBuffers {
+		if b.Type != buffer.BTInfo && b.Type != buffer.BTLog {
+			bufs = append(bufs, b)
+		}
+	}
+	return bufs
+}
+
+// screenLines returns the text of the lines of the screen, without their
+// trailing spaces
+func screenLines() []string {
+	w, h := screen.Screen.Size()
+	lines := make([]string, h)
+	for y := range lines {
+		var sb strings.Builder
+		for x := 0; x < w; {
+			r, combc, _, width := screen.Screen.GetContent(x, y)
+			sb.WriteRune(r)
+			for _, c := range combc {
+				sb.WriteRune(c)
+			}
+			x += max(width, 1)
+		}
+		lines[y] = strings.TrimRight(sb.String(), " ")
+	}
+	return lines
+}
+
+// openRecording sets the options, the bindings and the size of the screen
+// of a recording, and opens its files in new tabs. The files are written in
+// the current directory, at their relative path if they had one, so that
+// the messages with their path are the same
+func openRecording(rec *record.Recording, sim tcell.SimulationScreen) error {
+	for k, v := range rec.Settings {
+		if _, ok := config.GlobalSettings[k]; ok && config.OptionIsValid(k, v) == nil {
+			config.GlobalSettings[k] = v
+			config.VolatileSettings[k] = true
+		}
+	}
+	if rec.Bindings != "" {
+		if err := os.WriteFile(filepath.Join(config.ConfigDir, "bindings.json"), []byte(rec.Bindings), util.FileMode); err != nil {
+			return err
+		}
+		action.InitBindings()
+	}
+	if err := config.InitColorscheme(); err != nil {
+		return err
+	}
+	sim.SetSize(rec.Width, rec.Height)
+
+	for _, b := range editorBuffers() {
+		b.Close()
+	}
+	var bufs []*buffer.Buffer
+	for i, f := range rec.Files {
+		cmd := buffer.Command{StartCursor: buffer.Loc{X: f.X, Y: f.Y}}
+		var b *buffer.Buffer
+		if f.Path == "" {
+			b = buffer.NewBufferFromStringWithCommand(f.Contents, "", buffer.BTDefault, cmd)
+		} else {
+			// the other files keep their names, for the filetype detection
+			path := f.Path
+			if !filepath.IsLocal(path) {
+				path = filepath.Join(strconv.Itoa(i), filepath.Base(f.Path))
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if f.Exists {
+				if err := os.WriteFile(path, []byte(f.Contents), util.FileMode); err != nil {
+					return err
+				}
+			}
+			var err error
+			if b, err = buffer.NewBufferFromFileWithCommand(path, buffer.BTDefault, cmd); err != nil {
+				return err
+			}
+			if b.GetName() != f.Name {
+				b.SetName(f.Name)
+			}
+		}
+		// the filetype first, since it sets the options of the filetype
+		if ft, ok := f.Settings["filetype"]; ok && ft != b.Settings["filetype"] {
+			b.SetOptionNative("filetype", ft)
+		}
+		for k, v := range f.Settings {
+			if !reflect.DeepEqual(b.Settings[k], v) {
+				b.SetOptionNative(k, v)
+			}
+		}
+		bufs = append(bufs, b)
+	}
+	if len(bufs) == 0 {
+		bufs = append(bufs, buffer.NewBufferFromString("", "", buffer.BTDefault))
+	}
+	action.InitTabs(bufs)
+	action.Tabs.Resize()
+	return nil
+}
+
+// replayEvents replays the events of a recording which are followed by its
+// state, or all of them if it has none, and returns the state of the editor
+func replayEvents(rec *record.Recording) (*record.State, error) {
+	events := rec.Events
+	if rec.State != nil {
+		events = events[:min(rec.State.Events, len(events))]
+	}
+	for _, ev := range events {
+		e, err := ev.TcellEvent()
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := e.(*tcell.EventResize); ok {
+			screen.Screen.(tcell.SimulationScreen).SetSize(e.Size())
+		}
+		time.Sleep(min(ev.Delay, maxReplayDelay))
+		screen.Events <- e
+		for len(screen.DrawChan()) > 0 || len(screen.Events) > 0 {
+			DoEvent()
+		}
+	}
+	// draw the last frame
+	screen.Redraw()
+	DoEvent()
+
+	return &record.State{Events: len(events), Buffers: stateBuffers(editorBuffers()), Screen: screenLines()}, nil
+}
+
+// DoReplay replays the recording at path in a simulation screen, with the
+// default configuration and the options of the recording, and exits with 0
+// if the editor ends in the recorded state
+func DoReplay(path string) {
+	rec, err := record.Read(path)
+	if err != nil {
+		fmt.Println(err)
+		exit(1)
+	}
+	dir, err := os.MkdirTemp("", "micro-replay")
+	if err != nil {
+		fmt.Println(err)
+		exit(1)
+	}
+	replayExit := func(rc int) {
+		os.RemoveAll(dir)
+		exit(rc)
+	}
+
+	// the files are apart from the configuration
+	configDir, files := filepath.Join(dir, "config"), filepath.Join(dir, "files")
+	for _, d := range []string{configDir, files} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			fmt.Println(err)
+			replayExit(1)
+		}
+	}
+	if err := config.InitConfigDir(configDir); err != nil {
+		fmt.Println(err)
+		replayExit(1)
+	}
+	config.InitRuntimeFiles(true)
+	config.InitPlugins()
+	if err := config.ReadSettings(); err != nil {
+		fmt.Println(err)
+	}
+	if err := config.InitGlobalSettings(); err != nil {
+		fmt.Println(err)
+	}
+	sim, err := screen.InitSimScreen()
+	if err != nil {
+		fmt.Println(err)
+		replayExit(1)
+	}
+	screen.Events = make(chan tcell.Event, 1)
+	if err := config.LoadAllPlugins(); err != nil {
+		fmt.Println(err)
+	}
+	action.InitBindings()
+	action.InitCommands()
+	action.InitGlobals()
+	buffer.SetMessager(action.InfoBar)
+
+	if err := os.Chdir(files); err != nil {
+		fmt.Println(err)
+		replayExit(1)
+	}
+	if err := openRecording(rec, sim); err != nil {
+		fmt.Println(err)
+		replayExit(1)
+	}
+	if err := config.RunPluginFn("init"); err != nil {
+		fmt.Println(err)
+	}
+	if err := config.RunPluginFn("postinit"); err != nil {
+		fmt.Println(err)
+	}
+	state, err := replayEvents(rec)
+	if err != nil {
+		fmt.Println(err)
+		replayExit(1)
+	}
+
+	if rec.State == nil {
+		fmt.Printf("Replayed %d events. The recording has no final state to check, since micro did not exit normally.\n", state.Events)
+		replayExit(1)
+	}
+	diffs := record.Compare(rec.State, state)
+	if len(diffs) > 0 {
+		fmt.Printf("Replayed %d events. The state differs from the recording:\n", state.Events)
+		for _, d := range diffs {
+			fmt.Println(d)
+		}
+		replayExit(1)
+	}
+	fmt.Printf("Replayed %d events. The state matches the recording.\n", state.Events)
+	replayExit(0)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/helmutkemper/micro/v2/internal/buffer"
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/remote"
+	"github.com/helmutkemper/micro/v2/internal/util"
+)
+
+// DoRemoteFlags sends the files and the command given with the -remote
+// flags to the micro listening on the socket of the user, and exits once
+// they are opened, or closed with -remote-wait. If no micro is listening,
+// this micro starts and opens them itself
+func DoRemoteFlags() {
+	if !*flagRemote && !*flagRemoteWait && *flagRemoteCommand == "" {
+		return
+	}
+
+	req := remote.Request{Split: *flagRemoteSplit, Wait: *flagRemoteWait}
+	if *flagRemoteCommand != "" {
+		req.Commands = []string{*flagRemoteCommand}
+	}
+	for _, arg := range flag.Args() {
+		path, pos := util.GetPathAndCursorPosition(arg)
+		f := remote.File{Path: path}
+		if loc, err := buffer.ParseCursorLocation(pos); err == nil {
+			f.Line, f.Col = loc.Y+1, loc.X+1
+		}
+		// the running micro may have another working directory
+		if abs, err := filepath.Abs(f.Path); err == nil {
+			f.Path = abs
+		}
+		req.Files = append(req.Files, f)
+	}
+
+	err := remote.Send(remote.SocketPath(), req)
+	if err == nil {
+		exit(0)
+	}
+	if err != remote.ErrNotRunning {
+		fmt.Println("Error:", err)
+		exit(1)
+	}
+	// the files are given as FILE:LINE:COL with -remote
+	config.GlobalSettings["parsecursor"] = true
+	config.VolatileSettings["parsecursor"] = true
+}
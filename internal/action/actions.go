@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
@@ -645,6 +647,121 @@ func (h *BufPane) Retab() bool {
 	return true
 }
 
+// Reflow fills the paragraph at the cursor, or the paragraphs of the
+// selected lines, to the width of the textwidth option
+func (h *BufPane) Reflow() bool {
+	var start, end int
+	if h.Cursor.HasSelection() {
+		a, b := h.Cursor.CurSelection[0], h.Cursor.CurSelection[1]
+		if b.LessThan(a) {
+			a, b = b, a
+		}
+		start, end = a.Y, b.Y
+		if b.X == 0 && end > start {
+			end--
+		}
+	} else {
+		var ok bool
+		if start, end, ok = h.Buf.Paragraph(h.Cursor.Y); !ok {
+			return false
+		}
+	}
+
+	last := h.Buf.Reflow(start, end)
+	h.Cursor.Deselect(true)
+	h.Cursor.GotoLoc(buffer.Loc{X: util.CharacterCount(h.Buf.LineBytes(last)), Y: last})
+	h.Relocate()
+	return true
+}
+
+// gotoField moves the cursor to the start of a field of the table
+func (h *BufPane) gotoField(t *buffer.Table, rec, col int) bool {
+	f, ok := t.Field(rec, col)
+	if !ok {
+		return false
+	}
+	h.Cursor.Deselect(true)
+	h.Cursor.GotoLoc(f.Start)
+	h.Relocate()
+	return true
+}
+
+// ColumnNext moves the cursor to the next field in table mode, or to the
+// first field of the next record at the end of a record
+func (h *BufPane) ColumnNext() bool {
+	t := h.Buf.Table()
+	if t == nil {
+		return false
+	}
+	rec, col, ok := t.FieldAt(h.Cursor.Loc)
+	if !ok {
+		return false
+	}
+	if col+1 < len(t.Records[rec]) {
+		return h.gotoField(t, rec, col+1)
+	}
+	return h.gotoField(t, rec+1, 0)
+}
+
+// ColumnPrevious moves the cursor to the start of the field in table mode,
+// or to the previous field if it is at the start, which is the last field
+// of the previous record at the start of a record
+func (h *BufPane) ColumnPrevious() bool {
+	t := h.Buf.Table()
+	if t == nil {
+		return false
+	}
+	rec, col, ok := t.FieldAt(h.Cursor.Loc)
+	if !ok {
+		return false
+	}
+	if h.Cursor.Loc.GreaterThan(t.Records[rec][col].Start) {
+		return h.gotoField(t, rec, col)
+	}
+	if col > 0 {
+		return h.gotoField(t, rec, col-1)
+	}
+	if rec == 0 {
+		return false
+	}
+	return h.gotoField(t, rec-1, len(t.Records[rec-1])-1)
+}
+
+// SelectColumn selects the field of the cursor in every record of the
+// table, with a cursor for each record
+func (h *BufPane) SelectColumn() bool {
+	t := h.Buf.Table()
+	if t == nil {
+		return false
+	}
+	rec, col, ok := t.FieldAt(h.Cursor.Loc)
+	if !ok {
+		return false
+	}
+
+	h.Buf.ClearCursors()
+	main := h.Buf.GetActiveCursor()
+	for i := range t.Records {
+		f, ok := t.Field(i, col)
+		if !ok {
+			continue
+		}
+		c := main
+		if i != rec {
+			c = buffer.NewCursor(h.Buf, f.End)
+			h.Buf.AddCursor(c)
+		}
+		c.GotoLoc(f.End)
+		c.SetSelectionStart(f.Start)
+		c.SetSelectionEnd(f.End)
+		c.OrigSelection = c.CurSelection
+	}
+	h.Buf.MergeCursors()
+	h.Relocate()
+	InfoBar.Message(fmt.Sprintf("Selected column %d", col+1))
+	return true
+}
+
 // CursorStart moves the cursor to the start of the buffer
 func (h *BufPane) CursorStart() bool {
 	h.Cursor.Deselect(true)
@@ -942,6 +1059,88 @@ func (h *BufPane) CycleAutocompleteBack() bool {
 	return false
 }
 
+// SnippetExpand replaces the snippet prefix before the cursor with its
+// snippet
+func (h *BufPane) SnippetExpand() bool {
+	b := h.Buf
+	if h.Cursor.HasSelection() || b.HasSuggestions || b.NumCursors() > 1 || h.Cursor.X == 0 {
+		return false
+	}
+
+	before := string(util.SliceStart(b.LineBytes(h.Cursor.Y), h.Cursor.X))
+	var snippet *buffer.Snippet
+	for _, s := range b.Snippets() {
+		if !strings.HasSuffix(before, s.Prefix) {
+			continue
+		}
+		// the prefix must not be the end of a longer word
+		rest := []rune(strings.TrimSuffix(before, s.Prefix))
+		if len(rest) > 0 && util.IsWordChar(rest[len(rest)-1]) && util.IsWordChar([]rune(s.Prefix)[0]) {
+			continue
+		}
+		if snippet == nil || len(s.Prefix) > len(snippet.Prefix) {
+			snippet = s
+		}
+	}
+	if snippet == nil {
+		return false
+	}
+
+	start := h.Cursor.Loc.Move(-util.CharacterCountInString(snippet.Prefix), b)
+	b.Remove(start, h.Cursor.Loc)
+	b.InsertSnippet(start, snippet)
+	h.snippetMoved()
+	return true
+}
+
+// SnippetNext moves to the next tabstop of the snippet being edited
+func (h *BufPane) SnippetNext() bool {
+	if h.Buf.HasSuggestions || !h.Buf.NextSnippetStop(true) {
+		return false
+	}
+	h.snippetMoved()
+	return true
+}
+
+// SnippetPrevious moves to the previous tabstop of the snippet being edited
+func (h *BufPane) SnippetPrevious() bool {
+	if h.Buf.HasSuggestions || !h.Buf.NextSnippetStop(false) {
+		return false
+	}
+	h.snippetMoved()
+	return true
+}
+
+// snippetMoved is called once the cursors have been moved to a new
+// tabstop. If the tabstop offers choices, they are shown in a picker
+func (h *BufPane) snippetMoved() {
+	h.Cursor = h.Buf.GetActiveCursor()
+	h.Relocate()
+
+	choices := h.Buf.SnippetChoices()
+	if len(choices) < 2 {
+		return
+	}
+	h.Pick("Choice: ", "SnippetChoice", choices, nil, func(i int, canceled bool) {
+		if canceled {
+			return
+		}
+		for _, c := range h.Buf.GetCursors() {
+			start := c.Loc
+			if c.HasSelection() {
+				start = c.CurSelection[0]
+				c.DeleteSelection()
+				c.ResetSelection()
+			}
+			h.Buf.Insert(start, choices[i])
+			c.SetSelectionStart(start)
+			c.SetSelectionEnd(c.Loc)
+			c.OrigSelection = c.CurSelection
+		}
+		h.Relocate()
+	})
+}
+
 // InsertTab inserts a tab or spaces
 func (h *BufPane) InsertTab() bool {
 	b := h.Buf
@@ -1102,6 +1301,7 @@ func (h *BufPane) Search(str string, useRegex bool, searchDown bool) error {
 		return err
 	}
 	if found {
+		h.PushJump()
 		h.Cursor.SetSelectionStart(match[0])
 		h.Cursor.SetSelectionEnd(match[1])
 		h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
@@ -1146,6 +1346,7 @@ func (h *BufPane) find(useRegex bool) bool {
 				InfoBar.Error(err)
 			}
 			if found {
+				h.pushJumpAt(h.searchOrig)
 				h.Cursor.SetSelectionStart(match[0])
 				h.Cursor.SetSelectionEnd(match[1])
 				h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
@@ -1226,6 +1427,9 @@ func (h *BufPane) FindNext() bool {
 		match, found, _ = h.Buf.FindNext(h.Buf.LastSearch, h.Buf.Start(), h.Buf.End(), searchLoc, true, h.Buf.LastSearchRegex)
 	}
 	if found {
+		if h.Cursor.Num == 0 {
+			h.PushJump()
+		}
 		h.Cursor.SetSelectionStart(match[0])
 		h.Cursor.SetSelectionEnd(match[1])
 		h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
@@ -1263,6 +1467,9 @@ func (h *BufPane) FindPrevious() bool {
 		match, found, _ = h.Buf.FindNext(h.Buf.LastSearch, h.Buf.Start(), h.Buf.End(), searchLoc, false, h.Buf.LastSearchRegex)
 	}
 	if found {
+		if h.Cursor.Num == 0 {
+			h.PushJump()
+		}
 		h.Cursor.SetSelectionStart(match[0])
 		h.Cursor.SetSelectionEnd(match[1])
 		h.Cursor.OrigSelection[0] = h.Cursor.CurSelection[0]
@@ -1296,6 +1503,65 @@ func (h *BufPane) DiffPrevious() bool {
 	return true
 }
 
+// ToggleBookmark adds an anonymous bookmark on the current line, or removes
+// the bookmarks of the current line if there are any
+func (h *BufPane) ToggleBookmark() bool {
+	if h.Buf.ToggleBookmark(h.Cursor.Y) {
+		InfoBar.Message("Added bookmark")
+	} else {
+		InfoBar.Message("Removed bookmark")
+	}
+	return true
+}
+
+func (h *BufPane) gotoBookmark(forward bool) bool {
+	bm := h.Buf.NextBookmark(h.Cursor.Y, forward)
+	if bm == nil {
+		InfoBar.Message("No bookmarks")
+		return false
+	}
+	h.PushJump()
+	h.Cursor.Deselect(true)
+	h.GotoLoc(bm.Loc)
+	if bm.Name != "" {
+		InfoBar.Message("Bookmark ", bm.Name)
+	}
+	return true
+}
+
+// NextBookmark moves the cursor to the next bookmark in the buffer
+func (h *BufPane) NextBookmark() bool {
+	return h.gotoBookmark(true)
+}
+
+// PreviousBookmark moves the cursor to the previous bookmark in the buffer
+func (h *BufPane) PreviousBookmark() bool {
+	return h.gotoBookmark(false)
+}
+
+func (h *BufPane) gotoDiagnostic(forward bool) bool {
+	m := h.Buf.NextMessage(h.Cursor.Y, forward)
+	if m == nil {
+		InfoBar.Message("No diagnostics")
+		return false
+	}
+	h.PushJump()
+	h.Cursor.Deselect(true)
+	h.GotoLoc(buffer.Diagnostic{Message: m, Buf: h.Buf}.Loc())
+	InfoBar.GutterMessage(m.Msg)
+	return true
+}
+
+// NextDiagnostic moves the cursor to the next message in the buffer
+func (h *BufPane) NextDiagnostic() bool {
+	return h.gotoDiagnostic(true)
+}
+
+// PreviousDiagnostic moves the cursor to the previous message in the buffer
+func (h *BufPane) PreviousDiagnostic() bool {
+	return h.gotoDiagnostic(false)
+}
+
 // Undo undoes the last action
 func (h *BufPane) Undo() bool {
 	if !h.Buf.Undo() {
@@ -1342,12 +1608,55 @@ func (h *BufPane) selectLines() int {
 	return nlines
 }
 
+// SelectRegister asks for the register used by the next copy, cut or
+// paste instead of the clipboard
+func (h *BufPane) SelectRegister() bool {
+	InfoBar.Prompt("Register: ", "", "Register", func(resp string) {
+		if resp != "" {
+			InfoBar.DonePrompt(false)
+		}
+	}, func(resp string, canceled bool) {
+		if canceled {
+			return
+		}
+		r, err := clipboard.NamedRegister(resp)
+		if err != nil {
+			InfoBar.Error(err, ": ", resp)
+			return
+		}
+		h.register = r
+		h.freshClip = false
+	})
+	return true
+}
+
+// pasteRegister returns the register chosen for the next paste, or the
+// clipboard
+func (h *BufPane) pasteRegister() clipboard.Register {
+	if h.register != 0 {
+		return h.register
+	}
+	return clipboard.ClipboardReg
+}
+
+// copyRegister returns the register chosen for the next copy or cut, or the
+// clipboard, and false if the register is read-only
+func (h *BufPane) copyRegister() (clipboard.Register, bool) {
+	r := h.pasteRegister()
+	if clipboard.IsReadOnly(r) {
+		InfoBar.Error(clipboard.ErrReadOnly, ": ", r.Name())
+		return r, false
+	}
+	return r, true
+}
+
 // Copy the selection to the system clipboard
 func (h *BufPane) Copy() bool {
-	if !h.Cursor.HasSelection() {
+	r, ok := h.copyRegister()
+	if !ok || !h.Cursor.HasSelection() {
 		return false
 	}
-	h.Cursor.CopySelection(clipboard.ClipboardReg)
+	h.Cursor.CopySelection(r)
 	h.freshClip = false
 	InfoBar.Message("Copied selection")
 	h.Relocate()
@@ -1357,6 +1666,10 @@ func (h *BufPane) Copy() bool {
 // CopyLine copies the current line to the clipboard. If there is a selection,
 // CopyLine copies all the lines that are (fully or partially) in the selection.
 func (h *BufPane) CopyLine() bool {
+	r, ok := h.copyRegister()
+	if !ok {
+		return false
+	}
 	origLoc := h.Cursor.Loc
 	origLastVisualX := h.Cursor.LastVisualX
 	origLastWrappedVisualX := h.Cursor.LastWrappedVisualX
@@ -1366,7 +1679,7 @@ func (h *BufPane) CopyLine() bool {
 	if nlines == 0 {
 		return false
 	}
-	h.Cursor.CopySelection(clipboard.ClipboardReg)
+	h.Cursor.CopySelection(r)
 	h.freshClip = false
 	if nlines > 1 {
 		InfoBar.Message(fmt.Sprintf("Copied %d lines", nlines))
@@ -1384,10 +1697,11 @@ func (h *BufPane) CopyLine() bool {
 
 // Cut the selection to the system clipboard
 func (h *BufPane) Cut() bool {
-	if !h.Cursor.HasSelection() {
+	r, ok := h.copyRegister()
+	if !ok || !h.Cursor.HasSelection() {
 		return false
 	}
-	h.Cursor.CopySelection(clipboard.ClipboardReg)
+	h.Cursor.CopySelection(r)
 	h.Cursor.DeleteSelection()
 	h.Cursor.ResetSelection()
 	h.freshClip = false
@@ -1400,21 +1714,25 @@ func (h *BufPane) Cut() bool {
 // CutLine cuts the current line to the clipboard. If there is a selection,
 // CutLine cuts all the lines that are (fully or partially) in the selection.
 func (h *BufPane) CutLine() bool {
+	r, ok := h.copyRegister()
+	if !ok {
+		return false
+	}
 	nlines := h.selectLines()
 	if nlines == 0 {
 		return false
 	}
 	totalLines := nlines
 	if h.freshClip {
-		if clip, err := clipboard.Read(clipboard.ClipboardReg); err != nil {
+		if clip, err := clipboard.Read(r); err != nil {
 			InfoBar.Error(err)
 			return false
 		} else {
-			clipboard.WriteMulti(clip+string(h.Cursor.GetSelection()), clipboard.ClipboardReg, h.Cursor.Num, h.Buf.NumCursors())
+			clipboard.AppendMultiFrom(clip+string(h.Cursor.GetSelection()), h.Buf.GetName(), r.Base(), h.Cursor.Num, h.Buf.NumCursors())
 			totalLines = strings.Count(clip, "\n") + nlines
 		}
 	} else {
-		h.Cursor.CopySelection(clipboard.ClipboardReg)
+		h.Cursor.CopySelection(r)
 	}
 	h.freshClip = true
 	h.Cursor.DeleteSelection()
@@ -1588,16 +1906,141 @@ func (h *BufPane) MoveLinesDown() bool {
 // Paste whatever is in the system clipboard into the buffer
 // Delete and paste if the user has a selection
 func (h *BufPane) Paste() bool {
-	clip, err := clipboard.ReadMulti(clipboard.ClipboardReg, h.Cursor.Num, h.Buf.NumCursors())
+	clip, err := clipboard.ReadMulti(h.pasteRegister(), h.Cursor.Num, h.Buf.NumCursors())
 	if err != nil {
 		InfoBar.Error(err)
 	} else {
-		h.paste(clip)
+		h.trackPaste(h.paste(clip), h.historyIndex(clip))
 	}
 	h.Relocate()
 	return true
 }
 
+// InitClipboardHistory sets the size of the clipboard history and loads the
+// history saved by a previous session if the saveclipboard option is on
+func InitClipboardHistory() {
+	clipboard.SetMaxHistory(int(config.GetGlobalOption("clipboardhistory").(float64)))
+	if config.GetGlobalOption("saveclipboard").(bool) {
+		err := clipboard.LoadHistory(filepath.Join(config.ConfigDir, "buffers", "clipboard"))
+		if err != nil {
+			InfoBar.Error("Error loading clipboard history: ", err)
+		}
+	}
+}
+
+// saveClipboardHistory saves the clipboard history to
+// configDir/buffers/clipboard if the saveclipboard option is on
+func saveClipboardHistory() {
+	if config.GetGlobalOption("saveclipboard").(bool) {
+		err := clipboard.SaveHistory(filepath.Join(config.ConfigDir, "buffers", "clipboard"))
+		if err != nil {
+			screen.TermMessage("Error saving clipboard history: ", err)
+		}
+	}
+}
+
+// PasteHistory opens a picker to paste an entry of the clipboard history
+func (h *BufPane) PasteHistory() bool {
+	entries := clipboard.History()
+	if len(entries) == 0 {
+		InfoBar.Message("The clipboard history is empty")
+		return false
+	}
+
+	labels := make([]string, len(entries))
+	for i, e := range entries {
+		label, rest, more := strings.Cut(e.Text, "\n")
+		if more && rest != "" || util.CharacterCountInString(label) > 60 {
+			label = util.SliceStartStr(label, 60) + "…"
+		}
+		if e.Source != "" {
+			label += " (" + e.Source + ")"
+		}
+		labels[i] = fmt.Sprintf("%d: %s", i+1, label)
+	}
+
+	h.Pick("Paste: ", "ClipboardHistory", labels, nil, func(i int, canceled bool) {
+		if !canceled {
+			h.pasteEntry(entries[i], i, false)
+			InfoBar.Message(fmt.Sprintf("Pasted clipboard history entry %d", i+1))
+		}
+	})
+	return true
+}
+
+// CyclePaste replaces the text which was just pasted with the previous entry
+// of the clipboard history
+func (h *BufPane) CyclePaste() bool {
+	p := h.lastPaste
+	if p == nil || p.event != h.Buf.UndoStack.Peek() || !slices.Equal(p.locs, cursorLocs(h.Buf)) {
+		InfoBar.Error("The last action was not a paste")
+		return false
+	}
+	entries := clipboard.History()
+	if len(entries) == 0 {
+		return false
+	}
+
+	i := (p.index + 1) % len(entries)
+	h.pasteEntry(entries[i], i, true)
+	InfoBar.Message(fmt.Sprintf("Pasted clipboard history entry %d of %d", i+1, len(entries)))
+	return true
+}
+
+// pasteEntry pastes an entry of the clipboard history with all the cursors,
+// replacing the text pasted last if replace is true
+func (h *BufPane) pasteEntry(e clipboard.Entry, index int, replace bool) {
+	var lengths map[int]int
+	if replace {
+		lengths = h.lastPaste.lengths
+	}
+	h.lastPaste = nil
+
+	for _, c := range h.Buf.GetCursors() {
+		h.Buf.SetCurCursor(c.Num)
+		h.Cursor = c
+		if n := lengths[c.Num]; n > 0 {
+			h.Buf.Remove(c.Loc.Move(-n, h.Buf), c.Loc)
+		}
+		text := e.Text
+		if len(e.Parts) == h.Buf.NumCursors() {
+			text = e.Parts[c.Num]
+		}
+		h.trackPaste(h.paste(text), index)
+	}
+	h.Relocate()
+}
+
+// historyIndex returns the index in the clipboard history of the text
+// pasted by the current cursor, or -1 if it is not in the history
+func (h *BufPane) historyIndex(clip string) int {
+	for i, e := range clipboard.History() {
+		if len(e.Parts) == h.Buf.NumCursors() && e.Parts[h.Cursor.Num] == clip || e.Text == clip {
+			return i
+		}
+	}
+	return -1
+}
+
+// trackPaste remembers the text pasted by the current cursor so that
+// CyclePaste can replace it
+func (h *BufPane) trackPaste(text string, index int) {
+	if h.lastPaste == nil || h.Cursor.Num == 0 {
+		h.lastPaste = &pasteState{index: index, lengths: make(map[int]int)}
+	}
+	h.lastPaste.lengths[h.Cursor.Num] = util.CharacterCountInString(text)
+	h.lastPaste.event = h.Buf.UndoStack.Peek()
+	h.lastPaste.locs = cursorLocs(h.Buf)
+}
+
+func cursorLocs(b *buffer.Buffer) []buffer.Loc {
+	locs := make([]buffer.Loc, b.NumCursors())
+	for i, c := range b.GetCursors() {
+		locs[i] = c.Loc
+	}
+	return locs
+}
+
 // PastePrimary pastes from the primary clipboard (only use on linux)
 func (h *BufPane) PastePrimary() bool {
 	clip, err := clipboard.ReadMulti(clipboard.PrimaryReg, h.Cursor.Num, h.Buf.NumCursors())
@@ -1610,7 +2053,8 @@ func (h *BufPane) PastePrimary() bool {
 	return true
 }
 
-func (h *BufPane) paste(clip string) {
+// paste inserts text at the cursor and returns the inserted text
+func (h *BufPane) paste(clip string) string {
 	if h.Buf.Settings["smartpaste"].(bool) {
 		if h.Cursor.X > 0 {
 			leadingPasteWS := string(util.GetLeadingWhitespace([]byte(clip)))
@@ -1631,6 +2075,7 @@ func (h *BufPane) paste(clip string) {
 	// h.Cursor.Loc = h.Cursor.Loc.Move(Count(clip), h.Buf)
 	h.freshClip = false
 	InfoBar.Message("Pasted clipboard")
+	return clip
 }
 
 // JumpToMatchingBrace moves the cursor to the matching brace if it is
@@ -1905,8 +2350,9 @@ func (h *BufPane) ForceQuit() bool {
 	} else if len(Tabs.List) > 1 {
 		Tabs.RemoveTab(h.splitID)
 	} else {
-		screen.Screen.Fini()
+		screen.Fini()
 		InfoBar.Close()
+		saveClipboardHistory()
 		runtime.Goexit()
 	}
 	return true
@@ -1955,8 +2401,9 @@ func (h *BufPane) QuitAll() bool {
 
 	quit := func() {
 		buffer.CloseOpenBuffers()
-		screen.Screen.Fini()
+		screen.Fini()
 		InfoBar.Close()
+		saveClipboardHistory()
 		runtime.Goexit()
 	}
 
@@ -2185,7 +2632,7 @@ func (h *BufPane) SpawnMultiCursorUpN(n int) bool {
 	c := buffer.NewCursor(h.Buf, buffer.Loc{lastC.X, lastC.Y - n})
 	c.LastVisualX = lastC.LastVisualX
 	c.LastWrappedVisualX = lastC.LastWrappedVisualX
-	c.X = c.GetCharPosInLine(h.Buf.LineBytes(c.Y), c.LastVisualX)
+	c.X = h.Buf.CharPos(c.Y, c.LastVisualX)
 	c.Relocate()
 
 	h.Buf.AddCursor(c)
@@ -7,6 +7,7 @@ import (
 	luar "layeh.com/gopher-luar"
 
 	"github.com/helmutkemper/micro/v2/internal/buffer"
+	"github.com/helmutkemper/micro/v2/internal/clipboard"
 	"github.com/helmutkemper/micro/v2/internal/config"
 	"github.com/helmutkemper/micro/v2/internal/display"
 	ulua "github.com/helmutkemper/micro/v2/internal/lua"
@@ -43,6 +44,19 @@ func BufMouseActionGeneral(a BufMouseAction) PaneMouseAction {
 
 func init() {
 	BufBindings = NewKeyTree()
+
+	clipboard.SetReadOnly(clipboard.InsertReg, func() string {
+		return string(lastInsert.text)
+	})
+	clipboard.SetReadOnly(clipboard.SearchReg, func() string {
+		if Tabs == nil {
+			return ""
+		}
+		if h := MainTab().CurPane(); h != nil {
+			return h.Buf.LastSearch
+		}
+		return ""
+	})
 }
 
 // LuaAction makes an action from a lua function. It returns either a BufKeyAction
@@ -92,6 +106,21 @@ func LuaAction(fn string, k Event) BufAction {
 func BufMapEvent(k Event, action string) {
 	config.Bindings["buffer"][k.Name()] = action
 
+	bufAction := makeBufAction(k, action)
+
+	switch e := k.(type) {
+	case KeyEvent, KeySequenceEvent, RawEvent:
+		BufBindings.RegisterKeyBinding(e, BufKeyActionGeneral(func(h *BufPane) bool {
+			return bufAction(h, nil)
+		}))
+	case MouseEvent:
+		BufBindings.RegisterMouseBinding(e, BufMouseActionGeneral(bufAction))
+	}
+}
+
+// makeBufAction makes the function running the actions of a binding, which
+// are chained with &, | and ,
+func makeBufAction(k Event, action string) BufMouseAction {
 	var actionfns []BufAction
 	var names []string
 	var types []byte
@@ -147,7 +176,7 @@ func BufMapEvent(k Event, action string) {
 		}
 		actionfns = append(actionfns, afn)
 	}
-	bufAction := func(h *BufPane, te *tcell.EventMouse) bool {
+	return func(h *BufPane, te *tcell.EventMouse) bool {
 		for i, a := range actionfns {
 			var success bool
 			if _, ok := MultiActions[names[i]]; ok {
@@ -163,6 +192,11 @@ func BufMapEvent(k Event, action string) {
 				success = h.execAction(a, names[i], te)
 			}
 
+			// the chosen register is only used by the next action
+			if names[i] != "SelectRegister" {
+				h.register = 0
+			}
+
 			// if the action changed the current pane, update the reference
 			h = MainTab().CurPane()
 			if h == nil {
@@ -176,15 +210,6 @@ func BufMapEvent(k Event, action string) {
 		}
 		return true
 	}
-
-	switch e := k.(type) {
-	case KeyEvent, KeySequenceEvent, RawEvent:
-		BufBindings.RegisterKeyBinding(e, BufKeyActionGeneral(func(h *BufPane) bool {
-			return bufAction(h, nil)
-		}))
-	case MouseEvent:
-		BufBindings.RegisterMouseBinding(e, BufMouseActionGeneral(bufAction))
-	}
 }
 
 // BufUnmap unmaps a key or mouse event from any action
@@ -233,6 +258,14 @@ type BufPane struct {
 	// and have never been pasted yet.
 	freshClip bool
 
+	// register is the register used by the next copy, cut or paste
+	// instead of the clipboard, 0 if none was chosen
+	register clipboard.Register
+
+	// lastPaste is the text pasted last from the clipboard, which
+	// CyclePaste replaces
+	lastPaste *pasteState
+
 	// Was the last mouse event actually a double click?
 	// Useful for detecting triple clicks -- if a double click is detected
 	// but the last mouse event was actually a double click, it's a triple click
@@ -345,6 +378,7 @@ func (h *BufPane) resetMouse() {
 
 // OpenBuffer opens the given buffer in this pane.
 func (h *BufPane) OpenBuffer(b *buffer.Buffer) {
+	h.PushJump()
 	h.Buf.Close()
 	h.Buf = b
 	h.BWindow.SetBuffer(b)
@@ -474,6 +508,10 @@ func (h *BufPane) HandleEvent(event tcell.Event) {
 			}
 			isDrag := len(h.mousePressed) > 0
 
+			if !isDrag && e.Buttons() == tcell.Button1 && h.statusLineClick(me, e) {
+				break
+			}
+
 			if e.Buttons() & ^(tcell.WheelUp|tcell.WheelDown|tcell.WheelLeft|tcell.WheelRight) != tcell.ButtonNone {
 				h.mousePressed[me] = true
 			}
@@ -526,6 +564,21 @@ func (h *BufPane) HandleEvent(event tcell.Event) {
 	}
 }
 
+// statusLineClick runs the action of the statusline segment under the
+// mouse, and returns false if there is none
+func (h *BufPane) statusLineClick(me MouseEvent, e *tcell.EventMouse) bool {
+	w, ok := h.BWindow.(*display.BufWindow)
+	if !ok {
+		return false
+	}
+	action := w.StatusLineAction(e.Position())
+	if action == "" {
+		return false
+	}
+	makeBufAction(me, action)(h, e)
+	return true
+}
+
 // Bindings returns the current bindings tree for this buffer.
 func (h *BufPane) Bindings() *KeyTree {
 	if h.bindings != nil {
@@ -553,7 +606,9 @@ func (h *BufPane) DoKeyEvent(e Event) bool {
 }
 
 func (h *BufPane) execAction(action BufAction, name string, te *tcell.EventMouse) bool {
-	if name != "Autocomplete" && name != "CycleAutocompleteBack" {
+	switch name {
+	case "Autocomplete", "CycleAutocompleteBack", "SnippetExpand", "SnippetNext", "SnippetPrevious":
+	default:
 		h.Buf.HasSuggestions = false
 	}
 
@@ -632,6 +687,7 @@ func (h *BufPane) DoRuneInsert(r rune) {
 			c.ResetSelection()
 		}
 
+		start := c.Loc
 		if h.Buf.OverwriteMode {
 			next := c.Loc
 			next.X++
@@ -639,14 +695,38 @@ func (h *BufPane) DoRuneInsert(r rune) {
 		} else {
 			h.Buf.Insert(c.Loc, string(r))
 		}
+		if c.Num == 0 && h.Buf.Type != buffer.BTInfo {
+			trackInsert(h.Buf, start, c.Loc, r)
+		}
 		if recordingMacro {
 			curmacro = append(curmacro, r)
 		}
+		if !util.IsWhitespace(r) && h.Buf.Type != buffer.BTInfo {
+			h.Buf.HardWrap(c)
+		}
 		h.Relocate()
 		h.PluginCB("onRune", string(r))
 	}
 }
 
+// lastInsert is the text typed last, which the "." register holds
+var lastInsert struct {
+	buf  *buffer.Buffer
+	end  buffer.Loc
+	text []rune
+}
+
+// trackInsert adds a rune typed with the first cursor to the "." register,
+// which is reset when the rune isn't typed where the last one ended
+func trackInsert(b *buffer.Buffer, start, end buffer.Loc, r rune) {
+	if lastInsert.buf != b || lastInsert.end != start {
+		lastInsert.buf = b
+		lastInsert.text = lastInsert.text[:0]
+	}
+	lastInsert.text = append(lastInsert.text, r)
+	lastInsert.end = end
+}
+
 // VSplitIndex opens the given buffer in a vertical split on the given side.
 func (h *BufPane) VSplitIndex(buf *buffer.Buffer, right bool) *BufPane {
 	e := NewBufPaneFromBuf(buf, h.tab)
@@ -685,6 +765,31 @@ func (h *BufPane) HSplitBuf(buf *buffer.Buffer) *BufPane {
 	return h.HSplitIndex(buf, h.Buf.Settings["splitbottom"].(bool))
 }
 
+// hSplitPane opens a pane in a new horizontal split.
+func (h *BufPane) hSplitPane(p Pane) {
+	bottom := h.Buf.Settings["splitbottom"].(bool)
+	p.SetID(h.tab.GetNode(h.splitID).HSplit(bottom))
+	currentPaneIdx := h.tab.GetPane(h.splitID)
+	if bottom {
+		currentPaneIdx++
+	}
+	h.tab.AddPane(p, currentPaneIdx)
+	h.tab.Resize()
+	h.tab.SetActive(currentPaneIdx)
+}
+
+// setListText replaces the text of the read-only buffer of a list pane
+// through its event handler, keeping the cursor on the same line.
+func (h *BufPane) setListText(text string) {
+	y := h.Cursor.Y
+	h.Cursor.ResetSelection()
+	h.Buf.EventHandler.Replace(h.Buf.Start(), h.Buf.End(), text)
+	h.Cursor.GotoLoc(buffer.Loc{X: 0, Y: min(y, h.Buf.LinesNum()-1)})
+	if h.initialized {
+		h.Relocate()
+	}
+}
+
 // Close this pane.
 func (h *BufPane) Close() {
 	h.Buf.Close()
@@ -719,6 +824,18 @@ func (h *BufPane) SetActive(b bool) {
 	}
 }
 
+// pasteState is the text pasted by each cursor, removed by CyclePaste
+// unless the buffer or the cursors changed since
+type pasteState struct {
+	// index is the clipboard history entry which was pasted, -1 if the
+	// text is not in the history
+	index int
+	// lengths are the number of characters pasted by each cursor
+	lengths map[int]int
+	locs    []buffer.Loc
+	event   *buffer.TextEvent
+}
+
 // BufKeyActions contains the list of all possible key actions the bufhandler could execute
 var BufKeyActions = map[string]BufKeyAction{
 	"CursorUp":                  (*BufPane).CursorUp,
@@ -772,6 +889,13 @@ var BufKeyActions = map[string]BufKeyAction{
 	"FindPrevious":              (*BufPane).FindPrevious,
 	"DiffNext":                  (*BufPane).DiffNext,
 	"DiffPrevious":              (*BufPane).DiffPrevious,
+	"ToggleBookmark":            (*BufPane).ToggleBookmark,
+	"NextBookmark":              (*BufPane).NextBookmark,
+	"PreviousBookmark":          (*BufPane).PreviousBookmark,
+	"NextDiagnostic":            (*BufPane).NextDiagnostic,
+	"PreviousDiagnostic":        (*BufPane).PreviousDiagnostic,
+	"JumpBack":                  (*BufPane).JumpBack,
+	"JumpForward":               (*BufPane).JumpForward,
 	"Center":                    (*BufPane).Center,
 	"Undo":                      (*BufPane).Undo,
 	"Redo":                      (*BufPane).Redo,
@@ -784,13 +908,23 @@ var BufKeyActions = map[string]BufKeyAction{
 	"DeleteLine":                (*BufPane).DeleteLine,
 	"MoveLinesUp":               (*BufPane).MoveLinesUp,
 	"MoveLinesDown":             (*BufPane).MoveLinesDown,
+	"Reflow":                    (*BufPane).Reflow,
+	"ColumnNext":                (*BufPane).ColumnNext,
+	"ColumnPrevious":            (*BufPane).ColumnPrevious,
+	"SelectColumn":              (*BufPane).SelectColumn,
 	"IndentSelection":           (*BufPane).IndentSelection,
 	"OutdentSelection":          (*BufPane).OutdentSelection,
 	"Autocomplete":              (*BufPane).Autocomplete,
 	"CycleAutocompleteBack":     (*BufPane).CycleAutocompleteBack,
+	"SnippetExpand":             (*BufPane).SnippetExpand,
+	"SnippetNext":               (*BufPane).SnippetNext,
+	"SnippetPrevious":           (*BufPane).SnippetPrevious,
 	"OutdentLine":               (*BufPane).OutdentLine,
 	"IndentLine":                (*BufPane).IndentLine,
 	"Paste":                     (*BufPane).Paste,
+	"SelectRegister":            (*BufPane).SelectRegister,
+	"PasteHistory":              (*BufPane).PasteHistory,
+	"CyclePaste":                (*BufPane).CyclePaste,
 	"PastePrimary":              (*BufPane).PastePrimary,
 	"SelectAll":                 (*BufPane).SelectAll,
 	"OpenFile":                  (*BufPane).OpenFile,
@@ -917,6 +1051,9 @@ var MultiActions = map[string]bool{
 	"DeleteLine":                true,
 	"MoveLinesUp":               true,
 	"MoveLinesDown":             true,
+	"Reflow":                    true,
+	"ColumnNext":                true,
+	"ColumnPrevious":            true,
 	"IndentSelection":           true,
 	"OutdentSelection":          true,
 	"OutdentLine":               true,
@@ -4,19 +4,26 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/helmutkemper/micro/v2/internal/buffer"
 	"github.com/helmutkemper/micro/v2/internal/clipboard"
 	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/git"
 	"github.com/helmutkemper/micro/v2/internal/screen"
 	"github.com/helmutkemper/micro/v2/internal/shell"
+	"github.com/helmutkemper/micro/v2/internal/spell"
+	"github.com/helmutkemper/micro/v2/internal/transform"
 	"github.com/helmutkemper/micro/v2/internal/util"
 	shellquote "github.com/kballard/go-shellquote"
 )
@@ -32,41 +39,60 @@ var commands map[string]Command
 
 func InitCommands() {
 	commands = map[string]Command{
-		"set":         {(*BufPane).SetCmd, OptionValueComplete},
-		"setlocal":    {(*BufPane).SetLocalCmd, OptionValueComplete},
-		"toggle":      {(*BufPane).ToggleCmd, OptionValueComplete},
-		"togglelocal": {(*BufPane).ToggleLocalCmd, OptionValueComplete},
-		"reset":       {(*BufPane).ResetCmd, OptionValueComplete},
-		"show":        {(*BufPane).ShowCmd, OptionComplete},
-		"showkey":     {(*BufPane).ShowKeyCmd, nil},
-		"run":         {(*BufPane).RunCmd, nil},
-		"bind":        {(*BufPane).BindCmd, nil},
-		"unbind":      {(*BufPane).UnbindCmd, nil},
-		"quit":        {(*BufPane).QuitCmd, nil},
-		"goto":        {(*BufPane).GotoCmd, nil},
-		"jump":        {(*BufPane).JumpCmd, nil},
-		"save":        {(*BufPane).SaveCmd, nil},
-		"replace":     {(*BufPane).ReplaceCmd, nil},
-		"replaceall":  {(*BufPane).ReplaceAllCmd, nil},
-		"vsplit":      {(*BufPane).VSplitCmd, buffer.FileComplete},
-		"hsplit":      {(*BufPane).HSplitCmd, buffer.FileComplete},
-		"tab":         {(*BufPane).NewTabCmd, buffer.FileComplete},
-		"help":        {(*BufPane).HelpCmd, HelpComplete},
-		"eval":        {(*BufPane).EvalCmd, nil},
-		"log":         {(*BufPane).ToggleLogCmd, nil},
-		"plugin":      {(*BufPane).PluginCmd, PluginComplete},
-		"reload":      {(*BufPane).ReloadCmd, nil},
-		"reopen":      {(*BufPane).ReopenCmd, nil},
-		"cd":          {(*BufPane).CdCmd, buffer.FileComplete},
-		"pwd":         {(*BufPane).PwdCmd, nil},
-		"open":        {(*BufPane).OpenCmd, buffer.FileComplete},
-		"tabmove":     {(*BufPane).TabMoveCmd, nil},
-		"tabswitch":   {(*BufPane).TabSwitchCmd, nil},
-		"term":        {(*BufPane).TermCmd, nil},
-		"memusage":    {(*BufPane).MemUsageCmd, nil},
-		"retab":       {(*BufPane).RetabCmd, nil},
-		"raw":         {(*BufPane).RawCmd, nil},
-		"textfilter":  {(*BufPane).TextFilterCmd, nil},
+		"set":          {(*BufPane).SetCmd, OptionValueComplete},
+		"setlocal":     {(*BufPane).SetLocalCmd, OptionValueComplete},
+		"toggle":       {(*BufPane).ToggleCmd, OptionValueComplete},
+		"togglelocal":  {(*BufPane).ToggleLocalCmd, OptionValueComplete},
+		"reset":        {(*BufPane).ResetCmd, OptionValueComplete},
+		"show":         {(*BufPane).ShowCmd, OptionComplete},
+		"showkey":      {(*BufPane).ShowKeyCmd, nil},
+		"run":          {(*BufPane).RunCmd, nil},
+		"bind":         {(*BufPane).BindCmd, nil},
+		"unbind":       {(*BufPane).UnbindCmd, nil},
+		"quit":         {(*BufPane).QuitCmd, nil},
+		"goto":         {(*BufPane).GotoCmd, nil},
+		"jump":         {(*BufPane).JumpCmd, nil},
+		"save":         {(*BufPane).SaveCmd, nil},
+		"replace":      {(*BufPane).ReplaceCmd, nil},
+		"replaceall":   {(*BufPane).ReplaceAllCmd, nil},
+		"vsplit":       {(*BufPane).VSplitCmd, buffer.FileComplete},
+		"hsplit":       {(*BufPane).HSplitCmd, buffer.FileComplete},
+		"tab":          {(*BufPane).NewTabCmd, buffer.FileComplete},
+		"help":         {(*BufPane).HelpCmd, HelpComplete},
+		"eval":         {(*BufPane).EvalCmd, nil},
+		"log":          {(*BufPane).ToggleLogCmd, nil},
+		"plugin":       {(*BufPane).PluginCmd, PluginComplete},
+		"reload":       {(*BufPane).ReloadCmd, nil},
+		"reopen":       {(*BufPane).ReopenCmd, nil},
+		"cd":           {(*BufPane).CdCmd, buffer.FileComplete},
+		"pwd":          {(*BufPane).PwdCmd, nil},
+		"open":         {(*BufPane).OpenCmd, buffer.FileComplete},
+		"tabmove":      {(*BufPane).TabMoveCmd, nil},
+		"tabswitch":    {(*BufPane).TabSwitchCmd, nil},
+		"term":         {(*BufPane).TermCmd, nil},
+		"memusage":     {(*BufPane).MemUsageCmd, nil},
+		"retab":        {(*BufPane).RetabCmd, nil},
+		"raw":          {(*BufPane).RawCmd, nil},
+		"textfilter":   {(*BufPane).TextFilterCmd, nil},
+		"transform":    {(*BufPane).TransformCmd, TransformComplete},
+		"bookmark":     {(*BufPane).BookmarkCmd, nil},
+		"delbookmark":  {(*BufPane).DelBookmarkCmd, BookmarkComplete},
+		"bookmarks":    {(*BufPane).BookmarksCmd, nil},
+		"registers":    {(*BufPane).RegistersCmd, nil},
+		"colorscheme":  {(*BufPane).ColorschemeCmd, ColorschemeComplete},
+		"encrypt":      {(*BufPane).EncryptCmd, nil},
+		"decrypt":      {(*BufPane).DecryptCmd, nil},
+		"filehistory":  {(*BufPane).FileHistoryCmd, nil},
+		"recover":      {(*BufPane).RecoverCmd, nil},
+		"spellsuggest": {(*BufPane).SpellSuggestCmd, nil},
+		"spelladd":     {(*BufPane).SpellAddCmd, nil},
+		"sortcolumn":   {(*BufPane).SortColumnCmd, nil},
+		"deletecolumn": {(*BufPane).DeleteColumnCmd, nil},
+		"transpose":    {(*BufPane).TransposeCmd, nil},
+		"gitlog":       {(*BufPane).GitLogCmd, nil},
+		"gitstatus":    {(*BufPane).GitStatusCmd, nil},
+		"diagnostics":  {(*BufPane).DiagnosticsCmd, DiagnosticsComplete},
+		"format":       {(*BufPane).FormatCmd, nil},
 	}
 }
 
@@ -117,12 +143,165 @@ func (h *BufPane) PluginCmd(args []string) {
 	config.PluginCommand(buffer.LogBuf, args[0], args[1:])
 }
 
+var ColorschemeCmds = []string{"import"}
+
+// ColorschemeCmd lets the user pick a colorscheme with a live preview, or
+// imports a colorscheme from a VSCode, base16 or iTerm2 theme
+func (h *BufPane) ColorschemeCmd(args []string) {
+	if len(args) < 1 {
+		h.pickColorscheme()
+		return
+	}
+
+	switch args[0] {
+	case "import":
+		if len(args) < 2 {
+			InfoBar.Error("Not enough arguments")
+			return
+		}
+		path, err := util.ReplaceHome(args[1])
+		if err != nil {
+			InfoBar.Error(err)
+			return
+		}
+		name, text, unmapped, err := config.ImportColorscheme(path)
+		if err != nil {
+			InfoBar.Error("Error importing ", args[1], ": ", err)
+			return
+		}
+		file, err := config.InstallColorscheme(name, text)
+		if err != nil {
+			InfoBar.Error(err)
+			return
+		}
+
+		msg := fmt.Sprintf("Imported colorscheme %s to %s", name, file)
+		if len(unmapped) > 0 {
+			log.Println("Unmapped entries of", args[1]+":", strings.Join(unmapped, ", "))
+			const shown = 5
+			if len(unmapped) > shown {
+				msg += fmt.Sprintf("; not mapped: %s and %d more (listed in the file)",
+					strings.Join(unmapped[:shown], ", "), len(unmapped)-shown)
+			} else {
+				msg += "; not mapped: " + strings.Join(unmapped, ", ")
+			}
+		}
+		InfoBar.Message(msg)
+	default:
+		InfoBar.Error("Unknown colorscheme command: ", args[0])
+	}
+}
+
+// pickColorscheme opens a picker listing the colorschemes. Each colorscheme
+// is previewed on the open buffers while it is selected, and the original
+// one is restored if the picker is canceled
+func (h *BufPane) pickColorscheme() {
+	var names []string
+	seen := make(map[string]bool)
+	for _, f := range config.ListRuntimeFiles(config.RTColorscheme) {
+		if !seen[f.Name()] {
+			seen[f.Name()] = true
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	restore := func() {
+		config.InitColorscheme()
+		screen.Redraw()
+	}
+	preview := func(i int) {
+		if i < 0 {
+			restore()
+			return
+		}
+		if err := config.PreviewColorscheme(names[i]); err != nil {
+			log.Println("Error previewing colorscheme", names[i]+":", err)
+			restore()
+			return
+		}
+		screen.Redraw()
+	}
+
+	h.Pick("Colorscheme: ", "Colorscheme", names, preview, func(i int, canceled bool) {
+		restore()
+		if canceled {
+			return
+		}
+		if err := SetGlobalOption(config.ColorschemeOption(), names[i]); err != nil {
+			InfoBar.Error(err)
+		}
+	})
+}
+
+// reloadColorscheme loads the colorscheme given by the options again
+func reloadColorscheme() {
+	// LoadSyntaxFiles()
+	config.InitColorscheme()
+	for _, b := range buffer.OpenBuffers {
+		b.UpdateRules()
+	}
+}
+
+// SetTermBackground sets the detected background of the terminal and
+// switches to the colorscheme of colorscheme.light or colorscheme.dark
+func SetTermBackground(dark bool) {
+	background := "light"
+	if dark {
+		background = "dark"
+	}
+	if background != config.TermBackground {
+		config.TermBackground = background
+		if !config.ColorschemeChosen {
+			reloadColorscheme()
+		}
+	}
+}
+
 // RetabCmd changes all spaces to tabs or all tabs to spaces
 // depending on the user's settings
 func (h *BufPane) RetabCmd(args []string) {
 	h.Buf.Retab()
 }
 
+// EncryptCmd asks for a passphrase, with which the buffer is saved
+// encrypted
+func (h *BufPane) EncryptCmd(args []string) {
+	if h.Buf.Type != buffer.BTDefault {
+		InfoBar.Error("Only file buffers can be encrypted")
+		return
+	}
+	InfoBar.PasswordPrompt("Passphrase: ", func(passphrase string, canceled bool) {
+		if canceled || passphrase == "" {
+			return
+		}
+		InfoBar.PasswordPrompt("Confirm passphrase: ", func(confirm string, canceled bool) {
+			if canceled {
+				return
+			}
+			if confirm != passphrase {
+				InfoBar.Error("The passphrases do not match")
+				return
+			}
+			if err := h.Buf.SetPassphrase(passphrase); err != nil {
+				InfoBar.Error(err)
+				return
+			}
+			InfoBar.Message("The buffer will be saved encrypted")
+		})
+	})
+}
+
+// DecryptCmd makes an encrypted buffer saved in plain text
+func (h *BufPane) DecryptCmd(args []string) {
+	if !h.Buf.Encrypted() {
+		InfoBar.Error("The buffer is not encrypted")
+		return
+	}
+	h.Buf.SetPassphrase("")
+	InfoBar.Message("The buffer will be saved in plain text")
+}
+
 // RawCmd opens a new raw view which displays the escape sequences micro
 // is receiving in real-time
 func (h *BufPane) RawCmd(args []string) {
@@ -162,6 +341,69 @@ func (h *BufPane) TextFilterCmd(args []string) {
 	}
 }
 
+// TransformCmd transforms the selections with a built-in transform, or the
+// whole buffer when nothing is selected. All the selections are transformed
+// before the buffer is changed, as a single undo event
+func (h *BufPane) TransformCmd(args []string) {
+	if len(args) == 0 {
+		InfoBar.Error("usage: transform name [flags]")
+		return
+	}
+	opts := transform.Options{
+		Indent: h.Buf.IndentString(util.IntOpt(h.Buf.Settings["tabsize"])),
+	}
+
+	var cursors []*buffer.Cursor
+	for _, c := range h.Buf.GetCursors() {
+		if c.HasSelection() {
+			cursors = append(cursors, c)
+		}
+	}
+	whole := len(cursors) == 0
+	loc := h.Cursor.Loc
+	if whole {
+		h.Cursor.SetSelectionStart(h.Buf.Start())
+		h.Cursor.SetSelectionEnd(h.Buf.End())
+		cursors = append(cursors, h.Cursor)
+	}
+
+	results := make([]string, len(cursors))
+	for i, c := range cursors {
+		out, err := transform.Apply(args[0], string(c.GetSelection()), args[1:], opts)
+		if err != nil {
+			if whole {
+				h.Cursor.ResetSelection()
+			}
+			InfoBar.Error(err)
+			return
+		}
+		results[i] = out
+	}
+
+	h.Buf.Group(func() {
+		for i, c := range cursors {
+			start, end := c.CurSelection[0], c.CurSelection[1]
+			if end.LessThan(start) {
+				start, end = end, start
+			}
+			if string(h.Buf.Substr(start, end)) == results[i] {
+				continue
+			}
+			h.Buf.Replace(start, end, results[i])
+			end = start.Move(util.CharacterCountInString(results[i]), h.Buf)
+			c.SetSelectionStart(start)
+			c.SetSelectionEnd(end)
+			c.Loc = end
+			c.StoreVisualX()
+		}
+	})
+	if whole {
+		h.Cursor.ResetSelection()
+		h.Cursor.GotoLoc(loc.Clamp(h.Buf.Start(), h.Buf.End()))
+	}
+	h.Relocate()
+}
+
 // TabMoveCmd moves the current tab to a given index (starts at 1). The
 // displaced tabs are moved up.
 func (h *BufPane) TabMoveCmd(args []string) {
@@ -348,6 +590,7 @@ func reloadRuntime(reloadPlugins bool) {
 	}
 
 	config.InitRuntimeFiles(true)
+	buffer.ClearSnippets()
 
 	if reloadPlugins {
 		config.InitPlugins()
@@ -580,12 +823,14 @@ func doSetGlobalOptionNative(option string, nativeValue any) error {
 	config.ModifiedSettings[option] = true
 	delete(config.VolatileSettings, option)
 
-	if option == "colorscheme" {
-		// LoadSyntaxFiles()
-		config.InitColorscheme()
-		for _, b := range buffer.OpenBuffers {
-			b.UpdateRules()
+	if option == "colorscheme" || option == "colorscheme.light" || option == "colorscheme.dark" {
+		// the colorscheme set by hand takes precedence over the detected
+		// background until colorscheme.light or colorscheme.dark is set
+		config.ColorschemeChosen = option == "colorscheme"
+		if option != "colorscheme" {
+			screen.RestartBackgroundDetection()
 		}
+		reloadColorscheme()
 	} else if option == "infobar" || option == "keymenu" {
 		Tabs.Resize()
 	} else if option == "mouse" {
@@ -608,6 +853,8 @@ func doSetGlobalOptionNative(option string, nativeValue any) error {
 		if err != nil {
 			return err
 		}
+	} else if option == "clipboardhistory" {
+		clipboard.SetMaxHistory(int(nativeValue.(float64)))
 	} else {
 		for _, pl := range config.Plugins {
 			if option == pl.Name {
@@ -905,6 +1152,7 @@ func (h *BufPane) GotoCmd(args []string) {
 	line = util.Clamp(line-1, 0, h.Buf.LinesNum()-1)
 	col = util.Clamp(col-1, 0, util.CharacterCount(h.Buf.LineBytes(line)))
 
+	h.PushJump()
 	h.RemoveAllMultiCursors()
 	h.Cursor.Deselect(true)
 	h.GotoLoc(buffer.Loc{col, line})
@@ -924,6 +1172,7 @@ func (h *BufPane) JumpCmd(args []string) {
 	line = util.Clamp(line-1, 0, h.Buf.LinesNum()-1)
 	col = util.Clamp(col-1, 0, util.CharacterCount(h.Buf.LineBytes(line)))
 
+	h.PushJump()
 	h.RemoveAllMultiCursors()
 	h.Cursor.Deselect(true)
 	h.GotoLoc(buffer.Loc{col, line})
@@ -956,6 +1205,499 @@ func (h *BufPane) parseLineCol(args []string) (line int, col int, err error) {
 	return line, col, nil
 }
 
+// BookmarkCmd adds a bookmark with the given name at the cursor, or toggles
+// an anonymous bookmark on the current line if no name is given
+func (h *BufPane) BookmarkCmd(args []string) {
+	if len(args) == 0 {
+		h.ToggleBookmark()
+		return
+	}
+	h.Buf.AddBookmark(args[0], h.Cursor.Loc)
+	InfoBar.Message("Added bookmark ", args[0])
+}
+
+// DelBookmarkCmd removes the bookmark with the given name, or the bookmarks
+// on the current line if no name is given. `delbookmark -all` removes every
+// bookmark of the buffer
+func (h *BufPane) DelBookmarkCmd(args []string) {
+	if len(args) == 0 {
+		if h.Buf.BookmarkAtLine(h.Cursor.Y) == nil {
+			InfoBar.Error("No bookmark on this line")
+			return
+		}
+		h.Buf.ToggleBookmark(h.Cursor.Y)
+		InfoBar.Message("Removed bookmark")
+		return
+	}
+	if args[0] == "-all" {
+		h.Buf.ClearBookmarks()
+		InfoBar.Message("Removed all bookmarks")
+		return
+	}
+	bm := h.Buf.BookmarkByName(args[0])
+	if bm == nil {
+		InfoBar.Error("No bookmark named ", args[0])
+		return
+	}
+	h.Buf.RemoveBookmark(bm)
+	InfoBar.Message("Removed bookmark ", args[0])
+}
+
+// BookmarksCmd opens a picker listing the bookmarks of all open buffers
+func (h *BufPane) BookmarksCmd(args []string) {
+	type entry struct {
+		buf *buffer.Buffer
+		bm  *buffer.Bookmark
+	}
+	var entries []entry
+	var labels []string
+	seen := make(map[*buffer.SharedBuffer]bool)
+	for _, b := range buffer.OpenBuffers {
+		if seen[b.SharedBuffer] {
+			continue
+		}
+		seen[b.SharedBuffer] = true
+		for _, bm := range b.Bookmarks {
+			text := strings.TrimSpace(string(b.LineBytes(bm.Loc.Y)))
+			if util.CharacterCountInString(text) > 40 {
+				text = string([]rune(text)[:40])
+			}
+			label := fmt.Sprintf("%s:%d: %s", b.GetName(), bm.Loc.Y+1, text)
+			if bm.Name != "" {
+				label = bm.Name + " " + label
+			}
+			entries = append(entries, entry{b, bm})
+			labels = append(labels, label)
+		}
+	}
+	if len(entries) == 0 {
+		InfoBar.Message("No bookmarks")
+		return
+	}
+
+	h.Pick("Bookmark: ", "Bookmark", labels, nil, func(i int, canceled bool) {
+		if canceled {
+			return
+		}
+		e := entries[i]
+		h.PushJump()
+		h.GotoBufLoc(e.buf, e.buf.AbsPath, e.bm.Loc)
+	})
+}
+
+// RegistersCmd shows the registers holding some text in a picker, and
+// pastes the chosen one
+func (h *BufPane) RegistersCmd(args []string) {
+	var regs []clipboard.Register
+	var labels []string
+	for _, r := range clipboard.Registers() {
+		text, err := clipboard.Read(r)
+		if err != nil || text == "" {
+			continue
+		}
+		text = strings.NewReplacer("\n", "\\n", "\t", "\\t").Replace(text)
+		if util.CharacterCountInString(text) > 60 {
+			text = util.SliceStartStr(text, 60) + "…"
+		}
+		regs = append(regs, r)
+		labels = append(labels, fmt.Sprintf("\"%s %s", r.Name(), text))
+	}
+	if len(regs) == 0 {
+		InfoBar.Message("All registers are empty")
+		return
+	}
+
+	h.Pick("Register: ", "Registers", labels, nil, func(i int, canceled bool) {
+		if canceled {
+			return
+		}
+		h.register = regs[i]
+		for _, c := range h.Buf.GetCursors() {
+			h.Buf.SetCurCursor(c.Num)
+			h.Cursor = c
+			h.Paste()
+		}
+		h.register = 0
+	})
+}
+
+// FileHistoryCmd lists the versions of the file kept in the local file
+// history in a picker, and shows the differences between the chosen version
+// and the buffer, or restores it
+func (h *BufPane) FileHistoryCmd(args []string) {
+	versions, err := h.Buf.Versions()
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	if len(versions) == 0 {
+		InfoBar.Message("No saved versions of ", h.Buf.GetName())
+		return
+	}
+
+	labels := make([]string, len(versions))
+	for i, v := range versions {
+		labels[i] = fmt.Sprintf("%d. %s (%s, %s)", i+1, v.Time.Format("2006-01-02 15:04:05"), humanize.Time(v.Time), humanize.Bytes(uint64(v.Size)))
+	}
+
+	h.Pick("Version: ", "FileHistory", labels, nil, func(i int, canceled bool) {
+		if canceled {
+			return
+		}
+		v := versions[i]
+		InfoBar.Prompt("[d]iff, [r]estore: ", "", "FileHistoryAction", func(resp string) {
+			if resp != "" {
+				InfoBar.DonePrompt(false)
+			}
+		}, func(resp string, canceled bool) {
+			if canceled {
+				return
+			}
+			switch resp {
+			case "d":
+				h.diffVersion(v)
+			case "r":
+				if err := h.Buf.RestoreVersion(v); err != nil {
+					InfoBar.Error(err)
+					return
+				}
+				h.Relocate()
+				InfoBar.Message("Restored the version of ", v.Time.Format("2006-01-02 15:04:05"))
+			default:
+				InfoBar.Error("Invalid choice: ", resp)
+			}
+		})
+	})
+}
+
+// diffVersion shows the differences between a version of the file and the
+// buffer in a split
+func (h *BufPane) diffVersion(v buffer.Version) {
+	text, err := h.Buf.VersionText(v)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	when := v.Time.Format("2006-01-02 15:04:05")
+	diff := buffer.UnifiedDiff(text, string(h.Buf.Bytes()), h.Buf.GetName()+" "+when, h.Buf.GetName())
+	if diff == "" {
+		InfoBar.Message("The version of ", when, " is identical to the buffer")
+		return
+	}
+
+	b := buffer.NewBufferFromString(diff, "", buffer.BTScratch)
+	b.SetName("Diff " + when)
+	b.SetOptionNative("filetype", "patch")
+	h.HSplitBuf(b)
+}
+
+// RecoverCmd lists the orphaned backups in a picker, showing the
+// differences between the chosen backup and its file in a split, and
+// recovers or discards it
+func (h *BufPane) RecoverCmd(args []string) {
+	backups := buffer.OrphanedBackups()
+	if len(backups) == 0 {
+		InfoBar.Message("No orphaned backups")
+		return
+	}
+
+	labels := make([]string, len(backups))
+	for i, bk := range backups {
+		labels[i] = fmt.Sprintf("%d. %s (%s, %s)", i+1, bk.Path, humanize.Time(bk.ModTime), humanize.Bytes(uint64(bk.Size)))
+	}
+
+	var preview *BufPane
+	showPreview := func(i int) {
+		if i < 0 {
+			return
+		}
+		diff, err := backups[i].Diff()
+		if err != nil {
+			diff = err.Error()
+		} else if diff == "" {
+			diff = "The backup is identical to the file"
+		}
+		if preview == nil {
+			b := buffer.NewBufferFromString("", "", buffer.BTScratch)
+			b.SetName("Backup")
+			b.SetOptionNative("filetype", "patch")
+			preview = h.HSplitBuf(b)
+		}
+		preview.Buf.Replace(preview.Buf.Start(), preview.Buf.End(), diff)
+		preview.GotoLoc(preview.Buf.Start())
+	}
+
+	h.Pick("Backup: ", "Recover", labels, showPreview, func(i int, canceled bool) {
+		if preview != nil {
+			preview.ForceQuit()
+			h.tab.SetActive(h.tab.GetPane(h.splitID))
+		}
+		if canceled {
+			return
+		}
+		bk := backups[i]
+		InfoBar.Prompt("[r]ecover, [d]iscard, recover [a]s: ", "", "RecoverAction", func(resp string) {
+			if resp != "" {
+				InfoBar.DonePrompt(false)
+			}
+		}, func(resp string, canceled bool) {
+			if canceled {
+				return
+			}
+			switch resp {
+			case "r":
+				h.recoverBackup(bk, bk.Path)
+			case "d":
+				if err := bk.Discard(); err != nil {
+					InfoBar.Error(err)
+					return
+				}
+				InfoBar.Message("Discarded the backup of ", bk.Path)
+			case "a":
+				InfoBar.Prompt("Recover as: ", bk.Path, "RecoverAs", nil, func(resp string, canceled bool) {
+					if !canceled && resp != "" {
+						h.recoverBackup(bk, resp)
+					}
+				})
+			default:
+				InfoBar.Error("Invalid choice: ", resp)
+			}
+		})
+	})
+	showPreview(0)
+}
+
+// recoverBackup opens the backup as unsaved changes to the file with the
+// given path in a new tab
+func (h *BufPane) recoverBackup(bk buffer.Backup, path string) {
+	b, err := bk.Recover(path)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	width, height := screen.Screen.Size()
+	iOffset := config.GetInfoBarOffset()
+	Tabs.AddTab(NewTabFromBuffer(0, 0, width, height-1-iOffset, b))
+	Tabs.SetActive(len(Tabs.List) - 1)
+	InfoBar.Message("Recovered the backup of ", bk.Path, ", save the buffer to keep it")
+}
+
+// SpellSuggestCmd shows the suggestions for the word at the cursor in a
+// picker, and replaces the word with the chosen suggestion
+func (h *BufPane) SpellSuggestCmd(args []string) {
+	y := h.Cursor.Y
+	w, ok := h.Buf.SpellWordAt(h.Cursor.Loc)
+	if !ok {
+		InfoBar.Error("No word at the cursor")
+		return
+	}
+	checker, err := h.Buf.SpellChecker()
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	if len(checker) == 0 {
+		InfoBar.Message("The dictionaries are loading")
+		return
+	}
+	if checker.Check(w.Text) {
+		InfoBar.Message(w.Text, " is spelled correctly")
+		return
+	}
+
+	suggestions := checker.Suggest(w.Text)
+	if len(suggestions) == 0 {
+		InfoBar.Message("No suggestions for ", w.Text)
+		return
+	}
+	labels := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		labels[i] = fmt.Sprintf("%d. %s", i+1, s)
+	}
+	h.Pick("Replace "+w.Text+" with: ", "SpellSuggest", labels, nil, func(i int, canceled bool) {
+		if canceled {
+			return
+		}
+		h.Buf.Replace(buffer.Loc{X: w.Start, Y: y}, buffer.Loc{X: w.End, Y: y}, suggestions[i])
+	})
+}
+
+// SpellAddCmd adds the given word, or the word at the cursor, to the user
+// dictionary
+func (h *BufPane) SpellAddCmd(args []string) {
+	var word string
+	if len(args) > 0 {
+		word = args[0]
+	} else if w, ok := h.Buf.SpellWordAt(h.Cursor.Loc); ok {
+		word = w.Text
+	} else {
+		InfoBar.Error("No word at the cursor")
+		return
+	}
+	if err := spell.AddWord(word); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	InfoBar.Message("Added ", word, " to the dictionary")
+}
+
+// tableColumn returns the table of the buffer and the column given by an
+// argument, as an index starting at 1 or the name of a column, or the
+// column of the cursor without argument
+func (h *BufPane) tableColumn(args []string) (*buffer.Table, int, error) {
+	t := h.Buf.Table()
+	if t == nil {
+		return nil, 0, buffer.ErrNoTable
+	}
+	if len(args) == 0 {
+		_, col, ok := t.FieldAt(h.Cursor.Loc)
+		if !ok {
+			return nil, 0, errors.New("No column at the cursor")
+		}
+		return t, col, nil
+	}
+	if n, err := strconv.Atoi(args[0]); err == nil && n >= 1 && n <= t.Columns() {
+		return t, n - 1, nil
+	}
+	for col := range t.Columns() {
+		if t.ColumnName(col) == args[0] {
+			return t, col, nil
+		}
+	}
+	return nil, 0, errors.New("No column " + args[0])
+}
+
+// SortColumnCmd sorts the records of a table by a column, keeping the
+// header first, in reverse order with the -r flag
+func (h *BufPane) SortColumnCmd(args []string) {
+	reverse := len(args) > 0 && args[0] == "-r"
+	if reverse {
+		args = args[1:]
+	}
+	_, col, err := h.tableColumn(args)
+	if err == nil {
+		err = h.Buf.SortColumn(col, reverse)
+	}
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	h.Relocate()
+}
+
+// DeleteColumnCmd deletes a column of a table
+func (h *BufPane) DeleteColumnCmd(args []string) {
+	_, col, err := h.tableColumn(args)
+	if err == nil {
+		err = h.Buf.DeleteColumn(col)
+	}
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	h.Relocate()
+}
+
+// TransposeCmd swaps the rows and the columns of a table
+func (h *BufPane) TransposeCmd(args []string) {
+	if err := h.Buf.Transpose(); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	h.Relocate()
+}
+
+// GitLogCmd lists the commits which changed the file in a picker, and opens
+// the revision of the file in the chosen commit in a new tab
+func (h *BufPane) GitLogCmd(args []string) {
+	repo, err := h.Buf.Repo()
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	revs, err := repo.Log(h.Buf.AbsPath)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	if len(revs) == 0 {
+		InfoBar.Message("No commits of ", h.Buf.GetName())
+		return
+	}
+
+	labels := make([]string, len(revs))
+	for i, r := range revs {
+		labels[i] = fmt.Sprintf("%d. %s %s %s: %s", i+1, r.Short(), r.Time.Format("2006-01-02"), r.Author, r.Summary)
+	}
+
+	h.Pick("Commit: ", "GitLog", labels, nil, func(i int, canceled bool) {
+		if canceled {
+			return
+		}
+		r := revs[i]
+		text, err := repo.Show(r.Hash, r.Path)
+		if err != nil {
+			InfoBar.Error(err)
+			return
+		}
+
+		b := buffer.NewBufferFromString(string(text), "", buffer.BTRevision)
+		b.SetName(r.Path + "@" + r.Short())
+		b.SetOptionNative("filetype", h.Buf.Settings["filetype"])
+		width, height := screen.Screen.Size()
+		iOffset := config.GetInfoBarOffset()
+		Tabs.AddTab(NewTabFromBuffer(0, 0, width, height-1-iOffset, b))
+		Tabs.SetActive(len(Tabs.List) - 1)
+		InfoBar.Message(r.Short(), " ", r.Author, ", ", r.Time.Format("2006-01-02 15:04"), ": ", r.Summary)
+	})
+}
+
+// GitStatusCmd lists the changed files of the git repository of the file,
+// or of the current directory, in a split where their changes are staged
+// and unstaged
+func (h *BufPane) GitStatusCmd(args []string) {
+	repo, err := h.Buf.Repo()
+	if err != nil {
+		repo, err = git.Open(".")
+	}
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+
+	h.hSplitPane(NewGitStatusPane(repo, h.tab))
+	InfoBar.Message("s: stage, u: unstage, space: toggle, enter: open, r: refresh, q: close")
+}
+
+// DiagnosticsCmd lists the messages of all the open buffers in a split,
+// sorted by location, severity or owner
+func (h *BufPane) DiagnosticsCmd(args []string) {
+	sortBy := "location"
+	if len(args) > 0 {
+		sortBy = args[0]
+		if !slices.Contains(buffer.DiagnosticSorts, sortBy) {
+			InfoBar.Error("Invalid order ", sortBy, ", use ", strings.Join(buffer.DiagnosticSorts, ", "))
+			return
+		}
+	}
+	h.hSplitPane(NewDiagnosticsPane(sortBy, h.tab))
+	InfoBar.Message("enter: jump, s: sort, q: close")
+}
+
+// FormatCmd formats the buffer with the formatter of its filetype
+func (h *BufPane) FormatCmd(args []string) {
+	if h.Buf.Type.Readonly {
+		InfoBar.Error("Cannot format a readonly buffer")
+		return
+	}
+	if err := h.Buf.Format(); err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	h.Relocate()
+	InfoBar.Message("Formatted with ", h.Buf.Formatter())
+}
+
 // SaveCmd saves the buffer optionally with an argument file name
 func (h *BufPane) SaveCmd(args []string) {
 	if len(args) == 0 {
@@ -27,14 +27,18 @@ var bufdefaults = map[string]string{
 	"CtrlShiftDown":  "SelectToEnd",
 	"Alt-{":          "ParagraphPrevious",
 	"Alt-}":          "ParagraphNext",
+	"Alt-q":          "Reflow",
+	"Alt-l":          "ColumnNext",
+	"Alt-h":          "ColumnPrevious",
+	"Alt-|":          "SelectColumn",
 	"Enter":          "InsertNewline",
 	"CtrlH":          "Backspace",
 	"Backspace":      "Backspace",
 	"OldBackspace":   "Backspace",
 	"Alt-CtrlH":      "DeleteWordLeft",
 	"Alt-Backspace":  "DeleteWordLeft",
-	"Tab":            "Autocomplete|IndentSelection|InsertTab",
-	"Backtab":        "CycleAutocompleteBack|OutdentSelection|OutdentLine",
+	"Tab":            "SnippetExpand|SnippetNext|Autocomplete|IndentSelection|InsertTab",
+	"Backtab":        "SnippetPrevious|CycleAutocompleteBack|OutdentSelection|OutdentLine",
 	"Ctrl-o":         "OpenFile",
 	"Ctrl-s":         "Save",
 	"Ctrl-f":         "Find",
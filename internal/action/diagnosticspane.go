@@ -0,0 +1,101 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/helmutkemper/micro/v2/internal/buffer"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// DiagnosticsPane lists the messages of all the open buffers, such as the
+// errors found by a linter, one per line. The list follows the messages
+// added and removed. Enter jumps to the message of the line, s changes the
+// order of the list and q closes the pane
+type DiagnosticsPane struct {
+	*BufPane
+	sortBy string
+	diags  []buffer.Diagnostic
+	text   string
+}
+
+// NewDiagnosticsPane returns a pane listing the diagnostics in one of the
+// buffer.DiagnosticSorts orders
+func NewDiagnosticsPane(sortBy string, tab *Tab) *DiagnosticsPane {
+	b := buffer.NewBufferFromString("", "", buffer.BTList)
+	b.SetName("Diagnostics")
+
+	p := &DiagnosticsPane{sortBy: sortBy}
+	p.BufPane = NewBufPaneFromBuf(b, tab)
+	p.Refresh()
+	return p
+}
+
+// Refresh lists the diagnostics again if they changed
+func (p *DiagnosticsPane) Refresh() {
+	p.diags = buffer.Diagnostics(p.sortBy)
+
+	lines := make([]string, len(p.diags))
+	for i, d := range p.diags {
+		loc := d.Loc()
+		lines[i] = fmt.Sprintf("%s:%d:%d: %s: %s", d.Buf.GetName(), loc.Y+1, loc.X+1, d.Severity(), d.Msg)
+		if d.Owner != "" {
+			lines[i] += " (" + d.Owner + ")"
+		}
+	}
+	text := strings.Join(lines, "\n")
+	if len(lines) == 0 {
+		text = "No diagnostics"
+	}
+	if text != p.text {
+		p.text = text
+		p.setListText(text)
+	}
+}
+
+// jump moves the cursor to the diagnostic of the line of the cursor, in the
+// pane showing its buffer
+func (p *DiagnosticsPane) jump() {
+	if p.Cursor.Y >= len(p.diags) {
+		return
+	}
+	d := p.diags[p.Cursor.Y]
+	if p.GotoBufLoc(d.Buf, "", d.Loc()) == nil {
+		InfoBar.Error(d.Buf.GetName(), " is not shown in any pane")
+		return
+	}
+	InfoBar.GutterMessage(d.Msg)
+}
+
+// Display lists the diagnostics again before drawing the pane
+func (p *DiagnosticsPane) Display() {
+	p.Refresh()
+	p.BufPane.Display()
+}
+
+// HandleEvent handles the keys of the diagnostics pane, and passes the
+// other events to the buffer pane
+func (p *DiagnosticsPane) HandleEvent(event tcell.Event) {
+	if e, ok := event.(*tcell.EventKey); ok && e.Modifiers() == 0 {
+		switch {
+		case e.Key() == tcell.KeyEnter:
+			p.jump()
+			return
+		case e.Key() != tcell.KeyRune:
+		case e.Rune() == 's':
+			for i, s := range buffer.DiagnosticSorts {
+				if s == p.sortBy {
+					p.sortBy = buffer.DiagnosticSorts[(i+1)%len(buffer.DiagnosticSorts)]
+					break
+				}
+			}
+			p.Refresh()
+			InfoBar.Message("Sorted by ", p.sortBy)
+			return
+		case e.Rune() == 'q':
+			p.Quit()
+			return
+		}
+	}
+	p.BufPane.HandleEvent(event)
+}
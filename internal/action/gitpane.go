@@ -0,0 +1,152 @@
+package action
+
+import (
+	"path/filepath"
+
+	"github.com/helmutkemper/micro/v2/internal/buffer"
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/git"
+	"github.com/helmutkemper/micro/v2/internal/screen"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// GitStatusPane lists the changed files of a git repository, one per line
+// as in git status --short. The changes of the files on the lines of the
+// cursor or the selection are staged with s, unstaged with u and toggled
+// with space. Enter opens the file of the line, r refreshes the list and q
+// closes the pane
+type GitStatusPane struct {
+	*BufPane
+	repo  *git.Repo
+	files []git.FileStatus
+}
+
+// NewGitStatusPane returns a pane listing the changed files of a repository
+func NewGitStatusPane(repo *git.Repo, tab *Tab) *GitStatusPane {
+	b := buffer.NewBufferFromString("", "", buffer.BTList)
+	b.SetName("Git status")
+
+	p := &GitStatusPane{repo: repo}
+	p.BufPane = NewBufPaneFromBuf(b, tab)
+	p.Refresh()
+	return p
+}
+
+// Refresh lists the changed files again, keeping the cursor on the same
+// line
+func (p *GitStatusPane) Refresh() {
+	files, err := p.repo.Status()
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	p.files = files
+
+	text := "Nothing to commit, working tree clean"
+	if len(files) > 0 {
+		text = ""
+		for i, f := range files {
+			if i > 0 {
+				text += "\n"
+			}
+			text += f.String()
+		}
+	}
+
+	p.setListText(text)
+}
+
+// selected returns the files on the lines of the selection, or on the line
+// of the cursor
+func (p *GitStatusPane) selected() []git.FileStatus {
+	start, end := p.Cursor.Y, p.Cursor.Y
+	if p.Cursor.HasSelection() {
+		a, b := p.Cursor.CurSelection[0], p.Cursor.CurSelection[1]
+		if b.LessThan(a) {
+			a, b = b, a
+		}
+		start, end = a.Y, b.Y
+		// a selection ending at the start of a line does not include it
+		if b.X == 0 && end > start {
+			end--
+		}
+	}
+	if start >= len(p.files) {
+		return nil
+	}
+	return p.files[start:min(end+1, len(p.files))]
+}
+
+// update stages or unstages the selected files, as chosen by stage for
+// each file
+func (p *GitStatusPane) update(stage func(f git.FileStatus) bool) {
+	var add, reset []string
+	for _, f := range p.selected() {
+		if stage(f) {
+			add = append(add, f.Path)
+		} else {
+			reset = append(reset, f.Path)
+			if f.Orig != "" {
+				reset = append(reset, f.Orig)
+			}
+		}
+	}
+
+	var err error
+	if len(add) > 0 {
+		err = p.repo.Stage(add...)
+	}
+	if err == nil && len(reset) > 0 {
+		err = p.repo.Unstage(reset...)
+	}
+	if err != nil {
+		InfoBar.Error(err)
+	}
+	p.Refresh()
+}
+
+// open opens the file of the line of the cursor in a new tab
+func (p *GitStatusPane) open() {
+	files := p.selected()
+	if len(files) == 0 {
+		return
+	}
+	b, err := buffer.NewBufferFromFile(filepath.Join(p.repo.Root, files[0].Path), buffer.BTDefault)
+	if err != nil {
+		InfoBar.Error(err)
+		return
+	}
+	width, height := screen.Screen.Size()
+	iOffset := config.GetInfoBarOffset()
+	Tabs.AddTab(NewTabFromBuffer(0, 0, width, height-1-iOffset, b))
+	Tabs.SetActive(len(Tabs.List) - 1)
+}
+
+// HandleEvent handles the keys of the status pane, and passes the other
+// events to the buffer pane
+func (p *GitStatusPane) HandleEvent(event tcell.Event) {
+	if e, ok := event.(*tcell.EventKey); ok && e.Modifiers() == 0 {
+		switch {
+		case e.Key() == tcell.KeyEnter:
+			p.open()
+			return
+		case e.Key() != tcell.KeyRune:
+		case e.Rune() == 's':
+			p.update(func(f git.FileStatus) bool { return true })
+			return
+		case e.Rune() == 'u':
+			p.update(func(f git.FileStatus) bool { return false })
+			return
+		case e.Rune() == ' ':
+			p.update(func(f git.FileStatus) bool { return f.HasUnstaged() })
+			return
+		case e.Rune() == 'r':
+			p.Refresh()
+			return
+		case e.Rune() == 'q':
+			p.Quit()
+			return
+		}
+	}
+	p.BufPane.HandleEvent(event)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/helmutkemper/micro/v2/internal/buffer"
 	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/transform"
 	"github.com/helmutkemper/micro/v2/internal/util"
 	"github.com/helmutkemper/micro/v2/pkg/highlight"
 )
@@ -58,6 +59,68 @@ func HelpComplete(b *buffer.Buffer) ([]string, []string) {
 	return completions, suggestions
 }
 
+// BookmarkComplete autocompletes the names of the bookmarks in the buffer
+// of the current pane
+func BookmarkComplete(b *buffer.Buffer) ([]string, []string) {
+	c := b.GetActiveCursor()
+	input, argstart := b.GetArg()
+
+	var suggestions []string
+	if bp := MainTab().CurPane(); bp != nil {
+		for _, bm := range bp.Buf.Bookmarks {
+			if bm.Name != "" && strings.HasPrefix(bm.Name, input) {
+				suggestions = append(suggestions, bm.Name)
+			}
+		}
+	}
+
+	sort.Strings(suggestions)
+	completions := make([]string, len(suggestions))
+	for i := range suggestions {
+		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
+	}
+	return completions, suggestions
+}
+
+// TransformComplete autocompletes the names of the built-in transforms of
+// the transform command
+func TransformComplete(b *buffer.Buffer) ([]string, []string) {
+	c := b.GetActiveCursor()
+	input, argstart := b.GetArg()
+
+	var suggestions []string
+	for _, name := range transform.Names() {
+		if strings.HasPrefix(name, input) {
+			suggestions = append(suggestions, name)
+		}
+	}
+
+	completions := make([]string, len(suggestions))
+	for i := range suggestions {
+		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
+	}
+	return completions, suggestions
+}
+
+// DiagnosticsComplete autocompletes the orders of the diagnostics
+func DiagnosticsComplete(b *buffer.Buffer) ([]string, []string) {
+	c := b.GetActiveCursor()
+	input, argstart := b.GetArg()
+
+	var suggestions []string
+	for _, name := range buffer.DiagnosticSorts {
+		if strings.HasPrefix(name, input) {
+			suggestions = append(suggestions, name)
+		}
+	}
+
+	completions := make([]string, len(suggestions))
+	for i := range suggestions {
+		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
+	}
+	return completions, suggestions
+}
+
 // colorschemeComplete tab-completes names of colorschemes.
 // This is just a heper value for OptionValueComplete
 func colorschemeComplete(input string) (string, []string) {
@@ -219,7 +282,7 @@ func OptionValueComplete(b *buffer.Buffer) ([]string, []string) {
 		}
 	case string:
 		switch inputOpt {
-		case "colorscheme":
+		case "colorscheme", "colorscheme.light", "colorscheme.dark":
 			_, suggestions = colorschemeComplete(input)
 		case "filetype":
 			_, suggestions = filetypeComplete(input)
@@ -269,6 +332,34 @@ func PluginCmdComplete(b *buffer.Buffer) ([]string, []string) {
 	return completions, suggestions
 }
 
+// ColorschemeComplete completes the subcommands of the colorscheme command
+// and the file to import
+func ColorschemeComplete(b *buffer.Buffer) ([]string, []string) {
+	c := b.GetActiveCursor()
+	l := util.SliceStart(b.LineBytes(c.Y), c.X)
+	args := bytes.Split(l, []byte{' '})
+	if len(args) >= 3 && string(args[len(args)-2]) == "import" {
+		return buffer.FileComplete(b)
+	}
+	if len(args) > 2 {
+		return nil, nil
+	}
+
+	input, argstart := b.GetArg()
+	var suggestions []string
+	for _, cmd := range ColorschemeCmds {
+		if strings.HasPrefix(cmd, input) {
+			suggestions = append(suggestions, cmd)
+		}
+	}
+
+	completions := make([]string, len(suggestions))
+	for i := range suggestions {
+		completions[i] = util.SliceEndStr(suggestions[i], c.X-argstart)
+	}
+	return completions, suggestions
+}
+
 // PluginComplete completes values for the plugin command
 func PluginComplete(b *buffer.Buffer) ([]string, []string) {
 	c := b.GetActiveCursor()
@@ -203,7 +203,9 @@ func (h *InfoPane) CommandComplete() {
 	args := bytes.Split(l, []byte{' '})
 	cmd := string(args[0])
 
-	if h.PromptType == "Command" {
+	if curPicker != nil && h.PromptType == curPicker.ptype {
+		b.Autocomplete(curPicker.complete)
+	} else if h.PromptType == "Command" {
 		if len(args) == 1 {
 			b.Autocomplete(CommandComplete)
 		} else if action, ok := commands[cmd]; ok {
@@ -0,0 +1,161 @@
+package action
+
+import (
+	"github.com/helmutkemper/micro/v2/internal/buffer"
+	"github.com/helmutkemper/micro/v2/internal/util"
+)
+
+// A jump is a location recorded in the jump list
+type jump struct {
+	buf  *buffer.Buffer
+	path string
+	loc  buffer.Loc
+}
+
+// maxJumps is the number of locations kept in the jump list
+const maxJumps = 100
+
+// The jump list records the locations the cursor jumped away from with
+// large movements (searches, gotos, buffer switches...). It is shared by
+// all panes so that JumpBack can go back to another buffer. jumpIdx is the
+// position in the list while navigating it and is len(jumpList) otherwise
+var (
+	jumpList []jump
+	jumpIdx  int
+	// set while navigating the jump list so that the buffer switches
+	// done by the navigation are not recorded
+	jumping bool
+)
+
+func (j jump) sameLine(o jump) bool {
+	if j.loc.Y != o.loc.Y {
+		return false
+	}
+	return j.buf == o.buf || (j.path != "" && j.path == o.path)
+}
+
+// PushJump records the current cursor location in the jump list. It is
+// done before any large cursor movement and can be used by plugins which
+// move the cursor far away, for example when jumping to a definition
+func (h *BufPane) PushJump() {
+	h.pushJump()
+}
+
+// pushJump records the current cursor location and returns false if the
+// location could not be recorded
+func (h *BufPane) pushJump() bool {
+	return h.pushJumpAt(h.Buf.GetActiveCursor().Loc)
+}
+
+func (h *BufPane) pushJumpAt(loc buffer.Loc) bool {
+	if jumping || h.Buf.Type == buffer.BTInfo || h.Buf.Type == buffer.BTRaw || h.Buf.Type == buffer.BTLog || h.Buf.Type == buffer.BTList {
+		return false
+	}
+
+	j := jump{h.Buf, h.Buf.AbsPath, loc}
+
+	jumpList = jumpList[:util.Min(jumpIdx, len(jumpList))]
+	if n := len(jumpList); n > 0 && jumpList[n-1].sameLine(j) {
+		jumpList = jumpList[:n-1]
+	}
+	jumpList = append(jumpList, j)
+	if len(jumpList) > maxJumps {
+		jumpList = jumpList[len(jumpList)-maxJumps:]
+	}
+	jumpIdx = len(jumpList)
+	return true
+}
+
+// JumpBack goes back to the previous location in the jump list
+func (h *BufPane) JumpBack() bool {
+	if jumpIdx >= len(jumpList) && h.pushJump() {
+		// remember where we are so that JumpForward can come back here
+		jumpIdx = len(jumpList) - 1
+	}
+	for jumpIdx > 0 {
+		jumpIdx--
+		if h.gotoJump(jumpList[jumpIdx]) {
+			return true
+		}
+	}
+	InfoBar.Message("Already at the oldest location")
+	return false
+}
+
+// JumpForward goes forward to the next location in the jump list
+func (h *BufPane) JumpForward() bool {
+	for jumpIdx < len(jumpList)-1 {
+		jumpIdx++
+		if h.gotoJump(jumpList[jumpIdx]) {
+			return true
+		}
+	}
+	InfoBar.Message("Already at the newest location")
+	return false
+}
+
+func (h *BufPane) gotoJump(j jump) bool {
+	jumping = true
+	defer func() { jumping = false }()
+
+	return h.GotoBufLoc(j.buf, j.path, j.loc) != nil
+}
+
+// findBufPane returns the indices of the tab and the pane showing the
+// given buffer, or the file at path if it is not empty
+func findBufPane(b *buffer.Buffer, path string) (int, int, *BufPane) {
+	for i, t := range Tabs.List {
+		for j, p := range t.Panes {
+			bp, ok := p.(*BufPane)
+			if !ok {
+				continue
+			}
+			if bp.Buf == b || (path != "" && bp.Buf.AbsPath == path) {
+				return i, j, bp
+			}
+		}
+	}
+	return -1, -1, nil
+}
+
+// GotoBufLoc moves the cursor to loc in the given buffer, switching to the
+// pane that shows it. If no pane shows the buffer any longer, the file at
+// path is opened in the current pane. The pane showing the location is
+// returned, or nil if the location could not be reached
+func (h *BufPane) GotoBufLoc(b *buffer.Buffer, path string, loc buffer.Loc) *BufPane {
+	ti, pi, bp := findBufPane(b, path)
+	if bp == nil {
+		if path == "" {
+			return nil
+		}
+		nb, err := buffer.NewBufferFromFile(path, buffer.BTDefault)
+		if err != nil {
+			InfoBar.Error(err)
+			return nil
+		}
+		if h.Buf.Modified() && !h.Buf.Shared() {
+			h.VSplitBuf(nb)
+		} else {
+			h.OpenBuffer(nb)
+		}
+		ti, pi, bp = findBufPane(nb, path)
+		if bp == nil {
+			return nil
+		}
+	}
+
+	if ti != Tabs.Active() {
+		Tabs.SetActive(ti)
+	}
+	if t := Tabs.List[ti]; t.active != pi {
+		t.SetActive(pi)
+	}
+
+	loc.Y = util.Clamp(loc.Y, 0, bp.Buf.LinesNum()-1)
+	loc.X = util.Clamp(loc.X, 0, util.CharacterCount(bp.Buf.LineBytes(loc.Y)))
+
+	bp.RemoveAllMultiCursors()
+	bp.Cursor.Deselect(true)
+	bp.GotoLoc(loc)
+	return bp
+}
@@ -0,0 +1,115 @@
+package action
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/helmutkemper/micro/v2/internal/buffer"
+)
+
+// A picker lets the user choose one entry from a list using the infobar.
+// An entry can be chosen by typing its number, its full label or any part
+// of its label that matches a single entry. Tab cycles through the entries
+// matching what has been typed so far.
+type picker struct {
+	ptype  string
+	labels []string
+}
+
+// curPicker is the picker that is currently shown in the infobar, if any
+var curPicker *picker
+
+// Pick opens a picker prompt listing the given labels. The preview callback
+// (which may be nil) is called with the index of the entry matching the
+// current input whenever it changes, or -1 if nothing matches. The done
+// callback is called with the index of the chosen entry, or with canceled
+// set to true if the prompt was canceled.
+func (h *BufPane) Pick(prompt, ptype string, labels []string, preview func(i int), done func(i int, canceled bool)) {
+	p := &picker{
+		ptype:  ptype,
+		labels: labels,
+	}
+
+	last := -1
+	var eventCallback func(resp string)
+	if preview != nil {
+		eventCallback = func(resp string) {
+			if i := p.match(resp); i != last {
+				last = i
+				preview(i)
+			}
+		}
+	}
+
+	InfoBar.Prompt(prompt, "", ptype, eventCallback, func(resp string, canceled bool) {
+		curPicker = nil
+		if canceled {
+			done(-1, true)
+			return
+		}
+		i := p.match(resp)
+		if i < 0 {
+			InfoBar.Error("No match for ", resp)
+			done(-1, true)
+			return
+		}
+		done(i, false)
+	})
+	curPicker = p
+
+	// show every entry until the user starts typing
+	b := InfoBar.Buf
+	b.Suggestions = labels
+	b.Completions = labels
+	b.CurSuggestion = -1
+	b.HasSuggestions = len(labels) > 1
+}
+
+// match returns the index of the entry selected by resp, or -1 if resp does
+// not select exactly one entry
+func (p *picker) match(resp string) int {
+	resp = strings.TrimSpace(resp)
+	if resp == "" {
+		return -1
+	}
+	for i, l := range p.labels {
+		if l == resp {
+			return i
+		}
+	}
+	if n, err := strconv.Atoi(resp); err == nil && n >= 1 && n <= len(p.labels) {
+		return n - 1
+	}
+
+	matches := p.filter(resp)
+	if len(matches) == 1 {
+		return matches[0]
+	}
+	return -1
+}
+
+// filter returns the indices of the entries whose label contains input,
+// ignoring case
+func (p *picker) filter(input string) []int {
+	input = strings.ToLower(input)
+	var matches []int
+	for i, l := range p.labels {
+		if strings.Contains(strings.ToLower(l), input) {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// complete is the completer used for the picker prompt. Completions replace
+// the whole input since entries are matched anywhere in their label
+func (p *picker) complete(b *buffer.Buffer) ([]string, []string) {
+	var suggestions []string
+	for _, i := range p.filter(string(b.LineBytes(0))) {
+		suggestions = append(suggestions, p.labels[i])
+	}
+	if len(suggestions) > 0 {
+		b.Replace(b.Start(), b.End(), "")
+	}
+	return suggestions, suggestions
+}
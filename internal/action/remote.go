@@ -0,0 +1,80 @@
+package action
+
+import (
+	"errors"
+
+	"github.com/helmutkemper/micro/v2/internal/buffer"
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/remote"
+	"github.com/helmutkemper/micro/v2/internal/screen"
+)
+
+// HandleRemote opens the files of a call of a remote client, in new tabs or
+// splits, and runs its commands in the current pane. The call is answered
+// with the first error, and if it waits, once all its buffers are closed.
+// No file is shown unless all of them could be opened
+func HandleRemote(c *remote.Call) {
+	switch c.Split {
+	case "", "tab", "hsplit", "vsplit":
+	default:
+		c.Reply(errors.New("Invalid split " + c.Split))
+		return
+	}
+
+	var bufs []*buffer.Buffer
+	for _, f := range c.Files {
+		cmd := buffer.Command{StartCursor: buffer.Loc{X: -1, Y: -1}}
+		if f.Line > 0 {
+			cmd.StartCursor = buffer.Loc{X: max(f.Col-1, 0), Y: f.Line - 1}
+		}
+		b, err := buffer.NewBufferFromFileWithCommand(f.Path, buffer.BTDefault, cmd)
+		if err != nil {
+			for _, b := range bufs {
+				b.Close()
+			}
+			c.Reply(err)
+			return
+		}
+		bufs = append(bufs, b)
+	}
+	for _, b := range bufs {
+		openRemoteBuffer(b, c.Split)
+	}
+
+	for _, cmd := range c.Commands {
+		if h := MainTab().CurPane(); h != nil {
+			h.HandleCommand(cmd)
+		}
+	}
+	screen.Redraw()
+
+	c.Reply(nil)
+	if !c.Wait {
+		return
+	}
+	go func() {
+		for _, b := range bufs {
+			<-b.Closed()
+		}
+		c.Done()
+	}()
+}
+
+// openRemoteBuffer opens a buffer in a new tab, or in a split of the current
+// pane
+func openRemoteBuffer(b *buffer.Buffer, split string) {
+	if h := MainTab().CurPane(); h != nil {
+		switch split {
+		case "hsplit":
+			h.HSplitBuf(b)
+			return
+		case "vsplit":
+			h.VSplitBuf(b)
+			return
+		}
+	}
+	width, height := screen.Screen.Size()
+	iOffset := config.GetInfoBarOffset()
+	Tabs.AddTab(NewTabFromBuffer(0, 0, width, height-1-iOffset, b))
+	Tabs.SetActive(len(Tabs.List) - 1)
+}
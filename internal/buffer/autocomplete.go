@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/helmutkemper/micro/v2/internal/util"
+	"github.com/helmutkemper/micro/v2/internal/vfs"
 )
 
 // A Completer is a function that takes a buffer and returns info
@@ -115,9 +116,9 @@ func FileComplete(b *Buffer) ([]string, []string) {
 		directories := strings.Join(dirs[:len(dirs)-1], sep) + sep
 
 		directories, _ = util.ReplaceHome(directories)
-		files, err = os.ReadDir(directories)
+		files, err = vfs.ReadDir(directories)
 	} else {
-		files, err = os.ReadDir(".")
+		files, err = vfs.ReadDir(".")
 	}
 
 	if err != nil {
@@ -150,7 +151,8 @@ func FileComplete(b *Buffer) ([]string, []string) {
 	return completions, suggestions
 }
 
-// BufferComplete autocompletes based on previous words in the buffer
+// BufferComplete autocompletes based on the snippet prefixes of the
+// buffer's filetype and the previous words in the buffer
 func BufferComplete(b *Buffer) ([]string, []string) {
 	c := b.GetActiveCursor()
 	input, argstart := b.GetWord()
@@ -163,7 +165,16 @@ func BufferComplete(b *Buffer) ([]string, []string) {
 
 	suggestionsSet := make(map[string]struct{})
 
+	// snippet prefixes come first
 	var suggestions []string
+	for _, s := range b.Snippets() {
+		if strings.HasPrefix(s.Prefix, string(input)) && util.CharacterCountInString(s.Prefix) > inputLen {
+			if _, ok := suggestionsSet[s.Prefix]; !ok {
+				suggestionsSet[s.Prefix] = struct{}{}
+				suggestions = append(suggestions, s.Prefix)
+			}
+		}
+	}
 	for i := c.Y; i >= 0; i-- {
 		l := b.LineBytes(i)
 		words := bytes.FieldsFunc(l, util.IsNonWordChar)
@@ -1,6 +1,7 @@
 package buffer
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -81,7 +82,13 @@ func periodicBackup() {
 }
 
 func (b *SharedBuffer) backupDir() string {
-	backupdir, err := util.ReplaceHome(b.Settings["backupdir"].(string))
+	return backupDirFrom(b.Settings["backupdir"].(string))
+}
+
+// backupDirFrom returns the directory of the backups for the given value of
+// the backupdir option
+func backupDirFrom(option string) string {
+	backupdir, err := util.ReplaceHome(option)
 	if backupdir == "" || err != nil {
 		backupdir = filepath.Join(config.ConfigDir, "backups")
 	}
@@ -93,6 +100,15 @@ func (b *SharedBuffer) keepBackup() bool {
 }
 
 func (b *SharedBuffer) writeBackup(path string) (string, string, error) {
+	return b.writeBackupWith(path, func(name string) error {
+		_, err := b.overwriteFile(name)
+		return err
+	})
+}
+
+// writeBackupWith writes the backup of the file at path with the given
+// function, which writes the contents of the backup to the file name
+func (b *SharedBuffer) writeBackupWith(path string, write func(name string) error) (string, string, error) {
 	backupdir := b.backupDir()
 	if _, err := os.Stat(backupdir); err != nil {
 		if !errors.Is(err, fs.ErrNotExist) {
@@ -106,7 +122,7 @@ func (b *SharedBuffer) writeBackup(path string) (string, string, error) {
 	name, resolveName := util.DetermineEscapePath(backupdir, path)
 	tmp := name + util.BackupSuffix
 
-	_, err := b.overwriteFile(tmp)
+	err := write(tmp)
 	if err != nil {
 		os.Remove(tmp)
 		return name, resolveName, err
@@ -159,16 +175,15 @@ func (b *SharedBuffer) ApplyBackup(fsize int64) (bool, bool) {
 	if b.Settings["backup"].(bool) && !b.Settings["permbackup"].(bool) && len(b.Path) > 0 && b.Type == BTDefault {
 		backupfile, resolveName := util.DetermineEscapePath(b.backupDir(), b.AbsPath)
 		if info, err := os.Stat(backupfile); err == nil {
-			backup, err := os.Open(backupfile)
+			backup, err := b.readEncrypted(backupfile)
 			if err == nil {
-				defer backup.Close()
 				t := info.ModTime()
 				msg := fmt.Sprintf(BackupMsg, b.Path, t.Format("Mon Jan _2 at 15:04, 2006"), backupfile)
 				choice := screen.TermPrompt(msg, []string{"r", "i", "a", "recover", "ignore", "abort"}, true)
 
 				if choice%3 == 0 {
 					// recover
-					b.LineArray = NewLineArray(uint64(fsize), FFAuto, backup)
+					b.LineArray = NewLineArray(uint64(len(backup)), FFAuto, bytes.NewReader(backup))
 					b.setModified()
 					return true, true
 				} else if choice%3 == 1 {
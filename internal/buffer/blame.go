@@ -0,0 +1,69 @@
+package buffer
+
+import (
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/git"
+	"github.com/helmutkemper/micro/v2/internal/screen"
+)
+
+// invalidateBlame makes the blame of the buffer be computed again the next
+// time it is shown
+func (b *SharedBuffer) invalidateBlame() {
+	b.blameLock.Lock()
+	b.blameStale = true
+	b.blameLock.Unlock()
+}
+
+// Repo returns the git repository of the file of the buffer
+func (b *Buffer) Repo() (*git.Repo, error) {
+	if b.Path == "" || b.Type.Kind != BTDefault.Kind {
+		return nil, git.ErrNotRepository
+	}
+	return git.Open(b.AbsPath)
+}
+
+// Blame returns the commit which last changed a line when the blame option
+// is on, or nil. The blame is computed in the background, with the text of
+// the buffer, after it was opened, modified or saved
+func (b *Buffer) Blame(lineN int) *git.Commit {
+	if b.Settings["blame"].(string) == "off" {
+		return nil
+	}
+
+	b.blameLock.Lock()
+	defer b.blameLock.Unlock()
+	if b.blameStale && b.updateBlameTimer == nil {
+		b.updateBlameTimer = time.AfterFunc(500*time.Millisecond, b.updateBlame)
+	}
+	if lineN < 0 || lineN >= len(b.blame) {
+		return nil
+	}
+	return b.blame[lineN]
+}
+
+func (b *Buffer) updateBlame() {
+	b.blameLock.Lock()
+	b.blameStale = false
+	b.blameLock.Unlock()
+
+	var blame []*git.Commit
+	if r, err := b.Repo(); err == nil {
+		b.Lock()
+		var bytes []byte
+		// Don't blame very large files
+		if b.LinesNum() < 30000 {
+			bytes = b.Bytes()
+		}
+		b.Unlock()
+		if bytes != nil {
+			blame, _ = r.Blame(b.AbsPath, bytes)
+		}
+	}
+
+	b.blameLock.Lock()
+	b.blame = blame
+	b.updateBlameTimer = nil
+	b.blameLock.Unlock()
+	screen.Redraw()
+}
@@ -0,0 +1,50 @@
+package buffer
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlame(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir,
+			"-c", "user.name=Jane Doe", "-c", "user.email=jane@example.com",
+			"-c", "commit.gpgsign=false"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(err, string(out))
+		}
+	}
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "First")
+
+	b, err := NewBufferFromFile(path, BTDefault)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer b.Close()
+
+	assert.Nil(t, b.Blame(0))
+	b.SetOptionNative("blame", "gutter")
+	b.Insert(Loc{X: 0, Y: 1}, "new\n")
+	b.updateBlame()
+	if c := b.Blame(0); assert.NotNil(t, c) {
+		assert.Equal(t, "First", c.Summary)
+	}
+	if c := b.Blame(1); assert.NotNil(t, c) {
+		assert.True(t, c.Uncommitted())
+	}
+	assert.Nil(t, b.Blame(3))
+}
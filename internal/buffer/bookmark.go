@@ -0,0 +1,138 @@
+package buffer
+
+import "sort"
+
+// A Bookmark marks a location in a buffer. Bookmarks follow the text they
+// point to when the buffer is edited. Anonymous bookmarks have no name and
+// mark a whole line.
+type Bookmark struct {
+	Name string
+	Loc  Loc
+}
+
+// AddBookmark adds a bookmark at the given location. A named bookmark
+// replaces any other bookmark with the same name
+func (b *Buffer) AddBookmark(name string, loc Loc) *Bookmark {
+	loc = loc.Clamp(b.Start(), b.End())
+	if name != "" {
+		if bm := b.BookmarkByName(name); bm != nil {
+			bm.Loc = loc
+			b.sortBookmarks()
+			return bm
+		}
+	} else {
+		for _, bm := range b.Bookmarks {
+			if bm.Name == "" && bm.Loc.Y == loc.Y {
+				return bm
+			}
+		}
+	}
+
+	bm := &Bookmark{name, loc}
+	b.Bookmarks = append(b.Bookmarks, bm)
+	b.sortBookmarks()
+	return bm
+}
+
+// RemoveBookmark removes the given bookmark from the buffer
+func (b *Buffer) RemoveBookmark(bm *Bookmark) {
+	for i, m := range b.Bookmarks {
+		if m == bm {
+			b.Bookmarks = append(b.Bookmarks[:i], b.Bookmarks[i+1:]...)
+			return
+		}
+	}
+}
+
+// ClearBookmarks removes all bookmarks from the buffer
+func (b *Buffer) ClearBookmarks() {
+	b.Bookmarks = nil
+}
+
+// BookmarkByName returns the bookmark with the given name or nil
+// if there is none
+func (b *Buffer) BookmarkByName(name string) *Bookmark {
+	for _, bm := range b.Bookmarks {
+		if bm.Name == name {
+			return bm
+		}
+	}
+	return nil
+}
+
+// BookmarkAtLine returns the first bookmark on the given line or nil
+// if there is none
+func (b *Buffer) BookmarkAtLine(line int) *Bookmark {
+	for _, bm := range b.Bookmarks {
+		if bm.Loc.Y == line {
+			return bm
+		}
+	}
+	return nil
+}
+
+// ToggleBookmark removes every bookmark on the given line, or adds an
+// anonymous bookmark if there was none. It returns true if a bookmark
+// was added
+func (b *Buffer) ToggleBookmark(line int) bool {
+	found := false
+	for i := 0; i < len(b.Bookmarks); i++ {
+		if b.Bookmarks[i].Loc.Y == line {
+			b.Bookmarks = append(b.Bookmarks[:i], b.Bookmarks[i+1:]...)
+			i--
+			found = true
+		}
+	}
+	if found {
+		return false
+	}
+	b.AddBookmark("", Loc{0, line})
+	return true
+}
+
+// NextBookmark returns the closest bookmark after (or before if forward is
+// false) the given line, wrapping around the buffer
+func (b *Buffer) NextBookmark(line int, forward bool) *Bookmark {
+	n := len(b.Bookmarks)
+	if n == 0 {
+		return nil
+	}
+	if forward {
+		for _, bm := range b.Bookmarks {
+			if bm.Loc.Y > line {
+				return bm
+			}
+		}
+		return b.Bookmarks[0]
+	}
+	for i := n - 1; i >= 0; i-- {
+		if b.Bookmarks[i].Loc.Y < line {
+			return b.Bookmarks[i]
+		}
+	}
+	return b.Bookmarks[n-1]
+}
+
+func (b *SharedBuffer) sortBookmarks() {
+	sort.SliceStable(b.Bookmarks, func(i, j int) bool {
+		return b.Bookmarks[i].Loc.LessThan(b.Bookmarks[j].Loc)
+	})
+}
+
+// shiftBookmarksInsert moves the bookmarks located after pos so that they
+// keep pointing to the same text after the text ending at end has been
+// inserted at pos
+func (b *SharedBuffer) shiftBookmarksInsert(pos, end Loc) {
+	for _, bm := range b.Bookmarks {
+		bm.Loc = bm.Loc.shiftInsert(pos, end, false)
+	}
+}
+
+// shiftBookmarksRemove moves the bookmarks located after start so that they
+// keep pointing to the same text after the range start-end has been removed.
+// Bookmarks inside the removed range collapse to start
+func (b *SharedBuffer) shiftBookmarksRemove(start, end Loc) {
+	for _, bm := range b.Bookmarks {
+		bm.Loc = bm.Loc.shiftRemove(start, end)
+	}
+}
@@ -0,0 +1,87 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBookmarksFollowEdits(t *testing.T) {
+	b := NewBufferFromString("one\ntwo\nthree\nfour", "", BTDefault)
+
+	assert.True(t, b.ToggleBookmark(2))
+	named := b.AddBookmark("x", Loc{2, 1})
+
+	// inserting lines above moves the bookmarks down
+	b.Insert(Loc{0, 0}, "zero\n")
+	assert.NotNil(t, b.BookmarkAtLine(3))
+	assert.Equal(t, Loc{2, 2}, named.Loc)
+
+	// inserting text before a bookmark on the same line shifts it right
+	b.Insert(Loc{0, 2}, "--")
+	assert.Equal(t, Loc{4, 2}, named.Loc)
+
+	// removing the lines above moves the bookmarks up
+	b.Remove(Loc{0, 0}, Loc{0, 2})
+	assert.NotNil(t, b.BookmarkAtLine(1))
+	assert.Equal(t, Loc{4, 0}, named.Loc)
+
+	// a bookmark inside a removed range collapses to its start
+	b.Remove(Loc{1, 0}, Loc{2, 1})
+	assert.Equal(t, Loc{1, 0}, named.Loc)
+
+	assert.Equal(t, named, b.BookmarkByName("x"))
+
+	// toggling a line with bookmarks removes all of them
+	assert.False(t, b.ToggleBookmark(0))
+	assert.Nil(t, b.BookmarkAtLine(0))
+}
+
+func TestNextBookmark(t *testing.T) {
+	b := NewBufferFromString("a\nb\nc\nd\ne", "", BTDefault)
+
+	assert.Nil(t, b.NextBookmark(0, true))
+
+	b.ToggleBookmark(1)
+	b.ToggleBookmark(3)
+
+	assert.Equal(t, 1, b.NextBookmark(0, true).Loc.Y)
+	assert.Equal(t, 3, b.NextBookmark(1, true).Loc.Y)
+	assert.Equal(t, 1, b.NextBookmark(3, true).Loc.Y)
+	assert.Equal(t, 3, b.NextBookmark(0, false).Loc.Y)
+	assert.Equal(t, 1, b.NextBookmark(3, false).Loc.Y)
+}
+
+func TestSavedBookmarks(t *testing.T) {
+	assert.NoError(t, os.MkdirAll(filepath.Join(config.ConfigDir, "buffers"), 0755))
+	path := filepath.Join(t.TempDir(), "file.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644))
+	open := func() *Buffer {
+		b, err := NewBufferFromFile(path, BTDefault)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	b := open()
+	b.ToggleBookmark(2)
+	assert.NoError(t, b.Serialize())
+	b.Close()
+
+	b = open()
+	assert.NotNil(t, b.BookmarkAtLine(2))
+	b.Close()
+
+	// the file was modified by another program
+	assert.NoError(t, os.WriteFile(path, []byte("zero\none\ntwo\nthree\n"), 0644))
+	later := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(path, later, later))
+	b = open()
+	defer b.Close()
+	assert.Empty(t, b.Bookmarks)
+}
@@ -17,10 +17,13 @@ import (
 
 	luar "layeh.com/gopher-luar"
 
+	"github.com/helmutkemper/micro/v2/internal/clipboard"
 	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/git"
 	ulua "github.com/helmutkemper/micro/v2/internal/lua"
 	"github.com/helmutkemper/micro/v2/internal/screen"
 	"github.com/helmutkemper/micro/v2/internal/util"
+	"github.com/helmutkemper/micro/v2/internal/vfs"
 	"github.com/helmutkemper/micro/v2/pkg/highlight"
 	dmp "github.com/sergi/go-diff/diffmatchpatch"
 	"golang.org/x/text/encoding"
@@ -61,6 +64,11 @@ var (
 	// BTStdout is a buffer that only writes to stdout
 	// when closed
 	BTStdout = BufType{6, false, true, true}
+	// BTRevision is a buffer showing a past revision of a file
+	BTRevision = BufType{7, true, true, true}
+	// BTList is a buffer listing entries chosen with keys in a pane, such
+	// as the changed files of a git repository
+	BTList = BufType{8, true, true, false}
 )
 
 // SharedBuffer is a struct containing info that is shared among buffers
@@ -93,6 +101,10 @@ type SharedBuffer struct {
 	CurSuggestion int
 
 	Messages []*Message
+	// Bookmarks are kept sorted by location and follow edits
+	Bookmarks []*Bookmark
+	// snippetSessions is the stack of snippets being edited
+	snippetSessions []*snippetSession
 
 	updateDiffTimer   *time.Timer
 	diffBase          []byte
@@ -100,8 +112,20 @@ type SharedBuffer struct {
 	diffLock          sync.RWMutex
 	diff              map[int]DiffStatus
 
+	updateBlameTimer *time.Timer
+	blameLock        sync.Mutex
+	// blame is the commit of each line, computed again when blameStale is
+	// set
+	blame      []*git.Commit
+	blameStale bool
+
 	forceKeepBackup bool
 
+	// encryption is the passphrase of an encrypted buffer, which is never
+	// written in plain text to its file, its backups and its serialized
+	// undo history
+	encryption *encryption
+
 	// ReloadDisabled allows the user to disable reloads if they
 	// are viewing a file that is constantly changing
 	ReloadDisabled bool
@@ -113,11 +137,20 @@ type SharedBuffer struct {
 
 	// The Highlighter struct actually performs the highlighting
 	Highlighter *highlight.Highlighter
+	// hlWorker highlights the buffer in the background
+	hlWorker *highlightWorker
 	// SyntaxDef represents the syntax highlighting definition being used
 	// This stores the highlighting rules and filetype detection info
 	SyntaxDef *highlight.Def
 
 	ModifiedThisFrame bool
+	// edits counts the changes of the text, so that the values computed
+	// from the text can be cached until it changes
+	edits int
+
+	// table is the table of the buffer in table mode, whose records are
+	// parsed again from the first one modified
+	table *Table
 
 	// Hash of the original buffer -- empty if fastdirty is on
 	origHash [md5.Size]byte
@@ -125,7 +158,11 @@ type SharedBuffer struct {
 
 func (b *SharedBuffer) insert(pos Loc, value []byte) {
 	b.HasSuggestions = false
+	end := textEnd(pos, value)
+	b.shiftHighlightInsert(pos, end)
 	b.LineArray.insert(pos, value)
+	b.shiftBookmarksInsert(pos, end)
+	b.shiftSnippetsInsert(pos, end)
 	b.setModified()
 
 	inslines := bytes.Count(value, []byte{'\n'})
@@ -136,6 +173,9 @@ func (b *SharedBuffer) remove(start, end Loc) []byte {
 	b.HasSuggestions = false
 	defer b.setModified()
 	defer b.MarkModified(start.Y, end.Y)
+	b.shiftHighlightRemove(start, end)
+	b.shiftBookmarksRemove(start, end)
+	b.shiftSnippetsRemove(start, end)
 	return b.LineArray.remove(start, end)
 }
 
@@ -167,13 +207,13 @@ func (b *SharedBuffer) calcHash(out *[md5.Size]byte) {
 	if len(b.lines) > 0 {
 		h.Write(b.lines[0].data)
 
-		for _, l := range b.lines[1:] {
+		for i := 1; i < len(b.lines); i++ {
 			if b.Endings == FFDos {
 				h.Write([]byte{'\r', '\n'})
 			} else {
 				h.Write([]byte{'\n'})
 			}
-			h.Write(l.data)
+			h.Write(b.lines[i].data)
 		}
 	}
 
@@ -181,19 +221,20 @@ func (b *SharedBuffer) calcHash(out *[md5.Size]byte) {
 }
 
 // MarkModified marks the buffer as modified for this frame
-// and performs rehighlighting if syntax highlighting is enabled
+// and schedules rehighlighting if syntax highlighting is enabled
 func (b *SharedBuffer) MarkModified(start, end int) {
 	b.ModifiedThisFrame = true
+	b.edits++
+	if b.table != nil {
+		b.table.modify(start, end, len(b.lines))
+	}
+	b.invalidateBlame()
 
 	start = util.Clamp(start, 0, len(b.lines)-1)
 	end = util.Clamp(end, 0, len(b.lines)-1)
 
-	if b.Settings["syntax"].(bool) && b.SyntaxDef != nil {
-		l := -1
-		for i := start; i <= end; i++ {
-			l = util.Max(b.Highlighter.ReHighlightStates(b, i), l)
-		}
-		b.Highlighter.HighlightMatches(b, start, l)
+	if b.hlWorker != nil {
+		b.hlWorker.invalidate(start, end)
 	}
 
 	for i := start; i <= end; i++ {
@@ -261,11 +302,16 @@ type Buffer struct {
 	LastSearchRegex bool
 	// HighlightSearch enables highlighting all instances of the last successful search
 	HighlightSearch bool
+	// searchCount caches the number of matches of the last search
+	searchCount searchCount
 
 	// OverwriteMode indicates that we are in overwrite mode (toggled by
 	// Insert key by default) i.e. that typing a character shall replace the
 	// character under the cursor instead of inserting a character before it.
 	OverwriteMode bool
+
+	// closed is closed when the buffer is closed
+	closed chan struct{}
 }
 
 // NewBufferFromFileWithCommand opens a new buffer with a given command
@@ -289,7 +335,7 @@ func NewBufferFromFileWithCommand(path string, btype BufType, cmd Command) (*Buf
 		return nil, err
 	}
 
-	fileInfo, serr := os.Stat(filename)
+	fileInfo, serr := vfs.Stat(filename)
 	if serr != nil && !errors.Is(serr, fs.ErrNotExist) {
 		return nil, serr
 	}
@@ -300,11 +346,11 @@ func NewBufferFromFileWithCommand(path string, btype BufType, cmd Command) (*Buf
 		return nil, errors.New("Error: " + filename + " is not a regular file and cannot be opened")
 	}
 
-	f, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	f, err := os.OpenFile(vfs.HostPath(filename), os.O_WRONLY, 0)
 	readonly := errors.Is(err, fs.ErrPermission)
 	f.Close()
 
-	file, err := os.Open(filename)
+	file, size, err := vfs.Open(filename)
 	if err == nil {
 		defer file.Close()
 	}
@@ -316,7 +362,21 @@ func NewBufferFromFileWithCommand(path string, btype BufType, cmd Command) (*Buf
 	} else if err != nil {
 		return nil, err
 	} else {
-		buf = NewBuffer(file, util.FSize(file), filename, btype, cmd)
+		var e *encryption
+		br := bufio.NewReader(file)
+		var r io.Reader = br
+		if head, _ := br.Peek(len(encryptedMagic)); isEncrypted(head) && findOpenBuffer(filename) == nil {
+			data, err := io.ReadAll(br)
+			if err != nil {
+				return nil, err
+			}
+			data, e, err = decryptFile(filename, data)
+			if err != nil {
+				return nil, err
+			}
+			r, size = bytes.NewReader(data), int64(len(data))
+		}
+		buf = newBuffer(r, size, filename, btype, cmd, e)
 		if buf == nil {
 			return nil, errors.New("could not open file")
 		}
@@ -355,27 +415,47 @@ func NewBufferFromString(text, path string, btype BufType) *Buffer {
 // Places the cursor at startcursor. If startcursor is -1, -1 places the
 // cursor at an autodetected location (based on savecursor or :LINE:COL)
 func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command) *Buffer {
-	absPath, err := filepath.Abs(path)
+	return newBuffer(r, size, path, btype, cmd, nil)
+}
+
+// findOpenBuffer returns the open buffer of a file, or nil
+func findOpenBuffer(path string) *Buffer {
+	absPath, err := vfs.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	var found *Buffer
+	for _, buf := range OpenBuffers {
+		if buf.AbsPath == absPath && buf.Type != BTInfo {
+			found = buf
+		}
+	}
+	return found
+}
+
+// newBuffer is like NewBuffer, for a file encrypted with e if e is not nil
+func newBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command, e *encryption) *Buffer {
+	absPath, err := vfs.Abs(path)
 	if err != nil {
 		absPath = path
 	}
 
 	b := new(Buffer)
+	b.closed = make(chan struct{})
 
 	found := false
 	if len(path) > 0 {
-		for _, buf := range OpenBuffers {
-			if buf.AbsPath == absPath && buf.Type != BTInfo {
-				found = true
-				b.SharedBuffer = buf.SharedBuffer
-				b.EventHandler = buf.EventHandler
-			}
+		if buf := findOpenBuffer(path); buf != nil {
+			found = true
+			b.SharedBuffer = buf.SharedBuffer
+			b.EventHandler = buf.EventHandler
 		}
 	}
 
 	hasBackup := false
 	if !found {
 		b.SharedBuffer = new(SharedBuffer)
+		b.blameStale = true
 		b.Type = btype
 
 		b.AbsPath = absPath
@@ -390,6 +470,10 @@ func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command)
 			}
 		}
 		config.UpdatePathGlobLocals(b.Settings, absPath)
+		if e != nil {
+			b.encryption = e
+			clipboard.SetPrivate(b.GetName(), true)
+		}
 
 		b.encoding, err = htmlindex.Get(b.Settings["encoding"].(string))
 		if err != nil {
@@ -449,14 +533,15 @@ func NewBuffer(r io.Reader, size int64, path string, btype BufType, cmd Command)
 		os.Mkdir(filepath.Join(config.ConfigDir, "buffers"), os.ModePerm)
 	}
 
-	if cmd.StartCursor.X != -1 && cmd.StartCursor.Y != -1 {
-		b.StartCursor = cmd.StartCursor
-	} else if b.Settings["savecursor"].(bool) || b.Settings["saveundo"].(bool) {
+	if b.Settings["savecursor"].(bool) || b.Settings["saveundo"].(bool) || b.Settings["savebookmarks"].(bool) {
 		err := b.Unserialize()
 		if err != nil {
 			screen.TermMessage(err)
 		}
 	}
+	if cmd.StartCursor.X != -1 && cmd.StartCursor.Y != -1 {
+		b.StartCursor = cmd.StartCursor
+	}
 
 	b.AddCursor(NewCursor(b, b.StartCursor))
 	b.GetActiveCursor().Relocate()
@@ -516,11 +601,17 @@ func (b *Buffer) Close() {
 			copy(OpenBuffers[i:], OpenBuffers[i+1:])
 			OpenBuffers[len(OpenBuffers)-1] = nil
 			OpenBuffers = OpenBuffers[:len(OpenBuffers)-1]
+			close(b.closed)
 			return
 		}
 	}
 }
 
+// Closed returns a channel which is closed when the buffer is closed
+func (b *Buffer) Closed() <-chan struct{} {
+	return b.closed
+}
+
 // Fini should be called when a buffer is closed and performs
 // some cleanup
 func (b *Buffer) Fini() {
@@ -528,6 +619,9 @@ func (b *Buffer) Fini() {
 		b.Serialize()
 	}
 	b.CancelBackup()
+	if !b.Shared() {
+		b.stopHighlighting()
+	}
 
 	if b.Type == BTStdout {
 		fmt.Fprint(util.Stdout, string(b.Bytes()))
@@ -581,7 +675,8 @@ func (b *Buffer) FileType() string {
 // ExternallyModified returns whether the file being edited has
 // been modified by some external process
 func (b *Buffer) ExternallyModified() bool {
-	modTime, err := util.GetModTime(b.Path)
+	// it is checked for every event, which must not wait for remote hosts
+	modTime, err := vfs.ModTimeNoWait(b.Path)
 	if err == nil {
 		return modTime != b.ModTime
 	}
@@ -590,13 +685,13 @@ func (b *Buffer) ExternallyModified() bool {
 
 // UpdateModTime updates the modtime of this file
 func (b *Buffer) UpdateModTime() (err error) {
-	b.ModTime, err = util.GetModTime(b.Path)
+	b.ModTime, err = vfs.ModTime(b.Path)
 	return
 }
 
 // ReOpen reloads the current buffer from disk
 func (b *Buffer) ReOpen() error {
-	file, err := os.Open(b.Path)
+	file, _, err := vfs.Open(b.Path)
 	if err != nil {
 		return err
 	}
@@ -607,7 +702,25 @@ func (b *Buffer) ReOpen() error {
 		return err
 	}
 
-	reader := bufio.NewReader(transform.NewReader(file, enc.NewDecoder()))
+	br := bufio.NewReader(file)
+	var r io.Reader = br
+	if head, _ := br.Peek(len(encryptedMagic)); isEncrypted(head) {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return err
+		}
+		if b.encryption != nil {
+			data, err = b.encryption.open(data)
+		} else if data, b.encryption, err = decryptFile(b.Path, data); err == nil {
+			clipboard.SetPrivate(b.GetName(), true)
+		}
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	reader := bufio.NewReader(transform.NewReader(r, enc.NewDecoder()))
 	data, err := io.ReadAll(reader)
 	txt := string(data)
 
@@ -715,8 +828,18 @@ func (b *Buffer) Size() int {
 	return nb
 }
 
-func parseDefFromFile(f config.RuntimeFile, header *highlight.Header) *highlight.Def {
+// syntaxData returns the data of a syntax file, and shows the warnings of
+// its conversion if it was converted from a grammar
+func syntaxData(f config.RuntimeFile) ([]byte, error) {
 	data, err := f.Data()
+	if warnings := config.GrammarWarnings(f); len(warnings) > 0 {
+		screen.TermMessage("Warnings converting the grammar of syntax file " + f.Name() + ":\n" + strings.Join(warnings, "\n"))
+	}
+	return data, err
+}
+
+func parseDefFromFile(f config.RuntimeFile, header *highlight.Header) *highlight.Def {
+	data, err := syntaxData(f)
 	if err != nil {
 		screen.TermMessage("Error loading syntax file " + f.Name() + ": " + err.Error())
 		return nil
@@ -781,7 +904,7 @@ func resolveIncludes(syndef *highlight.Def) {
 
 	var files []*highlight.File
 	for _, f := range config.ListRuntimeFiles(config.RTSyntax) {
-		data, err := f.Data()
+		data, err := syntaxData(f)
 		if err != nil {
 			screen.TermMessage("Error loading syntax file " + f.Name() + ": " + err.Error())
 			continue
@@ -846,7 +969,7 @@ func (b *Buffer) UpdateRules() {
 			continue
 		}
 
-		data, err := f.Data()
+		data, err := syntaxData(f)
 		if err != nil {
 			screen.TermMessage("Error loading syntax file " + f.Name() + ": " + err.Error())
 			continue
@@ -863,7 +986,7 @@ func (b *Buffer) UpdateRules() {
 		matchedFileHeader := false
 
 		if ft == "unknown" || ft == "" {
-			if header.MatchFileName(b.Path) {
+			if header.MatchFileName(vfs.ContentName(b.Path)) {
 				matchedFileName = true
 			}
 			if len(fnameMatches) == 0 && header.MatchFileHeader(b.lines[0].data) {
@@ -904,7 +1027,7 @@ func (b *Buffer) UpdateRules() {
 	if !foundDef {
 		// search for the syntax file in the built-in syntax files
 		for _, f := range config.ListRuntimeFiles(config.RTSyntaxHeader) {
-			data, err := f.Data()
+			data, err := syntaxData(f)
 			if err != nil {
 				screen.TermMessage("Error loading syntax header file " + f.Name() + ": " + err.Error())
 				continue
@@ -917,7 +1040,7 @@ func (b *Buffer) UpdateRules() {
 			}
 
 			if ft == "unknown" || ft == "" {
-				if header.MatchFileName(b.Path) {
+				if header.MatchFileName(vfs.ContentName(b.Path)) {
 					fnameMatches = append(fnameMatches, syntaxFileInfo{header, f.Name(), nil})
 				}
 				if len(fnameMatches) == 0 && header.MatchFileHeader(b.lines[0].data) {
@@ -1000,18 +1123,16 @@ func (b *Buffer) UpdateRules() {
 
 	if b.SyntaxDef != nil {
 		b.Highlighter = highlight.NewHighlighter(b.SyntaxDef)
-		if b.Settings["syntax"].(bool) {
-			go func() {
-				b.Highlighter.HighlightStates(b)
-				b.Highlighter.HighlightMatches(b, 0, b.End().Y)
-				screen.Redraw()
-			}()
-		}
 	}
+	b.startHighlighting()
 }
 
 // ClearMatches clears all of the syntax highlighting for the buffer
 func (b *Buffer) ClearMatches() {
+	b.stopHighlighting()
+
+	b.Lock()
+	defer b.Unlock()
 	for i := range b.lines {
 		b.SetMatch(i, nil)
 		b.SetState(i, nil)
@@ -1462,6 +1583,54 @@ func (b *Buffer) SearchMatch(pos Loc) bool {
 	return b.LineArray.SearchMatch(b, pos)
 }
 
+// A searchCount is the number of matches of a search in each line of a
+// buffer, for the text of the buffer after the given number of edits
+type searchCount struct {
+	search     string
+	useRegex   bool
+	ignorecase bool
+	edits      int
+	// before[i] is the number of matches in the lines before line i
+	before []int
+}
+
+// SearchMatchIndex returns the number of matches of the last search in the
+// buffer, and the index, starting at 1, of the last match which starts at
+// or before `pos`, or 0 if there is none. The matches are only counted again
+// when the search or the text changes
+func (b *Buffer) SearchMatchIndex(pos Loc) (int, int) {
+	if b.LastSearch == "" {
+		return 0, 0
+	}
+	c := &b.searchCount
+	ignorecase := b.Settings["ignorecase"].(bool)
+	if c.before == nil || c.search != b.LastSearch || c.useRegex != b.LastSearchRegex ||
+		c.ignorecase != ignorecase || c.edits != b.edits {
+		*c = searchCount{
+			search:     b.LastSearch,
+			useRegex:   b.LastSearchRegex,
+			ignorecase: ignorecase,
+			edits:      b.edits,
+			before:     make([]int, b.LinesNum()+1),
+		}
+		for i := 0; i < b.LinesNum(); i++ {
+			c.before[i+1] = c.before[i] + len(b.LineArray.searchMatches(b, i))
+		}
+	}
+
+	total := c.before[len(c.before)-1]
+	if pos.Y < 0 || pos.Y >= b.LinesNum() {
+		return 0, total
+	}
+	index := c.before[pos.Y]
+	for _, m := range b.LineArray.searchMatches(b, pos.Y) {
+		if m[0] <= pos.X {
+			index++
+		}
+	}
+	return index, total
+}
+
 // WriteLog writes a string to the log buffer
 func WriteLog(s string) {
 	LogBuf.EventHandler.Insert(LogBuf.End(), s)
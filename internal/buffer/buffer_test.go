@@ -2,6 +2,7 @@ package buffer
 
 import (
 	"math/rand"
+	"os"
 	"strings"
 	"testing"
 
@@ -26,6 +27,19 @@ func init() {
 	config.GlobalSettings["fastdirty"] = true
 }
 
+// TestMain runs the tests with a temporary configuration directory, which
+// is set once since the backup goroutine reads it
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "micro-test")
+	if err != nil {
+		panic(err)
+	}
+	config.ConfigDir = dir
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func check(t *testing.T, before []string, operations []operation, after []string) {
 	assert := assert.New(t)
 
@@ -90,10 +90,7 @@ func (c *Cursor) GetVisualX(wrap bool) int {
 		return 0
 	}
 
-	bytes := c.buf.LineBytes(c.Y)
-	tabsize := int(c.buf.Settings["tabsize"].(float64))
-
-	return util.StringWidth(bytes, c.X, tabsize)
+	return c.buf.VisualWidth(c.Y, c.X)
 }
 
 // GetCharPosInLine gets the char position of a visual x y
@@ -146,7 +143,7 @@ func (c *Cursor) End() {
 func (c *Cursor) CopySelection(target clipboard.Register) {
 	if c.HasSelection() {
 		if target != clipboard.PrimaryReg || c.buf.Settings["useprimary"].(bool) {
-			clipboard.WriteMulti(string(c.GetSelection()), target, c.Num, c.buf.NumCursors())
+			clipboard.WriteMultiFrom(string(c.GetSelection()), c.buf.GetName(), target, c.Num, c.buf.NumCursors())
 		}
 	}
 }
@@ -253,7 +250,7 @@ func (c *Cursor) UpN(amount int) {
 	}
 
 	bytes := c.buf.LineBytes(proposedY)
-	c.X = c.GetCharPosInLine(bytes, c.LastVisualX)
+	c.X = c.buf.CharPos(proposedY, c.LastVisualX)
 
 	if c.X > util.CharacterCount(bytes) || (amount < 0 && proposedY == c.Y) {
 		c.X = util.CharacterCount(bytes)
@@ -0,0 +1,107 @@
+package buffer
+
+import (
+	"sort"
+	"strings"
+)
+
+// DiagnosticSorts are the orders in which the diagnostics can be sorted: by
+// file and location, by severity with the errors first, or by owner
+var DiagnosticSorts = []string{"location", "severity", "owner"}
+
+// A Diagnostic is a message of an open buffer
+type Diagnostic struct {
+	*Message
+	Buf *Buffer
+}
+
+// Loc returns the start of the diagnostic, at the start of the line if the
+// message was added to a whole line
+func (d Diagnostic) Loc() Loc {
+	return Loc{X: max(d.Start.X, 0), Y: d.Start.Y}
+}
+
+// Severity returns the name of the kind of the message
+func (m *Message) Severity() string {
+	switch m.Kind {
+	case MTError:
+		return "error"
+	case MTWarning:
+		return "warning"
+	}
+	return "info"
+}
+
+// Diagnostics returns the messages of all the open buffers, sorted in one
+// of the DiagnosticSorts orders. The ties are sorted by location
+func Diagnostics(sortBy string) []Diagnostic {
+	var diags []Diagnostic
+	seen := make(map[*SharedBuffer]bool)
+	for _, b := range OpenBuffers {
+		if seen[b.SharedBuffer] {
+			continue
+		}
+		seen[b.SharedBuffer] = true
+		for _, m := range b.Messages {
+			diags = append(diags, Diagnostic{Message: m, Buf: b})
+		}
+	}
+
+	byLocation := func(a, b Diagnostic) bool {
+		if a.Buf != b.Buf {
+			return a.Buf.GetName() < b.Buf.GetName()
+		}
+		return a.Start.LessThan(b.Start)
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i], diags[j]
+		switch {
+		case sortBy == "severity" && a.Kind != b.Kind:
+			return a.Kind > b.Kind
+		case sortBy == "owner" && a.Owner != b.Owner:
+			return strings.ToLower(a.Owner) < strings.ToLower(b.Owner)
+		}
+		return byLocation(a, b)
+	})
+	return diags
+}
+
+// DiagnosticCounts returns the number of error and warning messages of the
+// buffer
+func (b *Buffer) DiagnosticCounts() (errors, warnings int) {
+	for _, m := range b.Messages {
+		switch m.Kind {
+		case MTError:
+			errors++
+		case MTWarning:
+			warnings++
+		}
+	}
+	return errors, warnings
+}
+
+// NextMessage returns the closest message starting after (or before if
+// forward is false) the given line, wrapping around the buffer
+func (b *Buffer) NextMessage(line int, forward bool) *Message {
+	// the lines are negated to search backwards
+	sign := 1
+	if !forward {
+		sign = -1
+	}
+	// next is the closest message after the line, and first the first
+	// message of the buffer, to wrap around
+	var next, first *Message
+	for _, m := range b.Messages {
+		y := sign * m.Start.Y
+		if y > sign*line && (next == nil || y < sign*next.Start.Y) {
+			next = m
+		}
+		if first == nil || y < sign*first.Start.Y {
+			first = m
+		}
+	}
+	if next != nil {
+		return next
+	}
+	return first
+}
@@ -0,0 +1,49 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiagnostics(t *testing.T) {
+	a := NewBufferFromString("one\ntwo\nthree\n", "", BTDefault)
+	defer a.Close()
+	a.SetName("a")
+	b := NewBufferFromString("one\ntwo\n", "", BTDefault)
+	defer b.Close()
+	b.SetName("b")
+
+	warning := NewMessageAtLine("vet", "unused", 3, MTWarning)
+	a.AddMessage(warning)
+	a.AddMessage(NewMessage("lint", "bad name", Loc{X: 2, Y: 0}, Loc{X: 4, Y: 0}, MTError))
+	b.AddMessage(NewMessageAtLine("build", "failed", 2, MTError))
+	b.AddMessage(NewMessageAtLine("vet", "note", 1, MTInfo))
+
+	msgs := func(sortBy string) []string {
+		var msgs []string
+		for _, d := range Diagnostics(sortBy) {
+			if d.Buf == a || d.Buf == b {
+				msgs = append(msgs, d.Msg)
+			}
+		}
+		return msgs
+	}
+	assert.Equal(t, []string{"bad name", "unused", "note", "failed"}, msgs("location"))
+	assert.Equal(t, []string{"bad name", "failed", "unused", "note"}, msgs("severity"))
+	assert.Equal(t, []string{"failed", "bad name", "unused", "note"}, msgs("owner"))
+
+	errors, warnings := a.DiagnosticCounts()
+	assert.Equal(t, 1, errors)
+	assert.Equal(t, 1, warnings)
+
+	assert.Equal(t, warning, a.NextMessage(0, true))
+	assert.Equal(t, "bad name", a.NextMessage(2, true).Msg)
+	assert.Equal(t, "bad name", a.NextMessage(2, false).Msg)
+	assert.Equal(t, warning, a.NextMessage(0, false))
+	assert.Equal(t, Loc{X: 0, Y: 2}, Diagnostic{Message: warning, Buf: a}.Loc())
+
+	empty := NewBufferFromString("", "", BTDefault)
+	defer empty.Close()
+	assert.Nil(t, empty.NextMessage(0, true))
+}
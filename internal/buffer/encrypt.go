@@ -0,0 +1,221 @@
+package buffer
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"os"
+
+	"github.com/helmutkemper/micro/v2/internal/clipboard"
+	"github.com/helmutkemper/micro/v2/internal/screen"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedMagic starts the encrypted files, followed by the salt of the
+// key, the nonce and the data sealed with XChaCha20-Poly1305
+const encryptedMagic = "micro-encrypted/v1\n"
+
+const saltSize = 16
+
+// the scrypt parameters, deriving a key in about 100ms
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// maxPassphraseTries is the number of times the passphrase of an encrypted
+// file is asked before giving up
+const maxPassphraseTries = 3
+
+var ErrWrongPassphrase = errors.New("Wrong passphrase")
+
+// ReadPassphrase asks for the passphrase of an encrypted file when it is
+// opened
+var ReadPassphrase = func(path string) (string, error) {
+	return screen.TermPassword("Passphrase for " + path + ": ")
+}
+
+// encryption holds the passphrase of an encrypted buffer, and the key
+// derived from it
+type encryption struct {
+	passphrase string
+	salt       []byte
+	aead       cipher.AEAD
+	// hashKey is the key of the hashes of the contents, derived from the
+	// key of the passphrase
+	hashKey []byte
+}
+
+// newEncryption derives the key of a passphrase with the given salt, or a
+// random salt if salt is nil
+func newEncryption(passphrase string, salt []byte) (*encryption, error) {
+	if salt == nil {
+		salt = make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("hash"))
+	return &encryption{passphrase, salt, aead, mac.Sum(nil)}, nil
+}
+
+// isEncrypted returns true if the data starts with the header of the
+// encrypted files
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedMagic))
+}
+
+// hash returns a hash of data keyed with the passphrase, which tells whether
+// two texts are the same without revealing anything about them
+func (e *encryption) hash(data []byte) []byte {
+	mac := hmac.New(sha256.New, e.hashKey)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// seal encrypts data
+func (e *encryption) seal(data []byte) ([]byte, error) {
+	header := append([]byte(encryptedMagic), e.salt...)
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(append([]byte{}, header...), nonce...)
+	return e.aead.Seal(out, nonce, data, header), nil
+}
+
+// open decrypts data encrypted with the passphrase of e, deriving the key
+// again if the data was encrypted with another salt
+func (e *encryption) open(data []byte) ([]byte, error) {
+	headerSize := len(encryptedMagic) + saltSize
+	if !isEncrypted(data) || len(data) < headerSize+e.aead.NonceSize() {
+		return nil, errors.New("Invalid encrypted data")
+	}
+	header, salt := data[:headerSize], data[len(encryptedMagic):headerSize]
+	if !bytes.Equal(salt, e.salt) {
+		var err error
+		if e, err = newEncryption(e.passphrase, bytes.Clone(salt)); err != nil {
+			return nil, err
+		}
+	}
+	nonce := data[headerSize : headerSize+e.aead.NonceSize()]
+	plain, err := e.aead.Open(nil, nonce, data[headerSize+e.aead.NonceSize():], header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plain, nil
+}
+
+// decryptFile asks for the passphrase of an encrypted file until it
+// decrypts the data of the file, and returns the decrypted data
+func decryptFile(path string, data []byte) ([]byte, *encryption, error) {
+	if len(data) < len(encryptedMagic)+saltSize {
+		return nil, nil, errors.New("Invalid encrypted file " + path)
+	}
+	salt := bytes.Clone(data[len(encryptedMagic) : len(encryptedMagic)+saltSize])
+	for try := 0; try < maxPassphraseTries; try++ {
+		passphrase, err := ReadPassphrase(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := newEncryption(passphrase, salt)
+		if err != nil {
+			return nil, nil, err
+		}
+		plain, err := e.open(data)
+		if err == nil {
+			return plain, e, nil
+		} else if !errors.Is(err, ErrWrongPassphrase) {
+			return nil, nil, err
+		}
+	}
+	return nil, nil, ErrWrongPassphrase
+}
+
+// Encrypted returns true if the buffer is saved encrypted
+func (b *SharedBuffer) Encrypted() bool {
+	return b.encryption != nil
+}
+
+// SetPassphrase makes the buffer saved encrypted with the passphrase, or
+// saved as plain text if the passphrase is empty. The buffer is marked as
+// modified so that the file is saved again
+func (b *Buffer) SetPassphrase(passphrase string) error {
+	if passphrase == "" {
+		b.encryption = nil
+	} else {
+		e, err := newEncryption(passphrase, nil)
+		if err != nil {
+			return err
+		}
+		b.encryption = e
+	}
+	clipboard.SetPrivate(b.GetName(), b.encryption != nil)
+	// the file on disk differs from the buffer until it is saved again
+	b.origHash = [md5.Size]byte{}
+	b.isModified = true
+	b.RequestBackup()
+	return nil
+}
+
+// encodedBytes returns the contents of the buffer, as they are written to
+// its file: with the encoding and the line endings of the buffer, and
+// encrypted if the buffer is encrypted
+func (b *SharedBuffer) encodedBytes() ([]byte, error) {
+	data, err := b.plainBytes()
+	if err != nil {
+		return nil, err
+	}
+	return b.sealIfEncrypted(data)
+}
+
+// plainBytes returns the contents of the buffer with its encoding and its
+// line endings, before they are encrypted
+func (b *SharedBuffer) plainBytes() ([]byte, error) {
+	var data bytes.Buffer
+	b.Lock()
+	defer b.Unlock()
+	if len(b.lines) > 0 {
+		if _, err := b.encodeLines(&data); err != nil {
+			return nil, err
+		}
+	}
+	return data.Bytes(), nil
+}
+
+// sealIfEncrypted encrypts data if the buffer is encrypted
+func (b *SharedBuffer) sealIfEncrypted(data []byte) ([]byte, error) {
+	if b.encryption == nil {
+		return data, nil
+	}
+	return b.encryption.seal(data)
+}
+
+// readEncrypted reads a file written by the buffer, and decrypts it if it
+// is encrypted. Files encrypted while the buffer is not encrypted can't be
+// read
+func (b *SharedBuffer) readEncrypted(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil || !isEncrypted(data) {
+		return data, err
+	}
+	if b.encryption == nil {
+		return nil, errors.New(name + " is encrypted")
+	}
+	return b.encryption.open(data)
+}
@@ -0,0 +1,67 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryption(t *testing.T) {
+	e, err := newEncryption("secret", nil)
+	assert.NoError(t, err)
+	data, err := e.seal([]byte("plain text"))
+	assert.NoError(t, err)
+	assert.True(t, isEncrypted(data))
+	assert.NotContains(t, string(data), "plain text")
+
+	plain, err := e.open(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "plain text", string(plain))
+
+	other, err := newEncryption("wrong", nil)
+	assert.NoError(t, err)
+	_, err = other.open(data)
+	assert.ErrorIs(t, err, ErrWrongPassphrase)
+}
+
+func TestEncryptedFile(t *testing.T) {
+	dir := t.TempDir()
+
+	var tries []string
+	readPassphrase := ReadPassphrase
+	ReadPassphrase = func(path string) (string, error) {
+		tries = append(tries, path)
+		if len(tries) == 1 {
+			return "wrong", nil
+		}
+		return "secret", nil
+	}
+	defer func() { ReadPassphrase = readPassphrase }()
+
+	path := filepath.Join(dir, "secrets.txt")
+	b := NewBufferFromString("api key\n", path, BTDefault)
+	assert.NoError(t, b.SetPassphrase("secret"))
+	assert.True(t, b.Modified())
+	assert.NoError(t, b.Save())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, isEncrypted(data))
+	assert.NotContains(t, string(data), "api key")
+
+	backup, _, err := b.writeBackup(b.AbsPath)
+	assert.NoError(t, err)
+	data, err = os.ReadFile(backup)
+	assert.NoError(t, err)
+	assert.True(t, isEncrypted(data))
+	b.Close()
+
+	b, err = NewBufferFromFile(path, BTDefault)
+	assert.NoError(t, err)
+	assert.Len(t, tries, 2)
+	assert.True(t, b.Encrypted())
+	assert.Equal(t, "api key\n", string(b.Bytes()))
+	b.Close()
+}
@@ -228,6 +228,25 @@ func (eh *EventHandler) MultipleReplace(deltas []Delta) {
 	eh.Execute(e)
 }
 
+// Group runs fn and gives all the text events it executes the time of the
+// first one, so that they are undone and redone together
+func (eh *EventHandler) Group(fn func()) {
+	n := eh.UndoStack.Len()
+	fn()
+
+	var events []*TextEvent
+	for e := eh.UndoStack.Top; e != nil && len(events) < eh.UndoStack.Len()-n; e = e.Next {
+		events = append(events, e.Value)
+	}
+	if len(events) == 0 {
+		return
+	}
+	first := events[len(events)-1].Time
+	for _, t := range events {
+		t.Time = first
+	}
+}
+
 // Replace deletes from start to end and replaces it with the given string
 func (eh *EventHandler) Replace(start, end Loc, replace string) {
 	eh.Remove(start, end)
@@ -0,0 +1,191 @@
+package buffer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/util"
+	"golang.org/x/text/transform"
+)
+
+// A Version is a snapshot of a file taken when it was saved, and kept in
+// the local file history
+type Version struct {
+	Time time.Time
+	// Hash is the hash of the contents of the snapshot, which is also the
+	// name of the file holding them
+	Hash string
+	Size int64
+}
+
+// versionIndex lists the versions of a file, the most recent first
+type versionIndex struct {
+	Path     string
+	Versions []Version
+}
+
+// historyDir returns the directory holding the snapshots of the buffer's
+// file, which is named after the path of the file like the backups
+func (b *SharedBuffer) historyDir() string {
+	name, _ := util.DetermineEscapePath(filepath.Join(config.ConfigDir, "history"), b.AbsPath)
+	return name
+}
+
+func readVersionIndex(dir string) (*versionIndex, error) {
+	idx := &versionIndex{}
+	file, err := os.Open(filepath.Join(dir, "index"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return idx, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	err = gob.NewDecoder(file).Decode(idx)
+	return idx, err
+}
+
+func (idx *versionIndex) write(dir string) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(idx); err != nil {
+		return err
+	}
+	return util.SafeWrite(filepath.Join(dir, "index"), buf.Bytes(), true)
+}
+
+// contentHash returns the hash of the contents of a snapshot. The hash of
+// the contents of an encrypted buffer is keyed with its passphrase
+func (b *SharedBuffer) contentHash(data []byte) string {
+	if b.encryption != nil {
+		return hex.EncodeToString(b.encryption.hash(data))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// snapshot adds the contents of the buffer, as just saved, to the file
+// history, unless they did not change since the last snapshot. The snapshots
+// of encrypted buffers are encrypted too, and are compared before they are
+// encrypted
+func (b *SharedBuffer) snapshot() error {
+	if !b.Settings["filehistory"].(bool) || b.Type != BTDefault || config.ConfigDir == "" {
+		return nil
+	}
+	data, err := b.plainBytes()
+	if err != nil {
+		return err
+	}
+	hash := b.contentHash(data)
+
+	dir := b.historyDir()
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	idx, err := readVersionIndex(dir)
+	if err != nil {
+		return err
+	}
+	if len(idx.Versions) > 0 && idx.Versions[0].Hash == hash {
+		return nil
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, hash)); err != nil {
+		sealed, err := b.sealIfEncrypted(data)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, hash), sealed, 0600); err != nil {
+			return err
+		}
+	}
+	idx.Path = b.AbsPath
+	idx.Versions = append([]Version{{time.Now(), hash, int64(len(data))}}, idx.Versions...)
+	idx.prune()
+	if err := idx.write(dir); err != nil {
+		return err
+	}
+	return idx.removeUnused(dir)
+}
+
+// prune removes the versions exceeding the filehistorymax option and the
+// versions older than the filehistorydays option, always keeping the most
+// recent version
+func (idx *versionIndex) prune() {
+	maxVersions := int(config.GetGlobalOption("filehistorymax").(float64))
+	days := config.GetGlobalOption("filehistorydays").(float64)
+	keep := idx.Versions[:0]
+	for i, v := range idx.Versions {
+		if i > 0 && (maxVersions > 0 && len(keep) >= maxVersions ||
+			days > 0 && time.Since(v.Time) > time.Duration(days*24*float64(time.Hour))) {
+			continue
+		}
+		keep = append(keep, v)
+	}
+	idx.Versions = keep
+}
+
+// removeUnused removes the snapshots which are not listed in the index
+func (idx *versionIndex) removeUnused(dir string) error {
+	used := make(map[string]bool)
+	for _, v := range idx.Versions {
+		used[v.Hash] = true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() != "index" && !used[e.Name()] && !strings.HasSuffix(e.Name(), util.BackupSuffix) {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+	return nil
+}
+
+// Versions returns the versions of the buffer's file kept in the local file
+// history, the most recent first
+func (b *Buffer) Versions() ([]Version, error) {
+	if b.AbsPath == "" {
+		return nil, nil
+	}
+	idx, err := readVersionIndex(b.historyDir())
+	if err != nil {
+		return nil, err
+	}
+	return idx.Versions, nil
+}
+
+// VersionText returns the text of a version of the buffer's file
+func (b *Buffer) VersionText(v Version) (string, error) {
+	data, err := b.readEncrypted(filepath.Join(b.historyDir(), v.Hash))
+	if err != nil {
+		return "", err
+	}
+	r := transform.NewReader(bytes.NewReader(data), b.encoding.NewDecoder())
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(string(text), "\r\n", "\n"), nil
+}
+
+// RestoreVersion replaces the text of the buffer with a version of its
+// file, which can be undone
+func (b *Buffer) RestoreVersion(v Version) error {
+	text, err := b.VersionText(v)
+	if err != nil {
+		return err
+	}
+	b.EventHandler.ApplyDiff(text)
+	return nil
+}
@@ -0,0 +1,77 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileHistory(t *testing.T) {
+	dir := t.TempDir()
+	config.GlobalSettings["filehistory"] = true
+	config.GlobalSettings["filehistorymax"] = float64(2)
+	defer func() {
+		config.GlobalSettings["filehistory"] = false
+		config.GlobalSettings["filehistorymax"] = float64(50)
+	}()
+
+	path := filepath.Join(dir, "file.txt")
+	b := NewBufferFromString("one\n", path, BTDefault)
+	defer b.Close()
+	assert.NoError(t, b.Save())
+	// saving the same text again does not add a version
+	assert.NoError(t, b.Save())
+	versions, err := b.Versions()
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	b.Insert(b.End(), "two\n")
+	assert.NoError(t, b.Save())
+	b.Insert(b.End(), "three\n")
+	assert.NoError(t, b.Save())
+
+	versions, err = b.Versions()
+	assert.NoError(t, err)
+	if assert.Len(t, versions, 2) {
+		text, err := b.VersionText(versions[1])
+		assert.NoError(t, err)
+		assert.Equal(t, "one\ntwo\n", text)
+
+		assert.NoError(t, b.RestoreVersion(versions[1]))
+		assert.Equal(t, "one\ntwo\n", string(b.Bytes()))
+	}
+
+	// the snapshot of the first version was removed
+	entries, err := os.ReadDir(b.historyDir())
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	// the snapshots of an encrypted buffer are encrypted, and compared
+	// before they are encrypted
+	assert.NoError(t, b.SetPassphrase("secret"))
+	assert.NoError(t, b.Save())
+	versions, err = b.Versions()
+	assert.NoError(t, err)
+	assert.NoError(t, b.Save())
+	again, err := b.Versions()
+	assert.NoError(t, err)
+	assert.Equal(t, versions, again)
+	data, err := os.ReadFile(filepath.Join(b.historyDir(), versions[0].Hash))
+	assert.NoError(t, err)
+	assert.True(t, isEncrypted(data))
+	text, err := b.VersionText(versions[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "one\ntwo\n", text)
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	assert.Equal(t, "", UnifiedDiff("a\nb\n", "a\nb\n", "x", "y"))
+	assert.Equal(t, "--- x\n+++ y\n@@ -1,3 +1,3 @@\n a\n-b\n+c\n d\n",
+		UnifiedDiff("a\nb\nd\n", "a\nc\nd\n", "x", "y"))
+	// changes separated by more than twice the context are in two hunks
+	assert.Equal(t, "--- x\n+++ y\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		UnifiedDiff("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n", "x", "y"))
+}
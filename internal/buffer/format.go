@@ -0,0 +1,152 @@
+package buffer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/transform"
+	"github.com/helmutkemper/micro/v2/internal/util"
+	shellquote "github.com/kballard/go-shellquote"
+)
+
+// formatterOwner owns the messages of the errors of the formatters
+const formatterOwner = "formatter"
+
+// ErrNoFormatter is returned when formatting a buffer whose filetype has no
+// formatter
+var ErrNoFormatter = errors.New("No formatter for this filetype")
+
+// builtinFormatters are the aliases of the transforms which can be used as
+// formatters with builtin:name, besides the names of the transforms
+var builtinFormatters = map[string]string{
+	"json": "json-pretty",
+	"xml":  "xml-pretty",
+}
+
+// errorLoc matches the location in an error of a formatter, such as
+// <standard input>:12:4: message
+var errorLoc = regexp.MustCompile(`^[^:]*:(\d+)(?::(\d+))?:\s*(.*)$`)
+
+// Formatter returns the formatter of the filetype of the buffer in the
+// formatter option, or an empty string if there is none
+func (b *Buffer) Formatter() string {
+	formatters, _ := config.GetGlobalOption("formatter").(map[string]any)
+	f, _ := formatters[b.FileType()].(string)
+	return f
+}
+
+// Format formats the buffer with the formatter of its filetype, which is a
+// command reading the text on its standard input and writing the formatted
+// text on its standard output, or builtin: followed by the name of a
+// transform. The text is changed with ApplyDiff, so that the cursors stay
+// on the same text and the formatting is undone at once. The errors of the
+// formatter are added as messages of the buffer, and the first line of the
+// error is returned
+func (b *Buffer) Format() error {
+	f := b.Formatter()
+	if f == "" {
+		return ErrNoFormatter
+	}
+
+	b.ClearMessages(formatterOwner)
+	text := string(b.Bytes())
+	var out string
+	var err error
+	if name, ok := strings.CutPrefix(f, "builtin:"); ok {
+		if alias, ok := builtinFormatters[name]; ok {
+			name = alias
+		}
+		opts := transform.Options{Indent: b.IndentString(util.IntOpt(b.Settings["tabsize"]))}
+		out, err = transform.Apply(name, text, nil, opts)
+	} else {
+		timeout := time.Duration(config.GetGlobalOption("formattimeout").(float64) * float64(time.Second))
+		out, err = b.runFormatter(f, text, timeout)
+	}
+	if err != nil {
+		// the whole error is in the messages
+		b.addFormatterErrors(err)
+		msg, _, _ := strings.Cut(err.Error(), "\n")
+		return errors.New(msg)
+	}
+
+	if out != text {
+		b.Group(func() {
+			b.ApplyDiff(out)
+		})
+	}
+	return nil
+}
+
+// runFormatter runs a formatter command in the directory of the file with
+// the text on its standard input, and returns its output. The formatter is
+// killed after the timeout
+func (b *Buffer) runFormatter(command, text string, timeout time.Duration) (string, error) {
+	args, err := shellquote.Split(command)
+	if err != nil {
+		return "", err
+	}
+	if len(args) == 0 {
+		return "", ErrNoFormatter
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if b.AbsPath != "" {
+		cmd.Dir = filepath.Dir(b.AbsPath)
+	}
+	cmd.Stdin = strings.NewReader(text)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// don't wait for the processes started by the formatter which keep its
+	// output open
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("%s timed out after %v", args[0], timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", fmt.Errorf("%s: %v", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// addFormatterErrors adds a message for each line of the error of a
+// formatter, at the location given by the line if any, or else at the
+// first line
+func (b *Buffer) addFormatterErrors(err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		m := errorLoc.FindStringSubmatch(line)
+		if m == nil {
+			b.AddMessage(NewMessageAtLine(formatterOwner, line, 1, MTError))
+			continue
+		}
+		y, _ := strconv.Atoi(m[1])
+		y = util.Clamp(y-1, 0, b.LinesNum()-1)
+		if m[2] == "" {
+			b.AddMessage(NewMessageAtLine(formatterOwner, m[3], y+1, MTError))
+			continue
+		}
+		x, _ := strconv.Atoi(m[2])
+		loc := Loc{X: util.Clamp(x-1, 0, util.CharacterCount(b.LineBytes(y))), Y: y}
+		b.AddMessage(NewMessage(formatterOwner, m[3], loc, loc, MTError))
+	}
+}
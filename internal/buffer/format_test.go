@@ -0,0 +1,58 @@
+package buffer
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	defer func() {
+		config.GlobalSettings["formatter"] = map[string]any{}
+		config.GlobalSettings["formattimeout"] = float64(5)
+	}()
+	format := func(ft, formatter, text string) (*Buffer, error) {
+		config.GlobalSettings["formatter"] = map[string]any{ft: formatter}
+		b := NewBufferFromString(text, "", BTDefault)
+		b.Settings["filetype"] = ft
+		return b, b.Format()
+	}
+
+	b, err := format("json", "builtin:json", `{"a":1}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n\t\"a\": 1\n}", string(b.Bytes()))
+	b.Close()
+
+	b, err = format("text", "tr a-z A-Z", "ab\ncd\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "AB\nCD\n", string(b.Bytes()))
+	// the formatting is undone at once
+	b.Undo()
+	assert.Equal(t, "ab\ncd\n", string(b.Bytes()))
+	b.Close()
+
+	b, err = format("text", `sh -c 'echo "<standard input>:2:3: bad" >&2; echo worse >&2; exit 1'`, "ab\ncdef\n")
+	assert.EqualError(t, err, "<standard input>:2:3: bad")
+	assert.Equal(t, "ab\ncdef\n", string(b.Bytes()))
+	if assert.Len(t, b.Messages, 2) {
+		assert.Equal(t, "bad", b.Messages[0].Msg)
+		assert.Equal(t, Loc{X: 2, Y: 1}, b.Messages[0].Start)
+		assert.Equal(t, "worse", b.Messages[1].Msg)
+		assert.Equal(t, 0, b.Messages[1].Start.Y)
+	}
+	b.Close()
+
+	config.GlobalSettings["formattimeout"] = 0.1
+	b, err = format("text", "sleep 5", "ab\n")
+	assert.EqualError(t, err, "sleep timed out after 100ms")
+	b.Close()
+
+	b, err = format("go", "", "")
+	assert.Equal(t, ErrNoFormatter, err)
+	b.Close()
+}
@@ -0,0 +1,346 @@
+package buffer
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/helmutkemper/micro/v2/internal/screen"
+	"github.com/helmutkemper/micro/v2/internal/util"
+	"github.com/helmutkemper/micro/v2/pkg/highlight"
+)
+
+// A highlightWorker highlights a buffer in a background goroutine so that
+// editing never waits for the highlighter, even for big files with costly
+// syntax files.
+//
+// Edits mark a range of lines as dirty and wake the worker up. The worker
+// then recomputes the end of line states from the first dirty line until
+// they stop changing, and the matches of the lines whose text or previous
+// state changed. The lines shown on screen are highlighted first. An edit
+// made while the worker is running cancels the current pass, which starts
+// again with the new dirty range. Lines which have never been highlighted
+// have no matches and are displayed with the default style.
+type highlightWorker struct {
+	buf *SharedBuffer
+	hl  *highlight.Highlighter
+
+	// gen is incremented before every change of the buffer text and when
+	// lines are marked as dirty, so that the worker can notice that the
+	// lines it works on have changed
+	gen atomic.Uint64
+
+	lock sync.Mutex
+	// dirty is the range of lines edited since the last complete pass.
+	// dirty[0] is -1 if there is none
+	dirty [2]int
+	// view is the range of lines shown on screen
+	view [2]int
+
+	wake chan struct{}
+	quit chan struct{}
+}
+
+func newHighlightWorker(b *SharedBuffer, def *highlight.Def) *highlightWorker {
+	return &highlightWorker{
+		buf:   b,
+		hl:    highlight.NewHighlighter(def),
+		dirty: [2]int{-1, -1},
+		wake:  make(chan struct{}, 1),
+		quit:  make(chan struct{}),
+	}
+}
+
+func (w *highlightWorker) run() {
+	for {
+		select {
+		case <-w.wake:
+		case <-w.quit:
+			return
+		}
+		for w.step() {
+		}
+	}
+}
+
+func (w *highlightWorker) stopped() bool {
+	select {
+	case <-w.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *highlightWorker) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// invalidate marks the lines from start to end as dirty and wakes the
+// worker up. The current pass may have started after the change of the
+// text and before its lines are marked, so it is cancelled
+func (w *highlightWorker) invalidate(start, end int) {
+	w.lock.Lock()
+	w.gen.Add(1)
+	if w.dirty[0] < 0 {
+		w.dirty = [2]int{start, end}
+	} else {
+		w.dirty = [2]int{util.Min(w.dirty[0], start), util.Max(w.dirty[1], end)}
+	}
+	w.lock.Unlock()
+	w.notify()
+}
+
+// setView sets the range of lines shown on screen, which are highlighted
+// before the others
+func (w *highlightWorker) setView(start, end int) {
+	w.lock.Lock()
+	w.view = [2]int{start, end}
+	w.lock.Unlock()
+}
+
+func (w *highlightWorker) getView() [2]int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.view
+}
+
+// shiftInsert cancels the current pass and moves the dirty range to follow
+// the lines added by inserting the text ending at end at pos
+func (w *highlightWorker) shiftInsert(pos, end Loc) {
+	w.gen.Add(1)
+	n := end.Y - pos.Y
+	if n == 0 {
+		return
+	}
+	w.lock.Lock()
+	for i, l := range w.dirty {
+		if l > pos.Y {
+			w.dirty[i] = l + n
+		}
+	}
+	w.lock.Unlock()
+}
+
+// shiftRemove cancels the current pass and moves the dirty range to follow
+// the lines removed with the text between start and end
+func (w *highlightWorker) shiftRemove(start, end Loc) {
+	w.gen.Add(1)
+	n := end.Y - start.Y
+	if n == 0 {
+		return
+	}
+	w.lock.Lock()
+	for i, l := range w.dirty {
+		if l > end.Y {
+			w.dirty[i] = l - n
+		} else if l > start.Y {
+			w.dirty[i] = start.Y
+		}
+	}
+	w.lock.Unlock()
+}
+
+// step runs a highlighting pass over the dirty lines. It returns true if
+// another pass is needed because the buffer was edited in the meantime
+func (w *highlightWorker) step() bool {
+	if w.stopped() {
+		return false
+	}
+
+	gen, dirty := w.snapshot()
+	if dirty[0] < 0 {
+		return false
+	}
+	if !w.highlight(gen, dirty) {
+		return true
+	}
+	return !w.done(gen)
+}
+
+// snapshot returns the generation of the buffer and the dirty range, with
+// which a pass starts
+func (w *highlightWorker) snapshot() (uint64, [2]int) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.gen.Load(), w.dirty
+}
+
+// done clears the dirty range after a pass started at the generation gen,
+// unless the buffer was edited or lines were marked as dirty since. It
+// returns false if they were
+func (w *highlightWorker) done(gen uint64) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.gen.Load() != gen {
+		return false
+	}
+	w.dirty = [2]int{-1, -1}
+	return true
+}
+
+// highlight highlights the lines of the given dirty range, and the lines
+// after it whose state changes. It returns false if the pass was cancelled
+// by an edit
+func (w *highlightWorker) highlight(gen uint64, dirty [2]int) bool {
+	b := w.buf
+
+	// withLines runs f with the line array locked, unless the buffer has
+	// been edited since the start of the pass. f is given the number of
+	// lines of the buffer
+	withLines := func(f func(n int)) bool {
+		b.Lock()
+		defer b.Unlock()
+		if w.gen.Load() != gen || w.stopped() {
+			return false
+		}
+		f(b.LinesNum())
+		return true
+	}
+
+	start := dirty[0]
+	last := start - 1
+	var prev highlight.State
+	ok := withLines(func(n int) {
+		if start > 0 && start <= n {
+			prev = b.State(start - 1)
+		}
+	})
+	if !ok {
+		return false
+	}
+
+	// done records which lines of the range start-last already have their
+	// matches
+	var done []bool
+	highlightLine := func(i int) bool {
+		if done[i-start] {
+			return true
+		}
+		return withLines(func(n int) {
+			if i >= n {
+				return
+			}
+			var prev highlight.State
+			if i > 0 {
+				prev = b.State(i - 1)
+			}
+			b.SetMatch(i, w.hl.HighlightLine(i, b.LineBytes(i), prev))
+			done[i-start] = true
+		})
+	}
+	highlightView := func(view [2]int) bool {
+		if n := last - start + 1; len(done) < n {
+			done = append(done, make([]bool, n-len(done))...)
+		}
+		for i := util.Max(view[0], start); i <= util.Min(view[1], last); i++ {
+			if !highlightLine(i) {
+				return false
+			}
+		}
+		screen.Redraw()
+		return true
+	}
+
+	// compute the states first, and highlight the visible lines as soon
+	// as their states are known
+	view := [2]int{-1, -1}
+	for i := start; ; i++ {
+		more := false
+		ok := withLines(func(n int) {
+			if i >= n {
+				return
+			}
+			s := w.hl.HighlightState(i, b.LineBytes(i), prev)
+			changed := s != b.State(i)
+			b.SetState(i, s)
+			prev = s
+			last = i
+			more = i < dirty[1] || changed
+		})
+		if !ok {
+			return false
+		}
+		if !more {
+			break
+		}
+
+		if v := w.getView(); v != view && i >= v[1] {
+			view = v
+			if !highlightView(view) {
+				return false
+			}
+		}
+	}
+
+	if last < start {
+		return true
+	}
+
+	view = w.getView()
+	if !highlightView(view) {
+		return false
+	}
+	for i := start; i <= last; i++ {
+		if v := w.getView(); v != view {
+			// the user scrolled, highlight the new visible lines first
+			view = v
+			if !highlightView(view) {
+				return false
+			}
+		}
+		if !highlightLine(i) {
+			return false
+		}
+	}
+	screen.Redraw()
+	return true
+}
+
+// startHighlighting starts highlighting the buffer in the background with
+// its syntax definition
+func (b *SharedBuffer) startHighlighting() {
+	b.stopHighlighting()
+	if b.SyntaxDef == nil || !b.Settings["syntax"].(bool) {
+		return
+	}
+	b.hlWorker = newHighlightWorker(b, b.SyntaxDef)
+	b.hlWorker.invalidate(0, b.LinesNum()-1)
+	go b.hlWorker.run()
+}
+
+// stopHighlighting stops the background highlighting of the buffer
+func (b *SharedBuffer) stopHighlighting() {
+	if b.hlWorker != nil {
+		close(b.hlWorker.quit)
+		b.hlWorker = nil
+	}
+}
+
+// SetHighlightView tells the highlighter which lines are shown on screen, so
+// that they are highlighted first
+func (b *SharedBuffer) SetHighlightView(start, end int) {
+	if b.hlWorker != nil {
+		b.hlWorker.setView(start, end)
+	}
+}
+
+// HighlightReady returns true if the given line has been highlighted. Lines
+// which are not ready are displayed with the default style
+func (b *SharedBuffer) HighlightReady(lineN int) bool {
+	return b.Match(lineN) != nil
+}
+
+func (b *SharedBuffer) shiftHighlightInsert(pos, end Loc) {
+	if b.hlWorker != nil {
+		b.hlWorker.shiftInsert(pos, end)
+	}
+}
+
+func (b *SharedBuffer) shiftHighlightRemove(start, end Loc) {
+	if b.hlWorker != nil {
+		b.hlWorker.shiftRemove(start, end)
+	}
+}
@@ -0,0 +1,103 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/helmutkemper/micro/v2/pkg/highlight"
+	"github.com/stretchr/testify/assert"
+)
+
+const testSyntax = `filetype: test
+rules:
+  - constant.number: "\\b[0-9]+\\b"
+  - comment:
+      start: "/\\*"
+      end: "\\*/"
+      rules: []
+`
+
+func newTestHighlightWorker(t *testing.T, b *Buffer) *highlightWorker {
+	f, err := highlight.ParseFile([]byte(testSyntax))
+	assert.NoError(t, err)
+	def, err := highlight.ParseDef(f, &highlight.Header{FileType: "test"})
+	assert.NoError(t, err)
+
+	// the worker is run by hand instead of in its own goroutine
+	b.ClearMatches()
+	w := newHighlightWorker(b.SharedBuffer, def)
+	b.hlWorker = w
+	w.invalidate(0, b.LinesNum()-1)
+	return w
+}
+
+func TestHighlightWorker(t *testing.T) {
+	b := NewBufferFromString("a 1\n/* x\n2 */ 3\nb 4", "", BTDefault)
+	w := newTestHighlightWorker(t, b)
+	comment := highlight.Groups["comment"]
+	number := highlight.Groups["constant.number"]
+
+	for i := 0; i < b.LinesNum(); i++ {
+		assert.False(t, b.HighlightReady(i))
+	}
+	assert.False(t, w.step())
+	for i := 0; i < b.LinesNum(); i++ {
+		assert.True(t, b.HighlightReady(i))
+	}
+	assert.Equal(t, number, b.Match(0)[2])
+	assert.Equal(t, comment, b.Match(2)[0])
+	assert.Equal(t, number, b.Match(2)[5])
+	assert.Equal(t, number, b.Match(3)[2])
+
+	// removing the start of the comment rehighlights the following lines
+	b.Remove(Loc{0, 1}, Loc{2, 1})
+	assert.False(t, w.step())
+	assert.Equal(t, number, b.Match(2)[0])
+
+	// inserted lines are not ready until the worker highlights them
+	b.Insert(Loc{0, 0}, "5\n6\n")
+	assert.False(t, b.HighlightReady(0))
+	assert.False(t, w.step())
+	assert.Equal(t, number, b.Match(1)[0])
+	assert.Equal(t, number, b.Match(4)[0])
+}
+
+func TestHighlightWorkerCancel(t *testing.T) {
+	b := NewBufferFromString("/* a\nb\nc */ 1", "", BTDefault)
+	w := newTestHighlightWorker(t, b)
+
+	// an edit cancels the pass started before it
+	gen := w.gen.Load()
+	b.Insert(Loc{0, 0}, "x\n")
+	assert.False(t, w.highlight(gen, [2]int{0, 3}))
+	assert.False(t, b.HighlightReady(3))
+
+	// the dirty range follows the inserted lines and is highlighted by the
+	// next pass
+	assert.Equal(t, [2]int{0, 3}, w.dirty)
+	assert.False(t, w.step())
+	assert.Equal(t, [2]int{-1, -1}, w.dirty)
+	assert.Equal(t, highlight.Groups["comment"], b.Match(3)[0])
+}
+
+func TestHighlightWorkerInterleaved(t *testing.T) {
+	b := NewBufferFromString("a\nb\nc", "", BTDefault)
+	w := newTestHighlightWorker(t, b)
+	assert.False(t, w.step())
+	b.Insert(Loc{0, 0}, "1 ")
+
+	// an edit of the last line changes its text before marking it as
+	// dirty, and a pass over the first line starts in between
+	w.shiftInsert(Loc{0, 2}, Loc{0, 2})
+	b.Lock()
+	b.lines[2].data = []byte("2")
+	b.Unlock()
+	gen, dirty := w.snapshot()
+	assert.Equal(t, [2]int{0, 0}, dirty)
+	assert.True(t, w.highlight(gen, dirty))
+	w.invalidate(2, 2)
+
+	// the edited line is highlighted by the next pass
+	assert.False(t, w.done(gen))
+	assert.False(t, w.step())
+	assert.Equal(t, highlight.Groups["constant.number"], b.Match(2)[0])
+}
@@ -173,8 +173,8 @@ func (la *LineArray) Bytes() []byte {
 	b := new(bytes.Buffer)
 	// initsize should provide a good estimate
 	b.Grow(int(la.initsize + 4096))
-	for i, l := range la.lines {
-		b.Write(l.data)
+	for i := range la.lines {
+		b.Write(la.lines[i].data)
 		if i != len(la.lines)-1 {
 			if la.Endings == FFDos {
 				b.WriteByte('\r')
@@ -381,11 +381,22 @@ func (la *LineArray) Unlock() {
 // in different edit panes) which have distinct searches, so SearchMatch
 // needs to know which search to match against.
 func (la *LineArray) SearchMatch(b *Buffer, pos Loc) bool {
+	for _, m := range la.searchMatches(b, pos.Y) {
+		if pos.X >= m[0] && pos.X < m[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// searchMatches returns the start and end columns of the matches of the
+// last search for the buffer `b` in the given line, searching for them
+// only if they are not already known
+func (la *LineArray) searchMatches(b *Buffer, lineN int) [][2]int {
 	if b.LastSearch == "" {
-		return false
+		return nil
 	}
 
-	lineN := pos.Y
 	if la.lines[lineN].search == nil {
 		la.lines[lineN].search = make(map[*Buffer]*searchState)
 	}
@@ -425,13 +436,7 @@ func (la *LineArray) SearchMatch(b *Buffer, pos Loc) bool {
 
 		s.done = true
 	}
-
-	for _, m := range s.match {
-		if pos.X >= m[0] && pos.X < m[1] {
-			return true
-		}
-	}
-	return false
+	return s.match
 }
 
 // invalidateSearchMatches marks search matches for the given line as outdated.
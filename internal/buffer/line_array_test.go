@@ -59,3 +59,31 @@ func TestRemove(t *testing.T) {
 	bytes := la.Bytes()
 	assert.Equal(t, unicode_txt, string(bytes))
 }
+
+func TestSearchMatchIndex(t *testing.T) {
+	b := NewBufferFromString("foo bar foo\nbar\nfoo", "", BTDefault)
+	b.LastSearch = "foo"
+
+	index, total := b.SearchMatchIndex(Loc{0, 0})
+	assert.Equal(t, 1, index)
+	assert.Equal(t, 3, total)
+
+	index, _ = b.SearchMatchIndex(Loc{8, 0})
+	assert.Equal(t, 2, index)
+
+	// the matches follow edits
+	b.Insert(Loc{0, 1}, "foo ")
+	index, total = b.SearchMatchIndex(Loc{0, 1})
+	assert.Equal(t, 3, index)
+	assert.Equal(t, 4, total)
+
+	// and the count follows the search
+	b.LastSearch = "bar"
+	index, total = b.SearchMatchIndex(Loc{0, 2})
+	assert.Equal(t, 2, index)
+	assert.Equal(t, 2, total)
+
+	b.LastSearch = ""
+	_, total = b.SearchMatchIndex(Loc{0, 0})
+	assert.Equal(t, 0, total)
+}
@@ -1,6 +1,8 @@
 package buffer
 
 import (
+	"bytes"
+
 	"github.com/helmutkemper/micro/v2/internal/util"
 )
 
@@ -151,3 +153,38 @@ func ByteOffset(pos Loc, buf *Buffer) int {
 func clamp(pos Loc, la *LineArray) Loc {
 	return pos.Clamp(la.Start(), la.End())
 }
+
+// textEnd returns the location of the end of text once it is inserted at pos
+func textEnd(pos Loc, text []byte) Loc {
+	if i := bytes.LastIndexByte(text, '\n'); i >= 0 {
+		return Loc{util.CharacterCount(text[i+1:]), pos.Y + bytes.Count(text, []byte{'\n'})}
+	}
+	return Loc{pos.X + util.CharacterCount(text), pos.Y}
+}
+
+// shiftInsert returns the location l points to once the text ending at end
+// has been inserted at pos. If stick is true a loc equal to pos stays before
+// the inserted text instead of moving after it
+func (l Loc) shiftInsert(pos, end Loc, stick bool) Loc {
+	if l.LessThan(pos) || (stick && l == pos) {
+		return l
+	}
+	if l.Y == pos.Y {
+		return Loc{end.X + l.X - pos.X, end.Y}
+	}
+	return Loc{l.X, l.Y + end.Y - pos.Y}
+}
+
+// shiftRemove returns the location l points to once the text between start
+// and end has been removed. Locs inside the removed text collapse to start
+func (l Loc) shiftRemove(start, end Loc) Loc {
+	switch {
+	case l.LessEqual(start):
+		return l
+	case l.LessThan(end):
+		return start
+	case l.Y == end.Y:
+		return Loc{start.X + l.X - end.X, start.Y}
+	}
+	return Loc{l.X, l.Y - (end.Y - start.Y)}
+}
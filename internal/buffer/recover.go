@@ -0,0 +1,223 @@
+package buffer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/clipboard"
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/util"
+	"github.com/helmutkemper/micro/v2/internal/vfs"
+	"golang.org/x/text/transform"
+)
+
+// recoverSuffix is added to the name of a backup while it is recovered
+const recoverSuffix = ".recover"
+
+// A Backup is a backup left in the backup directory by a buffer which was
+// not closed properly, usually because micro crashed
+type Backup struct {
+	// Path is the path of the file which was backed up
+	Path string
+	// File is the path of the backup
+	File string
+	// ResolveFile holds Path if the name of File is a hash of Path
+	ResolveFile string
+	ModTime     time.Time
+	Size        int64
+}
+
+// OrphanedBackups returns the backups of the files which are not open, and
+// which differ from the files, the most recent first
+func OrphanedBackups() []Backup {
+	return orphanedBackups(backupDirFrom(config.GetGlobalOption("backupdir").(string)), openPaths())
+}
+
+// ScanOrphanedBackups looks for the orphaned backups in the background,
+// since the backups are compared with their files, and calls done with
+// them from another goroutine
+func ScanOrphanedBackups(done func([]Backup)) {
+	dir := backupDirFrom(config.GetGlobalOption("backupdir").(string))
+	open := openPaths()
+	go func() {
+		done(orphanedBackups(dir, open))
+	}()
+}
+
+// openPaths returns the absolute paths of the open files
+func openPaths() map[string]bool {
+	paths := make(map[string]bool)
+	for _, b := range OpenBuffers {
+		if b.Type != BTInfo {
+			paths[b.AbsPath] = true
+		}
+	}
+	return paths
+}
+
+// orphanedBackups returns the backups of the backup directory dir whose
+// files are not in open, and which differ from their files
+func orphanedBackups(dir string, open map[string]bool) []Backup {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var backups []Backup
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasSuffix(name, ".path") ||
+			strings.HasSuffix(name, util.BackupSuffix) || strings.HasSuffix(name, recoverSuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		bk := Backup{
+			File:    filepath.Join(dir, name),
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+		}
+		if path, err := os.ReadFile(bk.File + ".path"); err == nil {
+			bk.Path = string(path)
+			bk.ResolveFile = bk.File + ".path"
+		} else {
+			bk.Path = util.UnescapePath(name)
+		}
+
+		absPath, err := vfs.Abs(bk.Path)
+		if err != nil {
+			absPath = bk.Path
+		}
+		if open[absPath] || bk.identical() {
+			continue
+		}
+		backups = append(backups, bk)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ModTime.After(backups[j].ModTime)
+	})
+	return backups
+}
+
+// identical returns true if the backup holds the same text as the file,
+// which is the case of the backups kept by the permbackup option. These
+// backups are written before their file, and the backups of encrypted files
+// hold the same data as the file. Plain files are only read if the backup
+// has the size of the file and is not more recent
+func (bk Backup) identical() bool {
+	if vfs.IsRemote(bk.Path) {
+		return false
+	}
+	if !vfs.IsVirtual(bk.Path) {
+		info, err := os.Stat(bk.Path)
+		if err != nil || info.Size() != bk.Size || bk.ModTime.After(info.ModTime()) {
+			return false
+		}
+	}
+	backup, err := os.ReadFile(bk.File)
+	if err != nil {
+		return false
+	}
+	file, err := vfs.ReadFile(bk.Path)
+	return err == nil && bytes.Equal(file, backup)
+}
+
+// Encrypted returns true if the backup is the backup of an encrypted buffer
+func (bk Backup) Encrypted() bool {
+	f, err := os.Open(bk.File)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(encryptedMagic))
+	n, _ := f.Read(head)
+	return isEncrypted(head[:n])
+}
+
+// Diff returns the differences between the file and its backup, as a
+// unified diff
+func (bk Backup) Diff() (string, error) {
+	if bk.Encrypted() {
+		return "", errors.New("The backup is encrypted")
+	}
+	backup, err := os.ReadFile(bk.File)
+	if err != nil {
+		return "", err
+	}
+	if vfs.IsRemote(bk.Path) {
+		return UnifiedDiff("", string(backup), bk.Path, bk.File), nil
+	}
+	file, err := vfs.ReadFile(bk.Path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	return UnifiedDiff(string(file), string(backup), bk.Path, bk.File), nil
+}
+
+// Discard removes the backup
+func (bk Backup) Discard() error {
+	if bk.ResolveFile != "" {
+		os.Remove(bk.ResolveFile)
+	}
+	return os.Remove(bk.File)
+}
+
+// Recover opens the file with the given path, which is the path of the file
+// which was backed up or a new path, and replaces its text with the text
+// of the backup as unsaved changes. The backup is removed once recovered
+func (bk Backup) Recover(path string) (*Buffer, error) {
+	// the backup is moved aside so that opening the file does not ask
+	// whether to recover it
+	tmp := bk.File + recoverSuffix
+	if err := os.Rename(bk.File, tmp); err != nil {
+		return nil, err
+	}
+	fail := func(b *Buffer, err error) (*Buffer, error) {
+		if b != nil {
+			b.Close()
+		}
+		os.Rename(tmp, bk.File)
+		return nil, err
+	}
+
+	b, err := NewBufferFromFile(path, BTDefault)
+	if err != nil {
+		return fail(nil, err)
+	}
+	data, err := os.ReadFile(tmp)
+	if err != nil {
+		return fail(b, err)
+	}
+	if isEncrypted(data) {
+		if b.encryption != nil {
+			data, err = b.encryption.open(data)
+		} else if data, b.encryption, err = decryptFile(bk.Path, data); err == nil {
+			clipboard.SetPrivate(b.GetName(), true)
+		}
+		if err != nil {
+			return fail(b, err)
+		}
+	}
+
+	text, err := io.ReadAll(transform.NewReader(bytes.NewReader(data), b.encoding.NewDecoder()))
+	if err != nil {
+		return fail(b, err)
+	}
+	b.EventHandler.ApplyDiff(strings.ReplaceAll(string(text), "\r\n", "\n"))
+	os.Remove(tmp)
+	if bk.ResolveFile != "" {
+		os.Remove(bk.ResolveFile)
+	}
+	return b, nil
+}
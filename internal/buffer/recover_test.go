@@ -0,0 +1,85 @@
+package buffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrphanedBackups(t *testing.T) {
+	dir := t.TempDir()
+	backupdir := filepath.Join(dir, "backups")
+	assert.NoError(t, os.Mkdir(backupdir, os.ModePerm))
+	config.GlobalSettings["backupdir"] = backupdir
+	defer func() { config.GlobalSettings["backupdir"] = "" }()
+	backup := func(path, text string) {
+		name, resolveName := util.DetermineEscapePath(backupdir, path)
+		assert.NoError(t, os.WriteFile(name, []byte(text), 0644))
+		if resolveName != "" {
+			assert.NoError(t, os.WriteFile(resolveName, []byte(path), 0644))
+		}
+	}
+
+	changed := filepath.Join(dir, "changed.txt")
+	same := filepath.Join(dir, "same.txt")
+	assert.NoError(t, os.WriteFile(changed, []byte("one\n"), 0644))
+	assert.NoError(t, os.WriteFile(same, []byte("one\n"), 0644))
+	backup(changed, "one\ntwo\n")
+	backup(same, "one\n")
+	// the backups kept by permbackup are written before their file
+	old := time.Now().Add(-time.Minute)
+	name, _ := util.DetermineEscapePath(backupdir, same)
+	assert.NoError(t, os.Chtimes(name, old, old))
+
+	// the backup identical to its file is not orphaned
+	backups := OrphanedBackups()
+	if !assert.Len(t, backups, 1) {
+		return
+	}
+	bk := backups[0]
+	assert.Equal(t, changed, bk.Path)
+	diff, err := bk.Diff()
+	assert.NoError(t, err)
+	assert.Equal(t, "--- "+changed+"\n+++ "+bk.File+"\n@@ -1 +1,2 @@\n one\n+two\n", diff)
+
+	b, err := bk.Recover(changed)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "one\ntwo\n", string(b.Bytes()))
+		assert.True(t, b.Modified())
+		b.Close()
+	}
+	assert.NoFileExists(t, bk.File)
+	assert.Empty(t, OrphanedBackups())
+
+	backup(changed, "three\n")
+	backups = OrphanedBackups()
+	if assert.Len(t, backups, 1) {
+		assert.NoError(t, backups[0].Discard())
+	}
+	assert.Empty(t, OrphanedBackups())
+
+	// the backup kept of an encrypted file holds the same data as the file
+	config.GlobalSettings["permbackup"] = true
+	defer func() { config.GlobalSettings["permbackup"] = false }()
+	secret := filepath.Join(dir, "secret.txt")
+	b = NewBufferFromString("api key\n", secret, BTDefault)
+	assert.NoError(t, b.SetPassphrase("secret"))
+	assert.NoError(t, b.Save())
+	b.Close()
+	name, _ = util.DetermineEscapePath(backupdir, secret)
+	assert.FileExists(t, name)
+	assert.Empty(t, OrphanedBackups())
+
+	// and the backups are also looked for in the background
+	done := make(chan []Backup)
+	backup(changed, "four\n")
+	ScanOrphanedBackups(func(backups []Backup) {
+		done <- backups
+	})
+	assert.Len(t, <-done, 1)
+}
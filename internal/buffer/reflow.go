@@ -0,0 +1,352 @@
+package buffer
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/helmutkemper/micro/v2/internal/util"
+	runewidth "github.com/mattn/go-runewidth"
+)
+
+// defaultReflowWidth is the width of the reflowed text when the textwidth
+// option is not set
+const defaultReflowWidth = 80
+
+// noBreakBefore are the wide punctuation marks which can't start a line
+const noBreakBefore = "、。，．：；！？）」』】〕〉》"
+
+// A linePrefix is the start of a line which is kept when the line is
+// reflowed: the indentation, comment leader and blockquote markers, and
+// the list bullet
+type linePrefix struct {
+	lead   string
+	bullet string
+	// comment is true if the lead has a comment leader
+	comment bool
+}
+
+// parsePrefix splits a line into its prefix and its content. leader is
+// the comment leader of the filetype, if any
+func parsePrefix(line, leader string) (linePrefix, string) {
+	var p linePrefix
+	skipSpaces := func(i int) int {
+		for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+			i++
+		}
+		return i
+	}
+
+	i := skipSpaces(0)
+	if leader != "" && strings.HasPrefix(line[i:], leader) {
+		p.comment = true
+		i = skipSpaces(i + len(leader))
+	}
+	for i < len(line) && line[i] == '>' {
+		i = skipSpaces(i + 1)
+	}
+	p.lead = line[:i]
+
+	j := i
+	if j < len(line) && strings.ContainsRune("-*+", rune(line[j])) {
+		j++
+	} else {
+		for j < len(line) && line[j] >= '0' && line[j] <= '9' && j-i < 9 {
+			j++
+		}
+		if j == i || j == len(line) || line[j] != '.' && line[j] != ')' {
+			return p, line[i:]
+		}
+		j++
+	}
+	if j == len(line) || line[j] != ' ' && line[j] != '\t' {
+		return p, line[i:]
+	}
+	j = skipSpaces(j)
+	p.bullet = line[i:j]
+	return p, line[j:]
+}
+
+// continuation returns the prefix of the lines following the first line
+// of a paragraph, where the bullet is replaced by spaces
+func (p linePrefix) continuation() string {
+	return p.lead + strings.Repeat(" ", runewidth.StringWidth(p.bullet))
+}
+
+// follows returns true if a line with the prefix q continues the paragraph
+// of a line with the prefix p
+func (p linePrefix) follows(q linePrefix) bool {
+	if q.bullet != "" || p.comment != q.comment {
+		return false
+	}
+	cont := p.continuation()
+	if q.lead == cont {
+		return true
+	}
+	// the spaces after comment leaders and blockquote markers may differ
+	trimmed := strings.TrimRight(cont, " \t")
+	return strings.TrimSpace(trimmed) != "" &&
+		strings.TrimRight(q.lead, " \t") == trimmed &&
+		string(util.GetLeadingWhitespace([]byte(q.lead))) == string(util.GetLeadingWhitespace([]byte(cont)))
+}
+
+// isBlank returns true if a line has no content besides its prefix
+func isBlank(content string) bool {
+	return strings.TrimSpace(content) == ""
+}
+
+// An atom is a word, or a wide character, which is never split when text
+// is reflowed
+type atom struct {
+	text  string
+	width int
+	// space is true if the atom is separated from the previous atom by
+	// a space
+	space bool
+}
+
+// appendAtoms appends the atoms of the content of a line. The content is
+// separated from the previous atoms by a space, unless one of the
+// characters around the line break is wide, as in CJK text
+func appendAtoms(atoms []atom, content string) []atom {
+	space := len(atoms) > 0
+	joined := space
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			text := cur.String()
+			atoms[len(atoms)-1].text = text
+			atoms[len(atoms)-1].width = runewidth.StringWidth(text)
+			cur.Reset()
+		}
+	}
+	wide := func(i int) bool {
+		if i < 0 || i >= len(atoms) {
+			return false
+		}
+		r, _ := utf8.DecodeLastRuneInString(atoms[i].text)
+		return runewidth.RuneWidth(r) > 1
+	}
+
+	for _, r := range content {
+		switch {
+		case r == ' ' || r == '\t':
+			flush()
+			space, joined = true, false
+		case runewidth.RuneWidth(r) > 1:
+			flush()
+			if joined {
+				space = false
+			}
+			atoms = append(atoms, atom{text: string(r), width: runewidth.RuneWidth(r), space: space})
+			space, joined = false, false
+		default:
+			if cur.Len() == 0 {
+				if joined && wide(len(atoms)-1) {
+					space = false
+				}
+				atoms = append(atoms, atom{space: space})
+				space, joined = false, false
+			}
+			cur.WriteRune(r)
+		}
+	}
+	flush()
+	return atoms
+}
+
+// fill breaks the atoms into lines which fit in the given width, starting
+// with the prefix first for the first line and rest for the others
+func fill(atoms []atom, first, rest string, width, tabsize int) []string {
+	prefixWidth := func(prefix string) int {
+		return util.StringWidth([]byte(prefix), utf8.RuneCountInString(prefix), tabsize)
+	}
+
+	var lines []string
+	var line strings.Builder
+	line.WriteString(first)
+	w := prefixWidth(first)
+	empty := true
+	for _, a := range atoms {
+		add, sep := a.width, ""
+		if !empty && a.space {
+			add, sep = add+1, " "
+		}
+		breakable := a.space || !strings.Contains(noBreakBefore, a.text)
+		if !empty && w+add > width && breakable {
+			lines = append(lines, line.String())
+			line.Reset()
+			line.WriteString(rest)
+			w = prefixWidth(rest)
+			add, sep = a.width, ""
+		}
+		line.WriteString(sep)
+		line.WriteString(a.text)
+		w += add
+		empty = false
+	}
+	return append(lines, line.String())
+}
+
+// reflow fills the paragraphs of the lines so that they fit in the given
+// width. Paragraphs are separated by blank lines, list items and changes
+// of prefix
+func reflow(lines []string, leader string, width, tabsize int) []string {
+	var out []string
+	for i := 0; i < len(lines); {
+		p, content := parsePrefix(lines[i], leader)
+		if isBlank(content) {
+			out = append(out, lines[i])
+			i++
+			continue
+		}
+
+		atoms := appendAtoms(nil, content)
+		for i++; i < len(lines); i++ {
+			q, content := parsePrefix(lines[i], leader)
+			if isBlank(content) || !p.follows(q) {
+				break
+			}
+			atoms = appendAtoms(atoms, content)
+		}
+		out = append(out, fill(atoms, p.lead+p.bullet, p.continuation(), width, tabsize)...)
+	}
+	return out
+}
+
+// commentLeader returns the leader of the line comments of the filetype,
+// as given by the comment.type option of the comment plugin, or the
+// legacy commenttype option
+func (b *Buffer) commentLeader() string {
+	commentType := ""
+	for _, opt := range []string{"comment.type", "commenttype"} {
+		if s, ok := b.Settings[opt].(string); ok && s != "" {
+			commentType = s
+			break
+		}
+	}
+	before, after, found := strings.Cut(commentType, "%s")
+	if !found || strings.TrimSpace(after) != "" {
+		// block comments have no leader
+		return ""
+	}
+	return strings.TrimSpace(before)
+}
+
+// reflowWidth returns the width of the reflowed text
+func (b *Buffer) reflowWidth() int {
+	if width := util.IntOpt(b.Settings["textwidth"]); width > 0 {
+		return width
+	}
+	return defaultReflowWidth
+}
+
+// Paragraph returns the first and last lines of the paragraph at the given
+// line, or false if the line is blank
+func (b *Buffer) Paragraph(lineN int) (int, int, bool) {
+	leader := b.commentLeader()
+	prefix := func(n int) (linePrefix, bool) {
+		p, content := parsePrefix(string(b.LineBytes(n)), leader)
+		return p, !isBlank(content)
+	}
+
+	p, ok := prefix(lineN)
+	if !ok {
+		return 0, 0, false
+	}
+	start := lineN
+	for start > 0 && p.bullet == "" {
+		prev, ok := prefix(start - 1)
+		if !ok || !prev.follows(p) {
+			break
+		}
+		start, p = start-1, prev
+	}
+	end := lineN
+	for end+1 < b.LinesNum() {
+		next, ok := prefix(end + 1)
+		if !ok || !p.follows(next) {
+			break
+		}
+		end++
+	}
+	return start, end, true
+}
+
+// Reflow fills the paragraphs of the lines from start to end, which are
+// reflowed to the width of the textwidth option, keeping their comment
+// leaders, blockquote markers, list bullets and indentation. It returns
+// the last line of the reflowed text
+func (b *Buffer) Reflow(start, end int) int {
+	lines := make([]string, 0, end-start+1)
+	for i := start; i <= end; i++ {
+		lines = append(lines, string(b.LineBytes(i)))
+	}
+	tabsize := util.IntOpt(b.Settings["tabsize"])
+	out := reflow(lines, b.commentLeader(), b.reflowWidth(), tabsize)
+
+	text := strings.Join(out, "\n")
+	if text != strings.Join(lines, "\n") {
+		b.Replace(Loc{0, start}, Loc{util.CharacterCount(b.LineBytes(end)), end}, text)
+	}
+	return start + len(out) - 1
+}
+
+// HardWrap breaks the line of the cursor when the text before the cursor
+// is wider than the textwidth option, as the text is typed. Only comments
+// are wrapped in code, and all the text in the prose filetypes
+func (b *Buffer) HardWrap(c *Cursor) {
+	width := util.IntOpt(b.Settings["textwidth"])
+	if width <= 0 {
+		return
+	}
+	line := string(b.LineBytes(c.Y))
+	p, content := parsePrefix(line, b.commentLeader())
+	if !p.comment && !proseFiletypes[b.Settings["filetype"].(string)] {
+		return
+	}
+	tabsize := util.IntOpt(b.Settings["tabsize"])
+	if util.StringWidth([]byte(line), c.X, tabsize) <= width {
+		return
+	}
+
+	// the break is the last space, or the last position next to a wide
+	// character, which fits in the width. The text before the first
+	// space is never moved
+	prefixLen := utf8.RuneCountInString(line) - utf8.RuneCountInString(content)
+	breakStart, breakEnd := -1, -1
+	x := prefixLen
+	w := util.StringWidth([]byte(line), x, tabsize)
+	var prev rune
+	for _, r := range content {
+		if x >= c.X {
+			break
+		}
+		rw := runewidth.RuneWidth(r)
+		if r == ' ' || r == '\t' {
+			if prev != ' ' && prev != '\t' && prev != 0 {
+				if breakStart >= 0 && w > width {
+					break
+				}
+				breakStart = x
+			}
+			breakEnd = x + 1
+		} else if prev != 0 && prev != ' ' && prev != '\t' &&
+			(rw > 1 || runewidth.RuneWidth(prev) > 1) && !strings.ContainsRune(noBreakBefore, r) {
+			if breakStart >= 0 && w > width {
+				break
+			}
+			breakStart, breakEnd = x, x
+		}
+		if r == '\t' {
+			w += tabsize - w%tabsize
+		} else {
+			w += rw
+		}
+		prev = r
+		x++
+	}
+	if breakStart < 0 || breakStart <= prefixLen {
+		return
+	}
+	b.Replace(Loc{breakStart, c.Y}, Loc{breakEnd, c.Y}, "\n"+p.continuation())
+}
@@ -0,0 +1,91 @@
+package buffer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePrefix(t *testing.T) {
+	p, content := parsePrefix("\t// - item", "//")
+	assert.Equal(t, linePrefix{lead: "\t// ", bullet: "- ", comment: true}, p)
+	assert.Equal(t, "item", content)
+	assert.Equal(t, "\t//   ", p.continuation())
+
+	p, content = parsePrefix("> > 12. quoted", "")
+	assert.Equal(t, linePrefix{lead: "> > ", bullet: "12. "}, p)
+	assert.Equal(t, "quoted", content)
+
+	p, content = parsePrefix("-1 is not a bullet", "#")
+	assert.Equal(t, linePrefix{}, p)
+	assert.Equal(t, "-1 is not a bullet", content)
+}
+
+func TestReflow(t *testing.T) {
+	reflowed := func(text, leader string, width int) string {
+		return strings.Join(reflow(strings.Split(text, "\n"), leader, width, 4), "\n")
+	}
+
+	assert.Equal(t, "one two\nthree four\nfive\n\nsix seven",
+		reflowed("one\ntwo three four five\n\nsix\nseven", "", 10))
+	assert.Equal(t, "\t// one two\n\t// three\n\t//\n\t//four",
+		reflowed("\t// one two three\n\t//\n\t//four", "//", 14))
+	assert.Equal(t, "- one two\n  three\n- four\n  five six",
+		reflowed("- one two three\n- four\n  five\n  six", "", 10))
+	assert.Equal(t, "# > 1. one\n# >    two\n# >    three\n#\n#    two",
+		reflowed("# > 1. one two\n# > three\n#\n#    two", "#", 10))
+	// wide characters
+	assert.Equal(t, "日本語の文\n章です。",
+		reflowed("日本語の\n文章です。", "", 10))
+	assert.Equal(t, "a 日本語の\n文章。",
+		reflowed("a 日本語の文章。", "", 10))
+	// long words are not split
+	assert.Equal(t, "one\nextraordinarily\nlong", reflowed("one extraordinarily long", "", 10))
+}
+
+func TestBufferReflow(t *testing.T) {
+	b := NewBufferFromString("// one two three\n// four\n\nfunc main() {}\n", "", BTDefault)
+	defer b.Close()
+	b.Settings["comment.type"] = "// %s"
+	b.Settings["textwidth"] = float64(12)
+
+	start, end, ok := b.Paragraph(1)
+	assert.True(t, ok)
+	assert.Equal(t, 0, start)
+	assert.Equal(t, 1, end)
+	_, _, ok = b.Paragraph(2)
+	assert.False(t, ok)
+
+	assert.Equal(t, 2, b.Reflow(start, end))
+	assert.Equal(t, "// one two\n// three\n// four\n\nfunc main() {}\n", string(b.Bytes()))
+}
+
+func TestHardWrap(t *testing.T) {
+	b := NewBufferFromString("x := 1 // one two three", "", BTDefault)
+	defer b.Close()
+	b.Settings["filetype"] = "go"
+	b.Settings["comment.type"] = "// %s"
+	b.Settings["textwidth"] = float64(15)
+	c := b.GetActiveCursor()
+
+	// code is not wrapped
+	c.GotoLoc(b.End())
+	b.HardWrap(c)
+	assert.Equal(t, "x := 1 // one two three", string(b.Bytes()))
+
+	b.Replace(b.Start(), b.End(), "\t// - one two")
+	c.GotoLoc(b.End())
+	b.Insert(c.Loc, "x")
+	b.HardWrap(c)
+	assert.Equal(t, "\t// - one\n\t//   twox", string(b.Bytes()))
+	assert.Equal(t, Loc{X: 10, Y: 1}, c.Loc)
+
+	// all the text is wrapped in prose filetypes
+	b.Settings["filetype"] = "markdown"
+	b.Settings["textwidth"] = float64(12)
+	b.Replace(b.Start(), b.End(), "> one two three")
+	c.GotoLoc(b.End())
+	b.HardWrap(c)
+	assert.Equal(t, "> one two\n> three", string(b.Bytes()))
+}
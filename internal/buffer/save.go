@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"io/fs"
+	"log"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -14,9 +15,11 @@ import (
 	"time"
 	"unicode"
 
+	"github.com/helmutkemper/micro/v2/internal/clipboard"
 	"github.com/helmutkemper/micro/v2/internal/config"
 	"github.com/helmutkemper/micro/v2/internal/screen"
 	"github.com/helmutkemper/micro/v2/internal/util"
+	"github.com/helmutkemper/micro/v2/internal/vfs"
 	"golang.org/x/text/transform"
 )
 
@@ -133,8 +136,6 @@ func (wf wrappedFile) Truncate() error {
 }
 
 func (wf wrappedFile) Write(b *SharedBuffer) (int, error) {
-	file := bufio.NewWriter(transform.NewWriter(wf.writeCloser, b.encoding.NewEncoder()))
-
 	b.Lock()
 	defer b.Unlock()
 
@@ -142,6 +143,25 @@ func (wf wrappedFile) Write(b *SharedBuffer) (int, error) {
 		return 0, nil
 	}
 
+	err := wf.Truncate()
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := b.encodeLines(wf.writeCloser)
+	if err == nil && !wf.withSudo {
+		// Call Sync() on the file to make sure the content is safely on disk.
+		f := wf.writeCloser.(*os.File)
+		err = f.Sync()
+	}
+	return size, err
+}
+
+// encodeLines writes the lines of the buffer to w, with the encoding and the
+// line endings of the buffer. The buffer must be locked
+func (b *SharedBuffer) encodeLines(w io.Writer) (int, error) {
+	file := bufio.NewWriter(transform.NewWriter(w, b.encoding.NewEncoder()))
+
 	// end of line
 	var eol []byte
 	if b.Endings == FFDos {
@@ -150,34 +170,24 @@ func (wf wrappedFile) Write(b *SharedBuffer) (int, error) {
 		eol = []byte{'\n'}
 	}
 
-	err := wf.Truncate()
-	if err != nil {
-		return 0, err
-	}
-
 	// write lines
 	size, err := file.Write(b.lines[0].data)
 	if err != nil {
 		return 0, err
 	}
 
-	for _, l := range b.lines[1:] {
+	for i := 1; i < len(b.lines); i++ {
+		data := b.lines[i].data
 		if _, err = file.Write(eol); err != nil {
 			return 0, err
 		}
-		if _, err = file.Write(l.data); err != nil {
+		if _, err = file.Write(data); err != nil {
 			return 0, err
 		}
-		size += len(eol) + len(l.data)
+		size += len(eol) + len(data)
 	}
 
-	err = file.Flush()
-	if err == nil && !wf.withSudo {
-		// Call Sync() on the file to make sure the content is safely on disk.
-		f := wf.writeCloser.(*os.File)
-		err = f.Sync()
-	}
-	return size, err
+	return size, file.Flush()
 }
 
 func (wf wrappedFile) Close() error {
@@ -198,6 +208,14 @@ func (wf wrappedFile) Close() error {
 }
 
 func (b *SharedBuffer) overwriteFile(name string) (int, error) {
+	if b.encryption != nil {
+		data, err := b.encodedBytes()
+		if err != nil {
+			return 0, err
+		}
+		return len(data), os.WriteFile(name, data, util.FileMode)
+	}
+
 	file, err := openFile(name, false)
 	if err != nil {
 		return 0, err
@@ -247,11 +265,20 @@ func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error
 		return errors.New("Cannot save scratch buffer")
 	}
 
+	if !autoSave && b.Settings["formatonsave"].(bool) && b.Formatter() != "" {
+		// the file is saved even if it can't be formatted
+		if err := b.Format(); err != nil && prompt != nil {
+			prompt.Message("Could not format the file: ", err)
+		}
+		b.RelocateCursors()
+	}
+
 	if !autoSave && b.Settings["rmtrailingws"].(bool) {
-		for i, l := range b.lines {
-			leftover := util.CharacterCount(bytes.TrimRightFunc(l.data, unicode.IsSpace))
+		for i := range b.lines {
+			data := b.lines[i].data
+			leftover := util.CharacterCount(bytes.TrimRightFunc(data, unicode.IsSpace))
 
-			linelen := util.CharacterCount(l.data)
+			linelen := util.CharacterCount(data)
 			b.Remove(Loc{leftover, i}, Loc{linelen, i})
 		}
 
@@ -270,8 +297,12 @@ func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error
 		return err
 	}
 
+	if withSudo && (vfs.IsVirtual(filename) || b.encryption != nil) {
+		return errors.New("Cannot save " + filename + " with " + config.GlobalSettings["sucmd"].(string))
+	}
+
 	newFile := false
-	fileInfo, err := os.Stat(filename)
+	fileInfo, err := vfs.Stat(filename)
 	if err != nil {
 		if !errors.Is(err, fs.ErrNotExist) {
 			return err
@@ -285,13 +316,13 @@ func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error
 		return errors.New("Error: " + filename + " is not a regular file and cannot be saved")
 	}
 
-	absFilename, err := filepath.Abs(filename)
+	absFilename, err := vfs.Abs(filename)
 	if err != nil {
 		return err
 	}
 
 	// Get the leading path to the file | "." is returned if there's no leading path provided
-	if dirname := filepath.Dir(absFilename); dirname != "." {
+	if dirname := filepath.Dir(vfs.HostPath(absFilename)); dirname != "." {
 		// Check if the parent dirs don't exist
 		if _, statErr := os.Stat(dirname); errors.Is(statErr, fs.ErrNotExist) {
 			// Prompt to make sure they want to create the dirs that are missing
@@ -338,8 +369,16 @@ func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error
 	b.Path = filename
 	b.AbsPath = absFilename
 	b.isModified = false
+	b.invalidateBlame()
+	if b.encryption != nil {
+		clipboard.SetPrivate(b.GetName(), true)
+	}
 	b.UpdateModTime()
 
+	if err := b.snapshot(); err != nil {
+		log.Println("Could not add", filename, "to the file history:", err)
+	}
+
 	if newPath {
 		// need to update glob-based and filetype-based settings
 		b.ReloadSettings(true)
@@ -354,6 +393,10 @@ func (b *Buffer) saveToFile(filename string, withSudo bool, autoSave bool) error
 // This means that the file is not overwritten directly but by writing to the
 // backup file first.
 func (b *SharedBuffer) safeWrite(path string, withSudo bool, newFile bool) (int, error) {
+	if vfs.IsVirtual(path) || b.encryption != nil {
+		return b.safeWriteData(path)
+	}
+
 	file, err := openFile(path, withSudo)
 	if err != nil {
 		return 0, err
@@ -398,3 +441,32 @@ func (b *SharedBuffer) safeWrite(path string, withSudo bool, newFile bool) (int,
 
 	return size, err
 }
+
+// safeWriteData writes the buffer to a file which is not a plain file on
+// disk, such as a file inside an archive, or which is encrypted, after
+// backing it up like safeWrite. The backup holds the same data as the file,
+// so that the backup kept of an encrypted file is identical to the file
+func (b *SharedBuffer) safeWriteData(path string) (int, error) {
+	data, err := b.encodedBytes()
+	if err != nil {
+		return 0, err
+	}
+	backupName, resolveName, err := b.writeBackupWith(path, func(name string) error {
+		return os.WriteFile(name, data, util.FileMode)
+	})
+	if err != nil {
+		return 0, err
+	}
+	delete(requestedBackups, b)
+
+	err = vfs.WriteFile(path, data)
+	if err != nil {
+		b.forceKeepBackup = true
+		return 0, util.OverwriteError{What: err, BackupName: backupName}
+	}
+
+	if !b.keepBackup() {
+		b.removeBackup(backupName, resolveName)
+	}
+	return len(data), nil
+}
@@ -13,34 +13,56 @@ import (
 )
 
 // The SerializedBuffer holds the types that get serialized when a buffer is saved
-// These are used for the savecursor and saveundo options
+// These are used for the savecursor, saveundo and savebookmarks options
 type SerializedBuffer struct {
 	EventHandler *EventHandler
 	Cursor       Loc
 	ModTime      time.Time
+	Bookmarks    []Bookmark
 }
 
 // Serialize serializes the buffer to config.ConfigDir/buffers
 func (b *Buffer) Serialize() error {
-	if !b.Settings["savecursor"].(bool) && !b.Settings["saveundo"].(bool) {
+	if !b.Settings["savecursor"].(bool) && !b.Settings["saveundo"].(bool) && !b.Settings["savebookmarks"].(bool) {
 		return nil
 	}
 	if b.Path == "" {
 		return nil
 	}
 
+	name, resolveName := util.DetermineEscapePath(filepath.Join(config.ConfigDir, "buffers"), b.AbsPath)
+
+	var bookmarks []Bookmark
+	if b.Settings["savebookmarks"].(bool) {
+		for _, bm := range b.Bookmarks {
+			bookmarks = append(bookmarks, *bm)
+		}
+	}
+	if !b.Settings["savecursor"].(bool) && !b.Settings["saveundo"].(bool) && len(bookmarks) == 0 {
+		// Nothing left to remember about this file
+		os.Remove(name)
+		if resolveName != "" {
+			os.Remove(resolveName)
+		}
+		return nil
+	}
+
 	var buf bytes.Buffer
 	err := gob.NewEncoder(&buf).Encode(SerializedBuffer{
 		b.EventHandler,
 		b.GetActiveCursor().Loc,
 		b.ModTime,
+		bookmarks,
 	})
 	if err != nil {
 		return err
 	}
 
-	name, resolveName := util.DetermineEscapePath(filepath.Join(config.ConfigDir, "buffers"), b.AbsPath)
-	err = util.SafeWrite(name, buf.Bytes(), true)
+	data, err := b.sealIfEncrypted(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	err = util.SafeWrite(name, data, true)
 	if err != nil {
 		return err
 	}
@@ -57,17 +79,16 @@ func (b *Buffer) Serialize() error {
 
 // Unserialize loads the buffer info from config.ConfigDir/buffers
 func (b *Buffer) Unserialize() error {
-	// If either savecursor or saveundo is turned on, we need to load the serialized information
+	// If savecursor, saveundo or savebookmarks is turned on, we need to load the serialized information
 	// from ~/.config/micro/buffers
 	if b.Path == "" {
 		return nil
 	}
 	name, _ := util.DetermineEscapePath(filepath.Join(config.ConfigDir, "buffers"), b.AbsPath)
-	file, err := os.Open(name)
+	data, err := b.readEncrypted(name)
 	if err == nil {
-		defer file.Close()
 		var buffer SerializedBuffer
-		decoder := gob.NewDecoder(file)
+		decoder := gob.NewDecoder(bytes.NewReader(data))
 		err = decoder.Decode(&buffer)
 		if err != nil {
 			return errors.New(err.Error() + "\nYou may want to remove the files in ~/.config/micro/buffers (these files\nstore the information for the 'saveundo' and 'savecursor' options) if\nthis problem persists.\nThis may be caused by upgrading to version 2.0, and removing the 'buffers'\ndirectory will reset the cursor and undo history and solve the problem.")
@@ -84,6 +105,13 @@ func (b *Buffer) Unserialize() error {
 				b.EventHandler.buf = b.SharedBuffer
 			}
 		}
+
+		// the bookmarks would be on other lines if the file was modified
+		if b.Settings["savebookmarks"].(bool) && len(b.Bookmarks) == 0 && b.ModTime == buffer.ModTime {
+			for _, bm := range buffer.Bookmarks {
+				b.AddBookmark(bm.Name, bm.Loc)
+			}
+		}
 	}
 	return nil
 }
@@ -0,0 +1,727 @@
+package buffer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/util"
+	"github.com/micro-editor/json5"
+)
+
+// A Snippet is a piece of text with tabstops that can be inserted into a
+// buffer. Snippets are read from the RTSnippet runtime files, which are
+// named after the filetype they apply to and use the JSON format of
+// VSCode/TextMate snippets:
+//
+//	{
+//	    "For loop": {
+//	        "prefix": "for",
+//	        "body": ["for ${1:i} := 0; $1 < ${2:n}; $1++ {", "\t$0", "}"],
+//	        "description": "A for loop"
+//	    }
+//	}
+type Snippet struct {
+	Name        string
+	Prefix      string
+	Description string
+	Body        string
+}
+
+type snippetJSON struct {
+	Prefix      any    `json:"prefix"`
+	Body        any    `json:"body"`
+	Description string `json:"description"`
+}
+
+// ParseSnippets parses the contents of a snippets file. A snippet with
+// several prefixes is returned once per prefix
+func ParseSnippets(data []byte) ([]*Snippet, error) {
+	var parsed map[string]snippetJSON
+	if err := json5.Unmarshal(data, &parsed); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(parsed))
+	for name := range parsed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var snippets []*Snippet
+	for _, name := range names {
+		s := parsed[name]
+		body, err := jsonStrings(s.Body)
+		if err != nil {
+			return nil, fmt.Errorf("snippet %q: invalid body: %v", name, err)
+		}
+		prefixes, err := jsonStrings(s.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("snippet %q: invalid prefix: %v", name, err)
+		}
+		for _, prefix := range prefixes {
+			if prefix == "" {
+				continue
+			}
+			snippets = append(snippets, &Snippet{
+				Name:        name,
+				Prefix:      prefix,
+				Description: s.Description,
+				Body:        strings.Join(body, "\n"),
+			})
+		}
+	}
+	return snippets, nil
+}
+
+// jsonStrings converts a JSON value that may be either a string or an
+// array of strings
+func jsonStrings(v any) ([]string, error) {
+	switch t := v.(type) {
+	case string:
+		return []string{t}, nil
+	case []any:
+		strs := make([]string, len(t))
+		for i, e := range t {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, got %v", e)
+			}
+			strs[i] = s
+		}
+		return strs, nil
+	}
+	return nil, fmt.Errorf("expected a string or an array of strings, got %v", v)
+}
+
+// snippets caches the snippets of each filetype, so that the snippet files
+// are only read once and not at each completion
+var snippets = make(map[string][]*Snippet)
+
+// Snippets returns the snippets available for the given filetype. User
+// snippets come first so that they take precedence over the default ones
+func Snippets(filetype string) []*Snippet {
+	if s, ok := snippets[filetype]; ok {
+		return s
+	}
+	var fsnippets []*Snippet
+	for _, f := range config.ListRuntimeFiles(config.RTSnippet) {
+		if f.Name() != filetype {
+			continue
+		}
+		data, err := f.Data()
+		if err != nil {
+			log.Println("Error loading snippets:", err)
+			continue
+		}
+		s, err := ParseSnippets(data)
+		if err != nil {
+			log.Println("Error loading snippets for", filetype+":", err)
+			continue
+		}
+		fsnippets = append(fsnippets, s...)
+	}
+	snippets[filetype] = fsnippets
+	return fsnippets
+}
+
+// ClearSnippets clears the cached snippets, so that the snippet files are
+// read again when the runtime files are reloaded
+func ClearSnippets() {
+	clear(snippets)
+}
+
+// Snippets returns the snippets available in this buffer
+func (b *Buffer) Snippets() []*Snippet {
+	return Snippets(b.Settings["filetype"].(string))
+}
+
+type snippetNodeKind int
+
+const (
+	snText snippetNodeKind = iota
+	snTabstop
+	snVariable
+)
+
+// A snippetNode is an element of a parsed snippet body: some text, a
+// tabstop (possibly with a placeholder or choices) or a variable
+type snippetNode struct {
+	kind     snippetNodeKind
+	text     string
+	num      int
+	name     string
+	children []snippetNode
+	choices  []string
+}
+
+type snippetParser struct {
+	r []rune
+	i int
+}
+
+func parseSnippetBody(body string) []snippetNode {
+	p := &snippetParser{r: []rune(body)}
+	var nodes []snippetNode
+	for p.i < len(p.r) {
+		nodes = append(nodes, p.parse()...)
+		if p.i < len(p.r) {
+			// unmatched '}' at the top level
+			nodes = append(nodes, snippetNode{kind: snText, text: "}"})
+			p.i++
+		}
+	}
+	return nodes
+}
+
+func (p *snippetParser) peek(r rune) bool {
+	return p.i < len(p.r) && p.r[p.i] == r
+}
+
+// parse parses nodes until the end of the body or until a '}', which closes
+// the current placeholder and is not consumed
+func (p *snippetParser) parse() []snippetNode {
+	var nodes []snippetNode
+	var text []rune
+	flush := func() {
+		if len(text) > 0 {
+			nodes = append(nodes, snippetNode{kind: snText, text: string(text)})
+			text = nil
+		}
+	}
+
+	for p.i < len(p.r) {
+		c := p.r[p.i]
+		switch {
+		case c == '\\' && p.i+1 < len(p.r) && strings.ContainsRune(`$}\`, p.r[p.i+1]):
+			text = append(text, p.r[p.i+1])
+			p.i += 2
+		case c == '}':
+			flush()
+			return nodes
+		case c == '$':
+			if n, ok := p.parseDollar(); ok {
+				flush()
+				nodes = append(nodes, n)
+			} else {
+				text = append(text, c)
+				p.i++
+			}
+		default:
+			text = append(text, c)
+			p.i++
+		}
+	}
+	flush()
+	return nodes
+}
+
+func (p *snippetParser) parseInt() (int, bool) {
+	start := p.i
+	for p.i < len(p.r) && p.r[p.i] >= '0' && p.r[p.i] <= '9' {
+		p.i++
+	}
+	if p.i == start {
+		return 0, false
+	}
+	n, err := strconv.Atoi(string(p.r[start:p.i]))
+	return n, err == nil
+}
+
+func (p *snippetParser) parseName() (string, bool) {
+	start := p.i
+	for p.i < len(p.r) {
+		c := p.r[p.i]
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (p.i > start && c >= '0' && c <= '9') {
+			p.i++
+			continue
+		}
+		break
+	}
+	return string(p.r[start:p.i]), p.i > start
+}
+
+// parseDollar parses a tabstop or a variable starting at a '$'. If there is
+// no valid construct, the parser is left untouched and false is returned
+func (p *snippetParser) parseDollar() (snippetNode, bool) {
+	start := p.i
+	fail := func() (snippetNode, bool) {
+		p.i = start
+		return snippetNode{}, false
+	}
+
+	p.i++
+	if n, ok := p.parseInt(); ok {
+		return snippetNode{kind: snTabstop, num: n}, true
+	}
+	if name, ok := p.parseName(); ok {
+		return snippetNode{kind: snVariable, name: name}, true
+	}
+	if !p.peek('{') {
+		return fail()
+	}
+	p.i++
+
+	var node snippetNode
+	if n, ok := p.parseInt(); ok {
+		node = snippetNode{kind: snTabstop, num: n}
+	} else if name, ok := p.parseName(); ok {
+		node = snippetNode{kind: snVariable, name: name}
+	} else {
+		return fail()
+	}
+
+	switch {
+	case p.peek('}'):
+	case p.peek(':'):
+		p.i++
+		node.children = p.parse()
+	case p.peek('|') && node.kind == snTabstop:
+		p.i++
+		choices, ok := p.parseChoices()
+		if !ok {
+			return fail()
+		}
+		node.choices = choices
+	case p.peek('/'):
+		// transforms are not supported, the tabstop or variable
+		// is inserted as is
+		if !p.skipTransform() {
+			return fail()
+		}
+	default:
+		return fail()
+	}
+
+	if !p.peek('}') {
+		return fail()
+	}
+	p.i++
+	return node, true
+}
+
+// parseChoices parses the choices of a ${1|one,two|} tabstop, stopping
+// before the closing '}'
+func (p *snippetParser) parseChoices() ([]string, bool) {
+	var choices []string
+	var cur []rune
+	for p.i < len(p.r) {
+		c := p.r[p.i]
+		switch {
+		case c == '\\' && p.i+1 < len(p.r) && strings.ContainsRune(`$}\,|`, p.r[p.i+1]):
+			cur = append(cur, p.r[p.i+1])
+			p.i += 2
+		case c == ',':
+			choices = append(choices, string(cur))
+			cur = nil
+			p.i++
+		case c == '|' && p.i+1 < len(p.r) && p.r[p.i+1] == '}':
+			p.i++
+			return append(choices, string(cur)), true
+		default:
+			cur = append(cur, c)
+			p.i++
+		}
+	}
+	return nil, false
+}
+
+// skipTransform skips a /regex/format/flags transform, stopping before
+// the closing '}'
+func (p *snippetParser) skipTransform() bool {
+	for part := 0; part < 2; part++ {
+		p.i++
+		for p.i < len(p.r) && p.r[p.i] != '/' {
+			if p.r[p.i] == '\\' {
+				p.i++
+			}
+			p.i++
+		}
+		if p.i >= len(p.r) {
+			return false
+		}
+	}
+	p.i++
+	for p.i < len(p.r) && p.r[p.i] != '}' {
+		p.i++
+	}
+	return p.i < len(p.r)
+}
+
+// A snippetStop is a tabstop of an inserted snippet. It has a range for
+// each of its occurrences, since a tabstop may be mirrored
+type snippetStop struct {
+	num     int
+	ranges  [][2]Loc
+	choices []string
+}
+
+// maxSnippetDepth limits the expansion of placeholders mirrored inside
+// themselves
+const maxSnippetDepth = 8
+
+type snippetExpander struct {
+	text      strings.Builder
+	lineStart int
+	y         int
+
+	indent string
+	tab    string
+	vars   func(name string) (string, bool)
+
+	placeholders map[int][]snippetNode
+	stops        map[int]*snippetStop
+	depth        int
+}
+
+func (e *snippetExpander) loc() Loc {
+	return Loc{util.CharacterCountInString(e.text.String()[e.lineStart:]), e.y}
+}
+
+// write writes text to the snippet, indenting new lines like the line the
+// snippet is inserted in
+func (e *snippetExpander) write(s string) {
+	for _, r := range s {
+		switch r {
+		case '\n':
+			e.text.WriteByte('\n')
+			e.lineStart = e.text.Len()
+			e.y++
+			e.text.WriteString(e.indent)
+		case '\t':
+			e.text.WriteString(e.tab)
+		default:
+			e.text.WriteRune(r)
+		}
+	}
+}
+
+func (e *snippetExpander) collectPlaceholders(nodes []snippetNode) {
+	for _, n := range nodes {
+		if n.kind == snTabstop && len(n.children) > 0 {
+			if _, ok := e.placeholders[n.num]; !ok {
+				e.placeholders[n.num] = n.children
+			}
+		}
+		e.collectPlaceholders(n.children)
+	}
+}
+
+func (e *snippetExpander) expand(nodes []snippetNode) {
+	for _, n := range nodes {
+		switch n.kind {
+		case snText:
+			e.write(n.text)
+		case snVariable:
+			if v, ok := e.vars(n.name); ok {
+				e.write(v)
+			} else if len(n.children) > 0 {
+				e.expand(n.children)
+			} else {
+				e.write(n.name)
+			}
+		case snTabstop:
+			start := e.loc()
+			stop, ok := e.stops[n.num]
+			if !ok {
+				stop = &snippetStop{num: n.num}
+				e.stops[n.num] = stop
+			}
+			if len(n.choices) > 0 {
+				e.write(n.choices[0])
+				if stop.choices == nil {
+					stop.choices = n.choices
+				}
+			} else if e.depth < maxSnippetDepth {
+				children := n.children
+				if len(children) == 0 {
+					children = e.placeholders[n.num]
+				}
+				e.depth++
+				e.expand(children)
+				e.depth--
+			}
+			stop.ranges = append(stop.ranges, [2]Loc{start, e.loc()})
+		}
+	}
+}
+
+// expandSnippet expands a snippet body. It returns the text of the snippet
+// and its tabstops, in the order they are visited. The locations of the
+// tabstops are relative to the start of the snippet. The final tabstop ($0)
+// is always the last one, and is added at the end of the snippet if the
+// body does not contain it
+func expandSnippet(body, indent, tab string, vars func(string) (string, bool)) (string, []*snippetStop) {
+	nodes := parseSnippetBody(body)
+	e := &snippetExpander{
+		indent:       indent,
+		tab:          tab,
+		vars:         vars,
+		placeholders: make(map[int][]snippetNode),
+		stops:        make(map[int]*snippetStop),
+	}
+	e.collectPlaceholders(nodes)
+	e.expand(nodes)
+
+	stops := make([]*snippetStop, 0, len(e.stops)+1)
+	for _, s := range e.stops {
+		if s.num != 0 {
+			stops = append(stops, s)
+		}
+	}
+	sort.Slice(stops, func(i, j int) bool {
+		return stops[i].num < stops[j].num
+	})
+	final, ok := e.stops[0]
+	if !ok {
+		end := e.loc()
+		final = &snippetStop{ranges: [][2]Loc{{end, end}}}
+	}
+	stops = append(stops, final)
+
+	return e.text.String(), stops
+}
+
+// snippetVars returns the function resolving the variables of a snippet
+// inserted at loc
+func (b *Buffer) snippetVars(loc Loc) func(string) (string, bool) {
+	now := time.Now()
+	return func(name string) (string, bool) {
+		switch name {
+		case "TM_FILENAME":
+			return filepath.Base(b.GetName()), true
+		case "TM_FILENAME_BASE":
+			base := filepath.Base(b.GetName())
+			return strings.TrimSuffix(base, filepath.Ext(base)), true
+		case "TM_DIRECTORY":
+			if b.AbsPath == "" {
+				return "", true
+			}
+			return filepath.Dir(b.AbsPath), true
+		case "TM_FILEPATH":
+			return b.AbsPath, true
+		case "TM_LINE_INDEX":
+			return strconv.Itoa(loc.Y), true
+		case "TM_LINE_NUMBER":
+			return strconv.Itoa(loc.Y + 1), true
+		case "TM_CURRENT_LINE":
+			return b.Line(loc.Y), true
+		case "TM_CURRENT_WORD":
+			line := []rune(b.Line(loc.Y))
+			start, end := loc.X, loc.X
+			for start > 0 && start <= len(line) && util.IsWordChar(line[start-1]) {
+				start--
+			}
+			for end < len(line) && util.IsWordChar(line[end]) {
+				end++
+			}
+			if start > end || end > len(line) {
+				return "", true
+			}
+			return string(line[start:end]), true
+		case "TM_SELECTED_TEXT":
+			return string(b.GetActiveCursor().GetSelection()), true
+		case "CURRENT_YEAR":
+			return now.Format("2006"), true
+		case "CURRENT_YEAR_SHORT":
+			return now.Format("06"), true
+		case "CURRENT_MONTH":
+			return now.Format("01"), true
+		case "CURRENT_MONTH_NAME":
+			return now.Format("January"), true
+		case "CURRENT_MONTH_NAME_SHORT":
+			return now.Format("Jan"), true
+		case "CURRENT_DATE":
+			return now.Format("02"), true
+		case "CURRENT_DAY_NAME":
+			return now.Format("Monday"), true
+		case "CURRENT_DAY_NAME_SHORT":
+			return now.Format("Mon"), true
+		case "CURRENT_HOUR":
+			return now.Format("15"), true
+		case "CURRENT_MINUTE":
+			return now.Format("04"), true
+		case "CURRENT_SECOND":
+			return now.Format("05"), true
+		case "CURRENT_SECONDS_UNIX":
+			return strconv.FormatInt(now.Unix(), 10), true
+		case "RANDOM":
+			return fmt.Sprintf("%06d", randomInt()%1000000), true
+		case "RANDOM_HEX":
+			return randomHex(3), true
+		case "UUID":
+			h := randomHex(16)
+			return h[:8] + "-" + h[8:12] + "-4" + h[13:16] + "-" + h[16:20] + "-" + h[20:], true
+		}
+		return "", false
+	}
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func randomInt() int {
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return int(buf[0])<<16 | int(buf[1])<<8 | int(buf[2])
+}
+
+// A snippetSession holds the state of a snippet being edited: its tabstops
+// and the region of the buffer it covers. Its locations follow the edits
+// made to the buffer
+type snippetSession struct {
+	stops      []*snippetStop
+	cur        int
+	start, end Loc
+}
+
+// InsertSnippet inserts the given snippet at loc and selects its first
+// tabstop. Snippets can be inserted while another one is being edited, in
+// which case the outer snippet resumes once the inner one is done
+func (b *Buffer) InsertSnippet(loc Loc, s *Snippet) {
+	indent := string(util.GetLeadingWhitespace(b.LineBytes(loc.Y)))
+	tab := b.IndentString(util.IntOpt(b.Settings["tabsize"]))
+
+	text, stops := expandSnippet(s.Body, indent, tab, b.snippetVars(loc))
+
+	abs := func(l Loc) Loc {
+		if l.Y == 0 {
+			return Loc{loc.X + l.X, loc.Y}
+		}
+		return Loc{l.X, loc.Y + l.Y}
+	}
+	for _, st := range stops {
+		for i, r := range st.ranges {
+			st.ranges[i] = [2]Loc{abs(r[0]), abs(r[1])}
+		}
+	}
+
+	b.Insert(loc, text)
+
+	b.snippetSessions = append(b.snippetSessions, &snippetSession{
+		stops: stops,
+		cur:   -1,
+		start: loc,
+		end:   textEnd(loc, []byte(text)),
+	})
+	b.GetActiveCursor().GotoLoc(loc)
+	b.NextSnippetStop(true)
+}
+
+// InSnippet returns true if a snippet is being edited
+func (b *Buffer) InSnippet() bool {
+	return len(b.snippetSessions) > 0
+}
+
+// ExitSnippet stops editing the current snippets
+func (b *Buffer) ExitSnippet() {
+	b.snippetSessions = nil
+}
+
+// SnippetChoices returns the choices of the current tabstop, if it has any
+func (b *Buffer) SnippetChoices() []string {
+	if len(b.snippetSessions) == 0 {
+		return nil
+	}
+	s := b.snippetSessions[len(b.snippetSessions)-1]
+	if s.cur < 0 || s.cur >= len(s.stops) {
+		return nil
+	}
+	return s.stops[s.cur].choices
+}
+
+// NextSnippetStop moves to the next (or previous) tabstop of the snippet
+// being edited, with a cursor on each occurrence of the tabstop. Reaching
+// the final tabstop ends the snippet. It returns false if there is no
+// snippet being edited or if the cursor has left it
+func (b *Buffer) NextSnippetStop(forward bool) bool {
+	for len(b.snippetSessions) > 0 {
+		n := len(b.snippetSessions)
+		s := b.snippetSessions[n-1]
+
+		c := b.GetActiveCursor()
+		if c.Loc.LessThan(s.start) || c.Loc.GreaterThan(s.end) {
+			b.snippetSessions = nil
+			return false
+		}
+
+		if forward {
+			s.cur++
+		} else if s.cur > 0 {
+			s.cur--
+		}
+		if s.cur >= len(s.stops) {
+			b.snippetSessions = b.snippetSessions[:n-1]
+			continue
+		}
+
+		stop := s.stops[s.cur]
+		b.selectSnippetStop(stop)
+		if stop.num == 0 {
+			b.snippetSessions = b.snippetSessions[:n-1]
+		}
+		return true
+	}
+	return false
+}
+
+func (b *Buffer) selectSnippetStop(stop *snippetStop) {
+	b.ClearCursors()
+	for i, r := range stop.ranges {
+		c := b.GetActiveCursor()
+		if i > 0 {
+			c = NewCursor(b, r[1])
+			b.AddCursor(c)
+		}
+		c.GotoLoc(r[1])
+		if r[0] != r[1] {
+			c.SetSelectionStart(r[0])
+			c.SetSelectionEnd(r[1])
+			c.OrigSelection = c.CurSelection
+		}
+	}
+	b.MergeCursors()
+	b.SetCurCursor(0)
+}
+
+// shiftSnippetsInsert updates the snippets being edited after text has been
+// inserted at pos. Text inserted at the edge of a range of the current
+// tabstop extends the range, while the ranges of the other tabstops keep
+// their content
+func (b *SharedBuffer) shiftSnippetsInsert(pos, end Loc) {
+	for _, s := range b.snippetSessions {
+		s.start = s.start.shiftInsert(pos, end, true)
+		s.end = s.end.shiftInsert(pos, end, false)
+		for i, st := range s.stops {
+			for j, r := range st.ranges {
+				if i == s.cur {
+					st.ranges[j] = [2]Loc{r[0].shiftInsert(pos, end, true), r[1].shiftInsert(pos, end, false)}
+				} else {
+					st.ranges[j] = [2]Loc{r[0].shiftInsert(pos, end, false), r[1].shiftInsert(pos, end, r[0].LessThan(pos))}
+				}
+			}
+		}
+	}
+}
+
+func (b *SharedBuffer) shiftSnippetsRemove(start, end Loc) {
+	for _, s := range b.snippetSessions {
+		s.start = s.start.shiftRemove(start, end)
+		s.end = s.end.shiftRemove(start, end)
+		for _, st := range s.stops {
+			for i, r := range st.ranges {
+				st.ranges[i] = [2]Loc{r[0].shiftRemove(start, end), r[1].shiftRemove(start, end)}
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func noVars(string) (string, bool) {
+	return "", false
+}
+
+func TestParseSnippets(t *testing.T) {
+	snippets, err := ParseSnippets([]byte(`{
+		// comments are allowed
+		"Loop": {
+			"prefix": ["for", "loop"],
+			"body": ["for {", "\t$0", "}"],
+			"description": "A loop",
+		},
+		"If": {"prefix": "if", "body": "if $1 {}"}
+	}`))
+	assert.NoError(t, err)
+	assert.Len(t, snippets, 3)
+	assert.Equal(t, "if", snippets[0].Prefix)
+	assert.Equal(t, "for", snippets[1].Prefix)
+	assert.Equal(t, "loop", snippets[2].Prefix)
+	assert.Equal(t, "for {\n\t$0\n}", snippets[2].Body)
+
+	_, err = ParseSnippets([]byte(`{"Bad": {"prefix": "x", "body": 1}}`))
+	assert.Error(t, err)
+}
+
+func TestExpandSnippet(t *testing.T) {
+	text, stops := expandSnippet("for ${1:i} := 0; $1 < ${2:n}; $1++ {\n\t$0\n}", "  ", "    ", noVars)
+	assert.Equal(t, "for i := 0; i < n; i++ {\n      \n  }", text)
+	assert.Len(t, stops, 3)
+	assert.Equal(t, [][2]Loc{{{4, 0}, {5, 0}}, {{12, 0}, {13, 0}}, {{19, 0}, {20, 0}}}, stops[0].ranges)
+	assert.Equal(t, [][2]Loc{{{16, 0}, {17, 0}}}, stops[1].ranges)
+	assert.Equal(t, 0, stops[2].num)
+	assert.Equal(t, [][2]Loc{{{6, 1}, {6, 1}}}, stops[2].ranges)
+
+	// nested placeholders and the implicit final tabstop
+	text, stops = expandSnippet("${1:foo ${2:bar}}", "", "\t", noVars)
+	assert.Equal(t, "foo bar", text)
+	assert.Equal(t, [][2]Loc{{{0, 0}, {7, 0}}}, stops[0].ranges)
+	assert.Equal(t, [][2]Loc{{{4, 0}, {7, 0}}}, stops[1].ranges)
+	assert.Equal(t, [][2]Loc{{{7, 0}, {7, 0}}}, stops[2].ranges)
+
+	// choices
+	text, stops = expandSnippet(`${1|a,b\,c|}`, "", "\t", noVars)
+	assert.Equal(t, "a", text)
+	assert.Equal(t, []string{"a", "b,c"}, stops[0].choices)
+
+	// escapes and invalid constructs are kept as text
+	text, _ = expandSnippet(`\$1 \} $ \\ ${ ${1`, "", "\t", noVars)
+	assert.Equal(t, `$1 } $ \ ${ ${1`, text)
+
+	// variables
+	vars := func(name string) (string, bool) {
+		if name == "TM_FILENAME" {
+			return "main.go", true
+		}
+		return "", false
+	}
+	text, _ = expandSnippet("$TM_FILENAME ${UNKNOWN:default} $UNKNOWN ${TM_FILENAME/go/c/}", "", "\t", vars)
+	assert.Equal(t, "main.go default UNKNOWN main.go", text)
+}
+
+func TestSnippetSession(t *testing.T) {
+	b := NewBufferFromString("x\n", "", BTDefault)
+	s := &Snippet{Prefix: "for", Body: "for ${1:i} < ${2:n}; $1++ {\n\t$0\n}"}
+
+	b.InsertSnippet(Loc{1, 0}, s)
+	assert.Equal(t, "xfor i < n; i++ {\n\t\n}\n", string(b.Bytes()))
+	assert.True(t, b.InSnippet())
+
+	// the mirrored tabstop gets a cursor on each occurrence
+	cursors := b.GetCursors()
+	assert.Len(t, cursors, 2)
+	assert.Equal(t, [2]Loc{{5, 0}, {6, 0}}, [2]Loc(cursors[0].CurSelection))
+	assert.Equal(t, [2]Loc{{12, 0}, {13, 0}}, [2]Loc(cursors[1].CurSelection))
+
+	for _, c := range b.GetCursors() {
+		c.DeleteSelection()
+		c.ResetSelection()
+		b.Insert(c.Loc, "idx")
+	}
+	assert.Equal(t, "xfor idx < n; idx++ {\n\t\n}\n", string(b.Bytes()))
+
+	assert.True(t, b.NextSnippetStop(true))
+	assert.Len(t, b.GetCursors(), 1)
+	assert.Equal(t, "n", string(b.GetActiveCursor().GetSelection()))
+
+	assert.True(t, b.NextSnippetStop(false))
+	assert.Len(t, b.GetCursors(), 2)
+	assert.Equal(t, "idx", string(b.GetActiveCursor().GetSelection()))
+
+	b.NextSnippetStop(true)
+	assert.True(t, b.NextSnippetStop(true))
+	assert.Equal(t, Loc{1, 1}, b.GetActiveCursor().Loc)
+	assert.False(t, b.InSnippet())
+	assert.False(t, b.NextSnippetStop(true))
+}
+
+func TestNestedSnippet(t *testing.T) {
+	b := NewBufferFromString("\n", "", BTDefault)
+	b.InsertSnippet(Loc{0, 0}, &Snippet{Body: "f($1, $2)"})
+	b.InsertSnippet(b.GetActiveCursor().Loc, &Snippet{Body: "g($1)"})
+	assert.Equal(t, "f(g(), )\n", string(b.Bytes()))
+	assert.Equal(t, Loc{4, 0}, b.GetActiveCursor().Loc)
+
+	// leaving the inner snippet resumes the outer one
+	assert.True(t, b.NextSnippetStop(true))
+	assert.Equal(t, Loc{5, 0}, b.GetActiveCursor().Loc)
+	assert.True(t, b.NextSnippetStop(true))
+	assert.Equal(t, Loc{7, 0}, b.GetActiveCursor().Loc)
+
+	// moving the cursor out of the snippet abandons it
+	b.GetActiveCursor().GotoLoc(Loc{0, 1})
+	assert.False(t, b.NextSnippetStop(true))
+	assert.False(t, b.InSnippet())
+}
@@ -0,0 +1,93 @@
+package buffer
+
+import (
+	"strings"
+
+	"github.com/helmutkemper/micro/v2/internal/spell"
+	"github.com/helmutkemper/micro/v2/pkg/highlight"
+)
+
+// proseFiletypes are the filetypes of text rather than code, whose text is
+// spell checked and hard wrapped everywhere, and not only in comments
+var proseFiletypes = map[string]bool{
+	"asciidoc":   true,
+	"git-commit": true,
+	"markdown":   true,
+	"unknown":    true,
+}
+
+// spellErrors are the errors of the dictionaries which could not be loaded,
+// which were reported to the user
+var spellErrors = make(map[string]bool)
+
+// SpellChecker returns the checker for the languages of the buffer, with the
+// dictionaries which are loaded, and an error if a dictionary can't be
+// loaded
+func (b *Buffer) SpellChecker() (spell.Checker, error) {
+	return spell.NewChecker(strings.Split(b.Settings["spelllang"].(string), ","))
+}
+
+// spellChecked returns true if the text of a highlight group is spell
+// checked
+func (b *Buffer) spellChecked(g highlight.Group) bool {
+	if g == 0 {
+		return !b.Settings["syntax"].(bool) || b.SyntaxDef == nil ||
+			proseFiletypes[b.Settings["filetype"].(string)]
+	}
+	name := g.String()
+	return strings.HasPrefix(name, "comment") || strings.HasPrefix(name, "constant.string")
+}
+
+// Misspellings returns the misspelled words of a line, when the spellcheck
+// option is on. Only comments and strings are checked, except in the
+// prose filetypes
+func (b *Buffer) Misspellings(lineN int) []spell.Word {
+	if !b.Settings["spellcheck"].(bool) || b.Type.Scratch {
+		return nil
+	}
+	c, err := b.SpellChecker()
+	if err != nil && !spellErrors[err.Error()] {
+		spellErrors[err.Error()] = true
+		if prompt != nil {
+			prompt.Message(err)
+		}
+	}
+	if len(c) == 0 {
+		return nil
+	}
+
+	match := b.Match(lineN)
+	var g highlight.Group
+	checked := make(map[highlight.Group]bool)
+	x := 0
+
+	var words []spell.Word
+	for _, w := range spell.Words(b.LineBytes(lineN)) {
+		// the group at the start of the word
+		for ; x <= w.Start; x++ {
+			if m, ok := match[x]; ok {
+				g = m
+			}
+		}
+		ok, found := checked[g]
+		if !found {
+			ok = b.spellChecked(g)
+			checked[g] = ok
+		}
+		if ok && !c.Check(w.Text) {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+// SpellWordAt returns the word which is spell checked at the given
+// location, or which ends at the location
+func (b *Buffer) SpellWordAt(loc Loc) (spell.Word, bool) {
+	for _, w := range spell.Words(b.LineBytes(loc.Y)) {
+		if loc.X >= w.Start && loc.X <= w.End {
+			return w, true
+		}
+	}
+	return spell.Word{}, false
+}
@@ -0,0 +1,49 @@
+package buffer
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/spell"
+	"github.com/helmutkemper/micro/v2/pkg/highlight"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMisspellings(t *testing.T) {
+	aff, err := os.ReadFile("../spell/testdata/test.aff")
+	assert.NoError(t, err)
+	dic, err := os.ReadFile("../spell/testdata/test.dic")
+	assert.NoError(t, err)
+	config.PluginAddRuntimeFileFromMemory(config.RTAffix, "test", string(aff))
+	config.PluginAddRuntimeFileFromMemory(config.RTDictionary, "test", string(dic))
+
+	misspellings := func(b *Buffer, lineN int) func() []spell.Word {
+		b.SetOptionNative("spellcheck", true)
+		b.SetOptionNative("spelllang", "test")
+		return func() []spell.Word { return b.Misspellings(lineN) }
+	}
+
+	// all the text of the prose filetypes is checked
+	b := NewBufferFromString("Hello wrold, the cat\n", "", BTDefault)
+	defer b.Close()
+	assert.Eventually(t, func() bool {
+		return len(misspellings(b, 0)()) > 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []spell.Word{{Start: 6, End: 11, Text: "wrold"}, {Start: 13, End: 16, Text: "the"}}, misspellings(b, 0)())
+
+	// only comments and strings are checked in code
+	b = NewBufferFromString("helo wrold /* helo\ncat wrold */ helo\n", "", BTDefault)
+	defer b.Close()
+	w := newTestHighlightWorker(t, b)
+	w.step()
+	b.SyntaxDef = &highlight.Def{}
+	b.Settings["filetype"] = "test"
+	assert.Equal(t, []spell.Word{{Start: 14, End: 18, Text: "helo"}}, misspellings(b, 0)())
+	assert.Equal(t, []spell.Word{{Start: 4, End: 9, Text: "wrold"}}, misspellings(b, 1)())
+
+	word, ok := b.SpellWordAt(Loc{X: 9, Y: 1})
+	assert.True(t, ok)
+	assert.Equal(t, "wrold", word.Text)
+}
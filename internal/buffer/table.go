@@ -0,0 +1,585 @@
+package buffer
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/helmutkemper/micro/v2/internal/util"
+	runewidth "github.com/mattn/go-runewidth"
+)
+
+// tableDelimiters are the delimiters of the fields of the filetypes which
+// are shown as tables in table mode
+var tableDelimiters = map[string]rune{
+	"csv": ',',
+	"tsv": '\t',
+}
+
+// ErrNoTable is returned by the table edits when the buffer is not in
+// table mode
+var ErrNoTable = errors.New("Not in table mode")
+
+// A TableField is a field of a record, from Start to End, excluded. The
+// field includes its quotes
+type TableField struct {
+	Start, End Loc
+	// Raw is the text of the field, as it is written in the buffer
+	Raw string
+}
+
+// Value returns the value of the field, without its quotes and with its
+// escaped quotes unescaped
+func (f TableField) Value() string {
+	if !strings.HasPrefix(f.Raw, `"`) {
+		return f.Raw
+	}
+	v := f.Raw[1:]
+	if strings.HasSuffix(v, `"`) && strings.Count(v, `"`)%2 == 1 {
+		v = v[:len(v)-1]
+	}
+	return strings.ReplaceAll(v, `""`, `"`)
+}
+
+// A TableRecord is a record of a table. A record spans several lines when
+// a quoted field has newlines
+type TableRecord []TableField
+
+// text returns the text of the record, as it is written in the buffer
+func (r TableRecord) text(delim rune) string {
+	fields := make([]string, len(r))
+	for i, f := range r {
+		fields[i] = f.Raw
+	}
+	return strings.Join(fields, string(delim))
+}
+
+// tableDelim is a delimiter of a line, which is widened to Width cells to
+// align the next column
+type tableDelim struct {
+	x, width int
+}
+
+// A Table is the layout of a CSV or TSV buffer in table mode. The fields
+// are parsed as described by RFC 4180, and the columns are aligned by
+// widening the delimiters when the buffer is displayed, without changing
+// its text. The first record is the header, which names the columns
+type Table struct {
+	Delim   rune
+	Records []TableRecord
+	// Widths are the widths of the columns
+	Widths []int
+
+	tabsize int
+	// line returns the text of a line
+	line func(int) []byte
+	// lineRecords are the records of the lines, or -1 for a line without
+	// record, such as the empty line at the end of the buffer
+	lineRecords []int
+	// lineCols are the columns at the start of the lines
+	lineCols []int
+	// lineWidths are the widths of the text of the columns of the lines,
+	// from the column at the start of the line
+	lineWidths [][]int
+	delims     [][]tableDelim
+	// starts are the positions of the columns on the screen, and laidOut
+	// tells which lines have their delimiters widened for them
+	starts  []int
+	laidOut []bool
+
+	// the lines modified since the table was parsed, from modStart to
+	// modEnd, and the number of lines of the modified text
+	modified         bool
+	modStart, modEnd int
+	nlines           int
+}
+
+// newTable parses the lines of a CSV or TSV text
+func newTable(line func(int) []byte, nlines int, delim rune, tabsize int) *Table {
+	t := &Table{
+		Delim:   delim,
+		tabsize: tabsize,
+		line:    line,
+		nlines:  nlines,
+	}
+	t.parse(0, nlines, func(int) bool { return false })
+	t.layout()
+	return t
+}
+
+// parse parses the lines from the line y, which starts a record, until the
+// end of the text or until a line which starts a record and for which stop
+// returns true. It returns the line where it stopped
+func (t *Table) parse(y, nlines int, stop func(y int) bool) int {
+	var rec TableRecord
+	var raw strings.Builder
+	start := Loc{0, y}
+	quoted := false
+	endField := func(end Loc) {
+		rec = append(rec, TableField{start, end, raw.String()})
+		raw.Reset()
+	}
+
+	for first := y; y < nlines; y++ {
+		if y > first && rec == nil && !quoted && stop(y) {
+			return y
+		}
+		line := t.line(y)
+		t.lineRecords = append(t.lineRecords, len(t.Records))
+		t.lineCols = append(t.lineCols, len(rec))
+		t.delims = append(t.delims, nil)
+		t.laidOut = append(t.laidOut, false)
+		t.lineWidths = append(t.lineWidths, nil)
+		if !quoted && len(line) == 0 && y == nlines-1 && y > 0 {
+			// the empty line after the last newline of the text
+			t.lineRecords[y] = -1
+			break
+		}
+
+		x := 0
+		for text := line; len(text) > 0; {
+			r, _, size := util.DecodeCharacter(text)
+			c := text[:size]
+			text = text[size:]
+
+			switch {
+			case quoted && r == '"':
+				if len(text) > 0 && text[0] == '"' {
+					// an escaped quote
+					raw.WriteString(`""`)
+					text = text[1:]
+					x += 2
+					continue
+				}
+				quoted = false
+			case !quoted && r == t.Delim:
+				endField(Loc{x, y})
+				t.delims[y] = append(t.delims[y], tableDelim{x: x})
+				start = Loc{x + 1, y}
+				x++
+				continue
+			case !quoted && r == '"' && raw.Len() == 0:
+				quoted = true
+			}
+			raw.Write(c)
+			x++
+		}
+		t.lineWidths[y] = t.measure(y, line)
+
+		if quoted && y < nlines-1 {
+			raw.WriteByte('\n')
+			continue
+		}
+		quoted = false
+		endField(Loc{x, y})
+		t.Records = append(t.Records, rec)
+		rec = nil
+		start = Loc{0, y + 1}
+	}
+	return nlines
+}
+
+// measure returns the widths of the text of the columns of a line, from
+// the column at the start of the line, measured from the start of each
+// column
+func (t *Table) measure(y int, line []byte) []int {
+	widths := []int{0}
+	delims := t.delims[y]
+	for x := 0; len(line) > 0; x++ {
+		r, _, size := util.DecodeCharacter(line)
+		line = line[size:]
+		w := &widths[len(widths)-1]
+		if len(delims) > 0 && delims[0].x == x {
+			widths = append(widths, 0)
+			delims = delims[1:]
+		} else if r == '\t' {
+			*w += t.tabsize - *w%t.tabsize
+		} else {
+			*w += runewidth.RuneWidth(r)
+		}
+	}
+	return widths
+}
+
+// modify marks the lines from start to end as modified, as given to
+// MarkModified: end is a line of the text before the modification when
+// lines are removed. nlines is the number of lines after the modification
+func (t *Table) modify(start, end, nlines int) {
+	delta := nlines - t.nlines
+	t.nlines = nlines
+	end = max(start, end+min(delta, 0))
+	if t.modified {
+		// the lines of the previous modifications move with the text
+		if t.modEnd >= start {
+			t.modEnd = max(t.modEnd+delta, start)
+		}
+		start, end = min(start, t.modStart), max(end, t.modEnd)
+	}
+	t.modified, t.modStart, t.modEnd = true, start, end
+}
+
+// update parses again the modified lines, from the first record they
+// touch until the first record after them which did not change, and keeps
+// the records of the other lines
+func (t *Table) update() {
+	if !t.modified {
+		return
+	}
+	t.modified = false
+	delta := t.nlines - len(t.lineRecords)
+
+	y := min(t.modStart, len(t.lineRecords)-1)
+	if t.lineRecords[y] < 0 {
+		y--
+	}
+	r := t.lineRecords[y]
+	y = t.Records[r][0].Start.Y
+
+	// the records and the lines after y, which are kept if they did not
+	// change
+	records := slices.Clone(t.Records[r:])
+	lineRecords := slices.Clone(t.lineRecords[y:])
+	lineCols := slices.Clone(t.lineCols[y:])
+	lineWidths := slices.Clone(t.lineWidths[y:])
+	delims := slices.Clone(t.delims[y:])
+	laidOut := slices.Clone(t.laidOut[y:])
+	t.Records = t.Records[:r]
+	t.lineRecords = t.lineRecords[:y]
+	t.lineCols = t.lineCols[:y]
+	t.lineWidths = t.lineWidths[:y]
+	t.delims = t.delims[:y]
+	t.laidOut = t.laidOut[:y]
+
+	// a line after the modified lines is unchanged, and if it started a
+	// record, the rest of the text is parsed as before
+	old := func(ny int) int {
+		return ny - delta - y
+	}
+	end := t.modEnd
+	ny := t.parse(y, t.nlines, func(ny int) bool {
+		oy := old(ny)
+		if ny <= end || oy < 0 || oy >= len(lineRecords) || lineRecords[oy] < 0 {
+			return false
+		}
+		return records[lineRecords[oy]-r][0].Start.Y == ny-delta
+	})
+
+	if ny < t.nlines {
+		oy := old(ny)
+		dr := len(t.Records) - lineRecords[oy]
+		for _, rec := range records[lineRecords[oy]-r:] {
+			if delta != 0 {
+				rec = slices.Clone(rec)
+				for i := range rec {
+					rec[i].Start.Y += delta
+					rec[i].End.Y += delta
+				}
+			}
+			t.Records = append(t.Records, rec)
+		}
+		for _, n := range lineRecords[oy:] {
+			if n >= 0 {
+				n += dr
+			}
+			t.lineRecords = append(t.lineRecords, n)
+		}
+		t.lineCols = append(t.lineCols, lineCols[oy:]...)
+		t.lineWidths = append(t.lineWidths, lineWidths[oy:]...)
+		t.delims = append(t.delims, delims[oy:]...)
+		t.laidOut = append(t.laidOut, laidOut[oy:]...)
+	}
+	t.layout()
+}
+
+// charWidth returns the width of the character r of a line, which starts
+// at the visual position vx
+func (t *Table) charWidth(lineN, x int, r rune, vx int) int {
+	if w, ok := t.DelimWidth(lineN, x); ok {
+		return w
+	}
+	if r == '\t' {
+		return t.tabsize - vx%t.tabsize
+	}
+	return runewidth.RuneWidth(r)
+}
+
+// layout computes the widths of the columns and the positions where they
+// start. The delimiters which align them are widened when their line is
+// displayed
+func (t *Table) layout() {
+	t.Widths = nil
+	for y, widths := range t.lineWidths {
+		for i, w := range widths {
+			col := t.lineCols[y] + i
+			for len(t.Widths) <= col {
+				t.Widths = append(t.Widths, 0)
+			}
+			t.Widths[col] = max(t.Widths[col], w)
+		}
+	}
+
+	// each column starts after the widest text of the previous column, its
+	// delimiter and a space
+	starts := make([]int, len(t.Widths)+1)
+	for c, w := range t.Widths {
+		starts[c+1] = starts[c] + w + 2
+	}
+	if !slices.Equal(starts, t.starts) {
+		t.starts = starts
+		clear(t.laidOut)
+	}
+}
+
+// lineDelims returns the delimiters of a line, widened to align the
+// columns which follow them
+func (t *Table) lineDelims(lineN int) []tableDelim {
+	delims := t.delims[lineN]
+	if t.laidOut[lineN] {
+		return delims
+	}
+	t.laidOut[lineN] = true
+	line := t.line(lineN)
+	col := t.lineCols[lineN]
+	vx := 0
+	for x, i := 0, 0; len(line) > 0 && i < len(delims); x++ {
+		r, _, size := util.DecodeCharacter(line)
+		line = line[size:]
+		if delims[i].x == x {
+			col++
+			delims[i].width = max(t.starts[col]-vx, 1)
+			vx += delims[i].width
+			i++
+		} else if r == '\t' {
+			vx += t.tabsize - vx%t.tabsize
+		} else {
+			vx += runewidth.RuneWidth(r)
+		}
+	}
+	return delims
+}
+
+// DelimWidth returns the width of the character x of a line if it is a
+// delimiter, which is widened to align the next column
+func (t *Table) DelimWidth(lineN, x int) (int, bool) {
+	if t == nil || lineN < 0 || lineN >= len(t.delims) {
+		return 0, false
+	}
+	delims := t.lineDelims(lineN)
+	i := sort.Search(len(delims), func(i int) bool {
+		return delims[i].x >= x
+	})
+	if i < len(delims) && delims[i].x == x {
+		return delims[i].width, true
+	}
+	return 0, false
+}
+
+// stringWidth returns the visual width of the first n characters of a line
+func (t *Table) stringWidth(lineN int, line []byte, n int) int {
+	width := 0
+	for x := 0; x < n && len(line) > 0; x++ {
+		r, _, size := util.DecodeCharacter(line)
+		line = line[size:]
+		width += t.charWidth(lineN, x, r, width)
+	}
+	return width
+}
+
+// charPos returns the character of a line at a visual position, as
+// util.GetCharPosInLine
+func (t *Table) charPos(lineN int, line []byte, visualPos int) int {
+	width := 0
+	x := 0
+	for len(line) > 0 {
+		r, _, size := util.DecodeCharacter(line)
+		line = line[size:]
+		width += t.charWidth(lineN, x, r, width)
+		if width >= visualPos {
+			if width == visualPos {
+				x++
+			}
+			break
+		}
+		x++
+	}
+	return x
+}
+
+// FieldAt returns the record and the column of the field at a location.
+// A location at the end of a field, before its delimiter, is in the field
+func (t *Table) FieldAt(loc Loc) (int, int, bool) {
+	if loc.Y < 0 || loc.Y >= len(t.lineRecords) || t.lineRecords[loc.Y] < 0 {
+		return 0, 0, false
+	}
+	rec := t.lineRecords[loc.Y]
+	for col, f := range t.Records[rec] {
+		if loc.GreaterEqual(f.Start) && loc.LessEqual(f.End) {
+			return rec, col, true
+		}
+	}
+	return 0, 0, false
+}
+
+// Field returns the field of a record at a column, if the record has the
+// column
+func (t *Table) Field(rec, col int) (TableField, bool) {
+	if rec < 0 || rec >= len(t.Records) || col < 0 || col >= len(t.Records[rec]) {
+		return TableField{}, false
+	}
+	return t.Records[rec][col], true
+}
+
+// Columns returns the number of columns of the table
+func (t *Table) Columns() int {
+	return len(t.Widths)
+}
+
+// ColumnName returns the name of a column, given by the header
+func (t *Table) ColumnName(col int) string {
+	if f, ok := t.Field(0, col); ok {
+		return f.Value()
+	}
+	return ""
+}
+
+// Table returns the table of the buffer in table mode, which is used when
+// the tablemode option is on for the csv and tsv filetypes, or nil
+func (b *Buffer) Table() *Table {
+	delim, ok := tableDelimiters[b.Settings["filetype"].(string)]
+	if !ok || !b.Settings["tablemode"].(bool) {
+		return nil
+	}
+	tabsize := util.IntOpt(b.Settings["tabsize"])
+	if b.table == nil || b.table.Delim != delim || b.table.tabsize != tabsize || b.table.nlines != b.LinesNum() {
+		b.table = newTable(func(n int) []byte { return b.LineBytes(n) }, b.LinesNum(), delim, tabsize)
+	} else {
+		b.table.update()
+	}
+	return b.table
+}
+
+// VisualWidth returns the visual width of the first n characters of a
+// line. The delimiters of a table are widened in table mode
+func (b *Buffer) VisualWidth(lineN, n int) int {
+	if t := b.Table(); t != nil {
+		return t.stringWidth(lineN, b.LineBytes(lineN), n)
+	}
+	return util.StringWidth(b.LineBytes(lineN), n, util.IntOpt(b.Settings["tabsize"]))
+}
+
+// CharPos returns the character of a line at a visual position. The
+// delimiters of a table are widened in table mode
+func (b *Buffer) CharPos(lineN, visualPos int) int {
+	if t := b.Table(); t != nil {
+		return t.charPos(lineN, b.LineBytes(lineN), visualPos)
+	}
+	return util.GetCharPosInLine(b.LineBytes(lineN), visualPos, util.IntOpt(b.Settings["tabsize"]))
+}
+
+// replaceRecords replaces the records of the table with the given
+// records, as a single undoable edit
+func (b *Buffer) replaceRecords(t *Table, records []TableRecord) {
+	if len(t.Records) == 0 {
+		return
+	}
+	lines := make([]string, len(records))
+	for i, r := range records {
+		lines[i] = r.text(t.Delim)
+	}
+	last := t.Records[len(t.Records)-1]
+	end := last[len(last)-1].End
+	text := strings.Join(lines, "\n")
+	if text != string(b.Substr(Loc{0, 0}, end)) {
+		b.Replace(Loc{0, 0}, end, text)
+	}
+}
+
+// compareFields compares the values of two fields, as numbers if both are
+// numbers and as text otherwise
+func compareFields(a, b string) int {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	switch {
+	case errA == nil && errB == nil:
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+		return 0
+	case errA == nil:
+		// numbers come before text
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// SortColumn sorts the records of the table by the values of a column,
+// keeping the header first. The records without the column come last
+func (b *Buffer) SortColumn(col int, reverse bool) error {
+	t := b.Table()
+	if t == nil {
+		return ErrNoTable
+	}
+	if len(t.Records) < 2 {
+		return nil
+	}
+	records := append([]TableRecord{}, t.Records...)
+	body := records[1:]
+	sort.SliceStable(body, func(i, j int) bool {
+		if col >= len(body[i]) || col >= len(body[j]) {
+			return col < len(body[i]) && col >= len(body[j])
+		}
+		c := compareFields(body[i][col].Value(), body[j][col].Value())
+		if reverse {
+			return c > 0
+		}
+		return c < 0
+	})
+	b.replaceRecords(t, records)
+	return nil
+}
+
+// DeleteColumn deletes a column of the table
+func (b *Buffer) DeleteColumn(col int) error {
+	t := b.Table()
+	if t == nil {
+		return ErrNoTable
+	}
+	records := make([]TableRecord, len(t.Records))
+	for i, r := range t.Records {
+		if col < len(r) && len(r) > 1 {
+			r = append(append(TableRecord{}, r[:col]...), r[col+1:]...)
+		} else if col < len(r) {
+			r = TableRecord{{}}
+		}
+		records[i] = r
+	}
+	b.replaceRecords(t, records)
+	return nil
+}
+
+// Transpose swaps the rows and the columns of the table. The missing
+// fields of the short records are empty
+func (b *Buffer) Transpose() error {
+	t := b.Table()
+	if t == nil {
+		return ErrNoTable
+	}
+	records := make([]TableRecord, t.Columns())
+	for c := range records {
+		records[c] = make(TableRecord, len(t.Records))
+		for i := range t.Records {
+			if f, ok := t.Field(i, c); ok {
+				records[c][i] = f
+			}
+		}
+	}
+	b.replaceRecords(t, records)
+	return nil
+}
@@ -0,0 +1,123 @@
+package buffer
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/helmutkemper/micro/v2/internal/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func testTable(text string, delim rune) *Table {
+	var lines [][]byte
+	for _, l := range strings.Split(text, "\n") {
+		lines = append(lines, []byte(l))
+	}
+	return newTable(func(n int) []byte { return lines[n] }, len(lines), delim, 4)
+}
+
+func TestTableParse(t *testing.T) {
+	tbl := testTable("name,note\n\"Smith, J\",\"says \"\"hi\"\"\nand bye\"\nx\n", ',')
+	assert.Len(t, tbl.Records, 3)
+	assert.Equal(t, []int{0, 1, 1, 2, -1}, tbl.lineRecords)
+
+	f, ok := tbl.Field(1, 0)
+	assert.True(t, ok)
+	assert.Equal(t, "Smith, J", f.Value())
+	f, _ = tbl.Field(1, 1)
+	assert.Equal(t, `"says ""hi""`+"\nand bye\"", f.Raw)
+	assert.Equal(t, "says \"hi\"\nand bye", f.Value())
+	assert.Equal(t, Loc{11, 1}, f.Start)
+	assert.Equal(t, Loc{8, 2}, f.End)
+
+	rec, col, ok := tbl.FieldAt(Loc{3, 2})
+	assert.True(t, ok)
+	assert.Equal(t, 1, rec)
+	assert.Equal(t, 1, col)
+	assert.Equal(t, "note", tbl.ColumnName(col))
+	assert.Equal(t, 2, tbl.Columns())
+}
+
+func TestTableLayout(t *testing.T) {
+	tbl := testTable("a,bbb,c\nlong name,d,e", ',')
+	assert.Equal(t, []int{9, 3, 1}, tbl.Widths)
+
+	// the delimiters are widened so that the columns start at 0, 11 and 16
+	w, ok := tbl.DelimWidth(0, 1)
+	assert.True(t, ok)
+	assert.Equal(t, 10, w)
+	w, _ = tbl.DelimWidth(1, 11)
+	assert.Equal(t, 4, w)
+	_, ok = tbl.DelimWidth(0, 2)
+	assert.False(t, ok)
+
+	line := []byte("a,bbb,c")
+	assert.Equal(t, 11, tbl.stringWidth(0, line, 2))
+	assert.Equal(t, 16, tbl.stringWidth(0, line, 6))
+	assert.Equal(t, 2, tbl.charPos(0, line, 11))
+	assert.Equal(t, 1, tbl.charPos(0, line, 5))
+}
+
+func TestTableEdits(t *testing.T) {
+	b := NewBufferFromString("n,v\nb,10\na,9\nc,x\n", "", BTDefault)
+	defer b.Close()
+	b.Settings["filetype"] = "csv"
+
+	assert.NoError(t, b.SortColumn(1, false))
+	assert.Equal(t, "n,v\na,9\nb,10\nc,x\n", string(b.Bytes()))
+	assert.NoError(t, b.SortColumn(0, true))
+	assert.Equal(t, "n,v\nc,x\nb,10\na,9\n", string(b.Bytes()))
+
+	assert.NoError(t, b.Transpose())
+	assert.Equal(t, "n,c,b,a\nv,x,10,9\n", string(b.Bytes()))
+	assert.NoError(t, b.DeleteColumn(1))
+	assert.Equal(t, "n,b,a\nv,10,9\n", string(b.Bytes()))
+
+	b.Settings["tablemode"] = false
+	assert.Equal(t, ErrNoTable, b.Transpose())
+}
+
+func TestTableUpdate(t *testing.T) {
+	b := NewBufferFromString("name,note\n\"Smith, J\",\"says\nhi\"\nx,y\n", "", BTDefault)
+	defer b.Close()
+	b.Settings["filetype"] = "csv"
+
+	r := rand.New(rand.NewSource(1))
+	texts := []string{",", "\"", "\n", "ab", "\t", "\"\"", "long text,"}
+	randLoc := func() Loc {
+		y := r.Intn(b.LinesNum())
+		return Loc{r.Intn(util.CharacterCount(b.LineBytes(y)) + 1), y}
+	}
+	for i := 0; i < 500; i++ {
+		b.Table()
+		// several edits before the table is used again
+		for n := r.Intn(3) + 1; n > 0; n-- {
+			start, end := randLoc(), randLoc()
+			if end.LessThan(start) {
+				start, end = end, start
+			}
+			switch r.Intn(3) {
+			case 0:
+				b.Insert(start, texts[r.Intn(len(texts))])
+			case 1:
+				b.Remove(start, end)
+			default:
+				b.Replace(start, end, texts[r.Intn(len(texts))])
+			}
+		}
+
+		tbl := b.Table()
+		want := newTable(func(n int) []byte { return b.LineBytes(n) }, b.LinesNum(), ',', 4)
+		assert.Equal(t, want.Records, tbl.Records, string(b.Bytes()))
+		assert.Equal(t, want.lineRecords, tbl.lineRecords)
+		assert.Equal(t, want.lineCols, tbl.lineCols)
+		assert.Equal(t, want.Widths, tbl.Widths)
+		for y := range b.LinesNum() {
+			assert.Equal(t, want.lineDelims(y), tbl.lineDelims(y))
+		}
+		if t.Failed() {
+			break
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package buffer
+
+import (
+	"fmt"
+	"strings"
+
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// diffContext is the number of unchanged lines shown around the changes of
+// a unified diff
+const diffContext = 3
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// UnifiedDiff returns the changes from the text from to the text to, in
+// the unified diff format, or an empty string if the texts are equal
+func UnifiedDiff(from, to, fromName, toName string) string {
+	differ := dmp.New()
+	c1, c2, lines := differ.DiffLinesToChars(from, to)
+	diffs := differ.DiffCharsToLines(differ.DiffMain(c1, c2, false), lines)
+
+	var dl []diffLine
+	changed := false
+	for _, d := range diffs {
+		op := byte(' ')
+		switch d.Type {
+		case dmp.DiffDelete:
+			op = '-'
+		case dmp.DiffInsert:
+			op = '+'
+		}
+		changed = changed || op != ' '
+		for _, l := range strings.SplitAfter(d.Text, "\n") {
+			if l != "" {
+				dl = append(dl, diffLine{op, strings.TrimSuffix(l, "\n")})
+			}
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	// fromLine[i] and toLine[i] are the number of lines of each text before
+	// the line i of the diff
+	fromLine := make([]int, len(dl)+1)
+	toLine := make([]int, len(dl)+1)
+	for i, l := range dl {
+		fromLine[i+1], toLine[i+1] = fromLine[i], toLine[i]
+		if l.op != '+' {
+			fromLine[i+1]++
+		}
+		if l.op != '-' {
+			toLine[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for i := 0; i < len(dl); {
+		for i < len(dl) && dl[i].op == ' ' {
+			i++
+		}
+		if i == len(dl) {
+			break
+		}
+
+		// a hunk goes on while the changes are separated by less than
+		// twice the context
+		start, end := max(i-diffContext, 0), i
+		for {
+			for end < len(dl) && dl[end].op != ' ' {
+				end++
+			}
+			next := end
+			for next < len(dl) && dl[next].op == ' ' {
+				next++
+			}
+			if next == len(dl) || next-end > 2*diffContext {
+				end = min(end+diffContext, len(dl))
+				break
+			}
+			end = next
+		}
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(fromLine[start], fromLine[end]-fromLine[start]),
+			hunkRange(toLine[start], toLine[end]-toLine[start]))
+		for _, l := range dl[start:end] {
+			out.WriteByte(l.op)
+			out.WriteString(l.text)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+	return out.String()
+}
+
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
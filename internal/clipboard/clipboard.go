@@ -2,6 +2,7 @@ package clipboard
 
 import (
 	"errors"
+	"time"
 
 	"github.com/zyedidia/clipper"
 )
@@ -75,11 +76,15 @@ func Read(r Register) (string, error) {
 
 // Write writes text to a clipboard register
 func Write(text string, r Register) error {
+	if r == ClipboardReg && maxHistory > 0 {
+		add(&Entry{Text: text, Time: time.Now()})
+	}
 	return write(text, r, CurrentMethod)
 }
 
 // ReadMulti reads text from a clipboard register for a certain multi-cursor
 func ReadMulti(r Register, num, ncursors int) (string, error) {
+	r = r.Base()
 	clip, err := Read(r)
 	if err != nil {
 		return "", err
@@ -92,7 +97,24 @@ func ReadMulti(r Register, num, ncursors int) (string, error) {
 
 // WriteMulti writes text to a clipboard register for a certain multi-cursor
 func WriteMulti(text string, r Register, num int, ncursors int) error {
-	return writeMulti(text, r, num, ncursors, CurrentMethod)
+	return WriteMultiFrom(text, "", r, num, ncursors)
+}
+
+// WriteMultiFrom is like WriteMulti, and records the name of the buffer
+// the text comes from in the clipboard history
+func WriteMultiFrom(text, source string, r Register, num int, ncursors int) error {
+	err := writeMulti(text, r, num, ncursors, CurrentMethod)
+	record(r, source, num, ncursors, false)
+	return err
+}
+
+// AppendMultiFrom is like WriteMultiFrom for a text extending the text last
+// written to the register, as consecutive CutLine do, which replaces the
+// last entry of the clipboard history instead of adding one
+func AppendMultiFrom(text, source string, r Register, num int, ncursors int) error {
+	err := writeMulti(text, r, num, ncursors, CurrentMethod)
+	record(r, source, num, ncursors, true)
+	return err
 }
 
 // ValidMulti checks if the internal multi-clipboard is valid and up-to-date
@@ -102,11 +124,26 @@ func ValidMulti(r Register, clip string, ncursors int) bool {
 }
 
 func writeMulti(text string, r Register, num int, ncursors int, m Method) error {
+	if _, ok := readOnly[r]; ok {
+		return ErrReadOnly
+	}
+	if base := r.Base(); base != r {
+		r = base
+		if ValidMulti(r, internal.read(r), ncursors) {
+			text = multi.getText(r, num) + text
+		} else if num == 0 {
+			text = internal.read(r) + text
+		}
+	}
 	multi.writeText(text, r, num, ncursors)
 	return write(multi.getAllText(r), r, m)
 }
 
 func read(r Register, m Method) (string, error) {
+	if fn, ok := readOnly[r]; ok {
+		return fn(), nil
+	}
+	r = r.Base()
 	switch m {
 	case External:
 		switch r {
@@ -137,6 +174,13 @@ func read(r Register, m Method) (string, error) {
 }
 
 func write(text string, r Register, m Method) error {
+	if _, ok := readOnly[r]; ok {
+		return ErrReadOnly
+	}
+	if base := r.Base(); base != r {
+		r = base
+		text = internal.read(r) + text
+	}
 	switch m {
 	case External:
 		switch r {
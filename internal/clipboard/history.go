@@ -0,0 +1,146 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/util"
+)
+
+// An Entry is a text which was copied or cut to the clipboard
+type Entry struct {
+	Text string
+	// Parts are the texts copied by each cursor, when the text was copied
+	// with multiple cursors
+	Parts []string
+	// Source is the name of the buffer the text was copied from
+	Source string
+	Time   time.Time
+	// Private entries were copied from encrypted buffers, and are not saved
+	Private bool
+}
+
+// history holds the texts written to the clipboard register, the most
+// recent first
+var history []*Entry
+
+// privateSources are the names of the encrypted buffers
+var privateSources = make(map[string]bool)
+
+// SetPrivate sets whether the texts copied from a buffer are private: they
+// are kept in the history but never saved to disk
+func SetPrivate(source string, private bool) {
+	if private {
+		privateSources[source] = true
+	} else {
+		delete(privateSources, source)
+	}
+}
+
+// maxHistory is the maximum number of entries in the history, 0 to disable
+// the history
+var maxHistory = 50
+
+// SetMaxHistory sets the maximum number of entries in the clipboard
+// history, dropping the oldest entries if there are too many
+func SetMaxHistory(n int) {
+	maxHistory = max(n, 0)
+	if len(history) > maxHistory {
+		history = history[:maxHistory]
+	}
+}
+
+// History returns the clipboard history, the most recent entry first
+func History() []Entry {
+	entries := make([]Entry, len(history))
+	for i, e := range history {
+		entries[i] = *e
+	}
+	return entries
+}
+
+// record adds the text of a register to the history after it was written
+// for a certain multi-cursor. If extend is true, the text extends the last
+// one written and replaces its entry
+func record(r Register, source string, num, ncursors int, extend bool) {
+	if r != ClipboardReg || maxHistory == 0 {
+		return
+	}
+	text := multi.getAllText(r)
+	var parts []string
+	if ncursors > 1 {
+		parts = append(parts, multi[r]...)
+	}
+
+	if len(history) > 0 {
+		last := history[0]
+		// the following cursors of a multi-cursor copy, or a copy extending
+		// the last one
+		if num > 0 && len(last.Parts) == ncursors || extend && last.Source == source {
+			last.Text = text
+			last.Parts = parts
+			last.Time = time.Now()
+			last.Private = last.Private || privateSources[source]
+			return
+		}
+	}
+	add(&Entry{text, parts, source, time.Now(), privateSources[source]})
+}
+
+// add inserts an entry at the start of the history, removing the older
+// entries with the same text
+func add(e *Entry) {
+	if e.Text == "" {
+		return
+	}
+	h := []*Entry{e}
+	for _, old := range history {
+		if old.Text != e.Text && len(h) < maxHistory {
+			h = append(h, old)
+		}
+	}
+	history = h
+}
+
+// LoadHistory reads the clipboard history saved to the given file, if it
+// exists
+func LoadHistory(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	var entries []*Entry
+	if err := gob.NewDecoder(file).Decode(&entries); err != nil {
+		return err
+	}
+	if len(entries) > maxHistory {
+		entries = entries[:maxHistory]
+	}
+	history = entries
+	return nil
+}
+
+// SaveHistory saves the clipboard history to the given file, without the
+// private entries
+func SaveHistory(filename string) error {
+	var entries []*Entry
+	for _, e := range history {
+		if !e.Private {
+			entries = append(entries, e)
+		}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entries); err != nil {
+		return err
+	}
+	return util.SafeWrite(filename, buf.Bytes(), true)
+}
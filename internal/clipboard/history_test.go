@@ -0,0 +1,79 @@
+package clipboard
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	CurrentMethod = Internal
+	history = nil
+	SetMaxHistory(3)
+	defer SetMaxHistory(50)
+
+	WriteMultiFrom("one", "a.txt", ClipboardReg, 0, 1)
+	WriteMultiFrom("two", "b.txt", ClipboardReg, 0, 1)
+	WriteMultiFrom("x", "", PrimaryReg, 0, 1)
+	entries := History()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "two", entries[0].Text)
+	assert.Equal(t, "b.txt", entries[0].Source)
+
+	// consecutive cuts extending the last entry replace it
+	AppendMultiFrom("two\nthree", "b.txt", ClipboardReg, 0, 1)
+	assert.Len(t, History(), 2)
+	assert.Equal(t, "two\nthree", History()[0].Text)
+
+	// but a copy starting with the last entry is a new entry
+	WriteMultiFrom("two\nthree\nfour", "b.txt", ClipboardReg, 0, 1)
+	entries = History()
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "two\nthree", entries[1].Text)
+
+	// a multi-cursor copy is a single entry
+	WriteMultiFrom("a", "c.txt", ClipboardReg, 0, 2)
+	WriteMultiFrom("b", "c.txt", ClipboardReg, 1, 2)
+	entries = History()
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "ab", entries[0].Text)
+	assert.Equal(t, []string{"a", "b"}, entries[0].Parts)
+
+	// copying a text again moves it to the front, and the oldest entries
+	// are dropped
+	WriteMultiFrom("two\nthree", "d.txt", ClipboardReg, 0, 1)
+	WriteMultiFrom("four", "d.txt", ClipboardReg, 0, 1)
+	entries = History()
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "four", entries[0].Text)
+	assert.Equal(t, "two\nthree", entries[1].Text)
+	assert.Equal(t, "ab", entries[2].Text)
+
+	filename := filepath.Join(t.TempDir(), "clipboard")
+	assert.NoError(t, SaveHistory(filename))
+	history = nil
+	assert.NoError(t, LoadHistory(filename))
+	assert.Equal(t, entries[1].Text, History()[1].Text)
+	assert.Equal(t, entries[2].Parts, History()[2].Parts)
+}
+
+func TestPrivateHistory(t *testing.T) {
+	CurrentMethod = Internal
+	history = nil
+	SetPrivate("secret.txt", true)
+	defer SetPrivate("secret.txt", false)
+
+	WriteMultiFrom("public", "a.txt", ClipboardReg, 0, 1)
+	WriteMultiFrom("password", "secret.txt", ClipboardReg, 0, 1)
+	assert.Len(t, History(), 2)
+	assert.True(t, History()[0].Private)
+
+	filename := filepath.Join(t.TempDir(), "clipboard")
+	assert.NoError(t, SaveHistory(filename))
+	history = nil
+	assert.NoError(t, LoadHistory(filename))
+	if assert.Len(t, History(), 1) {
+		assert.Equal(t, "public", History()[0].Text)
+	}
+}
@@ -0,0 +1,115 @@
+package clipboard
+
+import (
+	"errors"
+)
+
+const (
+	// SearchReg holds the last search, and is read-only
+	SearchReg Register = '/'
+	// InsertReg holds the text typed last, and is read-only
+	InsertReg Register = '.'
+)
+
+var (
+	ErrInvalidRegister = errors.New("Invalid register")
+	ErrReadOnly        = errors.New("Register is read-only")
+)
+
+// readOnly holds the functions returning the text of the read-only registers
+var readOnly = map[Register]func() string{}
+
+// SetReadOnly makes a register read-only, its text being returned by fn
+func SetReadOnly(r Register, fn func() string) {
+	readOnly[r] = fn
+}
+
+// IsReadOnly returns true if the register can't be written to
+func IsReadOnly(r Register) bool {
+	_, ok := readOnly[r]
+	return ok
+}
+
+// NamedRegister returns the register with the given name. The registers "a"
+// to "z" store text for the user, and "A" to "Z" append to them. "+" is the
+// clipboard, "*" the primary clipboard, "/" the last search and "." the
+// text typed last
+func NamedRegister(name string) (Register, error) {
+	if len(name) != 1 {
+		return 0, ErrInvalidRegister
+	}
+	c := name[0]
+	switch {
+	case c == '+':
+		return ClipboardReg, nil
+	case c == '*':
+		return PrimaryReg, nil
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '/', c == '.':
+		return Register(c), nil
+	}
+	return 0, ErrInvalidRegister
+}
+
+// Name returns the name of the register, as accepted by NamedRegister
+func (r Register) Name() string {
+	switch r {
+	case ClipboardReg:
+		return "+"
+	case PrimaryReg:
+		return "*"
+	}
+	return string(rune(r))
+}
+
+// Base returns the register which is appended to when writing to r, or r
+// itself
+func (r Register) Base() Register {
+	if r >= 'A' && r <= 'Z' {
+		return r - 'A' + 'a'
+	}
+	return r
+}
+
+// Registers returns the registers which hold some text, with the clipboard
+// and primary registers first
+func Registers() []Register {
+	regs := []Register{ClipboardReg, PrimaryReg}
+	for r := Register('a'); r <= 'z'; r++ {
+		if internal.read(r) != "" {
+			regs = append(regs, r)
+		}
+	}
+	for _, r := range []Register{SearchReg, InsertReg} {
+		if fn, ok := readOnly[r]; ok && fn() != "" {
+			regs = append(regs, r)
+		}
+	}
+	return regs
+}
+
+// ReadNamed reads the text of the register with the given name
+func ReadNamed(name string) (string, error) {
+	r, err := NamedRegister(name)
+	if err != nil {
+		return "", err
+	}
+	return Read(r)
+}
+
+// WriteNamed writes text to the register with the given name
+func WriteNamed(name, text string) error {
+	r, err := NamedRegister(name)
+	if err != nil {
+		return err
+	}
+	return Write(text, r)
+}
+
+// RegisterNames returns the names of the registers which hold some text
+func RegisterNames() []string {
+	var names []string
+	for _, r := range Registers() {
+		names = append(names, r.Name())
+	}
+	return names
+}
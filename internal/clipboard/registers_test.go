@@ -0,0 +1,38 @@
+package clipboard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisters(t *testing.T) {
+	CurrentMethod = Internal
+
+	r, err := NamedRegister("a")
+	assert.NoError(t, err)
+	assert.NoError(t, Write("foo", r))
+	assert.NoError(t, WriteNamed("A", "bar"))
+	text, err := ReadNamed("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "foobar", text)
+
+	// appending with multiple cursors appends to each part
+	WriteMulti("1", r, 0, 2)
+	WriteMulti("2", r, 1, 2)
+	WriteMulti("x", Register('A'), 0, 2)
+	WriteMulti("y", Register('A'), 1, 2)
+	text, _ = ReadMulti(r, 1, 2)
+	assert.Equal(t, "2y", text)
+
+	SetReadOnly(SearchReg, func() string { return "needle" })
+	defer delete(readOnly, SearchReg)
+	text, _ = ReadNamed("/")
+	assert.Equal(t, "needle", text)
+	assert.Equal(t, ErrReadOnly, WriteNamed("/", "x"))
+	assert.Contains(t, RegisterNames(), "/")
+	assert.Contains(t, RegisterNames(), "a")
+
+	_, err = NamedRegister("ab")
+	assert.Equal(t, ErrInvalidRegister, err)
+}
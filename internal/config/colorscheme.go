@@ -42,6 +42,28 @@ func GetColor(color string) tcell.Style {
 	return st
 }
 
+// TermBackground is the background of the terminal, "light" or "dark", or
+// an empty string if it is not known
+var TermBackground string
+
+// ColorschemeChosen is true when the user set the colorscheme option by
+// hand, which then takes precedence over the background of the terminal
+var ColorschemeChosen bool
+
+// ColorschemeOption returns the option which gives the current colorscheme:
+// colorscheme.light or colorscheme.dark if it is set and the terminal has
+// that background, unless the colorscheme was chosen by hand, colorscheme
+// otherwise
+func ColorschemeOption() string {
+	if TermBackground != "" && !ColorschemeChosen {
+		option := "colorscheme." + TermBackground
+		if name, ok := GlobalSettings[option].(string); ok && name != "" {
+			return option
+		}
+	}
+	return "colorscheme"
+}
+
 // ColorschemeExists checks if a given colorscheme exists
 func ColorschemeExists(colorschemeName string) bool {
 	return FindRuntimeFile(RTColorscheme, colorschemeName) != nil
@@ -59,7 +81,8 @@ func InitColorscheme() error {
 		// The colorscheme setting seems broken (maybe because we have not validated
 		// it earlier, see comment in verifySetting()). So reset it to the default
 		// colorscheme and try again.
-		GlobalSettings["colorscheme"] = DefaultGlobalOnlySettings["colorscheme"]
+		option := ColorschemeOption()
+		GlobalSettings[option] = DefaultGlobalOnlySettings[option]
 		if c, err2 := LoadDefaultColorscheme(); err2 == nil {
 			Colorscheme = c
 		}
@@ -68,10 +91,25 @@ func InitColorscheme() error {
 	return err
 }
 
-// LoadDefaultColorscheme loads the default colorscheme from $(ConfigDir)/colorschemes
+// PreviewColorscheme makes the given colorscheme the current one without
+// changing the colorscheme option. InitColorscheme restores the colorscheme
+// of the option
+func PreviewColorscheme(colorschemeName string) error {
+	var parsedColorschemes []string
+	DefStyle = tcell.StyleDefault
+	c, err := LoadColorscheme(colorschemeName, &parsedColorschemes)
+	if err != nil {
+		return err
+	}
+	Colorscheme = c
+	return nil
+}
+
+// LoadDefaultColorscheme loads the colorscheme given by the options from
+// $(ConfigDir)/colorschemes
 func LoadDefaultColorscheme() (map[string]tcell.Style, error) {
 	var parsedColorschemes []string
-	return LoadColorscheme(GlobalSettings["colorscheme"].(string), &parsedColorschemes)
+	return LoadColorscheme(GlobalSettings[ColorschemeOption()].(string), &parsedColorschemes)
 }
 
 // LoadColorscheme loads the given colorscheme from a directory
@@ -253,7 +291,7 @@ func StringToColor(str string) (tcell.Color, bool) {
 		}
 		// Check if this is a truecolor hex value
 		if len(str) == 7 && str[0] == '#' {
-			return tcell.GetColor(str), true
+			return approximateColor(tcell.GetColor(str)), true
 		}
 		return tcell.ColorDefault, false
 	}
@@ -0,0 +1,578 @@
+package config
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/helmutkemper/micro/v2/pkg/highlight"
+	"github.com/micro-editor/json5"
+	"gopkg.in/yaml.v2"
+)
+
+// importedScheme is a colorscheme converted from another format
+type importedScheme struct {
+	name string
+	// links maps groups to styles in the format of color-link statements
+	links map[string]string
+	// unmapped lists the entries of the theme which have no micro group
+	unmapped []string
+}
+
+// groupOrder is the order in which the groups of imported colorschemes are
+// written. Other groups come after them, sorted by name
+var groupOrder = []string{
+	"default", "comment", "identifier", "constant", "constant.number",
+	"constant.string", "constant.specialChar", "statement", "symbol",
+	"symbol.operator", "symbol.brackets", "symbol.tag", "preproc", "type",
+	"type.keyword", "special", "underlined", "error", "todo", "selection",
+	"hlsearch", "diff-added", "diff-modified", "diff-deleted",
+	"gutter-error", "gutter-warning", "statusline", "tabbar", "indent-char",
+	"line-number", "current-line-number", "cursor-line", "color-column",
+	"match-brace", "tab-error", "trailingws",
+}
+
+// text returns the imported colorscheme in micro's format
+func (s *importedScheme) text(source string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Imported from %s\n\n", source)
+
+	written := make(map[string]bool)
+	link := func(g string) {
+		if style, ok := s.links[g]; ok && !written[g] {
+			fmt.Fprintf(&b, "color-link %s \"%s\"\n", g, style)
+			written[g] = true
+		}
+	}
+	for _, g := range groupOrder {
+		link(g)
+	}
+	var rest []string
+	for g := range s.links {
+		if !written[g] {
+			rest = append(rest, g)
+		}
+	}
+	sort.Strings(rest)
+	for _, g := range rest {
+		link(g)
+	}
+
+	if len(s.unmapped) > 0 {
+		b.WriteString("\n# Entries without a micro group:\n")
+		for _, u := range s.unmapped {
+			fmt.Fprintf(&b, "#   %s\n", u)
+		}
+	}
+	return b.String()
+}
+
+// ImportColorscheme converts a VSCode color theme (.json), a base16 scheme
+// (.yaml or .yml) or an iTerm2 color preset (.itermcolors) to a micro
+// colorscheme. It returns the name of the colorscheme, its text, and the
+// entries of the theme which couldn't be mapped to a micro group
+func ImportColorscheme(path string) (string, string, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var s *importedScheme
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		s, err = importVSCode(path, data)
+	case ".yaml", ".yml":
+		s, err = importBase16(data)
+	case ".itermcolors":
+		s, err = importITerm(data)
+	default:
+		err = errors.New("unknown theme format, expected .json, .yaml or .itermcolors")
+	}
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	base := filepath.Base(path)
+	if s.name == "" {
+		s.name = base[:len(base)-len(filepath.Ext(base))]
+	}
+	s.name = colorschemeName(s.name)
+	if s.name == "" {
+		return "", "", nil, errors.New("the theme has no usable name")
+	}
+	return s.name, s.text(base), s.unmapped, nil
+}
+
+var nameRegex = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// colorschemeName turns the name of a theme into a file name
+func colorschemeName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.TrimSuffix(name, "-color-theme")
+	return strings.Trim(nameRegex.ReplaceAllString(name, "-"), "-")
+}
+
+// InstallColorscheme writes a colorscheme to the colorschemes directory of
+// the config directory and makes it available. It returns the path of the
+// written file
+func InstallColorscheme(name, text string) (string, error) {
+	dir := filepath.Join(ConfigDir, "colorschemes")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name+".micro")
+	if _, err := os.Stat(path); err == nil {
+		return "", errors.New(path + " already exists")
+	}
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		return "", err
+	}
+	if FindRuntimeFile(RTColorscheme, name) == nil {
+		AddRealRuntimeFile(RTColorscheme, realFile(path))
+	}
+	return path, nil
+}
+
+// an RGB color with components between 0 and 255
+type rgb [3]float64
+
+func parseHex(s string) (rgb, float64, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) == 3 || len(s) == 4 {
+		var long strings.Builder
+		for _, c := range s {
+			long.WriteRune(c)
+			long.WriteRune(c)
+		}
+		s = long.String()
+	}
+	if len(s) != 6 && len(s) != 8 {
+		return rgb{}, 0, false
+	}
+	n, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return rgb{}, 0, false
+	}
+	alpha := 1.0
+	if len(s) == 8 {
+		alpha = float64(n&0xff) / 255
+		n >>= 8
+	}
+	return rgb{float64(n >> 16 & 0xff), float64(n >> 8 & 0xff), float64(n & 0xff)}, alpha, true
+}
+
+func (c rgb) String() string {
+	v := func(f float64) int {
+		return int(math.Round(math.Max(0, math.Min(255, f))))
+	}
+	return fmt.Sprintf("#%02X%02X%02X", v(c[0]), v(c[1]), v(c[2]))
+}
+
+// blend mixes c over bg with the given opacity
+func (c rgb) blend(bg rgb, alpha float64) rgb {
+	var r rgb
+	for i := range r {
+		r[i] = c[i]*alpha + bg[i]*(1-alpha)
+	}
+	return r
+}
+
+// importStyle builds a color-link style from a font style and colors, which may
+// be empty
+func importStyle(font, fg, bg string) string {
+	var attrs []string
+	for _, f := range strings.Fields(font) {
+		switch f {
+		case "bold", "italic", "underline":
+			attrs = append(attrs, f)
+		}
+	}
+	colors := fg
+	if bg != "" {
+		if fg == "" {
+			fg = "default"
+		}
+		colors = fg + "," + bg
+	}
+	if colors == "" {
+		colors = "default"
+	}
+	return strings.Join(append(attrs, colors), " ")
+}
+
+// vscodeUI maps the workbench colors of VSCode themes to micro groups. The
+// first color is the foreground and the second one the background
+var vscodeUI = []struct {
+	group  string
+	fg, bg string
+}{
+	{"default", "editor.foreground", "editor.background"},
+	{"selection", "editor.selectionForeground", "editor.selectionBackground"},
+	{"hlsearch", "", "editor.findMatchHighlightBackground"},
+	{"line-number", "editorLineNumber.foreground", "editorGutter.background"},
+	{"current-line-number", "editorLineNumber.activeForeground", "editorGutter.background"},
+	{"statusline", "statusBar.foreground", "statusBar.background"},
+	{"tabbar", "tab.inactiveForeground", "editorGroupHeader.tabsBackground"},
+	{"indent-char", "editorWhitespace.foreground", ""},
+	{"match-brace", "", "editorBracketMatch.background"},
+	{"gutter-error", "editorError.foreground", ""},
+	{"gutter-warning", "editorWarning.foreground", ""},
+	{"diff-added", "editorGutter.addedBackground", ""},
+	{"diff-modified", "editorGutter.modifiedBackground", ""},
+	{"diff-deleted", "editorGutter.deletedBackground", ""},
+	// micro uses the foreground of these as the background of the line or
+	// column
+	{"cursor-line", "editor.lineHighlightBackground", ""},
+	{"color-column", "editorRuler.foreground", ""},
+}
+
+type vscodeTheme struct {
+	Name        string            `json:"name"`
+	Include     string            `json:"include"`
+	Colors      map[string]string `json:"colors"`
+	TokenColors []struct {
+		Scope    any `json:"scope"`
+		Settings struct {
+			Foreground string `json:"foreground"`
+			Background string `json:"background"`
+			FontStyle  string `json:"fontStyle"`
+		} `json:"settings"`
+	} `json:"tokenColors"`
+}
+
+// loadVSCode reads a VSCode theme and the themes it includes. The
+// definitions of a theme override the ones of the themes it includes
+func loadVSCode(path string, data []byte, depth int) (*vscodeTheme, error) {
+	var t vscodeTheme
+	if err := json5.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	if t.Include == "" {
+		return &t, nil
+	}
+	if depth > 8 {
+		return nil, errors.New("too many nested includes")
+	}
+	inc := filepath.Join(filepath.Dir(path), t.Include)
+	incData, err := os.ReadFile(inc)
+	if err != nil {
+		return nil, err
+	}
+	base, err := loadVSCode(inc, incData, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	if base.Colors == nil {
+		base.Colors = make(map[string]string)
+	}
+	for k, v := range t.Colors {
+		base.Colors[k] = v
+	}
+	base.TokenColors = append(base.TokenColors, t.TokenColors...)
+	base.Name = t.Name
+	return base, nil
+}
+
+func importVSCode(path string, data []byte) (*importedScheme, error) {
+	t, err := loadVSCode(path, data, 0)
+	if err != nil {
+		return nil, err
+	}
+	s := &importedScheme{name: t.Name, links: make(map[string]string)}
+
+	bg, _, hasBg := parseHex(t.Colors["editor.background"])
+	// color converts a theme color, blending transparent colors with the
+	// editor background
+	color := func(c string) string {
+		col, alpha, ok := parseHex(c)
+		if !ok {
+			return ""
+		}
+		if alpha < 1 && hasBg {
+			col = col.blend(bg, alpha)
+		}
+		return col.String()
+	}
+
+	for _, ui := range vscodeUI {
+		fg, bg := color(t.Colors[ui.fg]), ""
+		if ui.bg != "" {
+			bg = color(t.Colors[ui.bg])
+		}
+		if fg != "" || bg != "" {
+			s.links[ui.group] = importStyle("", fg, bg)
+		}
+	}
+	// the most specific scope wins, and later rules win on ties, as in
+	// VSCode
+	specificity := make(map[string]int)
+	unmapped := make(map[string]bool)
+	for _, tc := range t.TokenColors {
+		var scopes []string
+		switch sc := tc.Scope.(type) {
+		case string:
+			scopes = strings.Split(sc, ",")
+		case []any:
+			for _, e := range sc {
+				if str, ok := e.(string); ok {
+					scopes = append(scopes, strings.Split(str, ",")...)
+				}
+			}
+		}
+		st := importStyle(tc.Settings.FontStyle, color(tc.Settings.Foreground), color(tc.Settings.Background))
+		if tc.Scope == nil {
+			// themes converted from TextMate give the editor colors in a
+			// rule without scope
+			if _, ok := s.links["default"]; !ok {
+				s.links["default"] = st
+			}
+			continue
+		}
+		for _, scope := range scopes {
+			// only the last scope of a descendant selector is used
+			fields := strings.Fields(scope)
+			if len(fields) == 0 {
+				continue
+			}
+			scope = fields[len(fields)-1]
+			group := highlight.ScopeGroup(scope)
+			if group == "" {
+				unmapped[scope] = true
+				continue
+			}
+			spec := strings.Count(scope, ".") + 1
+			if spec >= specificity[group] {
+				specificity[group] = spec
+				s.links[group] = st
+			}
+		}
+	}
+	if _, ok := s.links["default"]; !ok {
+		return nil, errors.New("the theme has no editor colors")
+	}
+	for u := range unmapped {
+		s.unmapped = append(s.unmapped, u)
+	}
+	sort.Strings(s.unmapped)
+	return s, nil
+}
+
+// base16Groups maps micro groups to base16 colors following the base16
+// styling guidelines. The first color is the foreground and the second
+// one the background
+var base16Groups = []struct {
+	group, font, fg, bg string
+}{
+	{"default", "", "base05", "base00"},
+	{"comment", "", "base03", ""},
+	{"identifier", "", "base0D", ""},
+	{"constant", "", "base09", ""},
+	{"constant.string", "", "base0B", ""},
+	{"constant.specialChar", "", "base0C", ""},
+	{"statement", "", "base0E", ""},
+	{"symbol", "", "base05", ""},
+	{"symbol.tag", "", "base08", ""},
+	{"preproc", "", "base0C", ""},
+	{"type", "", "base0A", ""},
+	{"type.keyword", "", "base0E", ""},
+	{"special", "", "base0C", ""},
+	{"underlined", "underline", "base0D", ""},
+	{"error", "bold", "base08", ""},
+	{"todo", "bold", "base0A", ""},
+	{"selection", "", "", "base02"},
+	{"hlsearch", "", "base00", "base0A"},
+	{"diff-added", "", "base0B", ""},
+	{"diff-modified", "", "base0E", ""},
+	{"diff-deleted", "", "base08", ""},
+	{"gutter-error", "", "base08", ""},
+	{"gutter-warning", "", "base0A", ""},
+	{"statusline", "", "base04", "base01"},
+	{"tabbar", "", "base04", "base01"},
+	{"indent-char", "", "base02", ""},
+	{"line-number", "", "base03", "base01"},
+	{"current-line-number", "", "base04", "base01"},
+	{"cursor-line", "", "base01", ""},
+	{"color-column", "", "base01", ""},
+	{"match-brace", "", "base00", "base0D"},
+	{"tab-error", "", "", "base08"},
+	{"trailingws", "", "", "base08"},
+}
+
+func hasColor(palette map[string]rgb, k string) bool {
+	_, ok := palette[k]
+	return ok
+}
+
+// fromBase16 builds a colorscheme from a base16 palette
+func fromBase16(name string, palette map[string]rgb) (*importedScheme, error) {
+	s := &importedScheme{name: name, links: make(map[string]string)}
+	for i := 0; i < 16; i++ {
+		if _, ok := palette[fmt.Sprintf("base%02X", i)]; !ok {
+			return nil, fmt.Errorf("base%02X is missing", i)
+		}
+	}
+	color := func(k string) string {
+		if k == "" {
+			return ""
+		}
+		return palette[k].String()
+	}
+	for _, g := range base16Groups {
+		s.links[g.group] = importStyle(g.font, color(g.fg), color(g.bg))
+	}
+	return s, nil
+}
+
+func importBase16(data []byte) (*importedScheme, error) {
+	var scheme map[string]any
+	if err := yaml.Unmarshal(data, &scheme); err != nil {
+		return nil, err
+	}
+	// the current format puts the colors in a palette and names the
+	// scheme with name, the original one has them at the top level
+	colors := scheme
+	if p, ok := scheme["palette"].(map[any]any); ok {
+		colors = make(map[string]any)
+		for k, v := range p {
+			colors[fmt.Sprint(k)] = v
+		}
+	}
+	name, _ := scheme["name"].(string)
+	if name == "" {
+		name, _ = scheme["scheme"].(string)
+	}
+
+	palette := make(map[string]rgb)
+	var unmapped []string
+	for k, v := range colors {
+		str, ok := v.(string)
+		if !ok || !strings.HasPrefix(k, "base") {
+			continue
+		}
+		c, _, ok := parseHex(str)
+		if !ok {
+			return nil, fmt.Errorf("invalid color %s: %q", k, str)
+		}
+		n, err := strconv.ParseUint(k[4:], 16, 8)
+		if len(k) != 6 || err != nil || n > 0xf {
+			unmapped = append(unmapped, k)
+			continue
+		}
+		palette[fmt.Sprintf("base%02X", n)] = c
+	}
+
+	s, err := fromBase16(name, palette)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(unmapped)
+	s.unmapped = unmapped
+	return s, nil
+}
+
+// itermBase16 maps the colors of an iTerm2 preset to base16 colors, as the
+// base16 terminal themes do
+var itermBase16 = map[string]string{
+	"Background Color": "base00",
+	"Selection Color":  "base02",
+	"Ansi 8 Color":     "base03",
+	"Foreground Color": "base05",
+	"Ansi 7 Color":     "base06",
+	"Ansi 15 Color":    "base07",
+	"Ansi 1 Color":     "base08",
+	"Ansi 9 Color":     "base09",
+	"Ansi 3 Color":     "base0A",
+	"Ansi 2 Color":     "base0B",
+	"Ansi 6 Color":     "base0C",
+	"Ansi 4 Color":     "base0D",
+	"Ansi 5 Color":     "base0E",
+	"Ansi 13 Color":    "base0F",
+}
+
+type plistColor struct {
+	Keys   []string `xml:"key"`
+	Values []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+func importITerm(data []byte) (*importedScheme, error) {
+	var p struct {
+		Dict struct {
+			Keys   []string     `xml:"key"`
+			Colors []plistColor `xml:"dict"`
+		} `xml:"dict"`
+	}
+	if err := xml.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	if len(p.Dict.Keys) != len(p.Dict.Colors) {
+		return nil, errors.New("invalid iTerm2 color preset")
+	}
+
+	palette := make(map[string]rgb)
+	var unmapped []string
+	for i, name := range p.Dict.Keys {
+		pc := p.Dict.Colors[i]
+		if len(pc.Keys) != len(pc.Values) {
+			return nil, fmt.Errorf("invalid color %q", name)
+		}
+		var c rgb
+		for j, k := range pc.Keys {
+			v, _ := strconv.ParseFloat(strings.TrimSpace(pc.Values[j].Value), 64)
+			switch k {
+			case "Red Component":
+				c[0] = v * 255
+			case "Green Component":
+				c[1] = v * 255
+			case "Blue Component":
+				c[2] = v * 255
+			}
+		}
+		if b, ok := itermBase16[name]; ok {
+			palette[b] = c
+		} else {
+			unmapped = append(unmapped, name)
+		}
+	}
+
+	bg, okBg := palette["base00"]
+	fg, okFg := palette["base05"]
+	if !okBg || !okFg {
+		return nil, errors.New("the preset has no background or foreground color")
+	}
+	// the shades between the background and the foreground have no
+	// terminal color
+	palette["base01"] = fg.blend(bg, 0.1)
+	if _, ok := palette["base02"]; !ok {
+		palette["base02"] = fg.blend(bg, 0.2)
+	}
+	palette["base04"] = fg.blend(bg, 0.6)
+	for _, f := range [][2]string{{"base03", "base04"}, {"base06", "base05"}, {"base07", "base06"}, {"base09", "base08"}, {"base0F", "base08"}} {
+		if !hasColor(palette, f[0]) && hasColor(palette, f[1]) {
+			palette[f[0]] = palette[f[1]]
+		}
+	}
+	// a missing terminal color is displayed with the foreground color
+	for i := 0; i < 16; i++ {
+		if k := fmt.Sprintf("base%02X", i); !hasColor(palette, k) {
+			palette[k] = fg
+		}
+	}
+
+	s, err := fromBase16("", palette)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(unmapped)
+	s.unmapped = unmapped
+	return s, nil
+}
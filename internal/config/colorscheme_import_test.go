@@ -0,0 +1,126 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func importTestScheme(t *testing.T, files map[string]string, file string) (string, string, []string) {
+	dir := t.TempDir()
+	for name, data := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0644))
+	}
+	name, text, unmapped, err := ImportColorscheme(filepath.Join(dir, file))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = ParseColorscheme(name, text, nil)
+	assert.NoError(t, err)
+	return name, text, unmapped
+}
+
+func TestImportVSCode(t *testing.T) {
+	name, text, unmapped := importTestScheme(t, map[string]string{
+		"base.json": `{
+			"colors": {"editor.background": "#000000", "editor.foreground": "#ffffff"},
+			"tokenColors": [{"scope": "comment", "settings": {"foreground": "#808080"}}]
+		}`,
+		"my-theme.json": `{
+			// comments are allowed
+			"name": "My Theme",
+			"include": "./base.json",
+			"colors": {
+				"editor.foreground": "#eeeeee",
+				"editor.lineHighlightBackground": "#ffffff33",
+			},
+			"tokenColors": [
+				{"scope": ["keyword", "meta.block"], "settings": {"foreground": "#f00", "fontStyle": "bold"}},
+				{"scope": "keyword.control, keyword.operator", "settings": {"foreground": "#00ff00"}},
+				{"scope": "source.go keyword", "settings": {"foreground": "#0000ff"}},
+				{"scope": "string", "settings": {"foreground": "#ABCDEF", "background": "#111111"}},
+			]
+		}`,
+	}, "my-theme.json")
+
+	assert.Equal(t, "my-theme", name)
+	assert.True(t, strings.HasPrefix(text, "# Imported from my-theme.json\n\ncolor-link default \"#EEEEEE,#000000\"\n"))
+	assert.Contains(t, text, `color-link comment "#808080"`)
+	// keyword.control is more specific than keyword
+	assert.Contains(t, text, `color-link statement "#00FF00"`)
+	assert.Contains(t, text, `color-link symbol.operator "#00FF00"`)
+	assert.Contains(t, text, `color-link constant.string "#ABCDEF,#111111"`)
+	assert.Contains(t, text, `color-link cursor-line "#333333"`)
+	assert.Equal(t, []string{"meta.block"}, unmapped)
+	assert.Contains(t, text, "#   meta.block\n")
+}
+
+func TestImportBase16(t *testing.T) {
+	var palette strings.Builder
+	for _, k := range []string{"00", "01", "02", "03", "04", "05", "06", "07", "08", "09", "0A", "0B", "0C", "0D", "0E", "0F"} {
+		palette.WriteString("  base" + k + `: "` + strings.Repeat(k, 3) + "\"\n")
+	}
+
+	name, text, unmapped := importTestScheme(t, map[string]string{
+		"x.yaml": "system: base16\nname: Ocean Dark\npalette:\n" + palette.String() + "  base10: \"000000\"\n",
+	}, "x.yaml")
+	assert.Equal(t, "ocean-dark", name)
+	assert.Contains(t, text, `color-link default "#050505,#000000"`)
+	assert.Contains(t, text, `color-link constant.string "#0B0B0B"`)
+	assert.Contains(t, text, `color-link error "bold #080808"`)
+	assert.Equal(t, []string{"base10"}, unmapped)
+
+	old := strings.ReplaceAll(palette.String(), "  base", "base")
+	name, text, _ = importTestScheme(t, map[string]string{
+		"old.yml": "scheme: \"Old\"\nauthor: \"me\"\n" + old,
+	}, "old.yml")
+	assert.Equal(t, "old", name)
+	assert.Contains(t, text, `color-link statement "#0E0E0E"`)
+
+	_, _, _, err := ImportColorscheme(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestImportITerm(t *testing.T) {
+	color := func(name string, r, g, b string) string {
+		return "<key>" + name + "</key><dict>" +
+			"<key>Blue Component</key><real>" + b + "</real>" +
+			"<key>Color Space</key><string>sRGB</string>" +
+			"<key>Green Component</key><real>" + g + "</real>" +
+			"<key>Red Component</key><real>" + r + "</real></dict>\n"
+	}
+	preset := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0"><dict>
+` + color("Background Color", "0", "0", "0") +
+		color("Foreground Color", "1", "1", "1") +
+		color("Ansi 1 Color", "1", "0", "0") +
+		color("Ansi 2 Color", "0", "1", "0") +
+		color("Cursor Color", "0.5", "0.5", "0.5") +
+		"</dict></plist>\n"
+
+	name, text, unmapped := importTestScheme(t, map[string]string{"Some Preset.itermcolors": preset}, "Some Preset.itermcolors")
+	assert.Equal(t, "some-preset", name)
+	assert.Contains(t, text, `color-link default "#FFFFFF,#000000"`)
+	assert.Contains(t, text, `color-link error "bold #FF0000"`)
+	assert.Contains(t, text, `color-link constant.string "#00FF00"`)
+	assert.Contains(t, text, `color-link statusline "#999999,#1A1A1A"`)
+	assert.Equal(t, []string{"Cursor Color"}, unmapped)
+}
+
+func TestInstallColorscheme(t *testing.T) {
+	old := ConfigDir
+	ConfigDir = t.TempDir()
+	defer func() { ConfigDir = old }()
+
+	path, err := InstallColorscheme("imported-test", "color-link default \"#FFFFFF,#000000\"\n")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(ConfigDir, "colorschemes", "imported-test.micro"), path)
+	assert.True(t, ColorschemeExists("imported-test"))
+
+	_, err = InstallColorscheme("imported-test", "")
+	assert.Error(t, err)
+}
@@ -72,3 +72,24 @@ color-link constant.string.char "#BDE6AD,#282828"`
 	assert.Equal(t, tcell.NewRGBColor(117, 113, 94), fg)
 	assert.Equal(t, tcell.NewRGBColor(40, 40, 40), bg)
 }
+
+func TestColorschemeOption(t *testing.T) {
+	globalSettings := GlobalSettings
+	defer func() {
+		GlobalSettings = globalSettings
+		TermBackground = ""
+		ColorschemeChosen = false
+	}()
+	GlobalSettings = map[string]any{"colorscheme": "default", "colorscheme.dark": "monokai", "colorscheme.light": ""}
+
+	assert.Equal(t, "colorscheme", ColorschemeOption())
+	TermBackground = "dark"
+	assert.Equal(t, "colorscheme.dark", ColorschemeOption())
+	TermBackground = "light"
+	assert.Equal(t, "colorscheme", ColorschemeOption())
+
+	// the colorscheme chosen by hand is kept whatever the background
+	TermBackground = "dark"
+	ColorschemeChosen = true
+	assert.Equal(t, "colorscheme", ColorschemeOption())
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"math"
+
+	"github.com/micro-editor/tcell/v2"
+)
+
+// TermColors is the number of colors the terminal can display. Hex colors
+// of colorschemes are approximated with the nearest palette color when the
+// terminal doesn't support true colors
+var TermColors = 1 << 24
+
+// lab is a color in the CIELAB color space, where distances approximate
+// perceived differences
+type lab [3]float64
+
+// the usual values of the 16 system colors, used to approximate colors on
+// terminals with 16 or 8 colors
+var systemColors = [16][3]uint8{
+	{0, 0, 0}, {128, 0, 0}, {0, 128, 0}, {128, 128, 0},
+	{0, 0, 128}, {128, 0, 128}, {0, 128, 128}, {192, 192, 192},
+	{128, 128, 128}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{0, 0, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// paletteLab holds the colors of the 256 color palette in CIELAB
+var paletteLab [256]lab
+
+func init() {
+	for i, c := range systemColors {
+		paletteLab[i] = toLab(c[0], c[1], c[2])
+	}
+	// the 6x6x6 color cube
+	levels := [6]uint8{0, 95, 135, 175, 215, 255}
+	for i := 0; i < 216; i++ {
+		paletteLab[16+i] = toLab(levels[i/36], levels[i/6%6], levels[i%6])
+	}
+	// the grayscale ramp
+	for i := 0; i < 24; i++ {
+		v := uint8(8 + 10*i)
+		paletteLab[232+i] = toLab(v, v, v)
+	}
+}
+
+func toLab(r, g, b uint8) lab {
+	linear := func(c uint8) float64 {
+		v := float64(c) / 255
+		if v <= 0.04045 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	lr, lg, lb := linear(r), linear(g), linear(b)
+
+	// XYZ relative to the D65 white point
+	x := (0.4124*lr + 0.3576*lg + 0.1805*lb) / 0.95047
+	y := 0.2126*lr + 0.7152*lg + 0.0722*lb
+	z := (0.0193*lr + 0.1192*lg + 0.9505*lb) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return lab{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// distance returns the CIE94 color difference between two colors
+func (c lab) distance(o lab) float64 {
+	dl := c[0] - o[0]
+	c1 := math.Hypot(c[1], c[2])
+	c2 := math.Hypot(o[1], o[2])
+	dc := c1 - c2
+	da, db := c[1]-o[1], c[2]-o[2]
+	dh2 := math.Max(0, da*da+db*db-dc*dc)
+	sc := 1 + 0.045*c1
+	sh := 1 + 0.015*c1
+	return math.Sqrt(dl*dl + dc*dc/(sc*sc) + dh2/(sh*sh))
+}
+
+// nearestColor returns the palette color which looks the closest to the
+// given color among the first n colors of the palette. With 256 colors, the
+// 16 system colors are not used since terminals often change them
+func nearestColor(r, g, b uint8, n int) tcell.Color {
+	start := 0
+	if n > 16 {
+		start = 16
+	}
+	if n > 256 {
+		n = 256
+	}
+
+	c := toLab(r, g, b)
+	best, bestDist := start, math.Inf(1)
+	for i := start; i < n; i++ {
+		if d := c.distance(paletteLab[i]); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return tcell.PaletteColor(best)
+}
+
+// approximateColor returns the color to use for a true color on the
+// terminal
+func approximateColor(c tcell.Color) tcell.Color {
+	if TermColors >= 1<<24 || !c.IsRGB() {
+		return c
+	}
+	if TermColors < 8 {
+		return tcell.ColorDefault
+	}
+	r, g, b := c.RGB()
+	return nearestColor(uint8(r), uint8(g), uint8(b), TermColors)
+}
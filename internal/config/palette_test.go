@@ -0,0 +1,40 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/micro-editor/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNearestColor(t *testing.T) {
+	// exact colors of the cube and the grayscale ramp
+	assert.Equal(t, tcell.PaletteColor(16), nearestColor(0, 0, 0, 256))
+	assert.Equal(t, tcell.PaletteColor(196), nearestColor(255, 0, 0, 256))
+	assert.Equal(t, tcell.PaletteColor(244), nearestColor(128, 128, 128, 256))
+	// dracula's background is a dark gray-blue, not black
+	assert.Equal(t, tcell.PaletteColor(235), nearestColor(0x28, 0x2a, 0x36, 256))
+
+	assert.Equal(t, tcell.PaletteColor(9), nearestColor(250, 10, 10, 16))
+	assert.Equal(t, tcell.PaletteColor(1), nearestColor(120, 10, 10, 8))
+	assert.Equal(t, tcell.PaletteColor(7), nearestColor(220, 220, 220, 8))
+}
+
+func TestApproximateColor(t *testing.T) {
+	defer func(n int) { TermColors = n }(TermColors)
+
+	TermColors = 1 << 24
+	c, ok := StringToColor("#ff0000")
+	assert.True(t, ok)
+	assert.Equal(t, tcell.GetColor("#ff0000"), c)
+
+	TermColors = 256
+	c, _ = StringToColor("#ff0000")
+	assert.Equal(t, tcell.PaletteColor(196), c)
+	c, _ = StringToColor("red")
+	assert.Equal(t, tcell.ColorMaroon, c)
+
+	TermColors = 2
+	c, _ = StringToColor("#ff0000")
+	assert.Equal(t, tcell.ColorDefault, c)
+}
@@ -7,7 +7,9 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
+	"github.com/helmutkemper/micro/v2/pkg/highlight"
 	rt "github.com/helmutkemper/micro/v2/runtime"
 )
 
@@ -17,10 +19,13 @@ const (
 	RTHelp         = 2
 	RTPlugin       = 3
 	RTSyntaxHeader = 4
+	RTSnippet      = 5
+	RTDictionary   = 6
+	RTAffix        = 7
 )
 
 var (
-	NumTypes = 5 // How many filetypes are there
+	NumTypes = 8 // How many filetypes are there
 )
 
 type RTFiletype int
@@ -66,6 +71,20 @@ type memoryFile struct {
 	data []byte
 }
 
+// a TextMate or Sublime Text grammar on the filesystem, converted to a
+// syntax file when it is first read
+type grammarFile struct {
+	path string
+	once sync.Once
+	data []byte
+	err  error
+
+	lock sync.Mutex
+	// warnings are the warnings of the conversion which were not returned
+	// by GrammarWarnings yet
+	warnings []string
+}
+
 func (mf memoryFile) Name() string {
 	return mf.name
 }
@@ -82,6 +101,41 @@ func (rf realFile) Data() ([]byte, error) {
 	return os.ReadFile(string(rf))
 }
 
+func (gf *grammarFile) Name() string {
+	return highlight.GrammarName(gf.path)
+}
+
+func (gf *grammarFile) Data() ([]byte, error) {
+	gf.once.Do(func() {
+		data, err := os.ReadFile(gf.path)
+		if err != nil {
+			gf.err = err
+			return
+		}
+		var warnings []string
+		gf.data, warnings, gf.err = highlight.ConvertGrammar(gf.path, data)
+		gf.lock.Lock()
+		gf.warnings = warnings
+		gf.lock.Unlock()
+	})
+	return gf.data, gf.err
+}
+
+// GrammarWarnings returns the warnings of the conversion of a syntax file
+// converted from a grammar, once it is read. Each warning is returned once
+// so that it is shown to the user once
+func GrammarWarnings(f RuntimeFile) []string {
+	gf, ok := f.(*grammarFile)
+	if !ok {
+		return nil
+	}
+	gf.lock.Lock()
+	defer gf.lock.Unlock()
+	warnings := gf.warnings
+	gf.warnings = nil
+	return warnings
+}
+
 func (af assetFile) Name() string {
 	fn := filepath.Base(string(af))
 	return fn[:len(fn)-len(filepath.Ext(fn))]
@@ -114,6 +168,17 @@ func AddRuntimeFilesFromDirectory(fileType RTFiletype, directory, pattern string
 	}
 }
 
+// AddGrammarFilesFromDirectory registers each TextMate or Sublime Text
+// grammar from the given directory as a syntax file
+func AddGrammarFilesFromDirectory(directory string) {
+	files, _ := os.ReadDir(directory)
+	for _, f := range files {
+		if !f.IsDir() && highlight.IsGrammarFile(f.Name()) {
+			AddRealRuntimeFile(RTSyntax, &grammarFile{path: filepath.Join(directory, f.Name())})
+		}
+	}
+}
+
 // AddRuntimeFilesFromAssets registers each file from the given asset-directory for
 // the filetype which matches the file-pattern
 func AddRuntimeFilesFromAssets(fileType RTFiletype, directory, pattern string) {
@@ -172,9 +237,15 @@ func InitRuntimeFiles(user bool) {
 	initRuntimeVars()
 
 	add(RTColorscheme, "colorschemes", "*.micro")
+	if user {
+		AddGrammarFilesFromDirectory(filepath.Join(ConfigDir, "syntax"))
+	}
 	add(RTSyntax, "syntax", "*.yaml")
 	add(RTSyntaxHeader, "syntax", "*.hdr")
 	add(RTHelp, "help", "*.md")
+	add(RTSnippet, "snippets", "*.json")
+	add(RTDictionary, "dictionaries", "*.dic")
+	add(RTAffix, "dictionaries", "*.aff")
 }
 
 // InitPlugins initializes the plugins
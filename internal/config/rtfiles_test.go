@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -40,3 +42,24 @@ func TestFindFile(t *testing.T) {
 	e := FindRuntimeFile(RTSyntax, "foobar")
 	assert.Nil(t, e)
 }
+
+func TestGrammarFile(t *testing.T) {
+	dir := t.TempDir()
+	grammar := `{"name": "Baz", "scopeName": "source.baz", "fileTypes": ["baz"],
+		"patterns": [{"match": "#.*$", "name": "comment.line.baz"},
+			{"begin": "<", "while": "^>", "name": "string.baz"}]}`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "baz.tmLanguage.json"), []byte(grammar), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "baz.json"), []byte("{}"), 0644))
+
+	AddGrammarFilesFromDirectory(dir)
+	f := FindRuntimeFile(RTSyntax, "baz")
+	assert.NotNil(t, f)
+	data, err := f.Data()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("filetype: baz"), data[:13])
+	// the warnings of the conversion are returned once
+	assert.Len(t, GrammarWarnings(f), 1)
+	assert.Empty(t, GrammarWarnings(f))
+	assert.Contains(t, ListRealRuntimeFiles(RTSyntax), f)
+	assert.Nil(t, FindRuntimeFile(RTSyntax, "baz.json"))
+}
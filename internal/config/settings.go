@@ -21,26 +21,36 @@ type optionValidator func(string, any) error
 
 // a list of settings that need option validators
 var optionValidators = map[string]optionValidator{
-	"autosave":        validateNonNegativeValue,
-	"clipboard":       validateChoice,
-	"colorcolumn":     validateNonNegativeValue,
-	"colorscheme":     validateColorscheme,
-	"detectlimit":     validateNonNegativeValue,
-	"encoding":        validateEncoding,
-	"fileformat":      validateChoice,
-	"helpsplit":       validateChoice,
-	"matchbracestyle": validateChoice,
-	"multiopen":       validateChoice,
-	"pageoverlap":     validateNonNegativeValue,
-	"reload":          validateChoice,
-	"scrollmargin":    validateNonNegativeValue,
-	"scrollspeed":     validateNonNegativeValue,
-	"tabsize":         validatePositiveValue,
-	"truecolor":       validateChoice,
+	"autosave":          validateNonNegativeValue,
+	"blame":             validateChoice,
+	"clipboard":         validateChoice,
+	"clipboardhistory":  validateNonNegativeValue,
+	"colorcolumn":       validateNonNegativeValue,
+	"colorscheme":       validateColorscheme,
+	"colorscheme.dark":  validateOptionalColorscheme,
+	"colorscheme.light": validateOptionalColorscheme,
+	"detectlimit":       validateNonNegativeValue,
+	"encoding":          validateEncoding,
+	"fileformat":        validateChoice,
+	"filehistorydays":   validateNonNegativeValue,
+	"filehistorymax":    validateNonNegativeValue,
+	"formattimeout":     validatePositiveValue,
+	"formatter":         validateFormatter,
+	"helpsplit":         validateChoice,
+	"matchbracestyle":   validateChoice,
+	"multiopen":         validateChoice,
+	"pageoverlap":       validateNonNegativeValue,
+	"reload":            validateChoice,
+	"scrollmargin":      validateNonNegativeValue,
+	"scrollspeed":       validateNonNegativeValue,
+	"tabsize":           validatePositiveValue,
+	"textwidth":         validateNonNegativeValue,
+	"truecolor":         validateChoice,
 }
 
 // a list of settings with pre-defined choices
 var OptionChoices = map[string][]string{
+	"blame":           {"off", "inline", "gutter"},
 	"clipboard":       {"internal", "external", "terminal"},
 	"fileformat":      {"unix", "dos"},
 	"helpsplit":       {"hsplit", "vsplit"},
@@ -58,6 +68,7 @@ var defaultCommonSettings = map[string]any{
 	"backup":          true,
 	"backupdir":       "",
 	"basename":        false,
+	"blame":           "off",
 	"colorcolumn":     float64(0),
 	"cursorline":      true,
 	"detectlimit":     float64(100),
@@ -66,6 +77,8 @@ var defaultCommonSettings = map[string]any{
 	"eofnewline":      true,
 	"fastdirty":       false,
 	"fileformat":      defaultFileFormat(),
+	"formatonsave":    true,
+	"filehistory":     false,
 	"filetype":        "unknown",
 	"hlsearch":        false,
 	"hltaberrors":     false,
@@ -85,6 +98,7 @@ var defaultCommonSettings = map[string]any{
 	"reload":          "prompt",
 	"rmtrailingws":    false,
 	"ruler":           true,
+	"savebookmarks":   true,
 	"savecursor":      false,
 	"saveundo":        false,
 	"scrollbar":       false,
@@ -93,15 +107,19 @@ var defaultCommonSettings = map[string]any{
 	"showchars":       "",
 	"smartpaste":      true,
 	"softwrap":        false,
+	"spellcheck":      false,
+	"spelllang":       "en_US",
 	"splitbottom":     true,
 	"splitright":      true,
-	"statusformatl":   "$(filename) $(modified)$(overwrite)($(line),$(col)) $(status.paste)| ft:$(opt:filetype) | $(opt:fileformat) | $(opt:encoding)",
+	"statusformatl":   "$(filename) $(modified)$(overwrite)($(line),$(col)) $(column)$(errors)$(warnings)$(status.paste)| ft:$(opt:filetype) | $(opt:fileformat) | $(opt:encoding)",
 	"statusformatr":   "$(bind:ToggleKeyMenu): bindings, $(bind:ToggleHelp): help",
 	"statusline":      true,
 	"syntax":          true,
+	"tablemode":       true,
 	"tabmovement":     false,
 	"tabsize":         float64(4),
 	"tabstospaces":    false,
+	"textwidth":       float64(0),
 	"truecolor":       "auto",
 	"useprimary":      true,
 	"wordwrap":        false,
@@ -110,27 +128,36 @@ var defaultCommonSettings = map[string]any{
 // a list of settings that should only be globally modified and their
 // default values
 var DefaultGlobalOnlySettings = map[string]any{
-	"autosave":       float64(0),
-	"clipboard":      "external",
-	"colorscheme":    "default",
-	"divchars":       "|-",
-	"divreverse":     true,
-	"fakecursor":     false,
-	"helpsplit":      "hsplit",
-	"infobar":        true,
-	"keymenu":        false,
-	"mouse":          true,
-	"multiopen":      "tab",
-	"parsecursor":    false,
-	"paste":          false,
-	"pluginchannels": []string{"https://raw.githubusercontent.com/micro-editor/plugin-channel/master/channel.json"},
-	"pluginrepos":    []string{},
-	"savehistory":    true,
-	"scrollbarchar":  "|",
-	"sucmd":          "sudo",
-	"tabhighlight":   false,
-	"tabreverse":     true,
-	"xterm":          false,
+	"autosave":          float64(0),
+	"clipboard":         "external",
+	"clipboardhistory":  float64(50),
+	"colorscheme":       "default",
+	"colorscheme.dark":  "",
+	"colorscheme.light": "",
+	"divchars":          "|-",
+	"divreverse":        true,
+	"fakecursor":        false,
+	"filehistorydays":   float64(30),
+	"filehistorymax":    float64(50),
+	"formattimeout":     float64(5),
+	"formatter":         map[string]any{},
+	"helpsplit":         "hsplit",
+	"infobar":           true,
+	"keymenu":           false,
+	"mouse":             true,
+	"multiopen":         "tab",
+	"parsecursor":       false,
+	"paste":             false,
+	"pluginchannels":    []string{"https://raw.githubusercontent.com/micro-editor/plugin-channel/master/channel.json"},
+	"pluginrepos":       []string{},
+	"remotecontrol":     true,
+	"saveclipboard":     false,
+	"savehistory":       true,
+	"scrollbarchar":     "|",
+	"sucmd":             "sudo",
+	"tabhighlight":      false,
+	"tabreverse":        true,
+	"xterm":             false,
 }
 
 // a list of settings that should never be globally modified
@@ -169,11 +196,22 @@ func init() {
 	VolatileSettings = make(map[string]bool)
 }
 
+// isSection returns true if a parsed setting is a section holding the
+// options of the files matching a glob or of a filetype, rather than an
+// option whose value is a map such as formatter
+func isSection(k string, v any) bool {
+	if _, ok := v.(map[string]any); !ok {
+		return false
+	}
+	_, option := DefaultGlobalOnlySettings[k]
+	return !option
+}
+
 func validateParsedSettings() error {
 	var err error
 	defaults := DefaultAllSettings()
 	for k, v := range parsedSettings {
-		if strings.HasPrefix(reflect.TypeOf(v).String(), "map") {
+		if isSection(k, v) {
 			if strings.HasPrefix(k, "ft:") {
 				for k1, v1 := range v.(map[string]any) {
 					if _, ok := defaults[k1]; ok {
@@ -276,7 +314,7 @@ func verifySetting(option string, value any, def any) error {
 		return fmt.Errorf("Error: setting '%s' has incorrect type (%s), using default value: %v (%s)", option, valType, def, defType)
 	}
 
-	if option == "colorscheme" {
+	if option == "colorscheme" || option == "colorscheme.light" || option == "colorscheme.dark" {
 		// Plugins are not initialized yet, so do not verify if the colorscheme
 		// exists yet, since the colorscheme may be added by a plugin later.
 		return nil
@@ -296,7 +334,7 @@ func InitGlobalSettings() error {
 	GlobalSettings = DefaultAllSettings()
 
 	for k, v := range parsedSettings {
-		if !strings.HasPrefix(reflect.TypeOf(v).String(), "map") {
+		if !isSection(k, v) {
 			GlobalSettings[k] = v
 		}
 	}
@@ -308,7 +346,7 @@ func InitGlobalSettings() error {
 // Must be called after ReadSettings
 func UpdatePathGlobLocals(settings map[string]any, path string) {
 	for k, v := range parsedSettings {
-		if strings.HasPrefix(reflect.TypeOf(v).String(), "map") && !strings.HasPrefix(k, "ft:") {
+		if isSection(k, v) && !strings.HasPrefix(k, "ft:") {
 			g, _ := glob.Compile(k)
 			if g.MatchString(path) {
 				for k1, v1 := range v.(map[string]any) {
@@ -324,7 +362,7 @@ func UpdatePathGlobLocals(settings map[string]any, path string) {
 // Must be called after ReadSettings
 func UpdateFileTypeLocals(settings map[string]any, filetype string) {
 	for k, v := range parsedSettings {
-		if strings.HasPrefix(reflect.TypeOf(v).String(), "map") && strings.HasPrefix(k, "ft:") {
+		if isSection(k, v) && strings.HasPrefix(k, "ft:") {
 			if filetype == k[3:] {
 				for k1, v1 := range v.(map[string]any) {
 					if k1 != "filetype" {
@@ -352,7 +390,7 @@ func WriteSettings(filename string) error {
 
 		// remove any options froms parsedSettings that have since been marked as default
 		for k, v := range parsedSettings {
-			if !strings.HasPrefix(reflect.TypeOf(v).String(), "map") {
+			if !isSection(k, v) {
 				cur, okcur := GlobalSettings[k]
 				_, vol := VolatileSettings[k]
 				if def, ok := defaults[k]; ok && okcur && !vol && reflect.DeepEqual(cur, def) {
@@ -496,6 +534,23 @@ func GetNativeValue(option, value string) (any, error) {
 			return nil, ErrInvalidValue
 		}
 		return f, nil
+	case reflect.Map:
+		// the entries of a map are set one at a time with key=value, and
+		// removed with key=
+		key, v, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, ErrInvalidValue
+		}
+		m := make(map[string]any)
+		for k, cur := range curVal.(map[string]any) {
+			m[k] = cur
+		}
+		if v == "" {
+			delete(m, key)
+		} else {
+			m[key] = v
+		}
+		return m, nil
 	default:
 		return nil, ErrInvalidValue
 	}
@@ -574,7 +629,29 @@ func validateColorscheme(option string, value any) error {
 	return nil
 }
 
+func validateOptionalColorscheme(option string, value any) error {
+	if value == "" {
+		return nil
+	}
+	return validateColorscheme(option, value)
+}
+
 func validateEncoding(option string, value any) error {
 	_, err := htmlindex.Get(value.(string))
 	return err
 }
+
+// validateFormatter checks that the formatter option maps filetypes to
+// formatter commands
+func validateFormatter(option string, value any) error {
+	formatters, ok := value.(map[string]any)
+	if !ok {
+		return errors.New("Expected an object mapping filetypes to formatters for " + option)
+	}
+	for ft, f := range formatters {
+		if _, ok := f.(string); !ok {
+			return errors.New("Expected a string as the formatter of " + ft)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatterSetting(t *testing.T) {
+	configDir, globalSettings := ConfigDir, GlobalSettings
+	defer func() {
+		ConfigDir, GlobalSettings = configDir, globalSettings
+		parsedSettings = make(map[string]any)
+	}()
+	ConfigDir = t.TempDir()
+	settings := `{
+    "formatter": {"go": "gofmt", "json": "builtin:json"},
+    "*.txt": {"tabsize": 2}
+}`
+	if err := os.WriteFile(filepath.Join(ConfigDir, "settings.json"), []byte(settings), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, ReadSettings())
+	assert.NoError(t, InitGlobalSettings())
+	formatters := map[string]any{"go": "gofmt", "json": "builtin:json"}
+	assert.Equal(t, formatters, GlobalSettings["formatter"])
+
+	// the formatter is not a glob section, unlike *.txt
+	local := make(map[string]any)
+	UpdatePathGlobLocals(local, "formatter")
+	assert.Empty(t, local)
+	UpdatePathGlobLocals(local, "a.txt")
+	assert.Equal(t, map[string]any{"tabsize": float64(2)}, local)
+
+	v, err := GetNativeValue("formatter", "c=clang-format")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"go": "gofmt", "json": "builtin:json", "c": "clang-format"}, v)
+	v, err = GetNativeValue("formatter", "go=")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"json": "builtin:json"}, v)
+	_, err = GetNativeValue("formatter", "gofmt")
+	assert.Equal(t, ErrInvalidValue, err)
+	// the option itself is not changed
+	assert.Equal(t, formatters, GlobalSettings["formatter"])
+
+	assert.Error(t, OptionIsValid("formatter", map[string]any{"go": true}))
+}
@@ -1,9 +1,11 @@
 package display
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 
+	humanize "github.com/dustin/go-humanize"
 	"github.com/helmutkemper/micro/v2/internal/buffer"
 	"github.com/helmutkemper/micro/v2/internal/config"
 	"github.com/helmutkemper/micro/v2/internal/screen"
@@ -27,6 +29,7 @@ type BufWindow struct {
 	bufHeight        int
 	gutterOffset     int
 	hasMessage       bool
+	hasBookmark      bool
 	maxLineNumLength int
 	drawDivider      bool
 }
@@ -142,6 +145,7 @@ func (w *BufWindow) updateDisplayInfo() {
 	}
 
 	w.hasMessage = len(b.Messages) > 0
+	w.hasBookmark = len(b.Bookmarks) > 0
 
 	// We need to know the string length of the largest line number
 	// so we can pad appropriately when displaying line numbers
@@ -151,9 +155,15 @@ func (w *BufWindow) updateDisplayInfo() {
 	if w.hasMessage {
 		w.gutterOffset += 2
 	}
+	if w.hasBookmark {
+		w.gutterOffset++
+	}
 	if b.Settings["diffgutter"].(bool) {
 		w.gutterOffset++
 	}
+	if b.Settings["blame"] == "gutter" {
+		w.gutterOffset += blameGutterWidth
+	}
 	if b.Settings["ruler"].(bool) {
 		w.gutterOffset += w.maxLineNumLength + 1
 	}
@@ -174,6 +184,7 @@ func (w *BufWindow) updateDisplayInfo() {
 
 func (w *BufWindow) getStartInfo(n, lineN int) ([]byte, int, int, *tcell.Style) {
 	tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
+	table := w.Buf.Table()
 	width := 0
 	bloc := buffer.Loc{0, lineN}
 	b := w.Buf.LineBytes(lineN)
@@ -187,9 +198,10 @@ func (w *BufWindow) getStartInfo(n, lineN int) ([]byte, int, int, *tcell.Style)
 			s = &curStyle
 		}
 
-		w := 0
-		switch r {
-		case '\t':
+		w, delim := table.DelimWidth(lineN, bloc.X)
+		switch {
+		case delim:
+		case r == '\t':
 			ts := tabsize - (width % tabsize)
 			w = ts
 		default:
@@ -295,6 +307,28 @@ func (w *BufWindow) drawGutter(vloc *buffer.Loc, bloc *buffer.Loc) {
 	}
 }
 
+func (w *BufWindow) drawBookmarkGutter(softwrapped bool, vloc *buffer.Loc, bloc *buffer.Loc) {
+	if vloc.X >= w.gutterOffset {
+		return
+	}
+
+	char := ' '
+	s := config.DefStyle
+	if bm := w.Buf.BookmarkAtLine(bloc.Y); bm != nil && !softwrapped {
+		char = '*'
+		if bm.Name != "" {
+			char = []rune(bm.Name)[0]
+		}
+		if style, ok := config.Colorscheme["bookmark"]; ok {
+			s = style
+		} else if style, ok := config.Colorscheme["gutter-info"]; ok {
+			s = style
+		}
+	}
+	screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, char, nil, s)
+	vloc.X++
+}
+
 func (w *BufWindow) drawDiffGutter(backgroundStyle tcell.Style, softwrapped bool, vloc *buffer.Loc, bloc *buffer.Loc) {
 	if vloc.X >= w.gutterOffset {
 		return
@@ -327,6 +361,66 @@ func (w *BufWindow) drawDiffGutter(backgroundStyle tcell.Style, softwrapped bool
 	vloc.X++
 }
 
+// blameGutterWidth is the width of the blame gutter, with the short hash,
+// the author and the date of the commits
+const blameGutterWidth = 32
+
+func (w *BufWindow) drawBlameGutter(lineNumStyle tcell.Style, softwrapped bool, vloc *buffer.Loc, bloc *buffer.Loc) {
+	text := ""
+	// the commit is only written on the first line of its block of lines
+	if c := w.Buf.Blame(bloc.Y); c != nil && !softwrapped && (bloc.Y == 0 || w.Buf.Blame(bloc.Y-1) != c) {
+		if c.Uncommitted() {
+			text = "Not committed yet"
+		} else {
+			author := c.Author
+			if util.CharacterCountInString(author) > 11 {
+				author = util.SliceStartStr(author, 10) + "…"
+			}
+			text = fmt.Sprintf("%s %-11s %s", c.Short(), author, c.Time.Format("2006-01-02"))
+		}
+	}
+
+	style := lineNumStyle
+	if s, ok := config.Colorscheme["blame"]; ok {
+		style = s
+	}
+	runes := []rune(text)
+	for i := 0; i < blameGutterWidth && vloc.X < w.gutterOffset; i++ {
+		r := ' '
+		if i < len(runes) {
+			r = runes[i]
+		}
+		screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, r, nil, style)
+		vloc.X++
+	}
+}
+
+// drawInlineBlame writes the author, the date and the summary of the commit
+// of the line of the cursor after its end, from vloc
+func (w *BufWindow) drawInlineBlame(vloc buffer.Loc, bloc buffer.Loc, maxWidth int) {
+	c := w.Buf.Blame(bloc.Y)
+	if c == nil {
+		return
+	}
+	text := fmt.Sprintf("%s, %s • %s", c.Author, humanize.Time(c.Time), c.Summary)
+	if c.Uncommitted() {
+		text = "Not committed yet"
+	}
+
+	style := config.GetColor("comment")
+	if s, ok := config.Colorscheme["blame"]; ok {
+		style = s
+	}
+	for _, r := range text {
+		width := runewidth.RuneWidth(r)
+		if vloc.X+width > maxWidth {
+			break
+		}
+		screen.SetContent(w.X+vloc.X, w.Y+vloc.Y, r, nil, style)
+		vloc.X += width
+	}
+}
+
 func (w *BufWindow) drawLineNum(lineNumStyle tcell.Style, softwrapped bool, vloc *buffer.Loc, bloc *buffer.Loc) {
 	cursorLine := w.Buf.GetActiveCursor().Loc.Y
 	var lineInt int
@@ -389,6 +483,8 @@ func (w *BufWindow) displayBuffer() {
 
 	maxWidth := w.gutterOffset + w.bufWidth
 
+	b.SetHighlightView(w.StartLine.Line, w.StartLine.Line+w.bufHeight)
+
 	if b.ModifiedThisFrame {
 		if b.Settings["diffgutter"].(bool) {
 			b.UpdateDiff()
@@ -436,6 +532,7 @@ func (w *BufWindow) displayBuffer() {
 
 	tabsize := util.IntOpt(b.Settings["tabsize"])
 	colorcolumn := util.IntOpt(b.Settings["colorcolumn"])
+	table := b.Table()
 
 	// this represents the current draw position
 	// within the current window
@@ -496,10 +593,18 @@ func (w *BufWindow) displayBuffer() {
 				w.drawGutter(&vloc, &bloc)
 			}
 
+			if w.hasBookmark {
+				w.drawBookmarkGutter(false, &vloc, &bloc)
+			}
+
 			if b.Settings["diffgutter"].(bool) {
 				w.drawDiffGutter(s, false, &vloc, &bloc)
 			}
 
+			if b.Settings["blame"] == "gutter" {
+				w.drawBlameGutter(s, false, &vloc, &bloc)
+			}
+
 			if b.Settings["ruler"].(bool) {
 				w.drawLineNum(s, false, &vloc, &bloc)
 			}
@@ -510,6 +615,8 @@ func (w *BufWindow) displayBuffer() {
 		bline := b.LineBytes(bloc.Y)
 		blineLen := util.CharacterCount(bline)
 
+		misspellings := b.Misspellings(bloc.Y)
+
 		leadingwsEnd := len(util.GetLeadingWhitespace(bline))
 		trailingwsStart := blineLen - util.CharacterCount(util.GetTrailingWhitespace(bline))
 
@@ -517,6 +624,10 @@ func (w *BufWindow) displayBuffer() {
 		if startStyle != nil {
 			curStyle = *startStyle
 		}
+		if !b.HighlightReady(bloc.Y) {
+			// the highlighter has not reached this line yet
+			curStyle = config.DefStyle
+		}
 		bloc.X = bslice
 
 		// returns the rune to be drawn, style of it and if the bg should be preserved
@@ -667,6 +778,18 @@ func (w *BufWindow) displayBuffer() {
 					}
 				}
 
+				for _, m := range misspellings {
+					if bloc.X >= m.Start && bloc.X < m.End {
+						style = style.Underline(true)
+						if s, ok := config.Colorscheme["spell-error"]; ok {
+							if fg, _, _ := s.Decompose(); fg != tcell.ColorDefault {
+								style = style.Foreground(fg)
+							}
+						}
+						break
+					}
+				}
+
 				if s, ok := config.Colorscheme["color-column"]; ok {
 					if colorcolumn != 0 && vloc.X-w.gutterOffset+w.StartCol == colorcolumn && !preservebg {
 						fg, _, _ := s.Decompose()
@@ -693,9 +816,15 @@ func (w *BufWindow) displayBuffer() {
 				if w.hasMessage {
 					w.drawGutter(&vloc, &bloc)
 				}
+				if w.hasBookmark {
+					w.drawBookmarkGutter(true, &vloc, &bloc)
+				}
 				if b.Settings["diffgutter"].(bool) {
 					w.drawDiffGutter(lineNumStyle, true, &vloc, &bloc)
 				}
+				if b.Settings["blame"] == "gutter" {
+					w.drawBlameGutter(lineNumStyle, true, &vloc, &bloc)
+				}
 
 				// This will draw an empty line number because the current line is wrapped
 				if b.Settings["ruler"].(bool) {
@@ -732,8 +861,12 @@ func (w *BufWindow) displayBuffer() {
 			width := 0
 
 			linex := totalwidth
-			switch r {
-			case '\t':
+			dw, delim := table.DelimWidth(loc.Y, loc.X)
+			switch {
+			case delim:
+				width = util.Min(dw, maxWidth-vloc.X)
+				totalwidth += dw
+			case r == '\t':
 				ts := tabsize - (totalwidth % tabsize)
 				width = util.Min(ts, maxWidth-vloc.X)
 				totalwidth += ts
@@ -829,6 +962,10 @@ func (w *BufWindow) displayBuffer() {
 			// Display newline within a selection
 			drawrune, drawstyle, preservebg := getRuneStyle(' ', config.DefStyle, 0, totalwidth, true)
 			draw(drawrune, nil, drawstyle, true, true, preservebg)
+
+			if b.Settings["blame"] == "inline" && w.active && len(line) == 0 && bloc.Y == b.GetActiveCursor().Y {
+				w.drawInlineBlame(buffer.Loc{X: vloc.X + 3, Y: vloc.Y}, bloc, maxWidth)
+			}
 		}
 
 		bloc.X = w.StartCol
@@ -867,6 +1004,15 @@ func (w *BufWindow) displayStatusLine() {
 	}
 }
 
+// StatusLineAction returns the action of the statusline segment at the
+// given screen location, or an empty string if there is none
+func (w *BufWindow) StatusLineAction(x, y int) string {
+	if !w.Buf.Settings["statusline"].(bool) || y != w.Y+w.Height-1 {
+		return ""
+	}
+	return w.sline.ClickAction(x - w.X)
+}
+
 func (w *BufWindow) displayScrollBar() {
 	if w.Buf.Settings["scrollbar"].(bool) && w.Buf.LinesNum() > w.Height {
 		scrollX := w.X + w.Width - 1
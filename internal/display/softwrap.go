@@ -80,6 +80,7 @@ func (w *BufWindow) getVLocFromLoc(loc buffer.Loc) VLoc {
 
 	wordwrap := w.Buf.Settings["wordwrap"].(bool)
 	tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
+	table := w.Buf.Table()
 
 	line := w.Buf.LineBytes(loc.Y)
 	x := 0
@@ -88,13 +89,17 @@ func (w *BufWindow) getVLocFromLoc(loc buffer.Loc) VLoc {
 	wordwidth := 0
 	wordoffset := 0
 
-	for len(line) > 0 {
+	for i := 0; len(line) > 0; i++ {
 		r, _, size := util.DecodeCharacter(line)
 		line = line[size:]
 
 		width := 0
-		switch r {
-		case '\t':
+		dw, delim := table.DelimWidth(loc.Y, i)
+		switch {
+		case delim:
+			width = util.Min(dw, w.bufWidth-vloc.VisualX)
+			totalwidth += dw
+		case r == '\t':
 			ts := tabsize - (totalwidth % tabsize)
 			width = util.Min(ts, w.bufWidth-vloc.VisualX)
 			totalwidth += ts
@@ -151,6 +156,7 @@ func (w *BufWindow) getLocFromVLoc(svloc VLoc) buffer.Loc {
 
 	wordwrap := w.Buf.Settings["wordwrap"].(bool)
 	tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
+	table := w.Buf.Table()
 
 	line := w.Buf.LineBytes(svloc.Line)
 	vloc := VLoc{SLoc: SLoc{svloc.Line, 0}, VisualX: 0}
@@ -170,8 +176,12 @@ func (w *BufWindow) getLocFromVLoc(svloc VLoc) buffer.Loc {
 		line = line[size:]
 
 		width := 0
-		switch r {
-		case '\t':
+		dw, delim := table.DelimWidth(svloc.Line, loc.X+len(widths))
+		switch {
+		case delim:
+			width = util.Min(dw, w.bufWidth-vloc.VisualX)
+			totalwidth += dw
+		case r == '\t':
 			ts := tabsize - (totalwidth % tabsize)
 			width = util.Min(ts, w.bufWidth-vloc.VisualX)
 			totalwidth += ts
@@ -321,9 +331,7 @@ func (w *BufWindow) SLocFromLoc(loc buffer.Loc) SLoc {
 // visual location in the linewrapped buffer.
 func (w *BufWindow) VLocFromLoc(loc buffer.Loc) VLoc {
 	if !w.Buf.Settings["softwrap"].(bool) {
-		tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
-
-		visualx := util.StringWidth(w.Buf.LineBytes(loc.Y), loc.X, tabsize)
+		visualx := w.Buf.VisualWidth(loc.Y, loc.X)
 		return VLoc{SLoc{loc.Y, 0}, visualx}
 	}
 	return w.getVLocFromLoc(loc)
@@ -333,9 +341,7 @@ func (w *BufWindow) VLocFromLoc(loc buffer.Loc) VLoc {
 // the position in the buffer corresponding to this visual location.
 func (w *BufWindow) LocFromVLoc(vloc VLoc) buffer.Loc {
 	if !w.Buf.Settings["softwrap"].(bool) {
-		tabsize := util.IntOpt(w.Buf.Settings["tabsize"])
-
-		x := util.GetCharPosInLine(w.Buf.LineBytes(vloc.Line), vloc.VisualX, tabsize)
+		x := w.Buf.CharPos(vloc.Line, vloc.VisualX)
 		return buffer.Loc{x, vloc.Line}
 	}
 	return w.getLocFromVLoc(vloc)
@@ -1,7 +1,7 @@
 package display
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -15,6 +15,7 @@ import (
 	"github.com/helmutkemper/micro/v2/internal/screen"
 	"github.com/helmutkemper/micro/v2/internal/util"
 	runewidth "github.com/mattn/go-runewidth"
+	"github.com/micro-editor/tcell/v2"
 	lua "github.com/yuin/gopher-lua"
 )
 
@@ -26,68 +27,330 @@ type StatusLine struct {
 	Info map[string]func(*buffer.Buffer) string
 
 	win *BufWindow
+	// clicks are the regions of the last drawn statusline which run an
+	// action when clicked
+	clicks []statusClick
 }
 
-var statusInfo = map[string]func(*buffer.Buffer) string{
-	"filename": func(b *buffer.Buffer) string {
-		return b.GetName()
+// A StatusSegment is a part of the statusline, which is placed in the
+// statusformatl and statusformatr options with $(name)
+type StatusSegment struct {
+	// Text returns the text of the segment. Segments with an empty text
+	// are hidden
+	Text func(b *buffer.Buffer) string
+	// Visible returns whether the segment is shown, if it is set
+	Visible func(b *buffer.Buffer) bool
+	// Style returns the colorscheme group used to draw the segment, if it
+	// is set. The statusline style is used if the group is empty or not
+	// defined by the colorscheme
+	Style func(b *buffer.Buffer) string
+	// Priority decides which segments are hidden when the statusline is
+	// too narrow: the segments with the lowest priority are hidden first
+	Priority int
+	// Click is the action run when the segment is clicked, written like
+	// the actions in bindings.json
+	Click string
+}
+
+type statusClick struct {
+	start, end int
+	action     string
+}
+
+// statusSpan is a part of a statusline format, either literal text or the
+// text of a segment
+type statusSpan struct {
+	text     []byte
+	width    int
+	style    tcell.Style
+	priority int
+	// segment is true if the span can be hidden to make room
+	segment bool
+	hidden  bool
+	click   string
+}
+
+func styleGroup(group string) func(*buffer.Buffer) string {
+	return func(*buffer.Buffer) string {
+		return group
+	}
+}
+
+var statusSegments = map[string]*StatusSegment{
+	"filename": {
+		Text: func(b *buffer.Buffer) string {
+			return b.GetName()
+		},
+		Priority: 10,
 	},
-	"line": func(b *buffer.Buffer) string {
-		return strconv.Itoa(b.GetActiveCursor().Y + 1)
+	"line": {
+		Text: func(b *buffer.Buffer) string {
+			return strconv.Itoa(b.GetActiveCursor().Y + 1)
+		},
+		Priority: 8,
 	},
-	"col": func(b *buffer.Buffer) string {
-		return strconv.Itoa(b.GetActiveCursor().X + 1)
+	"col": {
+		Text: func(b *buffer.Buffer) string {
+			return strconv.Itoa(b.GetActiveCursor().X + 1)
+		},
+		Priority: 8,
 	},
-	"modified": func(b *buffer.Buffer) string {
-		if b.Modified() {
-			return "+ "
-		}
-		if b.Type.Readonly {
-			return "[ro] "
-		}
-		return ""
+	"modified": {
+		Text: func(b *buffer.Buffer) string {
+			if b.Modified() {
+				return "+ "
+			}
+			if b.Type.Readonly {
+				return "[ro] "
+			}
+			return ""
+		},
+		Style:    styleGroup("statusline.modified"),
+		Priority: 9,
 	},
-	"overwrite": func(b *buffer.Buffer) string {
-		if b.OverwriteMode && !b.Type.Readonly {
-			return "[ovwr] "
-		}
-		return ""
+	"overwrite": {
+		Text: func(b *buffer.Buffer) string {
+			if b.OverwriteMode && !b.Type.Readonly {
+				return "[ovwr] "
+			}
+			return ""
+		},
+		Priority: 9,
+	},
+	"lines": {
+		Text: func(b *buffer.Buffer) string {
+			return strconv.Itoa(b.LinesNum())
+		},
+	},
+	"percentage": {
+		Text: func(b *buffer.Buffer) string {
+			return strconv.Itoa((b.GetActiveCursor().Y + 1) * 100 / b.LinesNum())
+		},
+	},
+	"encoding": {
+		Text: func(b *buffer.Buffer) string {
+			return b.Settings["encoding"].(string)
+		},
+		Click: "command-edit:set encoding ",
+	},
+	"lineendings": {
+		Text: func(b *buffer.Buffer) string {
+			if b.Settings["fileformat"] == "dos" {
+				return "CRLF"
+			}
+			return "LF"
+		},
+		Click: "command-edit:set fileformat ",
+	},
+	"indent": {
+		Text: func(b *buffer.Buffer) string {
+			size := fmt.Sprint(b.Settings["tabsize"])
+			if b.Settings["tabstospaces"].(bool) {
+				return "Spaces: " + size
+			}
+			return "Tabs: " + size
+		},
+		Click: "command-edit:set tabsize ",
+	},
+	"selection": {
+		Text:  selectionSize,
+		Style: styleGroup("statusline.selection"),
 	},
-	"lines": func(b *buffer.Buffer) string {
-		return strconv.Itoa(b.LinesNum())
+	"search": {
+		Text:  searchIndex,
+		Style: styleGroup("statusline.search"),
+		Click: "FindNext",
 	},
-	"percentage": func(b *buffer.Buffer) string {
-		return strconv.Itoa((b.GetActiveCursor().Y + 1) * 100 / b.LinesNum())
+	"column": {
+		Text: tableColumn,
+	},
+	"errors": {
+		Text: func(b *buffer.Buffer) string {
+			if n, _ := b.DiagnosticCounts(); n > 0 {
+				return fmt.Sprintf("E:%d ", n)
+			}
+			return ""
+		},
+		Style: styleGroup("statusline.error"),
+		Click: "NextDiagnostic",
+	},
+	"warnings": {
+		Text: func(b *buffer.Buffer) string {
+			if _, n := b.DiagnosticCounts(); n > 0 {
+				return fmt.Sprintf("W:%d ", n)
+			}
+			return ""
+		},
+		Style: styleGroup("statusline.warning"),
+		Click: "NextDiagnostic",
 	},
 }
 
-func SetStatusInfoFnLua(fn string) {
+// tableColumn returns the name and the index of the column of the cursor,
+// in table mode
+func tableColumn(b *buffer.Buffer) string {
+	t := b.Table()
+	if t == nil {
+		return ""
+	}
+	_, col, ok := t.FieldAt(b.GetActiveCursor().Loc)
+	if !ok {
+		return ""
+	}
+	if name := strings.ReplaceAll(t.ColumnName(col), "\n", " "); name != "" {
+		return fmt.Sprintf("[%s %d/%d] ", name, col+1, t.Columns())
+	}
+	return fmt.Sprintf("[%d/%d] ", col+1, t.Columns())
+}
+
+// selectionSize returns the number of lines and characters selected by all
+// the cursors
+func selectionSize(b *buffer.Buffer) string {
+	lines, chars := 0, 0
+	for _, c := range b.GetCursors() {
+		if !c.HasSelection() {
+			continue
+		}
+		start, end := c.CurSelection[0], c.CurSelection[1]
+		if start.GreaterThan(end) {
+			start, end = end, start
+		}
+		chars += util.CharacterCount(c.GetSelection())
+		lines += end.Y - start.Y + 1
+		if end.X == 0 && end.Y > start.Y {
+			// the selection ends before the last line
+			lines--
+		}
+	}
+	if chars == 0 {
+		return ""
+	}
+	if lines > 1 {
+		return fmt.Sprintf("[%d lines, %d chars] ", lines, chars)
+	}
+	return fmt.Sprintf("[%d chars] ", chars)
+}
+
+// searchIndex returns the index of the match of the last search at the
+// cursor and the number of matches
+func searchIndex(b *buffer.Buffer) string {
+	if b.LastSearch == "" {
+		return ""
+	}
+	c := b.GetActiveCursor()
+	loc := c.Loc
+	if c.HasSelection() {
+		loc = c.CurSelection[0]
+		if loc.GreaterThan(c.CurSelection[1]) {
+			loc = c.CurSelection[1]
+		}
+	}
+	index, total := b.SearchMatchIndex(loc)
+	return fmt.Sprintf("[%d/%d] ", index, total)
+}
+
+// RegisterStatusSegment adds a segment which can be used in the statusline
+// formats, replacing any segment with the same name
+func RegisterStatusSegment(name string, seg *StatusSegment) {
+	statusSegments[name] = seg
+}
+
+// luaStatusFn returns a function calling the given plugin function with a
+// buffer, or nil if the plugin doesn't exist
+func luaStatusFn(fn string) func(*buffer.Buffer) lua.LValue {
 	luaFn := strings.Split(fn, ".")
 	if len(luaFn) <= 1 {
-		return
+		return nil
 	}
 	plName, plFn := luaFn[0], luaFn[1]
 	pl := config.FindPlugin(plName)
 	if pl == nil {
-		return
+		return nil
 	}
-	statusInfo[fn] = func(b *buffer.Buffer) string {
-		if pl == nil || !pl.IsLoaded() {
-			return ""
+	return func(b *buffer.Buffer) lua.LValue {
+		if !pl.IsLoaded() {
+			return lua.LNil
 		}
 		val, err := pl.Call(plFn, luar.New(ulua.L, b))
-		if err == nil {
-			if v, ok := val.(lua.LString); !ok {
-				screen.TermMessage(plFn, "should return a string")
-				return ""
-			} else {
-				return string(v)
-			}
+		if err != nil {
+			return lua.LNil
 		}
+		return val
+	}
+}
+
+// luaStatusString returns a function calling the given plugin function,
+// which should return a string
+func luaStatusString(fn string) func(*buffer.Buffer) string {
+	call := luaStatusFn(fn)
+	if call == nil {
+		return nil
+	}
+	return func(b *buffer.Buffer) string {
+		val := call(b)
+		if val == lua.LNil {
+			return ""
+		}
+		if v, ok := val.(lua.LString); ok {
+			return string(v)
+		}
+		screen.TermMessage(fn, "should return a string")
 		return ""
 	}
 }
 
+func SetStatusInfoFnLua(fn string) {
+	if text := luaStatusString(fn); text != nil {
+		RegisterStatusSegment(fn, &StatusSegment{Text: text})
+	}
+}
+
+// SetStatusSegmentLua registers a statusline segment for a plugin. The
+// fields `text`, `visible` and `stylefn` of opts name plugin functions
+// which are called with the buffer, `style` is a colorscheme group,
+// `priority` a number and `click` an action. The text function defaults
+// to the name of the segment
+func SetStatusSegmentLua(name string, opts *lua.LTable) error {
+	seg := new(StatusSegment)
+	field := func(key string) string {
+		if opts == nil {
+			return ""
+		}
+		return lua.LVAsString(opts.RawGetString(key))
+	}
+
+	text := field("text")
+	if text == "" {
+		text = name
+	}
+	if seg.Text = luaStatusString(text); seg.Text == nil {
+		return errors.New("Status segment function " + text + " does not exist")
+	}
+	if fn := field("visible"); fn != "" {
+		call := luaStatusFn(fn)
+		if call == nil {
+			return errors.New("Status segment function " + fn + " does not exist")
+		}
+		seg.Visible = func(b *buffer.Buffer) bool {
+			return lua.LVAsBool(call(b))
+		}
+	}
+	if fn := field("stylefn"); fn != "" {
+		if seg.Style = luaStatusString(fn); seg.Style == nil {
+			return errors.New("Status segment function " + fn + " does not exist")
+		}
+	} else if group := field("style"); group != "" {
+		seg.Style = styleGroup(group)
+	}
+	if opts != nil {
+		seg.Priority = int(lua.LVAsNumber(opts.RawGetString("priority")))
+	}
+	seg.Click = field("click")
+
+	RegisterStatusSegment(name, seg)
+	return nil
+}
+
 // NewStatusLine returns a statusline bound to a window
 func NewStatusLine(win *BufWindow) *StatusLine {
 	s := new(StatusLine)
@@ -112,6 +375,8 @@ func (s *StatusLine) Display() {
 
 	winX := s.win.X
 
+	s.clicks = s.clicks[:0]
+
 	b := s.win.Buf
 	// autocomplete suggestions (for the buffer, not for the infowindow)
 	if b.HasSuggestions && len(b.Suggestions) > 1 {
@@ -148,32 +413,6 @@ func (s *StatusLine) Display() {
 		return
 	}
 
-	formatter := func(match []byte) []byte {
-		name := match[2 : len(match)-1]
-		if bytes.HasPrefix(name, []byte("opt")) {
-			option := name[4:]
-			return fmt.Append(nil, s.FindOpt(string(option)))
-		} else if bytes.HasPrefix(name, []byte("bind")) {
-			binding := string(name[5:])
-			for k, v := range config.Bindings["buffer"] {
-				if v == binding {
-					return []byte(k)
-				}
-			}
-			return []byte("null")
-		} else {
-			if fn, ok := statusInfo[string(name)]; ok {
-				return []byte(fn(s.win.Buf))
-			}
-			return []byte{}
-		}
-	}
-
-	leftText := []byte(s.win.Buf.Settings["statusformatl"].(string))
-	leftText = formatParser.ReplaceAllFunc(leftText, formatter)
-	rightText := []byte(s.win.Buf.Settings["statusformatr"].(string))
-	rightText = formatParser.ReplaceAllFunc(rightText, formatter)
-
 	statusLineStyle := config.DefStyle.Reverse(true)
 	if s.win.IsActive() {
 		if style, ok := config.Colorscheme["statusline"]; ok {
@@ -187,38 +426,155 @@ func (s *StatusLine) Display() {
 		}
 	}
 
-	leftLen := util.StringWidth(leftText, util.CharacterCount(leftText), 1)
-	rightLen := util.StringWidth(rightText, util.CharacterCount(rightText), 1)
+	left := s.spans(b.Settings["statusformatl"].(string), statusLineStyle)
+	right := s.spans(b.Settings["statusformatr"].(string), statusLineStyle)
+	fitStatus(s.win.Width, left, right)
 
 	for x := 0; x < s.win.Width; x++ {
-		if x < leftLen {
-			r, combc, size := util.DecodeCharacter(leftText)
-			leftText = leftText[size:]
-			rw := runewidth.RuneWidth(r)
-			for j := 0; j < rw; j++ {
-				c := r
-				if j > 0 {
-					c = ' '
-					combc = nil
-					x++
+		screen.SetContent(winX+x, y, ' ', nil, statusLineStyle)
+	}
+
+	// the left part is drawn last since it has precedence over the right part
+	x := s.win.Width - spansWidth(right)
+	for _, sp := range right {
+		x = s.drawSpan(x, y, sp)
+	}
+	x = 0
+	for _, sp := range left {
+		x = s.drawSpan(x, y, sp)
+	}
+}
+
+// spans splits a statusline format into its literal text and the text of
+// its directives
+func (s *StatusLine) spans(format string, style tcell.Style) []statusSpan {
+	b := s.win.Buf
+	var spans []statusSpan
+	add := func(text string, sp statusSpan) {
+		sp.text = []byte(text)
+		sp.width = util.StringWidth(sp.text, util.CharacterCount(sp.text), 1)
+		if sp.style == (tcell.Style{}) {
+			sp.style = style
+		}
+		spans = append(spans, sp)
+	}
+
+	last := 0
+	for _, m := range formatParser.FindAllStringIndex(format, -1) {
+		if m[0] > last {
+			add(format[last:m[0]], statusSpan{})
+		}
+		last = m[1]
+
+		name := format[m[0]+2 : m[1]-1]
+		if strings.HasPrefix(name, "opt") {
+			add(fmt.Sprint(s.FindOpt(name[4:])), statusSpan{segment: true})
+		} else if strings.HasPrefix(name, "bind") {
+			binding := "null"
+			for k, v := range config.Bindings["buffer"] {
+				if v == name[5:] {
+					binding = k
+					break
 				}
-				screen.SetContent(winX+x, y, c, combc, statusLineStyle)
-			}
-		} else if x >= s.win.Width-rightLen && x < rightLen+s.win.Width-rightLen {
-			r, combc, size := util.DecodeCharacter(rightText)
-			rightText = rightText[size:]
-			rw := runewidth.RuneWidth(r)
-			for j := 0; j < rw; j++ {
-				c := r
-				if j > 0 {
-					c = ' '
-					combc = nil
-					x++
+			}
+			add(binding, statusSpan{segment: true})
+		} else if seg, ok := statusSegments[name]; ok {
+			if seg.Visible != nil && !seg.Visible(b) {
+				continue
+			}
+			text := seg.Text(b)
+			if text == "" {
+				continue
+			}
+			sp := statusSpan{priority: seg.Priority, segment: true, click: seg.Click}
+			if seg.Style != nil && s.win.IsActive() {
+				if st, ok := config.Colorscheme[seg.Style(b)]; ok {
+					sp.style = st
 				}
-				screen.SetContent(winX+x, y, c, combc, statusLineStyle)
 			}
-		} else {
-			screen.SetContent(winX+x, y, ' ', nil, statusLineStyle)
+			add(text, sp)
+		}
+	}
+	if last < len(format) {
+		add(format[last:], statusSpan{})
+	}
+	return spans
+}
+
+// fitStatus hides segments, those with the lowest priority and the
+// rightmost first, until the statusline fits in the given width
+func fitStatus(width int, left, right []statusSpan) {
+	all := make([]*statusSpan, 0, len(left)+len(right))
+	for i := range left {
+		all = append(all, &left[i])
+	}
+	for i := range right {
+		all = append(all, &right[i])
+	}
+
+	total := spansWidth(left) + spansWidth(right)
+	for total > width {
+		var drop *statusSpan
+		for _, sp := range all {
+			if sp.segment && !sp.hidden && (drop == nil || sp.priority <= drop.priority) {
+				drop = sp
+			}
+		}
+		if drop == nil {
+			return
+		}
+		drop.hidden = true
+		total -= drop.width
+	}
+}
+
+func spansWidth(spans []statusSpan) int {
+	w := 0
+	for _, sp := range spans {
+		if !sp.hidden {
+			w += sp.width
+		}
+	}
+	return w
+}
+
+// drawSpan draws a span at the given column of the statusline and returns
+// the column following it
+func (s *StatusLine) drawSpan(x, y int, sp statusSpan) int {
+	if sp.hidden {
+		return x
+	}
+	start := x
+	text := sp.text
+	for len(text) > 0 && x < s.win.Width {
+		r, combc, size := util.DecodeCharacter(text)
+		text = text[size:]
+		rw := runewidth.RuneWidth(r)
+		if x >= 0 {
+			if x+rw > s.win.Width {
+				break
+			}
+			screen.SetContent(s.win.X+x, y, r, combc, sp.style)
+			for j := 1; j < rw; j++ {
+				screen.SetContent(s.win.X+x+j, y, ' ', nil, sp.style)
+			}
+		}
+		x += rw
+	}
+	if sp.click != "" {
+		s.clicks = append(s.clicks, statusClick{max(start, 0), x, sp.click})
+	}
+	return x
+}
+
+// ClickAction returns the action of the segment drawn at the given column
+// of the statusline, or an empty string if there is none
+func (s *StatusLine) ClickAction(x int) string {
+	for i := len(s.clicks) - 1; i >= 0; i-- {
+		c := s.clicks[i]
+		if x >= c.start && x < c.end {
+			return c.action
 		}
 	}
+	return ""
 }
@@ -0,0 +1,89 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commit is a commit of a repository
+type Commit struct {
+	Hash    string
+	Author  string
+	Time    time.Time
+	Summary string
+}
+
+// Short returns the abbreviated hash of the commit
+func (c *Commit) Short() string {
+	if len(c.Hash) > 8 {
+		return c.Hash[:8]
+	}
+	return c.Hash
+}
+
+// Uncommitted returns true for the pseudo commit of the lines which are not
+// committed yet
+func (c *Commit) Uncommitted() bool {
+	return strings.Trim(c.Hash, "0") == ""
+}
+
+// Blame returns the commit which last changed each line of the given
+// contents of a file. The lines which were changed since the last commit
+// belong to a commit for which Uncommitted returns true
+func (r *Repo) Blame(path string, contents []byte) ([]*Commit, error) {
+	rel, err := r.Rel(path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := run(r.Root, contents, "blame", "--porcelain", "--contents", "-", "--", rel)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(out), nil
+}
+
+// parseBlame parses the porcelain output of git blame. Each line of the file
+// is preceded by a header giving its commit, followed by the information
+// of the commit the first time it appears
+func parseBlame(out []byte) []*Commit {
+	commits := make(map[string]*Commit)
+	var lines []*Commit
+	var cur *Commit
+
+	s := bufio.NewScanner(bytes.NewReader(out))
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "\t") {
+			// the text of the line closes its entry
+			if cur != nil {
+				lines = append(lines, cur)
+			}
+			cur = nil
+			continue
+		}
+		if cur == nil {
+			hash, _, _ := strings.Cut(line, " ")
+			if cur = commits[hash]; cur == nil {
+				cur = &Commit{Hash: hash}
+				commits[hash] = cur
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "author":
+			cur.Author = value
+		case "author-time":
+			if t, err := strconv.ParseInt(value, 10, 64); err == nil {
+				cur.Time = time.Unix(t, 0)
+			}
+		case "summary":
+			cur.Summary = value
+		}
+	}
+	return lines
+}
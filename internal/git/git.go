@@ -0,0 +1,102 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotRepository is returned when a file is not in the work tree of a git
+// repository
+var ErrNotRepository = errors.New("Not in a git repository")
+
+// Repo is a git repository, whose commands are run at the top of its work
+// tree with the git executable
+type Repo struct {
+	// Root is the absolute path of the top of the work tree
+	Root string
+}
+
+// Open returns the repository whose work tree contains a file or directory
+func Open(path string) (*Repo, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	out, err := run(dir, nil, "rev-parse", "--show-toplevel")
+	if errors.Is(err, errGitFailed) {
+		return nil, ErrNotRepository
+	} else if err != nil {
+		return nil, err
+	}
+	return &Repo{Root: filepath.Clean(strings.TrimSpace(string(out)))}, nil
+}
+
+// Rel returns the path of a file relative to the top of the work tree, with
+// slashes, as git names it
+func (r *Repo) Rel(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	// the root given by git has the symbolic links resolved
+	if resolved, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		abs = filepath.Join(resolved, filepath.Base(abs))
+	}
+	rel, err := filepath.Rel(r.Root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrNotRepository
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Git runs a git command in the repository and returns its output
+func (r *Repo) Git(args ...string) ([]byte, error) {
+	return run(r.Root, nil, args...)
+}
+
+// errGitFailed is wrapped by the errors of the git commands which fail
+var errGitFailed = errors.New("git failed")
+
+// gitError is the error of a git command, with the message it printed
+type gitError struct {
+	msg string
+}
+
+func (e *gitError) Error() string {
+	return e.msg
+}
+
+func (e *gitError) Unwrap() error {
+	return errGitFailed
+}
+
+// run runs git in a directory with the given input, and returns its output.
+// The error of a failed command holds the first line git printed
+func run(dir string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "core.quotepath=off"}, args...)...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, err
+		}
+		msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, &gitError{msg: strings.TrimPrefix(msg, "fatal: ")}
+	}
+	return stdout.Bytes(), nil
+}
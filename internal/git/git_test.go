@@ -0,0 +1,137 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newRepo creates a repository in a temporary directory
+func newRepo(t *testing.T) (*Repo, func(args ...string)) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir,
+			"-c", "user.name=Jane Doe", "-c", "user.email=jane@example.com",
+			"-c", "commit.gpgsign=false"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatal(err, string(out))
+		}
+	}
+	git("init", "-q")
+
+	r, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, git
+}
+
+func write(t *testing.T, r *Repo, name, text string) string {
+	path := filepath.Join(r.Root, name)
+	if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpen(t *testing.T) {
+	r, _ := newRepo(t)
+	if err := os.Mkdir(filepath.Join(r.Root, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := write(t, r, "sub/a.txt", "a\n")
+
+	sub, err := Open(path)
+	assert.NoError(t, err)
+	assert.Equal(t, r.Root, sub.Root)
+	rel, err := sub.Rel(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "sub/a.txt", rel)
+
+	_, err = Open(t.TempDir())
+	assert.Equal(t, ErrNotRepository, err)
+}
+
+func TestBlameAndLog(t *testing.T) {
+	r, git := newRepo(t)
+	write(t, r, "old.txt", "one\ntwo\nfour\nfive\nsix\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "First")
+	git("mv", "old.txt", "new.txt")
+	path := write(t, r, "new.txt", "one\nthree\nfour\nfive\nsix\n")
+	git("commit", "-q", "-a", "-m", "Second")
+
+	lines, err := r.Blame(path, []byte("zero\none\nthree\n"))
+	assert.NoError(t, err)
+	if !assert.Len(t, lines, 3) {
+		return
+	}
+	assert.True(t, lines[0].Uncommitted())
+	assert.Equal(t, "First", lines[1].Summary)
+	assert.Equal(t, "Jane Doe", lines[1].Author)
+	assert.False(t, lines[1].Time.IsZero())
+	assert.Equal(t, "Second", lines[2].Summary)
+
+	revs, err := r.Log(path)
+	assert.NoError(t, err)
+	if !assert.Len(t, revs, 2) {
+		return
+	}
+	assert.Equal(t, "Second", revs[0].Summary)
+	assert.Equal(t, "new.txt", revs[0].Path)
+	assert.Equal(t, "old.txt", revs[1].Path)
+	assert.Equal(t, lines[1].Hash, revs[1].Hash)
+
+	text, err := r.Show(revs[1].Hash, revs[1].Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "one\ntwo\nfour\nfive\nsix\n", string(text))
+	_, err = r.Show(revs[1].Hash, "new.txt")
+	assert.Error(t, err)
+}
+
+func TestStatus(t *testing.T) {
+	r, git := newRepo(t)
+	write(t, r, "a.txt", "a\n")
+	write(t, r, "b.txt", "b\n")
+
+	// staging works before the first commit
+	assert.NoError(t, r.Stage("a.txt"))
+	files, err := r.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, []FileStatus{
+		{Path: "a.txt", Staged: 'A', Unstaged: ' '},
+		{Path: "b.txt", Staged: '?', Unstaged: '?'},
+	}, files)
+	assert.True(t, files[0].HasStaged())
+	assert.False(t, files[1].HasStaged())
+	assert.True(t, files[1].Untracked())
+	assert.NoError(t, r.Unstage("a.txt"))
+	files, err = r.Status()
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.True(t, files[0].Untracked())
+
+	git("add", ".")
+	git("commit", "-q", "-m", "First")
+	write(t, r, "a.txt", "aa\n")
+	git("mv", "b.txt", "c.txt")
+	files, err = r.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, []FileStatus{
+		{Path: "a.txt", Staged: ' ', Unstaged: 'M'},
+		{Path: "c.txt", Orig: "b.txt", Staged: 'R', Unstaged: ' '},
+	}, files)
+	assert.Equal(t, "R  b.txt -> c.txt", files[1].String())
+
+	assert.NoError(t, r.Stage("a.txt"))
+	files, err = r.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, byte('M'), files[0].Staged)
+	assert.False(t, files[0].HasUnstaged())
+}
@@ -0,0 +1,58 @@
+package git
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revision is a commit which changed a file, with the path of the file in
+// the commit, which differs from its current path if it was renamed
+type Revision struct {
+	Commit
+	Path string
+}
+
+// Log returns the commits which changed a file, newest first, following
+// its renames
+func (r *Repo) Log(path string) ([]Revision, error) {
+	rel, err := r.Rel(path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := r.Git("log", "--follow", "--name-only", "--format=%x1e%H%x1f%an%x1f%at%x1f%s", "--", rel)
+	if err != nil {
+		return nil, err
+	}
+
+	var revs []Revision
+	for _, rec := range strings.Split(string(out), "\x1e")[1:] {
+		fields := strings.SplitN(rec, "\x1f", 4)
+		if len(fields) < 4 {
+			continue
+		}
+		// the summary is followed by the names of the changed files
+		summary, names, _ := strings.Cut(fields[3], "\n")
+		rev := Revision{
+			Commit: Commit{Hash: fields[0], Author: fields[1], Summary: summary},
+			Path:   strings.TrimSpace(names),
+		}
+		if t, err := strconv.ParseInt(fields[2], 10, 64); err == nil {
+			rev.Time = time.Unix(t, 0)
+		}
+		if rev.Path == "" {
+			// merges list no file
+			rev.Path = rel
+			if len(revs) > 0 {
+				rev.Path = revs[len(revs)-1].Path
+			}
+		}
+		revs = append(revs, rev)
+	}
+	return revs, nil
+}
+
+// Show returns the contents of a file in a commit
+func (r *Repo) Show(hash, path string) ([]byte, error) {
+	return r.Git("show", hash+":"+path)
+}
@@ -0,0 +1,83 @@
+package git
+
+import (
+	"strings"
+)
+
+// FileStatus is the status of a changed file of the work tree
+type FileStatus struct {
+	// Path is relative to the top of the work tree
+	Path string
+	// Orig is the former path of a renamed or copied file
+	Orig string
+	// Staged is the status of the file in the index compared to HEAD, and
+	// Unstaged the status of the file in the work tree compared to the
+	// index, as letters of git status --short: M for modified, A for added,
+	// D for deleted, R for renamed, ? for untracked or a space if unchanged
+	Staged, Unstaged byte
+}
+
+// Untracked returns true if the file is not tracked by git
+func (s FileStatus) Untracked() bool {
+	return s.Staged == '?'
+}
+
+// HasStaged returns true if the file has changes in the index
+func (s FileStatus) HasStaged() bool {
+	return s.Staged != ' ' && !s.Untracked()
+}
+
+// HasUnstaged returns true if the file has changes which are not in the
+// index
+func (s FileStatus) HasUnstaged() bool {
+	return s.Unstaged != ' '
+}
+
+// String returns the status as a line of git status --short
+func (s FileStatus) String() string {
+	str := string([]byte{s.Staged, s.Unstaged, ' '})
+	if s.Orig != "" {
+		str += s.Orig + " -> "
+	}
+	return str + s.Path
+}
+
+// Status returns the changed and untracked files of the work tree
+func (r *Repo) Status() ([]FileStatus, error) {
+	out, err := r.Git("status", "--porcelain=v1", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FileStatus
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		if len(e) < 4 {
+			continue
+		}
+		s := FileStatus{Staged: e[0], Unstaged: e[1], Path: e[3:]}
+		if s.Staged == 'R' || s.Staged == 'C' || s.Unstaged == 'R' || s.Unstaged == 'C' {
+			// the former path follows in its own entry
+			if i+1 < len(entries) {
+				i++
+				s.Orig = entries[i]
+			}
+		}
+		files = append(files, s)
+	}
+	return files, nil
+}
+
+// Stage adds the changes of files to the index, including their deletion
+func (r *Repo) Stage(paths ...string) error {
+	_, err := r.Git(append([]string{"add", "-A", "--"}, paths...)...)
+	return err
+}
+
+// Unstage removes the changes of files from the index, keeping them in the
+// work tree
+func (r *Repo) Unstage(paths ...string) error {
+	_, err := r.Git(append([]string{"reset", "-q", "--"}, paths...)...)
+	return err
+}
@@ -17,6 +17,8 @@ type InfoBuf struct {
 	HasYN      bool
 
 	PromptType string
+	// Masked hides the response of a password prompt
+	Masked bool
 
 	Msg    string
 	YNResp bool
@@ -118,6 +120,13 @@ func (i *InfoBuf) Prompt(prompt string, msg string, ptype string, eventcb func(s
 	i.Buffer.Insert(i.Buffer.Start(), msg)
 }
 
+// PasswordPrompt prompts for a password, which is masked and not added to
+// the history
+func (i *InfoBuf) PasswordPrompt(prompt string, donecb func(string, bool)) {
+	i.Prompt(prompt, "", "Password", nil, donecb)
+	i.Masked = true
+}
+
 // YNPrompt creates a yes or no prompt, and the callback returns the yes/no result and whether
 // the prompt was canceled
 func (i *InfoBuf) YNPrompt(prompt string, donecb func(bool, bool)) {
@@ -136,6 +145,8 @@ func (i *InfoBuf) YNPrompt(prompt string, donecb func(bool, bool)) {
 // DonePrompt finishes the current prompt and indicates whether or not it was canceled
 func (i *InfoBuf) DonePrompt(canceled bool) {
 	hadYN := i.HasYN
+	masked := i.Masked
+	i.Masked = false
 	i.HasPrompt = false
 	i.HasYN = false
 	i.HasGutter = false
@@ -150,13 +161,17 @@ func (i *InfoBuf) DonePrompt(canceled bool) {
 				resp := string(i.LineBytes(0))
 				i.Replace(i.Start(), i.End(), "")
 				h := i.History[i.PromptType]
-				h[len(h)-1] = resp
-
-				// avoid duplicates
-				for j := len(h) - 2; j >= 0; j-- {
-					if h[j] == h[len(h)-1] {
-						i.History[i.PromptType] = append(h[:j], h[j+1:]...)
-						break
+				if masked {
+					i.History[i.PromptType] = h[:len(h)-1]
+				} else {
+					h[len(h)-1] = resp
+
+					// avoid duplicates
+					for j := len(h) - 2; j >= 0; j-- {
+						if h[j] == h[len(h)-1] {
+							i.History[i.PromptType] = append(h[:j], h[j+1:]...)
+							break
+						}
 					}
 				}
 
@@ -0,0 +1,254 @@
+// Package record reads and writes the recordings of the sessions of micro,
+// which are replayed to reproduce bugs. A recording is made of JSON objects,
+// one per line: the header with the terminal and the files opened, one line
+// per event, and the state of micro when the recording stopped.
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/screen"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// Version is the version of the format of the recordings
+const Version = 1
+
+// A Header describes the editor when the recording started
+type Header struct {
+	Version  int
+	Width    int
+	Height   int
+	Settings map[string]any // the global options
+	Bindings string         `json:",omitempty"` // the contents of bindings.json
+	Files    []File
+}
+
+// A File is a buffer open when the recording started. Path is empty if it
+// was not opened from a file, and Exists is false for a new file. The
+// contents of an encrypted buffer are not recorded
+type File struct {
+	Name      string
+	Path      string `json:",omitempty"`
+	Exists    bool   `json:",omitempty"`
+	Encrypted bool   `json:",omitempty"`
+	Contents  string
+	X, Y      int            // the cursor
+	Settings  map[string]any // the local options
+}
+
+// An Event is a terminal event, with the time since the previous event
+type Event struct {
+	Delay   time.Duration
+	Type    string           // key, mouse, resize, paste, raw or background
+	Key     tcell.Key        `json:",omitempty"`
+	Rune    rune             `json:",omitempty"`
+	Mod     tcell.ModMask    `json:",omitempty"`
+	Buttons tcell.ButtonMask `json:",omitempty"`
+	X       int              `json:",omitempty"`
+	Y       int              `json:",omitempty"`
+	Text    string           `json:",omitempty"` // the pasted text
+	Dark    bool             `json:",omitempty"`
+	Esc     string           `json:",omitempty"`
+}
+
+// A Buffer is a buffer open when the recording stopped. The contents of an
+// encrypted buffer are not recorded
+type Buffer struct {
+	Name      string
+	Encrypted bool `json:",omitempty"`
+	Contents  string
+}
+
+// A State is the state of the editor after the first Events events, which
+// are the ones to replay. Screen is nil if an encrypted buffer was open
+type State struct {
+	Events  int
+	Buffers []Buffer
+	Screen  []string
+}
+
+// A Recording is a recording read by Read. State is nil if the recording
+// did not stop, when micro crashed
+type Recording struct {
+	Header
+	Events []Event
+	State  *State
+}
+
+// line is a line of a recording, where one of the fields is set
+type line struct {
+	Header *Header `json:",omitempty"`
+	Event  *Event  `json:",omitempty"`
+	State  *State  `json:",omitempty"`
+}
+
+// NewEvent returns the event to record for a terminal event, or nil if the
+// event is not recorded
+func NewEvent(e tcell.Event) *Event {
+	switch e := e.(type) {
+	case *tcell.EventKey:
+		return &Event{Type: "key", Key: e.Key(), Rune: e.Rune(), Mod: e.Modifiers(), Esc: e.EscSeq()}
+	case *tcell.EventMouse:
+		x, y := e.Position()
+		return &Event{Type: "mouse", X: x, Y: y, Buttons: e.Buttons(), Mod: e.Modifiers(), Esc: e.EscSeq()}
+	case *tcell.EventResize:
+		w, h := e.Size()
+		return &Event{Type: "resize", X: w, Y: h}
+	case *tcell.EventPaste:
+		return &Event{Type: "paste", Text: e.Text(), Esc: e.EscSeq()}
+	case *tcell.EventRaw:
+		return &Event{Type: "raw", Esc: e.EscSeq()}
+	case *screen.EventBackground:
+		return &Event{Type: "background", Dark: e.Dark}
+	}
+	return nil
+}
+
+// TcellEvent returns the terminal event of a recorded event. A resize
+// event has the size of the terminal in X and Y
+func (e *Event) TcellEvent() (tcell.Event, error) {
+	switch e.Type {
+	case "key":
+		return tcell.NewEventKey(e.Key, e.Rune, e.Mod, e.Esc), nil
+	case "mouse":
+		return tcell.NewEventMouse(e.X, e.Y, e.Buttons, e.Mod, e.Esc), nil
+	case "resize":
+		return tcell.NewEventResize(e.X, e.Y), nil
+	case "paste":
+		return tcell.NewEventPaste(e.Text, e.Esc), nil
+	case "raw":
+		return tcell.NewEventRaw(e.Esc), nil
+	case "background":
+		return &screen.EventBackground{Dark: e.Dark}, nil
+	}
+	return nil, errors.New("Unknown event type " + e.Type)
+}
+
+// A Recorder writes a recording to a file. Each line is written at once so
+// that the recording is kept when micro crashes
+type Recorder struct {
+	lock   sync.Mutex
+	f      *os.File
+	enc    *json.Encoder
+	last   time.Time
+	events int
+}
+
+// Create starts a recording in the file at path with its header
+func Create(path string, h Header) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{f: f, enc: json.NewEncoder(f), last: time.Now()}
+	h.Version = Version
+	if err := r.enc.Encode(line{Header: &h}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Event records a terminal event, if it is recorded
+func (r *Recorder) Event(e tcell.Event) {
+	ev := NewEvent(e)
+	if ev == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := time.Now()
+	ev.Delay = now.Sub(r.last).Round(time.Millisecond)
+	r.last = now
+	r.events++
+	r.enc.Encode(line{Event: ev})
+}
+
+// Events returns the number of events recorded
+func (r *Recorder) Events() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.events
+}
+
+// Close stops the recording with the state of the editor
+func (r *Recorder) Close(s State) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err := r.enc.Encode(line{State: &s}); err != nil {
+		r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+// Read reads the recording in the file at path
+func Read(path string) (*Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rec := new(Recording)
+	sc := bufio.NewScanner(f)
+	// the lines of the header and of the state have whole files
+	sc.Buffer(nil, 1<<30)
+	for n := 1; sc.Scan(); n++ {
+		var l line
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		switch {
+		case l.Header != nil:
+			rec.Header = *l.Header
+		case l.Event != nil:
+			rec.Events = append(rec.Events, *l.Event)
+		case l.State != nil:
+			rec.State = l.State
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if rec.Version != Version {
+		return nil, fmt.Errorf("%s: unsupported recording version %d", path, rec.Version)
+	}
+	return rec, nil
+}
+
+// Compare returns the differences between the state of a recording and the
+// state after replaying it
+func Compare(want, got *State) []string {
+	var diffs []string
+	if len(got.Buffers) != len(want.Buffers) {
+		diffs = append(diffs, fmt.Sprintf("%d buffers are open instead of %d", len(got.Buffers), len(want.Buffers)))
+	}
+	for i := 0; i < len(want.Buffers) && i < len(got.Buffers); i++ {
+		w, g := want.Buffers[i], got.Buffers[i]
+		if g.Name != w.Name {
+			diffs = append(diffs, fmt.Sprintf("buffer %d is %s instead of %s", i+1, g.Name, w.Name))
+		} else if g.Contents != w.Contents && !w.Encrypted {
+			diffs = append(diffs, fmt.Sprintf("buffer %s has different contents:\n%q\ninstead of\n%q", w.Name, g.Contents, w.Contents))
+		}
+	}
+	if want.Screen == nil {
+		return diffs
+	}
+	if len(got.Screen) != len(want.Screen) {
+		diffs = append(diffs, fmt.Sprintf("the screen has %d lines instead of %d", len(got.Screen), len(want.Screen)))
+	}
+	for i := 0; i < len(want.Screen) && i < len(got.Screen); i++ {
+		if got.Screen[i] != want.Screen[i] {
+			diffs = append(diffs, fmt.Sprintf("screen line %d is\n%q\ninstead of\n%q", i+1, got.Screen[i], want.Screen[i]))
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,60 @@
+package record
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/helmutkemper/micro/v2/internal/screen"
+	"github.com/micro-editor/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.rec")
+	header := Header{Width: 80, Height: 24, Files: []File{{Name: "a.go", Path: "a.go", Exists: true, Contents: "package a\n", Y: 1}}}
+	r, err := Create(path, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []tcell.Event{
+		tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModAlt, ""),
+		tcell.NewEventMouse(3, 4, tcell.Button1, tcell.ModNone, ""),
+		tcell.NewEventResize(100, 30),
+		tcell.NewEventPaste("pasted\ntext", ""),
+		&screen.EventBackground{Dark: true},
+		tcell.NewEventError(io.EOF),
+	}
+	for _, e := range events {
+		r.Event(e)
+	}
+	// the error is not recorded
+	assert.Equal(t, 5, r.Events())
+	state := State{Events: 4, Buffers: []Buffer{{Name: "a.go", Contents: "package b\n"}}, Screen: []string{"1 package b", ""}}
+	assert.NoError(t, r.Close(state))
+
+	rec, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.Version = Version
+	assert.Equal(t, header, rec.Header)
+	assert.Equal(t, &state, rec.State)
+	if assert.Len(t, rec.Events, 5) {
+		for i, ev := range rec.Events {
+			e, err := ev.TcellEvent()
+			assert.NoError(t, err)
+			assert.IsType(t, events[i], e)
+			ev.Delay = 0
+			assert.Equal(t, NewEvent(events[i]), &ev)
+		}
+	}
+
+	got := State{Events: 4, Buffers: []Buffer{{Name: "a.go", Contents: "package a\n"}}, Screen: []string{"1 package a", ""}}
+	assert.Len(t, Compare(&state, &got), 2)
+	assert.Empty(t, Compare(&state, &state))
+
+	// the contents of the encrypted buffers and the screen are not compared
+	state = State{Buffers: []Buffer{{Name: "a.go", Encrypted: true}}}
+	assert.Empty(t, Compare(&state, &got))
+}
@@ -0,0 +1,194 @@
+// Package remote lets other programs control a running micro through a Unix
+// socket, to open files in it or run commands. The requests and responses
+// are JSON objects, one per line.
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// ErrNotRunning is returned when no micro listens on the socket
+var ErrNotRunning = errors.New("No running micro listens on the socket")
+
+// A File is a file to open, with the location of the cursor starting at 1.
+// The cursor is not moved if Line is 0
+type File struct {
+	Path string
+	Line int `json:",omitempty"`
+	Col  int `json:",omitempty"`
+}
+
+// A Request asks a running micro to open files, in new tabs or in splits,
+// and to run commands. With Wait, a second response is sent once all the
+// opened buffers are closed
+type Request struct {
+	Files    []File   `json:",omitempty"`
+	Split    string   `json:",omitempty"` // "tab" (the default), "hsplit" or "vsplit"
+	Commands []string `json:",omitempty"`
+	Wait     bool     `json:",omitempty"`
+}
+
+// A Response answers a request, with Closed set in the second response of a
+// request with Wait
+type Response struct {
+	Error  string `json:",omitempty"`
+	Closed bool   `json:",omitempty"`
+}
+
+// A Call is a request received by the server, to be handled on the main
+// goroutine, which answers it with Reply and, if it waits, Done
+type Call struct {
+	Request
+	replies chan Response
+}
+
+// Reply answers the call with the error of the request, if any. A call which
+// waits and succeeded must also be answered with Done
+func (c *Call) Reply(err error) {
+	r := Response{}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	c.replies <- r
+}
+
+// Done tells the client that the buffers opened by the call were closed
+func (c *Call) Done() {
+	c.replies <- Response{Closed: true}
+}
+
+// Calls receives the calls of the clients while the server listens
+var Calls chan *Call
+
+var (
+	listener net.Listener
+	lock     sync.Mutex
+)
+
+// SocketPath returns the path of the socket of the user, in the runtime
+// directory of the user if there is one, or else in a directory of the user
+// in the temporary directory
+func SocketPath() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = tempDir()
+	}
+	return filepath.Join(dir, "micro.sock")
+}
+
+// tempDir returns the directory of the user in the temporary directory
+func tempDir() string {
+	return filepath.Join(os.TempDir(), "micro-"+strconv.Itoa(os.Getuid()))
+}
+
+// Listen starts listening on the socket at path, unless another micro
+// already listens on it, and sends the calls of the clients on Calls
+func Listen(path string) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return errors.New("Another micro listens on " + path)
+	}
+	// only the user can access the socket. The directory of the user in
+	// the temporary directory could have been created by another user
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if filepath.Dir(path) == tempDir() {
+		if err := checkDir(tempDir()); err != nil {
+			return err
+		}
+	}
+	os.Remove(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return err
+	}
+
+	lock.Lock()
+	listener = l
+	lock.Unlock()
+	Calls = make(chan *Call)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+	return nil
+}
+
+// Close stops listening and removes the socket
+func Close() {
+	lock.Lock()
+	defer lock.Unlock()
+	if listener != nil {
+		listener.Close()
+		listener = nil
+	}
+}
+
+// serve reads the request of a client and writes the responses of the main
+// goroutine
+func serve(conn net.Conn) {
+	defer conn.Close()
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(Response{Error: err.Error()})
+		return
+	}
+	// the main goroutine never waits for the client
+	c := &Call{Request: req, replies: make(chan Response, 2)}
+	Calls <- c
+
+	enc := json.NewEncoder(conn)
+	r := <-c.replies
+	if enc.Encode(r) != nil || r.Error != "" || !req.Wait {
+		return
+	}
+	enc.Encode(<-c.replies)
+}
+
+// Send sends a request to the micro listening on the socket at path, and
+// returns the error of the request. With Wait, it returns once the opened
+// buffers are closed, or micro exits
+func Send(path string, req Request) error {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return ErrNotRunning
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	dec := json.NewDecoder(conn)
+	var r Response
+	if err := dec.Decode(&r); err != nil {
+		return err
+	}
+	if r.Error != "" {
+		return errors.New(r.Error)
+	}
+	if !req.Wait {
+		return nil
+	}
+	if err := dec.Decode(&r); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build plan9 || nacl || windows
+
+package remote
+
+// checkDir does nothing, since the directory of the socket has no Unix
+// permissions
+func checkDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build linux || darwin || dragonfly || solaris || openbsd || netbsd || freebsd
+
+package remote
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// checkDir checks that only the user can access the directory of the
+// socket, which is not a symbolic link
+func checkDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || !ok || int(st.Uid) != os.Getuid() || fi.Mode().Perm() != 0700 {
+		return errors.New(dir + " must be a directory of the user with mode 0700")
+	}
+	return nil
+}
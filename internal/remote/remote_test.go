@@ -0,0 +1,86 @@
+package remote
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemote(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "micro.sock")
+	assert.Equal(t, ErrNotRunning, Send(path, Request{}))
+
+	if err := Listen(path); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+	assert.Error(t, Listen(path))
+	if fi, err := os.Stat(path); assert.NoError(t, err) && runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	}
+
+	done := make(chan error)
+	send := func(req Request) *Call {
+		go func() {
+			done <- Send(path, req)
+		}()
+		return <-Calls
+	}
+
+	req := Request{
+		Files:    []File{{Path: "/a", Line: 12, Col: 4}, {Path: "/b"}},
+		Split:    "vsplit",
+		Commands: []string{"set tabsize 2"},
+	}
+	c := send(req)
+	assert.Equal(t, req, c.Request)
+	c.Reply(nil)
+	assert.NoError(t, <-done)
+
+	c = send(Request{Commands: []string{"bad"}})
+	c.Reply(errors.New("Unknown command bad"))
+	assert.EqualError(t, <-done, "Unknown command bad")
+
+	c = send(Request{Files: []File{{Path: "/a"}}, Wait: true})
+	c.Reply(nil)
+	select {
+	case <-done:
+		t.Fatal("Send returned before the files were closed")
+	default:
+	}
+	c.Done()
+	assert.NoError(t, <-done)
+}
+
+func TestSocketDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the directories have no Unix permissions")
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("TMPDIR", t.TempDir())
+	path := SocketPath()
+	dir := filepath.Dir(path)
+
+	// a directory which other users can access
+	assert.NoError(t, os.Mkdir(dir, 0755))
+	assert.Error(t, Listen(path))
+
+	// a link to a directory of the user
+	private := filepath.Join(os.TempDir(), "private")
+	assert.NoError(t, os.Mkdir(private, 0700))
+	assert.NoError(t, os.Remove(dir))
+	assert.NoError(t, os.Symlink(private, dir))
+	assert.Error(t, Listen(path))
+
+	// a new directory is created for the user
+	assert.NoError(t, os.Remove(dir))
+	assert.NoError(t, Listen(path))
+	Close()
+	if fi, err := os.Lstat(dir); assert.NoError(t, err) {
+		assert.Equal(t, os.ModeDir|0700, fi.Mode())
+	}
+}
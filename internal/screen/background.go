@@ -0,0 +1,224 @@
+package screen
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/micro-editor/tcell/v2"
+)
+
+// The terminal background color is queried with OSC 11, to which terminals
+// reply with the color as rgb:RRRR/GGGG/BBBB. Since tcell doesn't know
+// these replies, the start of the reply is registered as a raw sequence and
+// the rest arrives as key events, which FilterEvent collects. The
+// background is queried again when the terminal gains focus, since the
+// user may have switched the terminal profile in the meantime.
+const (
+	bgQuery  = "\x1b]11;?\x07"
+	focusOn  = "\x1b[?1004h"
+	focusOff = "\x1b[?1004l"
+	focusIn  = "\x1b[I"
+	focusOut = "\x1b[O"
+
+	// replies arriving later than this after the query are ignored, and
+	// the colorscheme option is used until a reply arrives
+	bgTimeout = time.Second
+)
+
+var bgReplies = []string{"\x1b]11;rgb:", "\x1b]11;rgba:"}
+
+var bg struct {
+	sync.Mutex
+	// terminal is true if the screen is a real terminal
+	terminal bool
+	// enabled is true if the background is detected
+	enabled bool
+	// sent is when the pending query was sent, zero if there is none
+	sent time.Time
+	// capturing is true while the color of a reply is being received
+	capturing bool
+	reply     strings.Builder
+}
+
+// EventBackground is sent by FilterEvent when the terminal reports its
+// background color
+type EventBackground struct {
+	t    time.Time
+	Dark bool
+}
+
+// When returns the time the background was reported
+func (e *EventBackground) When() time.Time {
+	return e.t
+}
+
+// EscSeq returns an empty string since the event has no escape sequence
+func (e *EventBackground) EscSeq() string {
+	return ""
+}
+
+// detectBackground returns true if the background should be detected,
+// which is only needed if a colorscheme depends on it
+func detectBackground() bool {
+	return config.GetGlobalOption("colorscheme.light").(string) != "" ||
+		config.GetGlobalOption("colorscheme.dark").(string) != ""
+}
+
+// writeTerm writes an escape sequence directly to the terminal, since
+// tcell has no way to send arbitrary sequences
+func writeTerm(seq string) bool {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+	_, err = tty.WriteString(seq)
+	return err == nil
+}
+
+// startBackgroundDetection enables focus reporting and queries the
+// background color of the terminal, if a colorscheme depends on it
+func startBackgroundDetection() {
+	bg.Lock()
+	defer bg.Unlock()
+
+	bg.terminal = true
+	bg.enabled = detectBackground()
+	if !bg.enabled {
+		return
+	}
+	for _, r := range bgReplies {
+		Screen.RegisterRawSeq(r)
+	}
+	Screen.RegisterRawSeq(focusIn)
+	Screen.RegisterRawSeq(focusOut)
+	writeTerm(focusOn)
+	queryBackground()
+}
+
+// RestartBackgroundDetection starts or stops detecting the background after
+// the colorscheme.light or colorscheme.dark option changed
+func RestartBackgroundDetection() {
+	bg.Lock()
+	terminal := bg.terminal
+	bg.Unlock()
+	if terminal {
+		stopBackgroundDetection()
+		startBackgroundDetection()
+	}
+}
+
+// stopBackgroundDetection disables focus reporting
+func stopBackgroundDetection() {
+	bg.Lock()
+	defer bg.Unlock()
+
+	if bg.enabled {
+		writeTerm(focusOff)
+		bg.enabled = false
+	}
+}
+
+// queryBackground sends a background query. bg must be locked
+func queryBackground() {
+	if writeTerm(bgQuery) {
+		bg.sent = time.Now()
+	}
+}
+
+// FilterEvent handles the focus events and the replies to background
+// queries. It returns nil if the event was consumed, an EventBackground
+// once a reply is complete, and the event itself otherwise
+func FilterEvent(event tcell.Event) tcell.Event {
+	bg.Lock()
+	defer bg.Unlock()
+
+	if !bg.enabled {
+		return event
+	}
+
+	switch e := event.(type) {
+	case *tcell.EventRaw:
+		seq := e.EscSeq()
+		switch seq {
+		case focusIn:
+			queryBackground()
+			return nil
+		case focusOut:
+			return nil
+		}
+		for _, r := range bgReplies {
+			if seq == r {
+				bg.capturing = true
+				bg.reply.Reset()
+				return nil
+			}
+		}
+	case *tcell.EventPaste:
+		if bg.capturing && isColorSpec(e.Text()) {
+			bg.reply.WriteString(e.Text())
+			return nil
+		}
+	case *tcell.EventKey:
+		if !bg.capturing {
+			return event
+		}
+		if e.Key() == tcell.KeyRune && e.Modifiers() == tcell.ModNone && isColorSpec(string(e.Rune())) {
+			bg.reply.WriteRune(e.Rune())
+			return nil
+		}
+
+		// the reply ends with BEL or ST (ESC \)
+		bg.capturing = false
+		end := e.Key() == tcell.KeyCtrlG || e.Key() == tcell.KeyEsc ||
+			e.Key() == tcell.KeyRune && e.Rune() == '\\' && e.Modifiers() == tcell.ModAlt
+		if !end {
+			return event
+		}
+		if bg.sent.IsZero() || time.Since(bg.sent) > bgTimeout {
+			return nil
+		}
+		bg.sent = time.Time{}
+		if dark, ok := parseBackground(bg.reply.String()); ok {
+			return &EventBackground{time.Now(), dark}
+		}
+		return nil
+	}
+	return event
+}
+
+func isColorSpec(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF/", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseBackground parses the color of a reply, made of 3 or 4 components
+// of 1 to 4 hex digits, and returns whether it is dark
+func parseBackground(spec string) (bool, bool) {
+	parts := strings.Split(spec, "/")
+	if len(parts) != 3 && len(parts) != 4 {
+		return false, false
+	}
+	var rgb [3]float64
+	for i := range rgb {
+		p := parts[i]
+		if len(p) < 1 || len(p) > 4 {
+			return false, false
+		}
+		v, err := strconv.ParseUint(p, 16, 16)
+		if err != nil {
+			return false, false
+		}
+		rgb[i] = float64(v) / float64(uint64(1)<<(4*len(p))-1)
+	}
+	luma := 0.299*rgb[0] + 0.587*rgb[1] + 0.114*rgb[2]
+	return luma < 0.5, true
+}
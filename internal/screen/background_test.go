@@ -0,0 +1,66 @@
+package screen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/micro-editor/tcell/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBackground(t *testing.T) {
+	dark, ok := parseBackground("0000/0000/0000")
+	assert.True(t, ok)
+	assert.True(t, dark)
+
+	dark, ok = parseBackground("fdfd/f6f6/e3e3")
+	assert.True(t, ok)
+	assert.False(t, dark)
+
+	dark, ok = parseBackground("2/3/3/f")
+	assert.True(t, ok)
+	assert.True(t, dark)
+
+	_, ok = parseBackground("ffff/ffff")
+	assert.False(t, ok)
+	_, ok = parseBackground("fffff/0/0")
+	assert.False(t, ok)
+}
+
+func feed(seq string, end tcell.Key) []tcell.Event {
+	events := []tcell.Event{tcell.NewEventRaw(bgReplies[0])}
+	for _, r := range seq {
+		events = append(events, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone, ""))
+	}
+	events = append(events, tcell.NewEventKey(end, 0, tcell.ModNone, ""))
+
+	var out []tcell.Event
+	for _, e := range events {
+		if e = FilterEvent(e); e != nil {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestFilterEvent(t *testing.T) {
+	bg.enabled = true
+	defer func() { bg.enabled = false }()
+
+	bg.sent = time.Now()
+	out := feed("ffff/ffff/ffff", tcell.KeyCtrlG)
+	assert.Len(t, out, 1)
+	if e, ok := out[0].(*EventBackground); assert.True(t, ok) {
+		assert.False(t, e.Dark)
+	}
+
+	// replies without a pending query are dropped
+	assert.Empty(t, feed("0000/0000/0000", tcell.KeyCtrlG))
+
+	bg.sent = time.Now().Add(-2 * bgTimeout)
+	assert.Empty(t, feed("0000/0000/0000", tcell.KeyEsc))
+
+	key := tcell.NewEventKey(tcell.KeyRune, 'a', tcell.ModNone, "")
+	assert.Equal(t, key, FilterEvent(key))
+	assert.Nil(t, FilterEvent(tcell.NewEventRaw(focusOut)))
+}
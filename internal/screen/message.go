@@ -6,6 +6,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"golang.org/x/term"
 )
 
 // TermMessage sends a message to the user in the terminal. This usually occurs before
@@ -58,6 +60,18 @@ func TermPrompt(prompt string, options []string, wait bool) int {
 	return idx
 }
 
+// TermPassword asks the user for a password in the terminal, without
+// echoing it
+func TermPassword(prompt string) (string, error) {
+	screenb := TempFini()
+	defer TempStart(screenb)
+
+	fmt.Print(prompt)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	return string(password), err
+}
+
 // TermError sends an error to the user in the terminal. Like TermMessage except formatted
 // as an error
 func TermError(filename string, lineNum int, err string) {
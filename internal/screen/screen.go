@@ -155,12 +155,18 @@ func UnregisterRawSeq(r string) {
 	}
 }
 
+// Fini shuts the screen down and restores the terminal
+func Fini() {
+	stopBackgroundDetection()
+	Screen.Fini()
+}
+
 // TempFini shuts the screen down temporarily
 func TempFini() bool {
 	screenWasNil := Screen == nil
 
 	if !screenWasNil {
-		Screen.Fini()
+		Fini()
 		Lock()
 		Screen = nil
 	}
@@ -220,6 +226,7 @@ func Init() error {
 	if err = Screen.Init(); err != nil {
 		return err
 	}
+	config.TermColors = Screen.Colors()
 
 	Screen.SetPaste(config.GetGlobalOption("paste").(bool))
 
@@ -236,6 +243,8 @@ func Init() error {
 		Screen.RegisterRawSeq(r)
 	}
 
+	startBackgroundDetection()
+
 	return nil
 }
 
@@ -0,0 +1,371 @@
+package spell
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/transform"
+)
+
+// A flag marks the words of a dictionary which can take an affix, or which
+// have a special meaning
+type flag uint32
+
+type flags []flag
+
+func (fs flags) has(f flag) bool {
+	if f == 0 {
+		return false
+	}
+	for _, x := range fs {
+		if x == f {
+			return true
+		}
+	}
+	return false
+}
+
+// An affix is a prefix or suffix rule of the affix file
+type affix struct {
+	flag   flag
+	prefix bool
+	// cross is true if the affix can be combined with an affix of the
+	// other kind
+	cross bool
+	// strip is removed from the root and add is added in its place
+	strip string
+	add   string
+	// cond matches the roots the affix applies to, nil for all roots
+	cond *regexp.Regexp
+	// cont are the flags of the affixes which can follow this one
+	cont flags
+}
+
+// matches returns true if the affix applies to the given root
+func (a *affix) matches(root string) bool {
+	return a.cond == nil || a.cond.MatchString(root)
+}
+
+// affixes holds the rules of an affix file
+type affixes struct {
+	flagType string
+	// aliases are the flags of the AF directive, which the words of the
+	// dictionary can refer to by number
+	aliases []flags
+
+	// prefixes and suffixes are indexed by the text they add
+	prefixes map[string][]*affix
+	suffixes map[string][]*affix
+
+	try string
+	key []string
+	rep [][2]string
+
+	forbidden      flag
+	needAffix      flag
+	noSuggest      flag
+	onlyInCompound flag
+	keepCase       flag
+}
+
+// parseFlags parses the flags of a word or of an affix rule
+func (a *affixes) parseFlags(s string) (flags, error) {
+	if len(a.aliases) > 0 {
+		if n, err := strconv.Atoi(s); err == nil {
+			if n < 1 || n > len(a.aliases) {
+				return nil, fmt.Errorf("invalid flag alias %d", n)
+			}
+			return a.aliases[n-1], nil
+		}
+	}
+
+	var fs flags
+	switch a.flagType {
+	case "long":
+		runes := []rune(s)
+		if len(runes)%2 != 0 {
+			return nil, fmt.Errorf("invalid long flags %q", s)
+		}
+		for i := 0; i < len(runes); i += 2 {
+			fs = append(fs, flag(runes[i])<<16|flag(runes[i+1]))
+		}
+	case "num":
+		for _, n := range strings.Split(s, ",") {
+			f, err := strconv.Atoi(n)
+			if err != nil || f <= 0 {
+				return nil, fmt.Errorf("invalid numeric flag %q", n)
+			}
+			fs = append(fs, flag(f))
+		}
+	default:
+		for _, r := range s {
+			fs = append(fs, flag(r))
+		}
+	}
+	return fs, nil
+}
+
+// parseFlag parses the flag of a directive such as FORBIDDENWORD
+func (a *affixes) parseFlag(s string) (flag, error) {
+	fs, err := a.parseFlags(s)
+	if err != nil {
+		return 0, err
+	}
+	if len(fs) != 1 {
+		return 0, fmt.Errorf("invalid flag %q", s)
+	}
+	return fs[0], nil
+}
+
+// condition converts the condition of an affix rule, a simplified regular
+// expression with character classes, to a regular expression matching the
+// start of the root for a prefix or its end for a suffix
+func condition(cond string, prefix bool) (*regexp.Regexp, error) {
+	if cond == "." {
+		return nil, nil
+	}
+	var expr strings.Builder
+	inClass := false
+	for _, r := range cond {
+		switch {
+		case r == '[' && !inClass:
+			inClass = true
+			expr.WriteRune(r)
+		case r == ']' && inClass:
+			inClass = false
+			expr.WriteRune(r)
+		case r == '^' && inClass, r == '.' && !inClass:
+			expr.WriteRune(r)
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	if prefix {
+		return regexp.Compile("^(?:" + expr.String() + ")")
+	}
+	return regexp.Compile("(?:" + expr.String() + ")$")
+}
+
+// encoding returns the character set of the SET directive of an affix file
+func encoding(aff []byte) string {
+	for _, line := range bytes.Split(aff, []byte("\n")) {
+		fields := strings.Fields(string(line))
+		if len(fields) >= 2 && fields[0] == "SET" {
+			return fields[1]
+		}
+	}
+	return "UTF-8"
+}
+
+// decode converts the text of a dictionary file to UTF-8
+func decode(data []byte, charset string) ([]byte, error) {
+	if strings.EqualFold(charset, "UTF-8") {
+		return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unknown character set %s", charset)
+	}
+	r := transform.NewReader(bytes.NewReader(data), enc.NewDecoder())
+	return io.ReadAll(r)
+}
+
+// parseAffixes parses an affix file
+func parseAffixes(data []byte) (*affixes, error) {
+	a := &affixes{
+		prefixes: make(map[string][]*affix),
+		suffixes: make(map[string][]*affix),
+	}
+
+	// the number of lines left in the current table
+	var table string
+	var left int
+	var cross bool
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("line %d: %s", n, fmt.Sprintf(format, args...))
+		}
+
+		if left > 0 && fields[0] == table {
+			left--
+			if err := a.parseTableLine(fields, cross); err != nil {
+				return nil, fail("%v", err)
+			}
+			continue
+		}
+		left = 0
+
+		var err error
+		switch fields[0] {
+		case "FLAG":
+			if len(fields) > 1 {
+				a.flagType = fields[1]
+			}
+		case "TRY":
+			if len(fields) > 1 {
+				a.try = fields[1]
+			}
+		case "KEY":
+			if len(fields) > 1 {
+				a.key = strings.Split(fields[1], "|")
+			}
+		case "FORBIDDENWORD":
+			a.forbidden, err = a.directiveFlag(fields)
+		case "NEEDAFFIX", "PSEUDOROOT":
+			a.needAffix, err = a.directiveFlag(fields)
+		case "NOSUGGEST":
+			a.noSuggest, err = a.directiveFlag(fields)
+		case "ONLYINCOMPOUND":
+			a.onlyInCompound, err = a.directiveFlag(fields)
+		case "KEEPCASE":
+			a.keepCase, err = a.directiveFlag(fields)
+		case "AF", "REP":
+			// the first line holds the number of lines of the table
+			if len(fields) > 1 {
+				if count, e := strconv.Atoi(fields[1]); e == nil {
+					table, left = fields[0], count
+				}
+			}
+		case "PFX", "SFX":
+			if len(fields) < 4 {
+				return nil, fail("invalid %s header", fields[0])
+			}
+			count, e := strconv.Atoi(fields[3])
+			if e != nil {
+				return nil, fail("invalid %s header", fields[0])
+			}
+			table, left, cross = fields[0], count, fields[2] == "Y"
+		}
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+	}
+	return a, scanner.Err()
+}
+
+// directiveFlag parses the flag of a directive line
+func (a *affixes) directiveFlag(fields []string) (flag, error) {
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("missing flag for %s", fields[0])
+	}
+	return a.parseFlag(fields[1])
+}
+
+// parseTableLine parses a line of an AF, REP, PFX or SFX table
+func (a *affixes) parseTableLine(fields []string, cross bool) error {
+	switch fields[0] {
+	case "AF":
+		if len(fields) < 2 {
+			return errors.New("invalid AF line")
+		}
+		// the aliases are not aliases themselves
+		aliases := a.aliases
+		a.aliases = nil
+		fs, err := a.parseFlags(fields[1])
+		a.aliases = append(aliases, fs)
+		return err
+	case "REP":
+		if len(fields) < 3 {
+			return errors.New("invalid REP line")
+		}
+		from := strings.ReplaceAll(fields[1], "_", " ")
+		to := strings.ReplaceAll(fields[2], "_", " ")
+		// anchors are not supported
+		from = strings.TrimSuffix(strings.TrimPrefix(from, "^"), "$")
+		a.rep = append(a.rep, [2]string{from, to})
+		return nil
+	}
+
+	if len(fields) < 4 {
+		return fmt.Errorf("invalid %s line", fields[0])
+	}
+	flag, err := a.parseFlag(fields[1])
+	if err != nil {
+		return err
+	}
+	af := &affix{
+		flag:   flag,
+		prefix: fields[0] == "PFX",
+		cross:  cross,
+	}
+	if fields[2] != "0" {
+		af.strip = fields[2]
+	}
+	add, cont, _ := strings.Cut(fields[3], "/")
+	if add != "0" {
+		af.add = add
+	}
+	if cont != "" {
+		if af.cont, err = a.parseFlags(cont); err != nil {
+			return err
+		}
+	}
+	cond := "."
+	if len(fields) > 4 {
+		cond = fields[4]
+	}
+	if af.cond, err = condition(cond, af.prefix); err != nil {
+		return err
+	}
+
+	if af.prefix {
+		a.prefixes[af.add] = append(a.prefixes[af.add], af)
+	} else {
+		a.suffixes[af.add] = append(a.suffixes[af.add], af)
+	}
+	return nil
+}
+
+// parseWords parses the word list of a dictionary
+func (a *affixes) parseWords(data []byte) (map[string][]flags, error) {
+	words := make(map[string][]flags)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if n == 1 {
+			// the approximate number of words
+			if count, err := strconv.Atoi(strings.TrimSpace(line)); err == nil {
+				words = make(map[string][]flags, count)
+				continue
+			}
+		}
+		// morphological fields follow a tab or a space
+		if i := strings.IndexAny(line, "\t "); i >= 0 {
+			line = line[:i]
+		}
+		if line == "" || !utf8.ValidString(line) {
+			continue
+		}
+
+		word, fs := line, ""
+		for i := 1; i < len(line); i++ {
+			if line[i] == '/' && line[i-1] != '\\' {
+				word, fs = line[:i], line[i+1:]
+				break
+			}
+		}
+		word = strings.ReplaceAll(word, `\/`, "/")
+		parsed, err := a.parseFlags(fs)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		words[word] = append(words[word], parsed)
+	}
+	return words, scanner.Err()
+}
@@ -0,0 +1,235 @@
+package spell
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Dictionary checks the spelling of words with a Hunspell dictionary,
+// made of an affix file and a word list. Compound words are not supported
+type Dictionary struct {
+	aff   *affixes
+	words map[string][]flags
+
+	mu sync.Mutex
+	// checked caches the result of Check
+	checked map[string]bool
+}
+
+// Load reads a Hunspell dictionary from the contents of its affix (.aff)
+// and word list (.dic) files
+func Load(aff, dic []byte) (*Dictionary, error) {
+	charset := encoding(aff)
+	aff, err := decode(aff, charset)
+	if err != nil {
+		return nil, err
+	}
+	dic, err = decode(dic, charset)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := parseAffixes(aff)
+	if err != nil {
+		return nil, err
+	}
+	words, err := a.parseWords(dic)
+	if err != nil {
+		return nil, err
+	}
+	return &Dictionary{
+		aff:     a,
+		words:   words,
+		checked: make(map[string]bool),
+	}, nil
+}
+
+// Check returns true if the word is spelled correctly. Capitalized and
+// uppercase words are also checked in lowercase
+func (d *Dictionary) Check(word string) bool {
+	d.mu.Lock()
+	ok, found := d.checked[word]
+	d.mu.Unlock()
+	if found {
+		return ok
+	}
+
+	ok = d.check(word)
+	d.mu.Lock()
+	d.checked[word] = ok
+	d.mu.Unlock()
+	return ok
+}
+
+func (d *Dictionary) check(word string) bool {
+	if word == "" || d.forbidden(word) {
+		return false
+	}
+	if d.lookup(word, false) {
+		return true
+	}
+	switch caseOf(word) {
+	case capitalizedCase:
+		lower := strings.ToLower(word)
+		return !d.forbidden(lower) && d.lookup(lower, true)
+	case upperCase:
+		lower := strings.ToLower(word)
+		capital := capitalize(lower)
+		return !d.forbidden(lower) && d.lookup(lower, true) ||
+			!d.forbidden(capital) && d.lookup(capital, true)
+	}
+	return false
+}
+
+// forbidden returns true if the word is marked as forbidden
+func (d *Dictionary) forbidden(word string) bool {
+	for _, fs := range d.words[word] {
+		if fs.has(d.aff.forbidden) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns true if the word is a word of the dictionary or is
+// derived from one with affixes. If changedCase is true, the word was
+// lowercased and does not match the words which keep their case
+func (d *Dictionary) lookup(word string, changedCase bool) bool {
+	a := d.aff
+	valid := func(fs flags) bool {
+		return !fs.has(a.forbidden) && !fs.has(a.onlyInCompound) &&
+			!(changedCase && fs.has(a.keepCase))
+	}
+
+	for _, fs := range d.words[word] {
+		if valid(fs) && !fs.has(a.needAffix) {
+			return true
+		}
+	}
+
+	// rootHas returns true if root is a word with the flag f
+	rootHas := func(root string, f ...flag) bool {
+	words:
+		for _, fs := range d.words[root] {
+			if !valid(fs) {
+				continue
+			}
+			for _, x := range f {
+				if !fs.has(x) {
+					continue words
+				}
+			}
+			return true
+		}
+		return false
+	}
+
+	// suffixed returns true if stem is derived from a root with a suffix
+	// followed by the suffix with the flag f
+	suffixed := func(stem string, f flag) bool {
+		for i := range len(stem) {
+			for _, sfx := range a.suffixes[stem[i:]] {
+				root := stem[:i] + sfx.strip
+				if sfx.cont.has(f) && i > 0 && sfx.matches(root) && rootHas(root, sfx.flag) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for i := range len(word) + 1 {
+		if i < len(word) && !utf8.RuneStart(word[i]) {
+			continue
+		}
+		for _, sfx := range a.suffixes[word[i:]] {
+			if i == 0 {
+				continue
+			}
+			stem := word[:i] + sfx.strip
+			if !sfx.matches(stem) {
+				continue
+			}
+			if !sfx.cont.has(a.needAffix) && rootHas(stem, sfx.flag) || suffixed(stem, sfx.flag) {
+				return true
+			}
+			if !sfx.cross {
+				continue
+			}
+			for j := range len(stem) + 1 {
+				for _, pfx := range a.prefixes[stem[:j]] {
+					root := pfx.strip + stem[j:]
+					if root == "" || !pfx.cross || !pfx.matches(root) {
+						continue
+					}
+					if rootHas(root, sfx.flag, pfx.flag) ||
+						sfx.cont.has(pfx.flag) && rootHas(root, sfx.flag) ||
+						pfx.cont.has(sfx.flag) && rootHas(root, pfx.flag) {
+						return true
+					}
+				}
+			}
+		}
+	}
+
+	for i := 1; i <= len(word); i++ {
+		if i < len(word) && !utf8.RuneStart(word[i]) {
+			continue
+		}
+		for _, pfx := range a.prefixes[word[:i]] {
+			root := pfx.strip + word[i:]
+			if root == "" || !pfx.matches(root) {
+				continue
+			}
+			if !pfx.cont.has(a.needAffix) && rootHas(root, pfx.flag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type wordCase int
+
+const (
+	lowerCase wordCase = iota
+	capitalizedCase
+	upperCase
+	mixedCase
+)
+
+// caseOf returns the case of a word
+func caseOf(word string) wordCase {
+	first := true
+	capital := false
+	nupper, nletters := 0, 0
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			nletters++
+			if unicode.IsUpper(r) {
+				nupper++
+				if first {
+					capital = true
+				}
+			}
+			first = false
+		}
+	}
+	switch {
+	case nupper == 0:
+		return lowerCase
+	case nupper == nletters && nletters > 1:
+		return upperCase
+	case capital && nupper == 1:
+		return capitalizedCase
+	}
+	return mixedCase
+}
+
+// capitalize returns the word with its first letter in uppercase
+func capitalize(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + word[size:]
+}
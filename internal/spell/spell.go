@@ -0,0 +1,272 @@
+package spell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/helmutkemper/micro/v2/internal/screen"
+	"github.com/helmutkemper/micro/v2/internal/util"
+)
+
+// a language is a dictionary loaded in the background
+type language struct {
+	dict *Dictionary
+	err  error
+	done chan struct{}
+}
+
+var (
+	mu        sync.Mutex
+	languages = make(map[string]*language)
+	// userWords are the words of the user dictionary, nil until it is read
+	userWords map[string]bool
+)
+
+// UserDictionary returns the path of the user dictionary, which lists the
+// words accepted in all languages, one per line
+func UserDictionary() string {
+	return filepath.Join(config.ConfigDir, "dictionaries", "user.txt")
+}
+
+// Language returns the dictionary of a language, read from the RTDictionary
+// and RTAffix runtime files named after the language, such as en_US. The
+// dictionary is loaded in the background the first time, and Language
+// returns nil until it is loaded, or an error if it can't be loaded
+func Language(name string) (*Dictionary, error) {
+	mu.Lock()
+	l, ok := languages[name]
+	if !ok {
+		l = &language{done: make(chan struct{})}
+		languages[name] = l
+		go l.load(name)
+	}
+	mu.Unlock()
+
+	select {
+	case <-l.done:
+		return l.dict, l.err
+	default:
+		return nil, nil
+	}
+}
+
+func (l *language) load(name string) {
+	defer screen.Redraw()
+	defer close(l.done)
+
+	dic := config.FindRuntimeFile(config.RTDictionary, name)
+	aff := config.FindRuntimeFile(config.RTAffix, name)
+	if dic == nil || aff == nil {
+		l.err = fmt.Errorf("No dictionary for %s", name)
+		return
+	}
+	affData, err := aff.Data()
+	if err != nil {
+		l.err = err
+		return
+	}
+	dicData, err := dic.Data()
+	if err != nil {
+		l.err = err
+		return
+	}
+	if l.dict, l.err = Load(affData, dicData); l.err != nil {
+		l.err = fmt.Errorf("Error loading the %s dictionary: %v", name, l.err)
+	}
+}
+
+// A Checker checks words against the dictionaries of several languages and
+// the user dictionary
+type Checker []*Dictionary
+
+// NewChecker returns a checker for the loaded dictionaries of the given
+// languages, and an error for the first language which can't be loaded
+func NewChecker(langs []string) (Checker, error) {
+	var c Checker
+	var firstErr error
+	for _, name := range langs {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		d, err := Language(name)
+		if d != nil {
+			c = append(c, d)
+		} else if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return c, firstErr
+}
+
+// Check returns true if the word is spelled correctly in one of the
+// languages or is in the user dictionary
+func (c Checker) Check(word string) bool {
+	word = strings.ReplaceAll(word, "’", "'")
+	if isUserWord(word) {
+		return true
+	}
+	for _, d := range c {
+		if d.Check(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// Suggest returns the suggestions of all the languages for a word
+func (c Checker) Suggest(word string) []string {
+	var suggestions []string
+	seen := make(map[string]bool)
+	for _, d := range c {
+		for _, s := range d.Suggest(word) {
+			if !seen[s] {
+				seen[s] = true
+				suggestions = append(suggestions, s)
+			}
+		}
+	}
+	return suggestions
+}
+
+// readUserWords reads the user dictionary if it was not read yet. mu must
+// be locked
+func readUserWords() {
+	if userWords != nil {
+		return
+	}
+	userWords = make(map[string]bool)
+	f, err := os.Open(UserDictionary())
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if w := strings.TrimSpace(scanner.Text()); w != "" {
+			userWords[w] = true
+		}
+	}
+}
+
+// isUserWord returns true if the word, or its lowercase form, is in the
+// user dictionary
+func isUserWord(word string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	readUserWords()
+	return userWords[word] || userWords[strings.ToLower(word)]
+}
+
+// AddWord adds a word to the user dictionary
+func AddWord(word string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	readUserWords()
+	if userWords[word] {
+		return nil
+	}
+
+	path := UserDictionary()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+	data = append(data, word+"\n"...)
+	if err := util.SafeWrite(path, data, true); err != nil {
+		return err
+	}
+	userWords[word] = true
+	return nil
+}
+
+// A Word is a word of a line, from the character Start to the character
+// End, excluded
+type Word struct {
+	Start, End int
+	Text       string
+}
+
+// separators join the parts of paths and addresses, which are not words
+const separators = "./\\@:"
+
+// Words returns the words of a line which can be spell checked. The
+// identifiers, such as the words with digits or underscores or in camel
+// case, and the parts of paths and addresses are skipped
+func Words(line []byte) []Word {
+	type char struct {
+		r    rune
+		text []byte
+	}
+	var chars []char
+	for len(line) > 0 {
+		r, _, size := util.DecodeCharacter(line)
+		chars = append(chars, char{r, line[:size]})
+		line = line[size:]
+	}
+	isWordChar := func(i int) bool {
+		if i < 0 || i >= len(chars) {
+			return false
+		}
+		r := chars[i].r
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+	}
+	// joined returns true if the character i is a separator between
+	// two words
+	joined := func(i int) bool {
+		return i >= 0 && i < len(chars) && strings.ContainsRune(separators, chars[i].r) &&
+			isWordChar(i-1) && isWordChar(i+1)
+	}
+
+	var words []Word
+	for i := 0; i < len(chars); {
+		if !isWordChar(i) {
+			i++
+			continue
+		}
+		start := i
+		identifier := false
+		for ; i < len(chars); i++ {
+			r := chars[i].r
+			if r == '\'' || r == '’' {
+				// an apostrophe inside a word
+				if isWordChar(i-1) && isWordChar(i+1) {
+					continue
+				}
+				break
+			}
+			if !isWordChar(i) {
+				break
+			}
+			if unicode.IsDigit(r) || r == '_' {
+				identifier = true
+			}
+		}
+
+		var text []byte
+		for _, c := range chars[start:i] {
+			text = append(text, c.text...)
+		}
+		w := Word{start, i, string(text)}
+		if identifier || w.End-w.Start < 2 || caseOf(w.Text) == mixedCase ||
+			joined(start-1) || joined(i) {
+			continue
+		}
+		words = append(words, w)
+	}
+	return words
+}
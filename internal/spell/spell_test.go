@@ -0,0 +1,106 @@
+package spell
+
+import (
+	"os"
+	"testing"
+
+	"github.com/helmutkemper/micro/v2/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func loadTest(t *testing.T) *Dictionary {
+	aff, err := os.ReadFile("testdata/test.aff")
+	assert.NoError(t, err)
+	dic, err := os.ReadFile("testdata/test.dic")
+	assert.NoError(t, err)
+	d, err := Load(aff, dic)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return d
+}
+
+func TestCheck(t *testing.T) {
+	d := loadTest(t)
+	for word, ok := range map[string]bool{
+		"hello":    true,
+		"Hello":    true,
+		"HELLO":    true,
+		"hellO":    false,
+		"helo":     false,
+		"cities":   true,
+		"citys":    false,
+		"days":     true,
+		"dayes":    false,
+		"unlocked": true,
+		"unlocks":  true,
+		"liked":    true,
+		"unliked":  true,
+		"likeed":   false,
+		"kindly":   true,
+		"kindlies": true,
+		"unkindly": true,
+		"kitten":   false,
+		"kittens":  false,
+		"NASA":     true,
+		"nasa":     false,
+		"Nasa":     false,
+		"Paris":    true,
+		"paris":    false,
+		"PARIS":    true,
+		"unhello":  false,
+		"phones":   true,
+		"unphone":  false,
+		"graphs":   true,
+	} {
+		assert.Equal(t, ok, d.Check(word), word)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	d := loadTest(t)
+	assert.Equal(t, "hello", d.Suggest("helo")[0])
+	assert.Equal(t, "Hello", d.Suggest("Helol")[0])
+	assert.Equal(t, "CITIES", d.Suggest("CITEIS")[0])
+	assert.Contains(t, d.Suggest("fone"), "phone")
+	assert.Contains(t, d.Suggest("grafs"), "graphs")
+	assert.Contains(t, d.Suggest("paris"), "Paris")
+	assert.Contains(t, d.Suggest("hellocat"), "hello cat")
+	// two errors
+	assert.Contains(t, d.Suggest("kondlu"), "kindly")
+	assert.NotContains(t, d.Suggest("kittns"), "kittens")
+}
+
+func TestWords(t *testing.T) {
+	text := func(words []Word) []string {
+		var s []string
+		for _, w := range words {
+			s = append(s, w.Text)
+		}
+		return s
+	}
+	assert.Equal(t, []string{"Don't", "open", "the", "or", "its", "files"},
+		text(Words([]byte("// Don't open the foo_bar.go or user@host file2, its files'"))))
+	assert.Equal(t, []string{"até", "amanhã"}, text(Words([]byte("\"até amanhã\""))))
+	assert.Equal(t, []Word{{3, 8, "hello"}}, Words([]byte("é  hello myVar")))
+}
+
+func TestUserDictionary(t *testing.T) {
+	configDir := config.ConfigDir
+	config.ConfigDir = t.TempDir()
+	defer func() { config.ConfigDir = configDir }()
+	userWords = nil
+	defer func() { userWords = nil }()
+
+	c := Checker{loadTest(t)}
+	assert.False(t, c.Check("micro"))
+	assert.NoError(t, AddWord("micro"))
+	assert.True(t, c.Check("micro"))
+	assert.True(t, c.Check("Micro"))
+
+	userWords = nil
+	assert.True(t, c.Check("micro"))
+	data, err := os.ReadFile(UserDictionary())
+	assert.NoError(t, err)
+	assert.Equal(t, "micro\n", string(data))
+}
@@ -0,0 +1,146 @@
+package spell
+
+import (
+	"strings"
+)
+
+// maxSuggestions is the maximum number of suggestions returned by Suggest
+const maxSuggestions = 10
+
+// defaultTry are the letters tried by Suggest when the affix file has no
+// TRY directive
+const defaultTry = "esianrtolcdugmphbyfvkwzxjq"
+
+// Suggest returns the correctly spelled words which are close to a word,
+// the most likely first
+func (d *Dictionary) Suggest(word string) []string {
+	wc := caseOf(word)
+	if wc == capitalizedCase || wc == upperCase {
+		word = strings.ToLower(word)
+	}
+
+	s := &suggester{d: d, seen: map[string]bool{word: true}}
+	s.try(capitalize(word))
+	for _, rep := range d.aff.rep {
+		for i := 0; ; {
+			j := strings.Index(word[i:], rep[0])
+			if j < 0 || rep[0] == "" {
+				break
+			}
+			j += i
+			s.try(word[:j] + rep[1] + word[j+len(rep[0]):])
+			i = j + 1
+		}
+	}
+	s.keyboard(word)
+	var near []string
+	s.edits(word, func(w string) {
+		s.try(w)
+		near = append(near, w)
+	})
+	runes := []rune(word)
+	for i := 1; i < len(runes); i++ {
+		s.try(string(runes[:i]) + " " + string(runes[i:]))
+	}
+	// words with two errors are only suggested when there are no better
+	// suggestions, since there are many of them
+	if len(s.found) == 0 && len(runes) <= 20 {
+		for _, w := range near {
+			s.edits(w, s.try)
+		}
+	}
+
+	for i, w := range s.found {
+		switch wc {
+		case capitalizedCase:
+			s.found[i] = capitalize(w)
+		case upperCase:
+			s.found[i] = strings.ToUpper(w)
+		}
+	}
+	return s.found
+}
+
+// suggester collects the suggestions for a word
+type suggester struct {
+	d     *Dictionary
+	seen  map[string]bool
+	found []string
+}
+
+// try adds a word to the suggestions if it is spelled correctly. The word
+// may be made of several words separated by spaces
+func (s *suggester) try(word string) {
+	if len(s.found) >= maxSuggestions || s.seen[word] {
+		return
+	}
+	s.seen[word] = true
+	for _, w := range strings.Split(word, " ") {
+		if !s.d.check(w) || s.noSuggest(w) {
+			return
+		}
+	}
+	s.found = append(s.found, word)
+}
+
+// noSuggest returns true if the word must not be suggested
+func (s *suggester) noSuggest(word string) bool {
+	for _, fs := range s.d.words[word] {
+		if fs.has(s.d.aff.noSuggest) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyboard tries the words where a letter is replaced by a neighbouring
+// key of the keyboard, as given by the KEY directive
+func (s *suggester) keyboard(word string) {
+	runes := []rune(word)
+	for i, r := range runes {
+		for _, row := range s.d.aff.key {
+			keys := []rune(row)
+			for j, k := range keys {
+				if k != r {
+					continue
+				}
+				for _, n := range []int{j - 1, j + 1} {
+					if n >= 0 && n < len(keys) {
+						s.try(string(runes[:i]) + string(keys[n]) + string(runes[i+1:]))
+					}
+				}
+			}
+		}
+	}
+}
+
+// edits calls fn with each word which is one edit away from a word: two
+// adjacent letters swapped, or a letter substituted, removed or inserted
+func (s *suggester) edits(word string, fn func(string)) {
+	try := s.d.aff.try
+	if try == "" {
+		try = defaultTry
+	}
+	letters := []rune(try)
+	runes := []rune(word)
+	for i := 0; i+1 < len(runes); i++ {
+		if runes[i] != runes[i+1] {
+			fn(string(runes[:i]) + string(runes[i+1]) + string(runes[i]) + string(runes[i+2:]))
+		}
+	}
+	for i := range runes {
+		for _, l := range letters {
+			if l != runes[i] {
+				fn(string(runes[:i]) + string(l) + string(runes[i+1:]))
+			}
+		}
+	}
+	for i := range runes {
+		fn(string(runes[:i]) + string(runes[i+1:]))
+	}
+	for i := range len(runes) + 1 {
+		for _, l := range letters {
+			fn(string(runes[:i]) + string(l) + string(runes[i:]))
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package transform
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+func isAlnum(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// caseConverter returns a function which converts the case of the phrases
+// of a text: the identifiers, and the words separated by single spaces or
+// hyphens. The words of each phrase are joined by join. The underscores
+// around a phrase are kept
+func caseConverter(join func(words []string) string) func(string) string {
+	return func(text string) string {
+		runes := []rune(text)
+		inPhrase := func(i int) bool {
+			switch r := runes[i]; {
+			case isAlnum(r) || r == '_':
+				return true
+			case r == ' ' || r == '-':
+				return i > 0 && i+1 < len(runes) && isAlnum(runes[i-1]) && isAlnum(runes[i+1])
+			}
+			return false
+		}
+
+		var b strings.Builder
+		for i := 0; i < len(runes); {
+			if !inPhrase(i) {
+				b.WriteRune(runes[i])
+				i++
+				continue
+			}
+			j := i
+			for j < len(runes) && inPhrase(j) {
+				j++
+			}
+			phrase := string(runes[i:j])
+			words := splitWords(phrase)
+			if len(words) == 0 {
+				b.WriteString(phrase)
+			} else {
+				trimmed := strings.TrimLeft(phrase, "_")
+				b.WriteString(phrase[:len(phrase)-len(trimmed)])
+				b.WriteString(join(words))
+				b.WriteString(trimmed[len(strings.TrimRight(trimmed, "_")):])
+			}
+			i = j
+		}
+		return b.String()
+	}
+}
+
+// splitWords splits a phrase into words at the underscores, hyphens and
+// spaces, and where the case changes, as in camelCase and HTTPServer
+func splitWords(phrase string) []string {
+	runes := []rune(phrase)
+	var words []string
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			words = append(words, string(runes[start:end]))
+			start = -1
+		}
+	}
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == ' ' {
+			flush(i)
+			continue
+		}
+		if start >= 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && next {
+				flush(i)
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	flush(len(runes))
+	return words
+}
+
+// capitalized returns a word with its first letter in uppercase and the
+// others in lowercase
+func capitalized(word string) string {
+	r, size := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + strings.ToLower(word[size:])
+}
+
+func camelCase(words []string) string {
+	return strings.ToLower(words[0]) + pascalCase(words[1:])
+}
+
+func pascalCase(words []string) string {
+	var b strings.Builder
+	for _, w := range words {
+		b.WriteString(capitalized(w))
+	}
+	return b.String()
+}
+
+func snakeCase(words []string) string {
+	return strings.ToLower(strings.Join(words, "_"))
+}
+
+func kebabCase(words []string) string {
+	return strings.ToLower(strings.Join(words, "-"))
+}
+
+// titleCase capitalizes the words, except the acronyms which are kept in
+// uppercase
+func titleCase(words []string) string {
+	title := make([]string, len(words))
+	for i, w := range words {
+		if utf8.RuneCountInString(w) > 1 && strings.ToUpper(w) == w {
+			title[i] = w
+		} else {
+			title[i] = capitalized(w)
+		}
+	}
+	return strings.Join(title, " ")
+}
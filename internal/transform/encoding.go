@@ -0,0 +1,73 @@
+package transform
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errBinary is returned when a decoded text is not valid UTF-8
+var errBinary = errors.New("The decoded text is binary")
+
+// text returns the decoded bytes as a string if they are valid UTF-8
+func text(b []byte) (string, error) {
+	if !utf8.Valid(b) {
+		return "", errBinary
+	}
+	return string(b), nil
+}
+
+// removeSpaces removes the whitespace of an encoded text, which may be
+// split into lines
+func removeSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func base64Encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// base64Decode decodes standard or URL-safe base64, with or without
+// padding
+func base64Decode(s string) (string, error) {
+	s = removeSpaces(s)
+	var err error
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+	} {
+		var b []byte
+		if b, err = enc.DecodeString(s); err == nil {
+			return text(b)
+		}
+	}
+	return "", err
+}
+
+func urlEncode(s string) string {
+	return url.QueryEscape(s)
+}
+
+func urlDecode(s string) (string, error) {
+	return url.QueryUnescape(s)
+}
+
+func hexEncode(s string) string {
+	return hex.EncodeToString([]byte(s))
+}
+
+func hexDecode(s string) (string, error) {
+	b, err := hex.DecodeString(removeSpaces(s))
+	if err != nil {
+		return "", err
+	}
+	return text(b)
+}
@@ -0,0 +1,230 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// number matches the number at the start of a sort key
+var number = regexp.MustCompile(`^\s*[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?`)
+
+// sortLines sorts the lines of a text. The flags are -n to sort by number,
+// -v to sort naturally, with the numbers inside the text compared by
+// value, -i to ignore the case, -r to reverse the order and -k with a
+// regular expression to sort by the part of the lines it matches, or its
+// first group
+func sortLines(text string, args []string, opts Options) (string, error) {
+	var numeric, natural, ignoreCase, reversed bool
+	var key *regexp.Regexp
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-n":
+			numeric = true
+		case "-v":
+			natural = true
+		case "-i":
+			ignoreCase = true
+		case "-r":
+			reversed = true
+		case "-k":
+			if i+1 == len(args) {
+				return "", errors.New("-k needs a regular expression")
+			}
+			i++
+			var err error
+			if key, err = regexp.Compile(args[i]); err != nil {
+				return "", err
+			}
+		default:
+			return "", fmt.Errorf("Unknown flag %s", args[i])
+		}
+	}
+
+	sortKey := func(line string) string {
+		if key != nil {
+			m := key.FindStringSubmatch(line)
+			switch {
+			case m == nil:
+				line = ""
+			case len(m) > 1:
+				line = m[1]
+			default:
+				line = m[0]
+			}
+		}
+		if ignoreCase {
+			line = strings.ToLower(line)
+		}
+		return line
+	}
+	compare := strings.Compare
+	if numeric {
+		compare = compareNumbers
+	} else if natural {
+		compare = compareNatural
+	}
+
+	return eachLine(text, func(lines []string) []string {
+		keys := make(map[string]string, len(lines))
+		for _, l := range lines {
+			keys[l] = sortKey(l)
+		}
+		sort.SliceStable(lines, func(i, j int) bool {
+			c := compare(keys[lines[i]], keys[lines[j]])
+			if reversed {
+				return c > 0
+			}
+			return c < 0
+		})
+		return lines
+	}), nil
+}
+
+// compareNumbers compares the numbers at the start of two keys. The keys
+// which start with a number come first
+func compareNumbers(a, b string) int {
+	x, errA := strconv.ParseFloat(strings.TrimSpace(number.FindString(a)), 64)
+	y, errB := strconv.ParseFloat(strings.TrimSpace(number.FindString(b)), 64)
+	switch {
+	case errA == nil && errB == nil:
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// compareNatural compares two keys where the runs of digits are compared by
+// value, so that "a2" comes before "a10"
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		da, db := digits(a), digits(b)
+		if da == "" || db == "" {
+			ra, sa := utf8.DecodeRuneInString(a)
+			rb, sb := utf8.DecodeRuneInString(b)
+			if ra != rb {
+				if ra < rb {
+					return -1
+				}
+				return 1
+			}
+			a, b = a[sa:], b[sb:]
+			continue
+		}
+		na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+		if len(na) != len(nb) {
+			if len(na) < len(nb) {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(na, nb); c != 0 {
+			return c
+		}
+		a, b = a[len(da):], b[len(db):]
+	}
+	return strings.Compare(a, b)
+}
+
+// digits returns the digits at the start of a string
+func digits(s string) string {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	if i < 0 {
+		return s
+	}
+	return s[:i]
+}
+
+// unique removes the repeated lines of a text, keeping the first one. The
+// -i flag ignores the case
+func unique(text string, args []string, opts Options) (string, error) {
+	ignoreCase := len(args) > 0 && args[0] == "-i"
+	if ignoreCase {
+		args = args[1:]
+	}
+	if err := noFlags(args); err != nil {
+		return "", err
+	}
+	return eachLine(text, func(lines []string) []string {
+		seen := make(map[string]bool)
+		out := lines[:0]
+		for _, l := range lines {
+			k := l
+			if ignoreCase {
+				k = strings.ToLower(k)
+			}
+			if !seen[k] {
+				seen[k] = true
+				out = append(out, l)
+			}
+		}
+		return out
+	}), nil
+}
+
+// reverse reverses the order of the lines of a text
+func reverse(text string, args []string, opts Options) (string, error) {
+	if err := noFlags(args); err != nil {
+		return "", err
+	}
+	return eachLine(text, func(lines []string) []string {
+		for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+			lines[i], lines[j] = lines[j], lines[i]
+		}
+		return lines
+	}), nil
+}
+
+// shuffle puts the lines of a text in a random order
+func shuffle(text string, args []string, opts Options) (string, error) {
+	if err := noFlags(args); err != nil {
+		return "", err
+	}
+	return eachLine(text, func(lines []string) []string {
+		rand.Shuffle(len(lines), func(i, j int) {
+			lines[i], lines[j] = lines[j], lines[i]
+		})
+		return lines
+	}), nil
+}
+
+// trim removes the whitespace around the lines of a text, only at their
+// start with the -l flag, or only at their end with the -t flag
+func trim(text string, args []string, opts Options) (string, error) {
+	leading, trailing := true, true
+	if len(args) > 0 && (args[0] == "-l" || args[0] == "-t") {
+		leading, trailing = args[0] == "-l", args[0] == "-t"
+		args = args[1:]
+	}
+	if err := noFlags(args); err != nil {
+		return "", err
+	}
+	return eachLine(text, func(lines []string) []string {
+		for i, l := range lines {
+			if leading {
+				l = strings.TrimLeftFunc(l, unicode.IsSpace)
+			}
+			if trailing {
+				l = strings.TrimRightFunc(l, unicode.IsSpace)
+			}
+			lines[i] = l
+		}
+		return lines
+	}), nil
+}
@@ -0,0 +1,175 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// keepNewline adds a newline at the end of the transformed text if the
+// original text ended with one
+func keepNewline(orig, out string) string {
+	if strings.HasSuffix(orig, "\n") && !strings.HasSuffix(out, "\n") {
+		return out + "\n"
+	}
+	return out
+}
+
+func jsonPretty(text string, args []string, opts Options) (string, error) {
+	if err := noFlags(args); err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, []byte(text), "", opts.Indent); err != nil {
+		return "", fmt.Errorf("Invalid JSON: %v", err)
+	}
+	return keepNewline(text, strings.TrimSpace(b.String())), nil
+}
+
+func jsonMinify(text string, args []string, opts Options) (string, error) {
+	if err := noFlags(args); err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := json.Compact(&b, []byte(text)); err != nil {
+		return "", fmt.Errorf("Invalid JSON: %v", err)
+	}
+	return keepNewline(text, strings.TrimSpace(b.String())), nil
+}
+
+func xmlPretty(text string, args []string, opts Options) (string, error) {
+	if err := noFlags(args); err != nil {
+		return "", err
+	}
+	if opts.Indent == "" {
+		opts.Indent = "\t"
+	}
+	out, err := formatXML(text, opts.Indent)
+	return keepNewline(text, out), err
+}
+
+func xmlMinify(text string, args []string, opts Options) (string, error) {
+	if err := noFlags(args); err != nil {
+		return "", err
+	}
+	out, err := formatXML(text, "")
+	return keepNewline(text, out), err
+}
+
+var (
+	xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;")
+)
+
+// xmlName returns a name with its namespace prefix, as it is written
+func xmlName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// formatXML writes an XML document again, with each element on its own
+// line indented with indent, or on a single line without whitespace between
+// the elements if indent is empty. The text of the elements is kept, and
+// the elements without content are closed with />
+func formatXML(text, indent string) (string, error) {
+	d := xml.NewDecoder(strings.NewReader(text))
+	var b strings.Builder
+	// the names of the open elements
+	var stack []string
+	// open is true when the last start tag is not closed with > yet
+	open := false
+	// inline is true when the content of the current element is text, and
+	// its end tag is written on the same line
+	inline := false
+	newline := func() {
+		if indent != "" && b.Len() > 0 {
+			b.WriteString("\n")
+			b.WriteString(strings.Repeat(indent, len(stack)))
+		}
+	}
+	closeTag := func() {
+		if open {
+			b.WriteString(">")
+			open = false
+		}
+	}
+
+	for {
+		tok, err := d.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return "", fmt.Errorf("Invalid XML: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			closeTag()
+			newline()
+			b.WriteString("<" + xmlName(t.Name))
+			for _, a := range t.Attr {
+				b.WriteString(" " + xmlName(a.Name) + `="` + xmlAttrEscaper.Replace(a.Value) + `"`)
+			}
+			open, inline = true, false
+			stack = append(stack, xmlName(t.Name))
+		case xml.EndElement:
+			name := xmlName(t.Name)
+			if len(stack) == 0 || stack[len(stack)-1] != name {
+				return "", fmt.Errorf("Invalid XML: unexpected </%s>", name)
+			}
+			stack = stack[:len(stack)-1]
+			if open {
+				b.WriteString("/>")
+				open = false
+			} else {
+				if !inline {
+					newline()
+				}
+				b.WriteString("</" + name + ">")
+			}
+			inline = false
+		case xml.CharData:
+			s := string(t)
+			if indent != "" {
+				s = strings.TrimSpace(s)
+			}
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+			if open {
+				closeTag()
+				inline = true
+			} else if !inline {
+				newline()
+			}
+			b.WriteString(xmlTextEscaper.Replace(s))
+		case xml.Comment:
+			closeTag()
+			newline()
+			b.WriteString("<!--" + string(t) + "-->")
+			inline = false
+		case xml.ProcInst:
+			closeTag()
+			newline()
+			b.WriteString("<?" + t.Target)
+			if len(t.Inst) > 0 {
+				b.WriteString(" " + string(t.Inst))
+			}
+			b.WriteString("?>")
+		case xml.Directive:
+			closeTag()
+			newline()
+			b.WriteString("<!" + string(t) + ">")
+		}
+	}
+	if len(stack) > 0 {
+		return "", fmt.Errorf("Invalid XML: unclosed <%s>", stack[len(stack)-1])
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,105 @@
+// Package transform implements the built-in text transforms of the
+// transform command, which don't depend on external tools
+package transform
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Options are the settings of the buffer which are used by the transforms
+type Options struct {
+	// Indent is the indentation of the pretty-printed JSON and XML
+	Indent string
+}
+
+// A Transform changes a text, with the flags given to the command
+type Transform func(text string, args []string, opts Options) (string, error)
+
+var transforms = map[string]Transform{
+	"sort":    sortLines,
+	"unique":  unique,
+	"reverse": reverse,
+	"shuffle": shuffle,
+	"trim":    trim,
+
+	"upper":  simple(strings.ToUpper),
+	"lower":  simple(strings.ToLower),
+	"camel":  simple(caseConverter(camelCase)),
+	"pascal": simple(caseConverter(pascalCase)),
+	"snake":  simple(caseConverter(snakeCase)),
+	"kebab":  simple(caseConverter(kebabCase)),
+	"title":  simple(caseConverter(titleCase)),
+
+	"base64-encode": simple(base64Encode),
+	"base64-decode": decoder(base64Decode),
+	"url-encode":    simple(urlEncode),
+	"url-decode":    decoder(urlDecode),
+	"hex-encode":    simple(hexEncode),
+	"hex-decode":    decoder(hexDecode),
+
+	"json-pretty": jsonPretty,
+	"json-minify": jsonMinify,
+	"xml-pretty":  xmlPretty,
+	"xml-minify":  xmlMinify,
+}
+
+// simple returns a transform without flags
+func simple(fn func(string) string) Transform {
+	return func(text string, args []string, opts Options) (string, error) {
+		if err := noFlags(args); err != nil {
+			return "", err
+		}
+		return fn(text), nil
+	}
+}
+
+// decoder returns a transform without flags which may fail
+func decoder(fn func(string) (string, error)) Transform {
+	return func(text string, args []string, opts Options) (string, error) {
+		if err := noFlags(args); err != nil {
+			return "", err
+		}
+		return fn(text)
+	}
+}
+
+// Names returns the names of the transforms, sorted
+func Names() []string {
+	names := make([]string, 0, len(transforms))
+	for name := range transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply runs the transform with the given name on a text
+func Apply(name, text string, args []string, opts Options) (string, error) {
+	t, ok := transforms[name]
+	if !ok {
+		return "", fmt.Errorf("Unknown transform %s", name)
+	}
+	return t(text, args, opts)
+}
+
+// eachLine applies fn to the lines of a text. The newline at the end of the
+// text, if any, is kept at the end
+func eachLine(text string, fn func(lines []string) []string) string {
+	final := strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	out := strings.Join(fn(lines), "\n")
+	if final {
+		out += "\n"
+	}
+	return out
+}
+
+// noFlags returns an error if a transform without flags is given flags
+func noFlags(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("Unknown flag %s", args[0])
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func apply(t *testing.T, name, text string, args ...string) string {
+	out, err := Apply(name, text, args, Options{Indent: "  "})
+	assert.NoError(t, err)
+	return out
+}
+
+func TestSort(t *testing.T) {
+	assert.Equal(t, "B\na\nc\n", apply(t, "sort", "c\na\nB\n"))
+	assert.Equal(t, "a\nB\nc", apply(t, "sort", "c\na\nB", "-i"))
+	assert.Equal(t, "2\n10\nx", apply(t, "sort", "10\nx\n2", "-n"))
+	assert.Equal(t, "f10\nf2\nf1", apply(t, "sort", "f1\nf10\nf2", "-v", "-r"))
+	assert.Equal(t, "b=1\na=2", apply(t, "sort", "a=2\nb=1", "-k", "=(.*)"))
+
+	_, err := Apply("sort", "", []string{"-x"}, Options{})
+	assert.Error(t, err)
+	_, err = Apply("nothing", "", nil, Options{})
+	assert.Error(t, err)
+}
+
+func TestLines(t *testing.T) {
+	assert.Equal(t, "a\nb\n", apply(t, "unique", "a\nb\na\n"))
+	assert.Equal(t, "a\nb", apply(t, "unique", "a\nb\nA", "-i"))
+	assert.Equal(t, "c\nb\na\n", apply(t, "reverse", "a\nb\nc\n"))
+	assert.ElementsMatch(t, []rune("a\nb\nc"), []rune(apply(t, "shuffle", "a\nb\nc")))
+	assert.Equal(t, "a\nb", apply(t, "trim", "  a \n\tb"))
+	assert.Equal(t, "  a\n\tb", apply(t, "trim", "  a \n\tb ", "-t"))
+}
+
+func TestCase(t *testing.T) {
+	assert.Equal(t, "fooBar(httpServer, x)", apply(t, "camel", "foo_bar(HTTPServer, x)"))
+	assert.Equal(t, "FooBar", apply(t, "pascal", "foo-bar"))
+	assert.Equal(t, "_foo_bar2 = 1 - x", apply(t, "snake", "_fooBar2 = 1 - x"))
+	assert.Equal(t, "hello-world", apply(t, "kebab", "Hello World"))
+	assert.Equal(t, "The Quick HTML Parser", apply(t, "title", "the quick HTML_parser"))
+}
+
+func TestEncoding(t *testing.T) {
+	assert.Equal(t, "aMOpIGI=", apply(t, "base64-encode", "hé b"))
+	assert.Equal(t, "hé b", apply(t, "base64-decode", "aMOp\nIGI"))
+	assert.Equal(t, "a+b%26c", apply(t, "url-encode", "a b&c"))
+	assert.Equal(t, "a b&c", apply(t, "url-decode", "a+b%26c"))
+	assert.Equal(t, "6869", apply(t, "hex-encode", "hi"))
+	assert.Equal(t, "hi", apply(t, "hex-decode", "68 69"))
+
+	_, err := Apply("hex-decode", "ff", nil, Options{})
+	assert.Equal(t, errBinary, err)
+}
+
+func TestMarkup(t *testing.T) {
+	assert.Equal(t, "{\n  \"a\": [\n    1,\n    2\n  ]\n}\n", apply(t, "json-pretty", `{"a": [1,2]}`+"\n"))
+	assert.Equal(t, `{"a":[1,2]}`, apply(t, "json-minify", "{\n  \"a\": [ 1, 2 ]\n}"))
+
+	xml := `<?xml version="1.0"?><a x="1&amp;"><b>t &lt; u</b><c/><!-- c --></a>`
+	assert.Equal(t, "<?xml version=\"1.0\"?>\n<a x=\"1&amp;\">\n  <b>t &lt; u</b>\n  <c/>\n  <!-- c -->\n</a>",
+		apply(t, "xml-pretty", xml))
+	assert.Equal(t, xml, apply(t, "xml-minify", apply(t, "xml-pretty", xml)))
+
+	_, err := Apply("xml-pretty", "<a><b></a>", nil, Options{})
+	assert.Error(t, err)
+}
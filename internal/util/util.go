@@ -468,6 +468,17 @@ func EscapePathLegacy(path string) string {
 	return strings.ReplaceAll(path, "/", "%")
 }
 
+// UnescapePath returns the path escaped by EscapePathUrl or by
+// EscapePathLegacy
+func UnescapePath(name string) string {
+	if strings.Contains(name, "%2F") {
+		if path, err := url.QueryUnescape(name); err == nil {
+			return filepath.FromSlash(path)
+		}
+	}
+	return filepath.FromSlash(strings.ReplaceAll(name, "%", "/"))
+}
+
 // DetermineEscapePath escapes a path, determining whether it should be escaped
 // using URL encoding (preferred, since it encodes unambiguously) or
 // legacy encoding with '%' (for backward compatibility, if the legacy-escaped
@@ -31,3 +31,9 @@ func TestSliceVisualEnd(t *testing.T) {
 	assert.Equal(t, []byte("ello"), slc)
 	assert.Equal(t, 0, n)
 }
+
+func TestUnescapePath(t *testing.T) {
+	path := "/home/user/a b%c.txt"
+	assert.Equal(t, path, UnescapePath(EscapePathUrl(path)))
+	assert.Equal(t, "/home/user/file.txt", UnescapePath(EscapePathLegacy("/home/user/file.txt")))
+}
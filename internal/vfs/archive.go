@@ -0,0 +1,380 @@
+package vfs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/util"
+)
+
+var errIsDir = errors.New("is a directory")
+
+// An archive is a format of archive, with the extensions of its files
+type archive struct {
+	exts []string
+	zip  bool
+	// c is the compression of a tar archive, if any
+	c *codec
+}
+
+var archives = []*archive{
+	{exts: []string{".zip", ".jar"}, zip: true},
+	{exts: []string{".tar"}},
+	{exts: []string{".tar.gz", ".tgz"}, c: codecs[0]},
+	{exts: []string{".tar.bz2", ".tbz2"}, c: codecs[1]},
+	{exts: []string{".tar.zst"}, c: codecs[2]},
+}
+
+// archiveFormat returns the format of an archive, or nil if the file is not
+// an archive
+func archiveFormat(name string) *archive {
+	for _, a := range archives {
+		for _, ext := range a.exts {
+			if strings.HasSuffix(name, ext) {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+// A member is a file or directory stored in an archive
+type member struct {
+	name string
+	info fs.FileInfo
+}
+
+// An index lists the members of an archive, as it was when last modified
+type index struct {
+	modTime time.Time
+	size    int64
+	members []member
+}
+
+var (
+	indexLock sync.Mutex
+	indexes   = make(map[string]*index)
+)
+
+// dirInfo is the info of a directory of an archive which has no member of
+// its own
+type dirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (d dirInfo) Name() string       { return d.name }
+func (d dirInfo) Size() int64        { return 0 }
+func (d dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (d dirInfo) ModTime() time.Time { return d.modTime }
+func (d dirInfo) IsDir() bool        { return true }
+func (d dirInfo) Sys() any           { return nil }
+
+// archiveBackend holds the files stored in an archive
+type archiveBackend struct {
+	path string
+}
+
+func (b archiveBackend) pathError(op, name string, err error) error {
+	return &fs.PathError{Op: op, Path: b.path + Separator + name, Err: err}
+}
+
+// index returns the members of the archive, reading them again only if the
+// archive was modified
+func (b archiveBackend) index() (*index, error) {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return nil, err
+	}
+
+	indexLock.Lock()
+	defer indexLock.Unlock()
+	if idx, ok := indexes[b.path]; ok && idx.modTime.Equal(info.ModTime()) && idx.size == info.Size() {
+		return idx, nil
+	}
+
+	idx := &index{modTime: info.ModTime(), size: info.Size()}
+	err = b.walk(func(name string, info fs.FileInfo, r io.Reader) (bool, error) {
+		idx.members = append(idx.members, member{name, info})
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	indexes[b.path] = idx
+	return idx, nil
+}
+
+// walk calls fn for each member of the archive, with a reader of its
+// contents, until fn returns true or an error
+func (b archiveBackend) walk(fn func(name string, info fs.FileInfo, r io.Reader) (bool, error)) error {
+	a := archiveFormat(b.path)
+	if a.zip {
+		zr, err := zip.OpenReader(b.path)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			stop, err := fn(cleanInner(f.Name), f.FileInfo(), rc)
+			rc.Close()
+			if stop || err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(b.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if a.c != nil {
+		cr, err := a.c.reader(f)
+		if err != nil {
+			return err
+		}
+		defer cr.Close()
+		r = cr
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if stop, err := fn(cleanInner(hdr.Name), hdr.FileInfo(), tr); stop || err != nil {
+			return err
+		}
+	}
+}
+
+func (b archiveBackend) ReadFile(name string) ([]byte, error) {
+	var data []byte
+	found := false
+	err := b.walk(func(n string, info fs.FileInfo, r io.Reader) (bool, error) {
+		if n != name {
+			return false, nil
+		}
+		if info.IsDir() {
+			return true, b.pathError("read", name, errIsDir)
+		}
+		found = true
+		var err error
+		data, err = io.ReadAll(r)
+		return true, err
+	})
+	if err == nil && !found {
+		err = b.pathError("read", name, fs.ErrNotExist)
+	}
+	return data, err
+}
+
+func (b archiveBackend) Stat(name string) (fs.FileInfo, error) {
+	idx, err := b.index()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return dirInfo{filepath.Base(b.path), idx.modTime}, nil
+	}
+	for _, m := range idx.members {
+		if m.name == name {
+			return m.info, nil
+		}
+	}
+	for _, m := range idx.members {
+		if strings.HasPrefix(m.name, name+"/") {
+			return dirInfo{path.Base(name), idx.modTime}, nil
+		}
+	}
+	return nil, b.pathError("stat", name, fs.ErrNotExist)
+}
+
+func (b archiveBackend) ReadDir(name string) ([]fs.DirEntry, error) {
+	idx, err := b.index()
+	if err != nil {
+		return nil, err
+	}
+	prefix := ""
+	if name != "" {
+		prefix = name + "/"
+	}
+
+	children := make(map[string]fs.FileInfo)
+	for _, m := range idx.members {
+		if !strings.HasPrefix(m.name, prefix) || m.name == name {
+			continue
+		}
+		child, rest, isDir := strings.Cut(m.name[len(prefix):], "/")
+		if !isDir || rest == "" {
+			children[child] = m.info
+		} else if _, ok := children[child]; !ok {
+			children[child] = dirInfo{child, idx.modTime}
+		}
+	}
+	if len(children) == 0 {
+		if info, err := b.Stat(name); err != nil {
+			return nil, err
+		} else if !info.IsDir() {
+			return nil, b.pathError("readdir", name, errors.New("not a directory"))
+		}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, info := range children {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// WriteFile rewrites the archive with the new contents of the file, which
+// keeps its place in the archive or is added at its end. The archive is
+// created if it does not exist
+func (b archiveBackend) WriteFile(name string, data []byte) error {
+	if name == "" {
+		return b.pathError("write", name, errIsDir)
+	}
+	if info, err := b.Stat(name); err == nil && info.IsDir() {
+		return b.pathError("write", name, errIsDir)
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var buf bytes.Buffer
+	var err error
+	if archiveFormat(b.path).zip {
+		err = b.writeZip(&buf, name, data)
+	} else {
+		err = b.writeTar(&buf, name, data)
+	}
+	if err != nil {
+		return err
+	}
+	return util.SafeWrite(b.path, buf.Bytes(), false)
+}
+
+func (b archiveBackend) writeZip(w io.Writer, name string, data []byte) error {
+	// the times are stored with a precision of a second
+	now := time.Now().Truncate(time.Second)
+	zw := zip.NewWriter(w)
+	write := func(hdr *zip.FileHeader) error {
+		hdr.Modified = now
+		fw, err := zw.CreateHeader(hdr)
+		if err == nil {
+			_, err = fw.Write(data)
+		}
+		return err
+	}
+
+	written := false
+	zr, err := zip.OpenReader(b.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	} else if err == nil {
+		defer zr.Close()
+		if err := zw.SetComment(zr.Comment); err != nil {
+			return err
+		}
+		for _, f := range zr.File {
+			if cleanInner(f.Name) != name {
+				err = zw.Copy(f)
+			} else {
+				written = true
+				err = write(&zip.FileHeader{
+					Name:           f.Name,
+					Comment:        f.Comment,
+					Method:         f.Method,
+					CreatorVersion: f.CreatorVersion,
+					ExternalAttrs:  f.ExternalAttrs,
+				})
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if !written {
+		hdr := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		hdr.SetMode(0644)
+		if err := write(hdr); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (b archiveBackend) writeTar(w io.Writer, name string, data []byte) error {
+	now := time.Now().Truncate(time.Second)
+	c := archiveFormat(b.path).c
+	if c != nil {
+		cw, err := c.writer(w)
+		if err != nil {
+			return err
+		}
+		w = cw
+	}
+	tw := tar.NewWriter(w)
+	write := func(hdr *tar.Header) error {
+		hdr.Size = int64(len(data))
+		hdr.ModTime = now
+		hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	written := false
+	err := b.walk(func(n string, info fs.FileInfo, r io.Reader) (bool, error) {
+		hdr := *info.Sys().(*tar.Header)
+		if n == name {
+			written = true
+			return false, write(&hdr)
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			return false, err
+		}
+		_, err := io.Copy(tw, r)
+		return false, err
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if !written {
+		err := write(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644})
+		if err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if cw, ok := w.(io.WriteCloser); ok {
+		return cw.Close()
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package vfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/dsnet/compress/bzip2"
+	"github.com/helmutkemper/micro/v2/internal/util"
+	"github.com/klauspost/compress/zstd"
+)
+
+// A codec compresses and decompresses the files with a certain extension
+type codec struct {
+	ext    string
+	reader func(r io.Reader) (io.ReadCloser, error)
+	writer func(w io.Writer) (io.WriteCloser, error)
+}
+
+var codecs = []*codec{
+	{
+		ext: ".gz",
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+		writer: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+	},
+	{
+		ext: ".bz2",
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			return bzip2.NewReader(r, nil)
+		},
+		writer: func(w io.Writer) (io.WriteCloser, error) {
+			return bzip2.NewWriter(w, &bzip2.WriterConfig{Level: bzip2.DefaultCompression})
+		},
+	},
+	{
+		ext: ".zst",
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return d.IOReadCloser(), nil
+		},
+		writer: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		},
+	},
+}
+
+// compression returns the codec of a compressed file, or nil
+func compression(name string) *codec {
+	for _, c := range codecs {
+		if strings.HasSuffix(name, c.ext) {
+			return c
+		}
+	}
+	return nil
+}
+
+// decompress reads all the data compressed by c from r
+func (c *codec) decompress(r io.Reader) ([]byte, error) {
+	cr, err := c.reader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer cr.Close()
+	return io.ReadAll(cr)
+}
+
+// compress returns data compressed by c
+func (c *codec) compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	cw, err := c.writer(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cw.Write(data); err != nil {
+		cw.Close()
+		return nil, err
+	}
+	if err := cw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressedBackend holds the files compressed by a codec, which are
+// decompressed when read and compressed again when written
+type compressedBackend struct {
+	c *codec
+}
+
+func (b compressedBackend) ReadFile(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return b.c.decompress(f)
+}
+
+func (b compressedBackend) WriteFile(name string, data []byte) error {
+	compressed, err := b.c.compress(data)
+	if err != nil {
+		return err
+	}
+	return util.SafeWrite(name, compressed, false)
+}
+
+func (b compressedBackend) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (b compressedBackend) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
@@ -0,0 +1,383 @@
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/util"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sftpScheme starts the paths of the files opened over SFTP, as in
+// sftp://user@host:port/path
+const sftpScheme = "sftp://"
+
+// KnownHostsFiles are the files listing the keys of the known SSH hosts.
+// Micro refuses to connect to the hosts which are not listed
+var KnownHostsFiles = []string{"~/.ssh/known_hosts"}
+
+// IdentityFiles are the private keys tried to log in to SSH hosts, after the
+// keys of the SSH agent. Keys protected by a passphrase must be added to the
+// agent
+var IdentityFiles = []string{"~/.ssh/id_ed25519", "~/.ssh/id_ecdsa", "~/.ssh/id_rsa"}
+
+// SFTPTimeout is the longest time micro waits for a host, to connect to it
+// or for an operation on a remote file
+var SFTPTimeout = 10 * time.Second
+
+// statTTL is how long the info of a remote file is cached, since the buffers
+// check whether their file was modified for every event
+const statTTL = 2 * time.Second
+
+type cachedStat struct {
+	info fs.FileInfo
+	err  error
+	time time.Time
+}
+
+// An sftpConn is the connection to an SSH host and its SFTP session
+type sftpConn struct {
+	ssh  *ssh.Client
+	sftp *sftp.Client
+}
+
+// close closes the SSH connection first, since closing the SFTP session waits
+// for the host to answer
+func (c sftpConn) close() {
+	c.ssh.Close()
+	c.sftp.Close()
+}
+
+var (
+	sftpLock  sync.Mutex
+	sftpConns = make(map[string]sftpConn)
+
+	statLock sync.Mutex
+	// statCache is the info of the remote files, and statPending the files
+	// whose info is being read in the background
+	statCache   = make(map[string]cachedStat)
+	statPending = make(map[string]bool)
+)
+
+// errStatPending is returned by statNoWait for the files whose info is not
+// known yet
+var errStatPending = errors.New("The info of the file is being read")
+
+// sftpBackend holds the files of an SSH host
+type sftpBackend struct {
+	user string
+	addr string
+	// err is the error of parsing the path, if any
+	err error
+}
+
+// IsRemote returns true if the path is the path of a file on another host
+func IsRemote(name string) bool {
+	return strings.HasPrefix(name, sftpScheme)
+}
+
+// splitRemote returns the backend of the host of a remote path, and the path
+// of the file on this host
+func splitRemote(name string) (sftpBackend, string) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return sftpBackend{err: err}, name
+	}
+	if u.Hostname() == "" {
+		return sftpBackend{err: errors.New("Missing host in " + name)}, name
+	}
+
+	b := sftpBackend{addr: u.Host}
+	if u.Port() == "" {
+		b.addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+	if u.User != nil {
+		b.user = u.User.Username()
+	} else if cur, err := user.Current(); err == nil {
+		b.user = cur.Username
+	}
+	return b, path.Clean("/" + u.Path)
+}
+
+func (b sftpBackend) key() string {
+	return b.user + "@" + b.addr
+}
+
+// hostKeyCallback verifies the keys of the hosts against KnownHostsFiles
+func hostKeyCallback() (ssh.HostKeyCallback, error) {
+	var files []string
+	for _, f := range KnownHostsFiles {
+		f, err := util.ReplaceHome(f)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(f); err == nil {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("No known_hosts file to verify the host key")
+	}
+	check, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			if len(keyErr.Want) == 0 {
+				return fmt.Errorf("Unknown host key for %s, add it to known_hosts with ssh-keyscan", hostname)
+			}
+			return fmt.Errorf("The host key of %s does not match known_hosts: someone may be impersonating the host", hostname)
+		}
+		return err
+	}, nil
+}
+
+// authMethods returns the keys of the SSH agent and of IdentityFiles, and a
+// function closing the connection to the agent
+func authMethods() ([]ssh.AuthMethod, func()) {
+	var methods []ssh.AuthMethod
+	done := func() {}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+			done = func() { conn.Close() }
+		}
+	}
+
+	var signers []ssh.Signer
+	for _, f := range IdentityFiles {
+		f, err := util.ReplaceHome(f)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(data); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	return methods, done
+}
+
+// client returns the connection to the host, connecting to it if needed
+func (b sftpBackend) client() (sftpConn, error) {
+	if b.err != nil {
+		return sftpConn{}, b.err
+	}
+
+	sftpLock.Lock()
+	defer sftpLock.Unlock()
+	if c, ok := sftpConns[b.key()]; ok {
+		return c, nil
+	}
+
+	callback, err := hostKeyCallback()
+	if err != nil {
+		return sftpConn{}, err
+	}
+	conn, err := net.DialTimeout("tcp", b.addr, SFTPTimeout)
+	if err != nil {
+		return sftpConn{}, err
+	}
+	// the handshakes fail if the host does not answer in time
+	conn.SetDeadline(time.Now().Add(SFTPTimeout))
+	auth, done := authMethods()
+	sc, chans, reqs, err := ssh.NewClientConn(conn, b.addr, &ssh.ClientConfig{
+		User:            b.user,
+		Auth:            auth,
+		HostKeyCallback: callback,
+	})
+	done()
+	if err != nil {
+		conn.Close()
+		return sftpConn{}, err
+	}
+	c := sftpConn{ssh: ssh.NewClient(sc, chans, reqs)}
+	if c.sftp, err = sftp.NewClient(c.ssh); err != nil {
+		c.ssh.Close()
+		return sftpConn{}, err
+	}
+	conn.SetDeadline(time.Time{})
+	sftpConns[b.key()] = c
+	return c, nil
+}
+
+// do calls fn with the client of the host, connecting again once if the
+// connection was lost. The connection is closed if fn takes longer than
+// SFTPTimeout, which makes fn fail
+func (b sftpBackend) do(fn func(c *sftp.Client) error) error {
+	for try := 0; ; try++ {
+		c, err := b.client()
+		if err != nil {
+			return err
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- fn(c.sftp)
+		}()
+		timer := time.NewTimer(SFTPTimeout)
+		select {
+		case err = <-done:
+			timer.Stop()
+		case <-timer.C:
+			b.disconnect()
+			<-done
+			return fmt.Errorf("%s: %w", b.addr, os.ErrDeadlineExceeded)
+		}
+
+		if try > 0 || !(errors.Is(err, sftp.ErrSSHFxConnectionLost) || errors.Is(err, io.EOF)) {
+			return err
+		}
+		b.disconnect()
+	}
+}
+
+// disconnect closes the connection to the host
+func (b sftpBackend) disconnect() {
+	sftpLock.Lock()
+	defer sftpLock.Unlock()
+	if c, ok := sftpConns[b.key()]; ok {
+		c.close()
+		delete(sftpConns, b.key())
+	}
+}
+
+func (b sftpBackend) ReadFile(name string) ([]byte, error) {
+	var data []byte
+	err := b.do(func(c *sftp.Client) error {
+		f, err := c.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		data, err = io.ReadAll(f)
+		return err
+	})
+	return data, err
+}
+
+// WriteFile writes the file to a temporary file on the host, which replaces
+// the file once complete, with the permissions of the file
+func (b sftpBackend) WriteFile(name string, data []byte) error {
+	statLock.Lock()
+	delete(statCache, b.key()+name)
+	statLock.Unlock()
+
+	tmp := path.Join(path.Dir(name), "."+path.Base(name)+util.BackupSuffix)
+	return b.do(func(c *sftp.Client) error {
+		mode := fs.FileMode(0644)
+		if info, err := c.Stat(name); err == nil {
+			mode = info.Mode().Perm()
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		f, err := c.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		if err == nil {
+			err = f.Chmod(mode)
+		}
+		if err2 := f.Close(); err == nil {
+			err = err2
+		}
+		if err == nil {
+			if _, ok := c.HasExtension("posix-rename@openssh.com"); ok {
+				err = c.PosixRename(tmp, name)
+			} else {
+				c.Remove(name)
+				err = c.Rename(tmp, name)
+			}
+		}
+		if err != nil {
+			c.Remove(tmp)
+		}
+		return err
+	})
+}
+
+func (b sftpBackend) Stat(name string) (fs.FileInfo, error) {
+	key := b.key() + name
+	statLock.Lock()
+	cached, ok := statCache[key]
+	statLock.Unlock()
+	if ok && time.Since(cached.time) < statTTL {
+		return cached.info, cached.err
+	}
+
+	var info fs.FileInfo
+	err := b.do(func(c *sftp.Client) error {
+		var err error
+		info, err = c.Stat(name)
+		return err
+	})
+	if b.err == nil {
+		statLock.Lock()
+		statCache[key] = cachedStat{info, err, time.Now()}
+		statLock.Unlock()
+	}
+	return info, err
+}
+
+// statNoWait returns the cached info of a file, and reads it again in the
+// background when it is outdated. It returns errStatPending if the info has
+// not been read yet
+func (b sftpBackend) statNoWait(name string) (fs.FileInfo, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	key := b.key() + name
+	statLock.Lock()
+	defer statLock.Unlock()
+	cached, ok := statCache[key]
+	if (!ok || time.Since(cached.time) >= statTTL) && !statPending[key] {
+		statPending[key] = true
+		go func() {
+			b.Stat(name)
+			statLock.Lock()
+			delete(statPending, key)
+			statLock.Unlock()
+		}()
+	}
+	if !ok {
+		return nil, errStatPending
+	}
+	return cached.info, cached.err
+}
+
+func (b sftpBackend) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	err := b.do(func(c *sftp.Client) error {
+		infos, err := c.ReadDir(name)
+		for _, info := range infos {
+			entries = append(entries, fs.FileInfoToDirEntry(info))
+		}
+		return err
+	})
+	return entries, err
+}
@@ -0,0 +1,212 @@
+package vfs
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startSFTPServer starts an SSH server serving the local files over SFTP,
+// and lets the client log in to it. It returns the address of the server
+func startSFTPServer(t *testing.T) string {
+	dir := t.TempDir()
+	signer := func() ssh.Signer {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := ssh.NewSignerFromKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	hostKey := signer()
+
+	_, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientSigner, _ := ssh.NewSignerFromKey(clientKey)
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(identity, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(clientSigner.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTP(nc, config)
+		}
+	}()
+	addr := ln.Addr().String()
+
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey.PublicKey())
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	oldKnownHosts, oldIdentities := KnownHostsFiles, IdentityFiles
+	KnownHostsFiles, IdentityFiles = []string{knownHosts}, []string{identity}
+	t.Cleanup(func() {
+		KnownHostsFiles, IdentityFiles = oldKnownHosts, oldIdentities
+		b, _ := splitRemote(sftpScheme + "tester@" + addr)
+		b.disconnect()
+		ln.Close()
+	})
+	return addr
+}
+
+// sftpStall makes the test server stop answering
+var sftpStall atomic.Bool
+
+// stallingChannel is a channel whose reads wait while sftpStall is set
+type stallingChannel struct {
+	ssh.Channel
+}
+
+func (c stallingChannel) Read(p []byte) (int, error) {
+	n, err := c.Channel.Read(p)
+	for sftpStall.Load() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return n, err
+}
+
+func serveSFTP(nc net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(nc, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "")
+			continue
+		}
+		ch, reqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range reqs {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if ok {
+					if server, err := sftp.NewServer(stallingChannel{ch}); err == nil {
+						server.Serve()
+						server.Close()
+					}
+					ch.Close()
+				}
+			}
+		}()
+	}
+}
+
+func TestSFTP(t *testing.T) {
+	addr := startSFTPServer(t)
+	dir := t.TempDir()
+	name := sftpScheme + "tester@" + addr + dir + "/file.txt"
+
+	assert.True(t, IsVirtual(name))
+	assert.Equal(t, "", HostPath(name))
+
+	_, err := Stat(name)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("a"), 0640))
+	assert.NoError(t, WriteFile(name, []byte("hello\n")))
+	data, err := os.ReadFile(filepath.Join(dir, "file.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(data))
+
+	info, err := Stat(name)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	data, err = ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(data))
+
+	entries, err := ReadDir(sftpScheme + "tester@" + addr + dir + "/")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "file.txt", entries[0].Name())
+	}
+}
+
+func TestSFTPUnknownHost(t *testing.T) {
+	addr := startSFTPServer(t)
+	other := filepath.Join(t.TempDir(), "known_hosts")
+	assert.NoError(t, os.WriteFile(other, nil, 0600))
+	KnownHostsFiles = []string{other}
+
+	_, err := ReadFile(sftpScheme + "tester@" + addr + "/etc/hostname")
+	assert.ErrorContains(t, err, "Unknown host key")
+}
+
+func TestSFTPTimeout(t *testing.T) {
+	addr := startSFTPServer(t)
+	dir := t.TempDir()
+	name := sftpScheme + "tester@" + addr + dir + "/file.txt"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello\n"), 0644))
+	info, err := os.Stat(filepath.Join(dir, "file.txt"))
+	assert.NoError(t, err)
+
+	// the modification time is read in the background
+	_, err = ModTimeNoWait(name)
+	assert.ErrorIs(t, err, errStatPending)
+	assert.Eventually(t, func() bool {
+		modTime, err := ModTimeNoWait(name)
+		return err == nil && modTime.Equal(info.ModTime().Truncate(time.Second))
+	}, time.Second, 10*time.Millisecond)
+
+	timeout := SFTPTimeout
+	SFTPTimeout = 100 * time.Millisecond
+	defer func() { SFTPTimeout = timeout }()
+	sftpStall.Store(true)
+	_, err = ReadFile(name)
+	sftpStall.Store(false)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	// the host is connected to again
+	data, err := ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", string(data))
+}
@@ -0,0 +1,211 @@
+// Package vfs lets micro open and save files which are not plain files on
+// disk: files inside .zip and .tar archives, written as archive.zip//dir/file,
+// files compressed with gzip, bzip2 or zstd, and files on other hosts opened
+// over SFTP, written as sftp://user@host/path
+package vfs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/helmutkemper/micro/v2/internal/util"
+)
+
+// Separator separates the path of an archive from the path of a file
+// inside it
+const Separator = "//"
+
+// A Backend is a filesystem holding the files edited by micro
+type Backend interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte) error
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+}
+
+// osBackend is the filesystem of the OS
+type osBackend struct{}
+
+func (osBackend) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (osBackend) WriteFile(name string, data []byte) error {
+	return util.SafeWrite(name, data, false)
+}
+
+func (osBackend) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osBackend) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+// Split splits a path such as archive.zip//dir/file into the path of the
+// archive and the path of the file inside it. ok is false if the path is
+// not inside an archive
+func Split(name string) (archive, inner string, ok bool) {
+	if IsRemote(name) {
+		return name, "", false
+	}
+	for i := 0; i < len(name); {
+		j := strings.Index(name[i:], Separator)
+		if j < 0 {
+			break
+		}
+		j += i
+		if archiveFormat(name[:j]) != nil {
+			return name[:j], cleanInner(name[j+len(Separator):]), true
+		}
+		i = j + 1
+	}
+	return name, "", false
+}
+
+// cleanInner returns the canonical form of a path inside an archive, which
+// is empty for the root of the archive
+func cleanInner(name string) string {
+	name = path.Clean("/" + filepath.ToSlash(name))
+	return strings.TrimPrefix(name, "/")
+}
+
+// Resolve returns the backend holding the file with the given path and the
+// name of the file for this backend
+func Resolve(name string) (Backend, string) {
+	if IsRemote(name) {
+		return splitRemote(name)
+	}
+	if archive, inner, ok := Split(name); ok {
+		return archiveBackend{archive}, inner
+	}
+	if c := compression(name); c != nil {
+		return compressedBackend{c}, name
+	}
+	return osBackend{}, name
+}
+
+// IsVirtual returns true if the file is not a plain file on disk, and must
+// be read and written through this package
+func IsVirtual(name string) bool {
+	b, _ := Resolve(name)
+	_, ok := b.(osBackend)
+	return !ok
+}
+
+// HostPath returns the path of the file on disk holding the given file,
+// that is the archive or the compressed file, or an empty string for a
+// remote file
+func HostPath(name string) string {
+	if IsRemote(name) {
+		return ""
+	}
+	archive, _, _ := Split(name)
+	return archive
+}
+
+// ContentName returns the name to use to detect the filetype of a file,
+// without the extension of its compression
+func ContentName(name string) string {
+	if _, inner, ok := Split(name); ok {
+		name = inner
+	}
+	if c := compression(name); c != nil {
+		return strings.TrimSuffix(name, c.ext)
+	}
+	return name
+}
+
+// Abs returns the absolute path of a file, keeping the separator between an
+// archive and the path inside it
+func Abs(name string) (string, error) {
+	if IsRemote(name) {
+		return name, nil
+	}
+	archive, inner, ok := Split(name)
+	abs, err := filepath.Abs(archive)
+	if err != nil || !ok {
+		return abs, err
+	}
+	return abs + Separator + inner, nil
+}
+
+// ReadFile reads the contents of a file
+func ReadFile(name string) ([]byte, error) {
+	b, n := Resolve(name)
+	return b.ReadFile(n)
+}
+
+// WriteFile replaces the contents of a file, creating it if needed
+func WriteFile(name string, data []byte) error {
+	b, n := Resolve(name)
+	return b.WriteFile(n, data)
+}
+
+// Stat returns the info of a file
+func Stat(name string) (fs.FileInfo, error) {
+	b, n := Resolve(name)
+	return b.Stat(n)
+}
+
+// ReadDir returns the entries of a directory
+func ReadDir(name string) ([]fs.DirEntry, error) {
+	b, n := Resolve(name)
+	return b.ReadDir(n)
+}
+
+// Open opens a file for reading, and returns its size. Plain files are
+// streamed, other files are read at once
+func Open(name string) (io.ReadCloser, int64, error) {
+	if !IsVirtual(name) {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, 0, err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		return f, info.Size(), nil
+	}
+	data, err := ReadFile(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+}
+
+// ModTime returns the modification time of a file, or the current time if
+// there was an error
+func ModTime(name string) (time.Time, error) {
+	info, err := Stat(name)
+	if err != nil {
+		return time.Now(), err
+	}
+	return info.ModTime(), nil
+}
+
+// ModTimeNoWait is like ModTime, but does not wait for the host of a remote
+// file: it returns the last known modification time of the file, which is
+// read again in the background when it is outdated
+func ModTimeNoWait(name string) (time.Time, error) {
+	b, n := Resolve(name)
+	var info fs.FileInfo
+	var err error
+	if sb, ok := b.(sftpBackend); ok {
+		info, err = sb.statNoWait(n)
+	} else {
+		info, err = b.Stat(n)
+	}
+	if err != nil {
+		return time.Now(), err
+	}
+	return info.ModTime(), nil
+}
@@ -0,0 +1,67 @@
+package vfs
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	archive, inner, ok := Split("a/b.zip//dir/file.txt")
+	assert.True(t, ok)
+	assert.Equal(t, "a/b.zip", archive)
+	assert.Equal(t, "dir/file.txt", inner)
+
+	archive, inner, ok = Split("b.tar.gz//")
+	assert.True(t, ok)
+	assert.Equal(t, "b.tar.gz", archive)
+	assert.Equal(t, "", inner)
+
+	_, _, ok = Split("a//b.txt")
+	assert.False(t, ok)
+	assert.False(t, IsVirtual("file.txt"))
+	assert.True(t, IsVirtual("file.txt.zst"))
+	assert.Equal(t, "main.go", ContentName("src.zip//main.go.gz"))
+	assert.Equal(t, "b.zip", HostPath("b.zip//c"))
+}
+
+func TestCompressed(t *testing.T) {
+	for _, c := range codecs {
+		name := filepath.Join(t.TempDir(), "file.txt"+c.ext)
+		assert.NoError(t, WriteFile(name, []byte("hello\n")))
+		data, err := ReadFile(name)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello\n", string(data), c.ext)
+	}
+}
+
+func TestArchives(t *testing.T) {
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tar.zst"} {
+		name := filepath.Join(t.TempDir(), "archive"+ext)
+		assert.NoError(t, WriteFile(name+"//dir/a.txt", []byte("a")), ext)
+		assert.NoError(t, WriteFile(name+"//b.txt", []byte("b")), ext)
+		assert.NoError(t, WriteFile(name+"//dir/a.txt", []byte("new a")), ext)
+
+		data, err := ReadFile(name + "//dir/a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, "new a", string(data), ext)
+
+		entries, err := ReadDir(name + "//")
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 2, ext) {
+			assert.Equal(t, "b.txt", entries[0].Name())
+			assert.Equal(t, "dir", entries[1].Name())
+			assert.True(t, entries[1].IsDir())
+		}
+
+		info, err := Stat(name + "//dir/a.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), info.Size())
+		_, err = Stat(name + "//missing.txt")
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = ReadFile(name + "//dir")
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,886 @@
+package highlight
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// maxGrammarRules limits the number of rules generated from a grammar.
+// Grammars include the same repository items from many places, and since
+// micro syntax files have no repository every include is copied
+const maxGrammarRules = 5000
+
+// scopeGroups maps TextMate scopes to micro highlight groups. The longest
+// matching scope prefix wins, and an empty group means the scope is not
+// highlighted
+var scopeGroups = map[string]string{
+	"comment":                           "comment",
+	"comment.block.documentation":       "comment",
+	"constant":                          "constant",
+	"constant.character":                "constant.string.char",
+	"constant.character.escape":         "constant.specialChar",
+	"constant.language":                 "constant.bool",
+	"constant.numeric":                  "constant.number",
+	"constant.other.placeholder":        "constant.specialChar",
+	"entity.name":                       "identifier",
+	"entity.name.class":                 "type",
+	"entity.name.function":              "identifier",
+	"entity.name.function.preprocessor": "preproc",
+	"entity.name.section":               "special",
+	"entity.name.tag":                   "symbol.tag",
+	"entity.name.type":                  "type",
+	"entity.other.attribute-name":       "special",
+	"entity.other.inherited-class":      "type",
+	"invalid":                           "error",
+	"keyword":                           "statement",
+	"keyword.control.directive":         "preproc",
+	"keyword.operator":                  "symbol.operator",
+	"keyword.other.directive":           "preproc",
+	"keyword.other.todo":                "todo",
+	"markup.bold":                       "special",
+	"markup.changed":                    "diff-modified",
+	"markup.deleted":                    "diff-deleted",
+	"markup.heading":                    "special",
+	"markup.inline.raw":                 "constant",
+	"markup.inserted":                   "diff-added",
+	"markup.italic":                     "special",
+	"markup.list":                       "special",
+	"markup.quote":                      "comment",
+	"markup.raw":                        "constant",
+	"markup.underline":                  "underlined",
+	"markup.underline.link":             "underlined.url",
+	"meta.diff.header":                  "preproc",
+	"meta.diff.range":                   "preproc",
+	"meta.preprocessor":                 "preproc",
+	"punctuation.definition.comment":    "comment",
+	"punctuation.definition.string":     "constant.string",
+	"punctuation.definition.tag":        "symbol.tag",
+	"punctuation.section.braces":        "symbol.brackets",
+	"punctuation.section.brackets":      "symbol.brackets",
+	"punctuation.section.parens":        "symbol.brackets",
+	"storage":                           "type",
+	"storage.modifier":                  "type.keyword",
+	"storage.type":                      "type",
+	"string":                            "constant.string",
+	"string.regexp":                     "constant.string",
+	"string.unquoted.heredoc":           "constant.string",
+	"support.class":                     "type",
+	"support.constant":                  "constant",
+	"support.function":                  "identifier",
+	"support.function.builtin":          "identifier",
+	"support.type":                      "type",
+	"support.variable":                  "identifier",
+	"variable.language":                 "special",
+	"variable.other.constant":           "constant",
+	"variable.parameter":                "identifier.var",
+}
+
+// ScopeGroup returns the micro highlight group for a TextMate scope, or
+// an empty string if the scope is not highlighted. If the scope contains
+// several space separated scopes, the first one with a group is used
+func ScopeGroup(scope string) string {
+	for _, s := range strings.Fields(scope) {
+		for s != "" {
+			if g, ok := scopeGroups[s]; ok {
+				return g
+			}
+			i := strings.LastIndexByte(s, '.')
+			if i < 0 {
+				break
+			}
+			s = s[:i]
+		}
+	}
+	return ""
+}
+
+// IsGrammarFile returns true if the given file name is a TextMate or
+// Sublime Text grammar which can be converted with ConvertGrammar
+func IsGrammarFile(name string) bool {
+	return GrammarName(name) != name
+}
+
+// GrammarName returns the name of a grammar file without its directory
+// and its extension
+func GrammarName(name string) string {
+	name = filepath.Base(name)
+	for _, ext := range []string{".tmLanguage.json", ".sublime-syntax"} {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// ConvertGrammar converts a TextMate grammar in JSON (.tmLanguage.json) or
+// a Sublime Text grammar (.sublime-syntax) to a micro syntax file. The
+// format is chosen from the file name.
+//
+// Micro syntax files are less expressive than these grammars, so the
+// conversion is approximate: captures are not highlighted separately,
+// lookbehinds and negative lookaheads are removed, positive lookaheads
+// become part of the match, and recursive includes are dropped. Each
+// construct which can't be converted exactly is reported in the returned
+// warnings.
+func ConvertGrammar(name string, data []byte) ([]byte, []string, error) {
+	c := &grammarConverter{warned: make(map[string]bool)}
+
+	var out yaml.MapSlice
+	var err error
+	if strings.HasSuffix(name, ".sublime-syntax") {
+		out, err = c.convertSublime(data)
+	} else {
+		out, err = c.convertTextMate(data)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	yamlData, err := yaml.Marshal(out)
+	if err != nil {
+		return nil, nil, err
+	}
+	return yamlData, c.warnings, nil
+}
+
+type grammarConverter struct {
+	warnings []string
+	warned   map[string]bool
+	nrules   int
+	full     bool
+
+	// TextMate
+	repository map[string]any
+	patterns   []any
+
+	// Sublime Text
+	contexts  map[string]any
+	variables map[string]string
+	prototype []any
+}
+
+func (c *grammarConverter) warn(format string, args ...any) {
+	w := fmt.Sprintf(format, args...)
+	if !c.warned[w] {
+		c.warned[w] = true
+		c.warnings = append(c.warnings, w)
+	}
+}
+
+func (c *grammarConverter) notes(where string, notes []string) {
+	for _, n := range notes {
+		c.warn("%s: %s", where, n)
+	}
+}
+
+// addRule counts a generated rule and returns false once the limit is
+// reached
+func (c *grammarConverter) addRule() bool {
+	if c.nrules >= maxGrammarRules {
+		if !c.full {
+			c.full = true
+			c.warn("the grammar generates more than %d rules, the remaining ones are dropped", maxGrammarRules)
+		}
+		return false
+	}
+	c.nrules++
+	return true
+}
+
+// header builds the filetype and detect sections of a syntax file
+func (c *grammarConverter) header(name, scope string, extensions []string, firstLine string) yaml.MapSlice {
+	filetype := strings.ToLower(strings.Join(strings.Fields(name), "-"))
+	if filetype == "" {
+		filetype = scope[strings.LastIndexByte(scope, '.')+1:]
+	}
+
+	var detect yaml.MapSlice
+	if len(extensions) > 0 {
+		exts := make([]string, len(extensions))
+		for i, e := range extensions {
+			exts[i] = regexp.QuoteMeta(e)
+		}
+		detect = append(detect, yaml.MapItem{
+			Key:   "filename",
+			Value: `(^|[/.])(` + strings.Join(exts, "|") + `)$`,
+		})
+	}
+	if firstLine != "" {
+		re, notes, err := convertRegex(firstLine, false)
+		c.notes("first line match", notes)
+		if err != nil {
+			c.warn("first line match: %v", err)
+		} else {
+			detect = append(detect, yaml.MapItem{Key: "header", Value: re})
+		}
+	}
+
+	return yaml.MapSlice{
+		{Key: "filetype", Value: filetype},
+		{Key: "detect", Value: detect},
+	}
+}
+
+// pattern converts a rule matching a single regular expression
+func (c *grammarConverter) pattern(where, match, scope string, captures map[string]any) []any {
+	group := ScopeGroup(scope)
+	if group == "" && len(captures) > 0 {
+		group = c.capturesGroup(where, captures)
+	}
+	if group == "" {
+		return nil
+	}
+	re, notes, err := convertRegex(match, false)
+	c.notes(where, notes)
+	if err != nil {
+		c.warn("%s: rule dropped: %v", where, err)
+		return nil
+	}
+	if re == "" || !c.addRule() {
+		return nil
+	}
+	return []any{yaml.MapSlice{{Key: group, Value: re}}}
+}
+
+// capturesGroup returns the group of a match which only has scopes for
+// its captures. Micro highlights whole matches, so this only works when
+// all the captures have the same group
+func (c *grammarConverter) capturesGroup(where string, captures map[string]any) string {
+	groups := make(map[string]bool)
+	for _, capture := range captures {
+		if m, ok := capture.(map[string]any); ok {
+			if name, ok := m["name"].(string); ok {
+				groups[ScopeGroup(name)] = true
+			} else if _, ok := m["patterns"]; ok {
+				c.warn("%s: patterns in captures are not supported", where)
+			}
+		}
+	}
+	if len(groups) == 1 {
+		for g := range groups {
+			return g
+		}
+	}
+	if len(groups) > 1 {
+		c.warn("%s: captures with different scopes are not supported, the whole match is not highlighted", where)
+	}
+	return ""
+}
+
+// region converts a rule with a start and an end
+func (c *grammarConverter) region(where, start, end, scope string, rules []any) []any {
+	group := ScopeGroup(scope)
+	if group == "" {
+		group = "default"
+	}
+	startRe, notes, err := convertRegex(start, false)
+	c.notes(where+" start", notes)
+	if err == nil && startRe == "" {
+		err = errors.New("empty start")
+	}
+	if err != nil {
+		c.warn("%s: region dropped: %v", where, err)
+		return nil
+	}
+	endRe, notes, err := convertRegex(end, true)
+	c.notes(where+" end", notes)
+	if err == nil && endRe == "" {
+		// the end was only a lookaround, end the region with the line
+		endRe = "$"
+	}
+	if err != nil {
+		c.warn("%s: region dropped: %v", where, err)
+		return nil
+	}
+	if !c.addRule() {
+		return nil
+	}
+	if rules == nil {
+		rules = []any{}
+	}
+	return []any{yaml.MapSlice{{Key: group, Value: yaml.MapSlice{
+		{Key: "start", Value: startRe},
+		{Key: "end", Value: endRe},
+		{Key: "rules", Value: rules},
+	}}}}
+}
+
+// include converts an include of another grammar to an include of the
+// micro filetype with the same name
+func (c *grammarConverter) include(scope string) []any {
+	ft := scope[strings.LastIndexByte(scope, '.')+1:]
+	if ft == "" {
+		return nil
+	}
+	c.warn("include of %s uses the micro filetype %q", scope, ft)
+	return []any{yaml.MapSlice{{Key: "include", Value: ft}}}
+}
+
+// order reorders converted rules for micro. A grammar uses the first rule
+// matching at a position, but micro gives precedence to the last pattern,
+// so the patterns are reversed. Regions keep their order, since micro uses
+// the first one on ties
+func order(rules []any) []any {
+	var patterns, regions []any
+	for _, r := range rules {
+		m := r.(yaml.MapSlice)
+		if _, ok := m[0].Value.(yaml.MapSlice); ok {
+			regions = append(regions, r)
+		} else {
+			patterns = append(patterns, r)
+		}
+	}
+	for i, j := 0, len(patterns)-1; i < j; i, j = i+1, j-1 {
+		patterns[i], patterns[j] = patterns[j], patterns[i]
+	}
+	return append(patterns, regions...)
+}
+
+func contains(stack []string, s string) bool {
+	for _, e := range stack {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *grammarConverter) convertTextMate(data []byte) (yaml.MapSlice, error) {
+	var g struct {
+		Name           string         `json:"name"`
+		ScopeName      string         `json:"scopeName"`
+		FileTypes      []string       `json:"fileTypes"`
+		FirstLineMatch string         `json:"firstLineMatch"`
+		Patterns       []any          `json:"patterns"`
+		Repository     map[string]any `json:"repository"`
+		Injections     map[string]any `json:"injections"`
+	}
+	if err := json.Unmarshal(data, &g); err != nil {
+		return nil, err
+	}
+	if g.Name == "" && g.ScopeName == "" {
+		return nil, errors.New("the grammar has no name and no scopeName")
+	}
+	if len(g.Injections) > 0 {
+		c.warn("injections are not supported")
+	}
+	c.repository = g.Repository
+	c.patterns = g.Patterns
+
+	out := c.header(g.Name, g.ScopeName, g.FileTypes, g.FirstLineMatch)
+	rules := c.tmPatterns("patterns", g.Patterns, []string{"$self"})
+	return append(out, yaml.MapItem{Key: "rules", Value: rules}), nil
+}
+
+func (c *grammarConverter) tmPatterns(where string, patterns []any, stack []string) []any {
+	var rules []any
+	for i, p := range patterns {
+		if rule, ok := p.(map[string]any); ok {
+			rules = append(rules, c.tmRule(fmt.Sprintf("%s[%d]", where, i), rule, stack)...)
+		}
+	}
+	return order(rules)
+}
+
+func (c *grammarConverter) tmRule(where string, rule map[string]any, stack []string) []any {
+	str := func(k string) string {
+		s, _ := rule[k].(string)
+		return s
+	}
+	captures := func(k string) map[string]any {
+		m, _ := rule[k].(map[string]any)
+		return m
+	}
+
+	if d, ok := rule["disabled"].(float64); ok && d != 0 {
+		return nil
+	}
+
+	if inc := str("include"); inc != "" {
+		switch {
+		case inc == "$self" || inc == "$base":
+			if contains(stack, "$self") {
+				c.warn("%s: recursive include of %s dropped", where, inc)
+				return nil
+			}
+			return c.tmPatterns(inc, c.patterns, append(stack, "$self"))
+		case strings.HasPrefix(inc, "#"):
+			key := inc[1:]
+			if contains(stack, key) {
+				c.warn("%s: recursive include of %s dropped", where, inc)
+				return nil
+			}
+			r, ok := c.repository[key].(map[string]any)
+			if !ok {
+				c.warn("%s: unknown repository item %s", where, inc)
+				return nil
+			}
+			return c.tmRule("repository."+key, r, append(stack, key))
+		default:
+			return c.include(strings.SplitN(inc, "#", 2)[0])
+		}
+	}
+
+	if match := str("match"); match != "" {
+		caps := captures("captures")
+		return c.pattern(where, match, str("name"), caps)
+	}
+
+	if begin := str("begin"); begin != "" {
+		if str("while") != "" {
+			c.warn("%s: begin/while rules are not supported", where)
+			return nil
+		}
+		end := str("end")
+		if end == "" {
+			c.warn("%s: region without end dropped", where)
+			return nil
+		}
+		if len(captures("beginCaptures"))+len(captures("endCaptures"))+len(captures("captures")) > 0 {
+			c.warn("%s: begin and end captures are highlighted with the region", where)
+		}
+		scope := str("name")
+		if scope == "" {
+			scope = str("contentName")
+		}
+		var rules []any
+		if p, ok := rule["patterns"].([]any); ok {
+			rules = c.tmPatterns(where+".patterns", p, stack)
+		}
+		return c.region(where, begin, end, scope, rules)
+	}
+
+	if p, ok := rule["patterns"].([]any); ok {
+		return c.tmPatterns(where+".patterns", p, stack)
+	}
+	return nil
+}
+
+// normalize converts the maps decoded by yaml.v2 to maps with string keys
+func normalize(v any) any {
+	switch v := v.(type) {
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case []any:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+	}
+	return v
+}
+
+var variableRegex = regexp.MustCompile(`\{\{(\w+)\}\}`)
+
+// expand replaces the variables of a Sublime Text regular expression
+func (c *grammarConverter) expand(re string) string {
+	for i := 0; i < 10 && strings.Contains(re, "{{"); i++ {
+		re = variableRegex.ReplaceAllStringFunc(re, func(v string) string {
+			name := v[2 : len(v)-2]
+			if val, ok := c.variables[name]; ok {
+				return val
+			}
+			c.warn("unknown variable %s", name)
+			return ""
+		})
+	}
+	return re
+}
+
+func (c *grammarConverter) convertSublime(data []byte) (yaml.MapSlice, error) {
+	var g struct {
+		Name           string            `yaml:"name"`
+		Scope          string            `yaml:"scope"`
+		FileExtensions []string          `yaml:"file_extensions"`
+		FirstLineMatch string            `yaml:"first_line_match"`
+		Variables      map[string]string `yaml:"variables"`
+		Contexts       map[string]any    `yaml:"contexts"`
+	}
+	// yaml.v2 only knows YAML 1.1 and rejects the directive which starts
+	// most grammars
+	if bytes.HasPrefix(data, []byte("%YAML")) {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+	if err := yaml.Unmarshal(data, &g); err != nil {
+		return nil, err
+	}
+	if g.Name == "" && g.Scope == "" {
+		return nil, errors.New("the grammar has no name and no scope")
+	}
+	if _, ok := g.Contexts["main"]; !ok {
+		return nil, errors.New("the grammar has no main context")
+	}
+	c.variables = g.Variables
+	c.contexts = make(map[string]any, len(g.Contexts))
+	for k, v := range g.Contexts {
+		c.contexts[k] = normalize(v)
+	}
+	c.prototype, _ = c.contexts["prototype"].([]any)
+
+	out := c.header(g.Name, g.Scope, g.FileExtensions, c.expand(g.FirstLineMatch))
+	main, _ := c.contexts["main"].([]any)
+	rules := c.sublContext("main", main, []string{"main"})
+	return append(out, yaml.MapItem{Key: "rules", Value: rules}), nil
+}
+
+// sublContext converts the items of the context name, which is on the
+// stack, with the prototype unless the context excludes it
+func (c *grammarConverter) sublContext(name string, items []any, stack []string) []any {
+	var rules []any
+	proto := name != "prototype" && len(c.prototype) > 0
+	for _, it := range items {
+		if m, ok := it.(map[string]any); ok {
+			if p, ok := m["meta_include_prototype"].(bool); ok && !p {
+				proto = false
+			}
+		}
+	}
+	if proto && !contains(stack, "prototype") {
+		rules = c.sublRules("prototype", c.prototype, append(stack, "prototype"))
+	}
+	return order(append(rules, c.sublRules(name, items, stack)...))
+}
+
+func (c *grammarConverter) sublRules(where string, items []any, stack []string) []any {
+	var rules []any
+	for i, it := range items {
+		item, ok := it.(map[string]any)
+		if !ok {
+			continue
+		}
+		w := fmt.Sprintf("%s[%d]", where, i)
+		str := func(k string) string {
+			s, _ := item[k].(string)
+			return s
+		}
+
+		if inc := str("include"); inc != "" {
+			if strings.HasPrefix(inc, "scope:") {
+				rules = append(rules, c.include(strings.SplitN(inc[len("scope:"):], "#", 2)[0])...)
+				continue
+			}
+			if contains(stack, inc) {
+				c.warn("%s: recursive include of %s dropped", w, inc)
+				continue
+			}
+			inner, ok := c.contexts[inc].([]any)
+			if !ok {
+				c.warn("%s: unknown context %s", w, inc)
+				continue
+			}
+			rules = append(rules, c.sublRules(inc, inner, append(stack, inc))...)
+			continue
+		}
+
+		match := str("match")
+		if match == "" {
+			continue
+		}
+		match = c.expand(match)
+		if _, ok := item["pop"]; ok {
+			// handled as the end of the region of this context
+			continue
+		}
+		if _, ok := item["embed"]; ok {
+			c.warn("%s: embed is not supported", w)
+			continue
+		}
+		if _, ok := item["branch"]; ok {
+			c.warn("%s: branches are not supported", w)
+			continue
+		}
+
+		target, ok := item["push"]
+		if !ok {
+			target, ok = item["set"]
+		}
+		if !ok {
+			caps, _ := item["captures"].(map[string]any)
+			rules = append(rules, c.sublCaptures(w, match, str("scope"), caps)...)
+			continue
+		}
+		rules = append(rules, c.sublPush(w, match, str("scope"), target, stack)...)
+	}
+	return rules
+}
+
+// sublCaptures converts a match whose captures are keyed by numbers
+func (c *grammarConverter) sublCaptures(where, match, scope string, captures map[string]any) []any {
+	caps := make(map[string]any, len(captures))
+	for k, v := range captures {
+		if s, ok := v.(string); ok {
+			caps[k] = map[string]any{"name": s}
+		}
+	}
+	return c.pattern(where, match, scope, caps)
+}
+
+// sublPush converts a match pushing a context to a region which ends with
+// the matches popping that context
+func (c *grammarConverter) sublPush(where, start, scope string, target any, stack []string) []any {
+	var name string
+	var items []any
+	switch t := target.(type) {
+	case string:
+		name = t
+		items, _ = c.contexts[t].([]any)
+	case []any:
+		if len(t) == 0 {
+			return nil
+		}
+		if s, ok := t[len(t)-1].(string); ok {
+			if len(t) > 1 {
+				c.warn("%s: only the last of several pushed contexts is used", where)
+			}
+			name = s
+			items, _ = c.contexts[s].([]any)
+		} else {
+			name = where + ".push"
+			items = t
+		}
+	}
+	if contains(stack, name) {
+		c.warn("%s: recursive push of %s dropped", where, name)
+		return nil
+	}
+
+	var ends []string
+	c.sublPops(items, &ends, []string{name})
+	if len(ends) == 0 {
+		c.warn("%s: context %s never pops, region dropped", where, name)
+		return nil
+	}
+	end := ends[0]
+	if len(ends) > 1 {
+		end = "(?:" + strings.Join(ends, ")|(?:") + ")"
+	}
+
+	for _, it := range items {
+		if m, ok := it.(map[string]any); ok {
+			if s, ok := m["meta_scope"].(string); ok {
+				scope = s
+			} else if s, ok := m["meta_content_scope"].(string); ok && scope == "" {
+				scope = s
+			}
+		}
+	}
+
+	rules := c.sublContext(name, items, append(stack, name))
+	return c.region(where, start, end, scope, rules)
+}
+
+// sublPops collects the matches which pop a context, including the ones of
+// the contexts it includes
+func (c *grammarConverter) sublPops(items []any, ends *[]string, stack []string) {
+	for _, it := range items {
+		item, ok := it.(map[string]any)
+		if !ok {
+			continue
+		}
+		if inc, ok := item["include"].(string); ok {
+			if inner, ok := c.contexts[inc].([]any); ok && !contains(stack, inc) {
+				c.sublPops(inner, ends, append(stack, inc))
+			}
+			continue
+		}
+		match, _ := item["match"].(string)
+		if pop, ok := item["pop"]; ok && pop != false && match != "" {
+			*ends = append(*ends, c.expand(match))
+		}
+	}
+}
+
+// convertRegex converts an Oniguruma regular expression, as used by
+// TextMate and Sublime Text, to Go syntax. Lookbehinds and negative
+// lookaheads are removed and positive lookaheads become normal groups.
+// Backreferences are only kept if backrefs is true, since micro only
+// supports them in region ends. An error is returned if the expression
+// can't be converted. The returned notes describe the approximations made
+func convertRegex(re string, backrefs bool) (string, []string, error) {
+	var out strings.Builder
+	var notes []string
+	note := func(n string) {
+		for _, m := range notes {
+			if m == n {
+				return
+			}
+		}
+		notes = append(notes, n)
+	}
+	extended := false
+	class := 0
+	quantifier := false
+
+	// skipGroup returns the index after the group starting at i
+	skipGroup := func(i int) int {
+		depth := 0
+		inClass := false
+		for ; i < len(re); i++ {
+			switch re[i] {
+			case '\\':
+				i++
+			case '[':
+				inClass = true
+			case ']':
+				inClass = false
+			case '(':
+				if !inClass {
+					depth++
+				}
+			case ')':
+				if !inClass {
+					depth--
+					if depth == 0 {
+						return i + 1
+					}
+				}
+			}
+		}
+		return i
+	}
+
+	for i := 0; i < len(re); i++ {
+		ch := re[i]
+		wasQuantifier := quantifier
+		quantifier = false
+
+		if class > 0 {
+			switch {
+			case ch == '\\' && i+1 < len(re):
+				i++
+				switch re[i] {
+				case 'h':
+					out.WriteString("0-9a-fA-F")
+				case 'e':
+					out.WriteString(`\x1b`)
+				case 'H':
+					return "", nil, errors.New(`\H in a character class is not supported`)
+				default:
+					out.WriteByte('\\')
+					out.WriteByte(re[i])
+				}
+			case ch == '[' && strings.HasPrefix(re[i:], "[:"):
+				end := strings.Index(re[i:], ":]")
+				if end < 0 {
+					return "", nil, errors.New("unterminated POSIX class")
+				}
+				out.WriteString(re[i : i+end+2])
+				i += end + 1
+			case ch == '[':
+				return "", nil, errors.New("nested character classes are not supported")
+			case ch == '&' && strings.HasPrefix(re[i:], "&&"):
+				return "", nil, errors.New("character class intersections are not supported")
+			case ch == ']':
+				class = 0
+				out.WriteByte(ch)
+			default:
+				out.WriteByte(ch)
+			}
+			continue
+		}
+
+		switch {
+		case extended && (ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'):
+			quantifier = wasQuantifier
+		case extended && ch == '#':
+			for i < len(re) && re[i] != '\n' {
+				i++
+			}
+			quantifier = wasQuantifier
+		case ch == '\\' && i+1 < len(re):
+			i++
+			switch e := re[i]; {
+			case e == 'h':
+				out.WriteString("[0-9a-fA-F]")
+			case e == 'H':
+				out.WriteString("[^0-9a-fA-F]")
+			case e == 'e':
+				out.WriteString(`\x1b`)
+			case e == 'Z':
+				out.WriteString("$")
+			case e == 'G':
+				// micro has no notion of the end of the previous match
+				note(`\G removed`)
+			case e >= '1' && e <= '9':
+				if !backrefs {
+					return "", nil, errors.New("backreferences are only supported in region ends")
+				}
+				out.WriteByte('\\')
+				out.WriteByte(e)
+			case e == 'k' || e == 'g':
+				return "", nil, fmt.Errorf(`\%c references are not supported`, e)
+			case e == 'K' || e == 'R' || e == 'X' || e == 'N' || e == 'O' || e == 'y' || e == 'Y':
+				return "", nil, fmt.Errorf(`\%c is not supported`, e)
+			default:
+				out.WriteByte('\\')
+				out.WriteByte(e)
+			}
+		case ch == '[':
+			class = 1
+			out.WriteByte(ch)
+			// a ] right after [ or [^ is a literal
+			if i+1 < len(re) && re[i+1] == '^' {
+				i++
+				out.WriteByte('^')
+			}
+			if i+1 < len(re) && re[i+1] == ']' {
+				i++
+				out.WriteString(`\]`)
+			}
+		case ch == '(' && strings.HasPrefix(re[i:], "(?x)"):
+			extended = true
+			i += 3
+		case ch == '(' && strings.HasPrefix(re[i:], "(?#"):
+			i = skipGroup(i) - 1
+		case ch == '(' && (strings.HasPrefix(re[i:], "(?!") || strings.HasPrefix(re[i:], "(?<=") || strings.HasPrefix(re[i:], "(?<!")):
+			if re[i+2] == '!' {
+				note("negative lookahead removed")
+			} else {
+				note("lookbehind removed")
+			}
+			i = skipGroup(i) - 1
+			// a quantifier on the removed group is removed too
+			if i+1 < len(re) && strings.IndexByte("*+?", re[i+1]) >= 0 {
+				i++
+			}
+		case ch == '(' && (strings.HasPrefix(re[i:], "(?=") || strings.HasPrefix(re[i:], "(?>")):
+			if re[i+2] == '=' {
+				note("lookahead made part of the match")
+			}
+			out.WriteString("(?:")
+			i += 2
+		case ch == '*' || ch == '+' || ch == '?' || ch == '}':
+			if wasQuantifier && ch == '+' {
+				// possessive quantifier
+				note("possessive quantifier made greedy")
+				break
+			}
+			out.WriteByte(ch)
+			quantifier = ch != '?' || !wasQuantifier
+		default:
+			out.WriteByte(ch)
+		}
+	}
+
+	result := out.String()
+	check := result
+	if backrefs {
+		check = backrefRegex.ReplaceAllString(check, "")
+	}
+	if _, err := regexp.Compile(check); err != nil {
+		return "", nil, err
+	}
+	return result, notes, nil
+}
@@ -0,0 +1,154 @@
+package highlight
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func convertTestGrammar(t *testing.T, name, grammar string) (*Def, *Header, []string) {
+	data, warnings, err := ConvertGrammar(name, []byte(grammar))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	header, err := MakeHeaderYaml(data)
+	assert.NoError(t, err)
+	f, err := ParseFile(data)
+	assert.NoError(t, err)
+	def, err := ParseDef(f, header)
+	assert.NoError(t, err, string(data))
+	return def, header, warnings
+}
+
+func TestScopeGroup(t *testing.T) {
+	assert.Equal(t, "comment", ScopeGroup("comment.line.double-slash.go"))
+	assert.Equal(t, "constant.number", ScopeGroup("constant.numeric.integer"))
+	assert.Equal(t, "constant.specialChar", ScopeGroup("constant.character.escape.c"))
+	assert.Equal(t, "statement", ScopeGroup("keyword.control.go"))
+	assert.Equal(t, "symbol.operator", ScopeGroup("keyword.operator.assignment"))
+	assert.Equal(t, "type", ScopeGroup("meta.type storage.type.go"))
+	assert.Equal(t, "", ScopeGroup("variable.other.readwrite"))
+	assert.Equal(t, "", ScopeGroup("meta.block"))
+}
+
+func TestConvertRegex(t *testing.T) {
+	re, notes, err := convertRegex(`(?<=\.)\h+(?=\()`, false)
+	assert.NoError(t, err)
+	assert.Equal(t, `[0-9a-fA-F]+(?:\()`, re)
+	assert.Len(t, notes, 2)
+
+	re, _, err = convertRegex("(?x) a  # comment\n [ ]b++", false)
+	assert.NoError(t, err)
+	assert.Equal(t, "a[ ]b+", re)
+
+	_, _, err = convertRegex(`(\w)\1`, false)
+	assert.Error(t, err)
+	re, _, err = convertRegex(`\1"`, true)
+	assert.NoError(t, err)
+	assert.Equal(t, `\1"`, re)
+
+	_, _, err = convertRegex(`[a-z&&[^x]]`, false)
+	assert.Error(t, err)
+}
+
+func TestConvertTextMate(t *testing.T) {
+	def, header, warnings := convertTestGrammar(t, "foo.tmLanguage.json", `{
+	"name": "Foo Lang",
+	"scopeName": "source.foo",
+	"fileTypes": ["foo", "Foofile"],
+	"firstLineMatch": "^#!.*\\bfoo\\b",
+	"patterns": [
+		{"include": "#comments"},
+		{"match": "\\b(if|else)\\b", "name": "keyword.control.foo"},
+		{"match": "\\b\\d+\\b", "name": "constant.numeric.foo"},
+		{"match": "\\b(?<!\\.)(\\w+)\\s*(?=\\()", "captures": {"1": {"name": "entity.name.function.foo"}}},
+		{
+			"begin": "\"", "end": "\"", "name": "string.quoted.double.foo",
+			"patterns": [{"match": "\\\\.", "name": "constant.character.escape.foo"}]
+		},
+		{"begin": "^\\s*>", "while": "^\\s*>", "name": "markup.quote"}
+	],
+	"repository": {
+		"comments": {
+			"patterns": [
+				{"match": "//.*$", "name": "comment.line.foo"},
+				{"begin": "/\\*", "end": "\\*/", "name": "comment.block.foo", "patterns": [{"include": "#comments"}]}
+			]
+		}
+	}
+}`)
+	assert.Equal(t, "foo-lang", header.FileType)
+	assert.True(t, header.MatchFileName("/src/main.foo"))
+	assert.True(t, header.MatchFileName("Foofile"))
+	assert.False(t, header.MatchFileName("main.bar"))
+	assert.True(t, header.MatchFileHeader([]byte("#!/usr/bin/env foo")))
+	assert.Contains(t, warnings, "patterns[5]: begin/while rules are not supported")
+	assert.Contains(t, warnings, "repository.comments.patterns[1].patterns[0]: recursive include of #comments dropped")
+
+	h := NewHighlighter(def)
+	matches := h.HighlightString(`if x 12 call("a\n") // c`)
+	assert.Equal(t, Groups["statement"], matches[0][0])
+	assert.Equal(t, Groups["constant.number"], matches[0][5])
+	assert.Equal(t, Groups["identifier"], matches[0][8])
+	assert.Equal(t, Groups["constant.string"], matches[0][13])
+	assert.Equal(t, Groups["constant.specialChar"], matches[0][15])
+	assert.Equal(t, Groups["comment"], matches[0][20])
+
+	h = NewHighlighter(def)
+	matches = h.HighlightString("/* if\n12 */ if")
+	assert.Equal(t, Groups["comment"], matches[1][0])
+	assert.Equal(t, Groups["statement"], matches[1][6])
+}
+
+func TestConvertSublime(t *testing.T) {
+	def, header, warnings := convertTestGrammar(t, "bar.sublime-syntax", `%YAML 1.2
+---
+name: Bar
+scope: source.bar
+file_extensions: [bar]
+variables:
+  ident: '[A-Za-z_]\w*'
+contexts:
+  prototype:
+    - match: '#.*$'
+      scope: comment.line.bar
+  main:
+    - match: '\b(fn)\s+({{ident}})'
+      captures:
+        1: storage.type.function.bar
+        2: entity.name.function.bar
+    - match: '\blet\b'
+      scope: keyword.other.bar
+    - match: "'"
+      push: string
+    - match: '<<(\w+)'
+      push: heredoc
+  string:
+    - meta_scope: string.quoted.single.bar
+    - meta_include_prototype: false
+    - match: '\\.'
+      scope: constant.character.escape.bar
+    - match: "'"
+      pop: true
+  heredoc:
+    - meta_scope: string.unquoted.heredoc.bar
+    - match: '^\1$'
+      pop: true
+`)
+	assert.Equal(t, "bar", header.FileType)
+	assert.True(t, header.MatchFileName("x.bar"))
+	assert.Contains(t, warnings, "main[0]: captures with different scopes are not supported, the whole match is not highlighted")
+
+	h := NewHighlighter(def)
+	matches := h.HighlightString(`let '#\n' # c`)
+	assert.Equal(t, Groups["statement"], matches[0][0])
+	assert.Equal(t, Groups["constant.string"], matches[0][4])
+	assert.Equal(t, Groups["constant.specialChar"], matches[0][6])
+	assert.Equal(t, Groups["constant.string"], matches[0][8])
+	assert.Equal(t, Groups["comment"], matches[0][10])
+
+	h = NewHighlighter(def)
+	matches = h.HighlightString("<<EOT\nEND\nEOT\nlet")
+	assert.Equal(t, Groups["constant.string"], matches[1][0])
+	assert.Equal(t, Groups["statement"], matches[3][0])
+}
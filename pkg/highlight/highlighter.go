@@ -101,6 +101,25 @@ func findIndex(regex *regexp.Regexp, skip *regexp.Regexp, str []byte) []int {
 	return []int{runePos(match[0], str), runePos(match[1], str)}
 }
 
+// startCaptures returns the submatches of the start of the region r in str,
+// if the end of r refers to them
+func startCaptures(r *region, str []byte) []string {
+	if r.endTemplate == "" {
+		return nil
+	}
+	if r.skip != nil {
+		str = r.skip.ReplaceAllFunc(str, func(match []byte) []byte {
+			return make([]byte, CharacterCount(match))
+		})
+	}
+	match := r.start.FindSubmatch(str)
+	captures := make([]string, len(match))
+	for i, m := range match {
+		captures[i] = string(m)
+	}
+	return captures
+}
+
 func findAllIndex(regex *regexp.Regexp, str []byte) [][]int {
 	matches := regex.FindAllIndex(str, -1)
 	for i, m := range matches {
@@ -146,6 +165,7 @@ func (h *Highlighter) highlightRegion(highlights LineMatch, start int, canMatchE
 		if !statesOnly {
 			highlights[start+firstLoc[0]] = firstRegion.limitGroup
 		}
+		firstRegion = firstRegion.instance(curRegion, startCaptures(firstRegion, line))
 		h.highlightEmptyRegion(highlights, start+firstLoc[1], canMatchEnd, lineNum, sliceStart(line, firstLoc[1]), statesOnly)
 		h.highlightRegion(highlights, start+firstLoc[1], canMatchEnd, lineNum, sliceStart(line, firstLoc[1]), firstRegion, statesOnly)
 		return highlights
@@ -228,6 +248,7 @@ func (h *Highlighter) highlightEmptyRegion(highlights LineMatch, start int, canM
 		if !statesOnly {
 			highlights[start+firstLoc[0]] = firstRegion.limitGroup
 		}
+		firstRegion = firstRegion.instance(nil, startCaptures(firstRegion, line))
 		h.highlightEmptyRegion(highlights, start, false, lineNum, sliceEnd(line, firstLoc[0]), statesOnly)
 		h.highlightRegion(highlights, start+firstLoc[1], canMatchEnd, lineNum, sliceStart(line, firstLoc[1]), firstRegion, statesOnly)
 		return highlights
@@ -287,6 +308,28 @@ func (h *Highlighter) HighlightString(input string) []LineMatch {
 	return lineMatches
 }
 
+// HighlightState returns the end of line state of a line given the state at
+// the end of the previous line. It only computes the state, which is much
+// cheaper than computing the matches
+func (h *Highlighter) HighlightState(lineN int, line []byte, prev State) State {
+	if lineN == 0 || prev == nil {
+		h.highlightEmptyRegion(nil, 0, true, lineN, line, true)
+	} else {
+		h.highlightRegion(nil, 0, true, lineN, line, prev, true)
+	}
+	return h.lastRegion
+}
+
+// HighlightLine returns the matches for a line given the state at the end of
+// the previous line
+func (h *Highlighter) HighlightLine(lineN int, line []byte, prev State) LineMatch {
+	highlights := make(LineMatch)
+	if lineN == 0 || prev == nil {
+		return h.highlightEmptyRegion(highlights, 0, true, lineN, line, false)
+	}
+	return h.highlightRegion(highlights, 0, true, lineN, line, prev, false)
+}
+
 // HighlightStates correctly sets all states for the buffer
 func (h *Highlighter) HighlightStates(input LineStates) {
 	for i := 0; ; i++ {
@@ -0,0 +1,83 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseTestDef(t *testing.T, syntax string) *Def {
+	f, err := ParseFile([]byte(syntax))
+	assert.NoError(t, err)
+	def, err := ParseDef(f, &Header{FileType: f.FileType})
+	assert.NoError(t, err)
+	return def
+}
+
+func TestBackrefRegion(t *testing.T) {
+	def := parseTestDef(t, `filetype: test
+rules:
+  - constant.string:
+      start: "\\[(=*)\\["
+      end: "\\]\\1\\]"
+      rules: []
+  - special:
+      start: "<<(\\w+)$"
+      end: "^\\1$"
+      rules:
+        - identifier: "\\$\\w+"
+`)
+	str := Groups["constant.string"]
+	special := Groups["special"]
+
+	h := NewHighlighter(def)
+	matches := h.HighlightString("a [==[ b ]] ]=] c\n]==] d")
+	assert.Equal(t, str, matches[0][2])
+	assert.Equal(t, str, matches[1][0])
+	assert.Equal(t, Group(0), matches[1][4])
+
+	h = NewHighlighter(def)
+	matches = h.HighlightString("cat <<EOF\nEND $x\nEOF\nEOF")
+	assert.Equal(t, special, matches[1][0])
+	assert.Equal(t, Groups["identifier"], matches[1][4])
+	assert.Equal(t, special, matches[2][0])
+	assert.Equal(t, Group(0), matches[3][0])
+
+	// the same delimiter gives the same state, so that rehighlighting can
+	// stop as soon as the states stop changing
+	h = NewHighlighter(def)
+	s1 := h.HighlightState(0, []byte("[=["), nil)
+	s2 := h.HighlightState(0, []byte("x [=["), nil)
+	s3 := h.HighlightState(0, []byte("[==["), nil)
+	assert.NotNil(t, s1)
+	assert.Equal(t, s1, s2)
+	assert.NotEqual(t, s1, s3)
+
+	// the delimiters typed one character at a time are not all kept
+	for i := range 2 * maxInstances {
+		h.HighlightState(0, []byte("["+strings.Repeat("=", i)+"["), nil)
+	}
+	assert.Len(t, def.rules.regions[0].instances, maxInstances)
+	assert.Len(t, def.rules.regions[0].instOrder, maxInstances)
+	// the recently used delimiters stay in the cache
+	s1 = h.HighlightState(0, []byte("[=["), nil)
+	assert.Equal(t, s1, h.HighlightState(0, []byte("[=["), nil))
+}
+
+func TestBackrefParse(t *testing.T) {
+	f, err := ParseFile([]byte(`filetype: test
+rules:
+  - constant.string:
+      start: "\\["
+      end: "\\]\\1"
+      rules: []
+`))
+	assert.NoError(t, err)
+	_, err = ParseDef(f, &Header{FileType: "test"})
+	assert.Error(t, err)
+
+	assert.True(t, hasBackrefs(`\]\1\]`))
+	assert.False(t, hasBackrefs(`\\1`))
+	assert.Equal(t, `\]=\.\]`, expandBackrefs(`\]\1\]`, []string{"", `=.`}))
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
+	"sync"
 
 	"gopkg.in/yaml.v2"
 )
@@ -78,6 +80,9 @@ type rules struct {
 // A region also has rules of its own that only apply when matching inside the
 // region and also rules from the above region do not match inside this region
 // Note that a region may contain more regions
+// The end regular expression may refer to the submatches of the start regular
+// expression with \1 to \9, for delimiters such as heredocs. Such a region is
+// instantiated with its end compiled for the captured text each time it starts
 type region struct {
 	group      Group
 	limitGroup Group
@@ -86,6 +91,108 @@ type region struct {
 	end        *regexp.Regexp
 	skip       *regexp.Regexp
 	rules      *rules
+
+	// endTemplate is the end regular expression if it has backreferences,
+	// in which case end is nil
+	endTemplate string
+	// instances caches the instances of the region so that the same
+	// captured text inside the same parent gives the same region, which
+	// keeps line states comparable. instOrder has their keys from the
+	// least recently used
+	instances map[regionKey]*region
+	instOrder []regionKey
+	instLock  sync.Mutex
+}
+
+// maxInstances is the number of instances of a region which are cached, so
+// that the delimiters typed one character at a time are not all kept. An
+// instance removed from the cache stays valid in the states which use it
+const maxInstances = 32
+
+type regionKey struct {
+	parent *region
+	end    string
+}
+
+// backrefRegex matches the backreferences in an end regular expression
+var backrefRegex = regexp.MustCompile(`\\(\\|[1-9])`)
+
+// hasBackrefs returns true if the given regular expression refers to
+// submatches of the start regular expression
+func hasBackrefs(re string) bool {
+	for _, m := range backrefRegex.FindAllStringSubmatch(re, -1) {
+		if m[1] != `\` {
+			return true
+		}
+	}
+	return false
+}
+
+// expandBackrefs replaces the backreferences in re with the quoted captured
+// text
+func expandBackrefs(re string, captures []string) string {
+	return backrefRegex.ReplaceAllStringFunc(re, func(m string) string {
+		if m == `\\` {
+			return m
+		}
+		n := int(m[1] - '0')
+		if n < len(captures) {
+			return regexp.QuoteMeta(captures[n])
+		}
+		return ""
+	})
+}
+
+// neverRegex is used as the end of a region whose end could not be compiled
+var neverRegex = regexp.MustCompile(`[^\s\S]`)
+
+// instance returns the region to use when r starts inside parent with the
+// given submatches of its start regular expression. It is r itself unless r
+// has backreferences or parent is an instance of r's parent
+func (r *region) instance(parent *region, captures []string) *region {
+	if r.endTemplate == "" && parent == r.parent {
+		return r
+	}
+
+	key := regionKey{parent: parent}
+	if r.endTemplate != "" {
+		key.end = expandBackrefs(r.endTemplate, captures)
+	}
+
+	r.instLock.Lock()
+	defer r.instLock.Unlock()
+	if inst, ok := r.instances[key]; ok {
+		i := slices.Index(r.instOrder, key)
+		r.instOrder = append(slices.Delete(r.instOrder, i, i+1), key)
+		return inst
+	}
+
+	inst := &region{
+		group:      r.group,
+		limitGroup: r.limitGroup,
+		parent:     parent,
+		start:      r.start,
+		end:        r.end,
+		skip:       r.skip,
+		rules:      r.rules,
+	}
+	if r.endTemplate != "" {
+		end, err := regexp.Compile(key.end)
+		if err != nil {
+			end = neverRegex
+		}
+		inst.end = end
+	}
+	if r.instances == nil {
+		r.instances = make(map[regionKey]*region)
+	}
+	if len(r.instOrder) >= maxInstances {
+		delete(r.instances, r.instOrder[0])
+		r.instOrder = slices.Delete(r.instOrder, 0, 1)
+	}
+	r.instances[key] = inst
+	r.instOrder = append(r.instOrder, key)
+	return inst
 }
 
 func init() {
@@ -434,9 +541,21 @@ func parseRegion(group string, regionInfo map[any]any, prevRegion *region) (r *r
 			return nil, fmt.Errorf("Empty end in %s", group)
 		}
 
-		r.end, err = regexp.Compile(end)
-		if err != nil {
-			return nil, err
+		if hasBackrefs(end) {
+			// check that the end is valid once the backreferences
+			// are replaced
+			if r.start.NumSubexp() == 0 {
+				return nil, fmt.Errorf("End of %s refers to submatches but start has none", group)
+			}
+			if _, err = regexp.Compile(expandBackrefs(end, nil)); err != nil {
+				return nil, err
+			}
+			r.endTemplate = end
+		} else {
+			r.end, err = regexp.Compile(end)
+			if err != nil {
+				return nil, err
+			}
 		}
 	} else {
 		return nil, fmt.Errorf("Missing end in %s", group)
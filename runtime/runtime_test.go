@@ -3,6 +3,7 @@ package config
 import (
 	"testing"
 
+	"github.com/helmutkemper/micro/v2/pkg/highlight"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,3 +15,21 @@ func TestAssetDir(t *testing.T) {
 	assert.Contains(t, entries, "go.yaml")
 	assert.True(t, len(entries) > 5)
 }
+
+func TestShellHeredoc(t *testing.T) {
+	t.Parallel()
+	data, err := Asset("syntax/sh.yaml")
+	assert.NoError(t, err)
+	f, err := highlight.ParseFile(data)
+	assert.NoError(t, err)
+	def, err := highlight.ParseDef(f, &highlight.Header{FileType: f.FileType})
+	assert.NoError(t, err)
+
+	h := highlight.NewHighlighter(def)
+	// the heredocs continue on the next lines
+	assert.NotNil(t, h.HighlightState(0, []byte("cat <<EOF"), nil))
+	assert.NotNil(t, h.HighlightState(0, []byte("cat <<-'END'"), nil))
+	// here-strings and shifts are not heredocs
+	assert.Nil(t, h.HighlightState(0, []byte(`cat <<< "hello"`), nil))
+	assert.Nil(t, h.HighlightState(0, []byte("echo $(( 1 << x ))"), nil))
+}
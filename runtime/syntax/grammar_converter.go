@@ -0,0 +1,33 @@
+//go:build ignore
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/helmutkemper/micro/v2/pkg/highlight"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println("no args")
+		return
+	}
+
+	data, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	yaml, warnings, err := highlight.ConvertGrammar(os.Args[1], data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, os.Args[1]+":", err)
+		os.Exit(1)
+	}
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "warning:", w)
+	}
+	os.Stdout.Write(yaml)
+}